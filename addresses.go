@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Aptos addresses are up to 64 hex digits; shorter matches than this are more
+// likely hex constants than accounts
+var aptosAddressPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{32,64}\b`)
+
+// fullAptosAddressPattern matches an address written out in full, the form
+// wallets copy and /wallet records
+var fullAptosAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// checkAptosAddress validates settings holding an account funds are sent to,
+// so a typo or a shortened address can't send them nowhere
+func checkAptosAddress(value string) error {
+	if value != "" && !fullAptosAddressPattern.MatchString(value) {
+		return fmt.Errorf("not a full Aptos address (0x and 64 hex digits)")
+	}
+	return nil
+}
+
+// poisonMatchLen is how many leading and trailing hex digits an address
+// poisoner copies, matching how wallets shorten addresses ("0x1a2b…9f8e")
+const poisonMatchLen = 4
+
+// normalizeAddress lower-cases an address and restores its leading zeros
+func normalizeAddress(address string) string {
+	hexPart := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(address)), "0x")
+	if len(hexPart) < 64 {
+		hexPart = strings.Repeat("0", 64-len(hexPart)) + hexPart
+	}
+	return "0x" + hexPart
+}
+
+// messageAddresses returns the distinct, normalized Aptos addresses in text
+func messageAddresses(text string) []string {
+	var addresses []string
+	for _, match := range aptosAddressPattern.FindAllString(text, -1) {
+		if address := normalizeAddress(match); !containsString(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// lookalikeAddress reports whether a and b are different addresses that share
+// the digits a wallet shows
+func lookalikeAddress(a, b string) bool {
+	return a != b && a[2:2+poisonMatchLen] == b[2:2+poisonMatchLen] && a[len(a)-poisonMatchLen:] == b[len(b)-poisonMatchLen:]
+}
+
+// RecordAddress remembers that an address was posted in chatID
+func (s *Store) RecordAddress(ctx context.Context, chatID int64, address string, userID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO chat_addresses (chat_id, address, user_id, first_seen) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, address) DO NOTHING
+	`, chatID, address, userID, time.Now().Unix())
+	return err
+}
+
+// LookalikeAddress returns a previously posted address in chatID that address
+// imitates, or "" if there is none
+func (s *Store) LookalikeAddress(ctx context.Context, chatID int64, address string) (string, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `SELECT address FROM chat_addresses WHERE chat_id = ? AND address LIKE ?`,
+		chatID, address[:2+poisonMatchLen]+"%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seen string
+		if err := rows.Scan(&seen); err != nil {
+			return "", err
+		}
+		if lookalikeAddress(address, seen) {
+			return seen, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// recordAddresses remembers the addresses in a message so later lookalikes can be caught
+func (b *Bot) recordAddresses(ctx context.Context, message *Message, text string) {
+	for _, address := range messageAddresses(text) {
+		if err := b.app.db.RecordAddress(ctx, message.Chat.ID, address, message.From.ID); err != nil {
+			b.logf("Failed to record address %s in chat %d: %v", address, message.Chat.ID, err)
+			return
+		}
+	}
+}
+
+// applyAddressChecks flags Aptos addresses on APTOS_SCAM_ADDRESSES and
+// lookalikes of addresses posted earlier in the chat (address poisoning),
+// per scam_address_policy. Clean addresses are recorded. Returns true when
+// the message was handled.
+func (b *Bot) applyAddressChecks(ctx context.Context, message *Message, text string) bool {
+	addresses := messageAddresses(text)
+	if len(addresses) == 0 {
+		return false
+	}
+	scamList := b.app.Config().AptosScamAddresses
+	for _, address := range addresses {
+		for _, scam := range scamList {
+			if normalizeAddress(scam) == address {
+				return b.enforcePolicy(ctx, message, settingScamAddressPolicy, "known scam address "+address)
+			}
+		}
+		original, err := b.app.db.LookalikeAddress(ctx, message.Chat.ID, address)
+		if err != nil {
+			b.logf("Failed to look up addresses in chat %d: %v", message.Chat.ID, err)
+			continue
+		}
+		if original != "" {
+			metrics.Add("address_poisoning", 1)
+			return b.enforcePolicy(ctx, message, settingScamAddressPolicy, "address poisoning: "+address+" imitates "+original)
+		}
+	}
+	b.recordAddresses(ctx, message, text)
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminCacheTTL bounds how stale a chat's admin list can be when a
+// chat_member update is missed, e.g. by another instance
+const adminCacheTTL = 10 * time.Minute
+
+// adminCacheSize bounds the chats whose admin lists each bot keeps
+const adminCacheSize = 2000
+
+// adminCache remembers each chat's administrators, so the admin exemption of
+// every group message doesn't cost a getChatMember call
+type adminCache struct {
+	lists *lruCache[int64, []tgbotapi.ChatMember]
+}
+
+func newAdminCache() *adminCache {
+	return &adminCache{lists: newLRUCache[int64, []tgbotapi.ChatMember]("admins", adminCacheSize, adminCacheTTL)}
+}
+
+func (c *adminCache) get(chatID int64) ([]tgbotapi.ChatMember, bool) {
+	return c.lists.get(chatID)
+}
+
+func (c *adminCache) set(chatID int64, admins []tgbotapi.ChatMember) {
+	c.lists.put(chatID, admins)
+}
+
+// forget drops chatID's admin list after its admins changed
+func (c *adminCache) forget(chatID int64) {
+	c.lists.remove(chatID)
+}
+
+// chatAdmins lists chatID's administrators and creator, cached for adminCacheTTL
+func (b *Bot) chatAdmins(ctx context.Context, chatID int64) ([]tgbotapi.ChatMember, error) {
+	if admins, ok := b.admins.get(chatID); ok {
+		return admins, nil
+	}
+	admins, err := lookup(ctx, b.lookups, fmt.Sprintf("getChatAdministrators:%d", chatID), func() ([]tgbotapi.ChatMember, error) {
+		admins, err := b.api.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+		if err == nil {
+			metrics.Add("admin_lists_fetched", 1)
+			b.admins.set(chatID, admins)
+		}
+		return admins, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admins, nil
+}
+
+// handleChatMember drops the cached admin list of a chat whose member was
+// promoted or demoted; joins and leaves are handled via their service messages
+func (b *Bot) handleChatMember(update *tgbotapi.ChatMemberUpdated) {
+	if isAdminStatus(update.OldChatMember.Status) || isAdminStatus(update.NewChatMember.Status) {
+		b.admins.forget(update.Chat.ID)
+	}
+}
+
+func isAdminStatus(status string) bool {
+	return status == "administrator" || status == "creator"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// albumTTL is how long album parts are remembered; Telegram delivers them within seconds
+const albumTTL = 2 * time.Minute
+
+// albumTracker groups messages by media_group_id so a spam album is removed as a whole
+type albumTracker struct {
+	mu     sync.Mutex
+	albums map[string]*album
+}
+
+type album struct {
+	chatID     int64
+	messageIDs []int
+	spam       bool
+	touched    time.Time
+}
+
+func newAlbumTracker() *albumTracker {
+	return &albumTracker{albums: make(map[string]*album)}
+}
+
+// add records an album part and reports whether the album was already judged spam
+func (t *albumTracker) add(message *tgbotapi.Message) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for id, a := range t.albums {
+		if now.Sub(a.touched) > albumTTL {
+			delete(t.albums, id)
+		}
+	}
+
+	a, ok := t.albums[message.MediaGroupID]
+	if !ok {
+		a = &album{chatID: message.Chat.ID}
+		t.albums[message.MediaGroupID] = a
+	}
+	a.messageIDs = append(a.messageIDs, message.MessageID)
+	a.touched = now
+	return a.spam
+}
+
+// markSpam flags the album and returns the parts seen so far, other than exceptID
+func (t *albumTracker) markSpam(mediaGroupID string, exceptID int) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.albums[mediaGroupID]
+	if !ok {
+		return nil
+	}
+	a.spam = true
+	a.touched = time.Now()
+	var others []int
+	for _, id := range a.messageIDs {
+		if id != exceptID {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// trackAlbumPart registers an album message, deleting it right away if an earlier
+// part of the same album was spam. Returns true when the message was removed.
+func (b *Bot) trackAlbumPart(ctx context.Context, message *Message) bool {
+	if message.MediaGroupID == "" || !b.albums.add(message.Message) {
+		return false
+	}
+	b.logf("Deleting part %d of spam album %s", message.MessageID, message.MediaGroupID)
+	if _, err := b.request(ctx, tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)); err != nil {
+		b.logf("Failed to delete album part %d from chat %d: %v", message.MessageID, message.Chat.ID, err)
+	}
+	return true
+}
+
+// deleteAlbum removes the other parts of a spam message's album
+func (b *Bot) deleteAlbum(ctx context.Context, message *Message) {
+	if message.MediaGroupID == "" {
+		return
+	}
+	for _, id := range b.albums.markSpam(message.MediaGroupID, message.MessageID) {
+		if _, err := b.request(ctx, tgbotapi.NewDeleteMessage(message.Chat.ID, id)); err != nil {
+			b.logf("Failed to delete album part %d from chat %d: %v", id, message.Chat.ID, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxAlertBody caps the size of a posted alert
+const maxAlertBody = 64 << 10
+
+// alertPathPrefix is where alerts are posted: /alerts/<route>, or /alerts for the "default" route
+const alertPathPrefix = "/alerts"
+
+// alertSeverities maps an alert's severity to the emoji it is published with
+var alertSeverities = map[string]string{
+	"info":     "ℹ️",
+	"warning":  "⚠️",
+	"critical": "🚨",
+}
+
+// alertRoute is the chat, and optionally forum topic, an alert route publishes to
+type alertRoute struct {
+	ChatID   int64
+	ThreadID int
+}
+
+// parseAlertRoutes reads ALERT_ROUTES entries of the form name=chat_id or name=chat_id/topic_id
+func parseAlertRoutes(entries []string) (map[string]alertRoute, error) {
+	routes := make(map[string]alertRoute, len(entries))
+	for _, entry := range entries {
+		name, target, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid route %q, want name=chat_id[/topic_id]", entry)
+		}
+		chat, topic, hasTopic := strings.Cut(target, "/")
+		chatID, err := strconv.ParseInt(chat, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat id in route %q", entry)
+		}
+		route := alertRoute{ChatID: chatID}
+		if hasTopic {
+			if route.ThreadID, err = strconv.Atoi(topic); err != nil {
+				return nil, fmt.Errorf("invalid topic id in route %q", entry)
+			}
+		}
+		routes[strings.ToLower(name)] = route
+	}
+	return routes, nil
+}
+
+// externalAlert is the JSON body of a posted alert; a text/plain body is just Text
+type externalAlert struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Severity string `json:"severity"`
+	URL      string `json:"url"`
+}
+
+// format renders the alert as a Telegram message
+func (a externalAlert) format() string {
+	var lines []string
+	title := strings.TrimSpace(a.Title)
+	if emoji, ok := alertSeverities[strings.ToLower(a.Severity)]; ok {
+		title = strings.TrimSpace(emoji + " " + title)
+	}
+	for _, line := range []string{title, strings.TrimSpace(a.Text), strings.TrimSpace(a.URL)} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// AlertHandler accepts alerts from external systems (indexers, monitoring)
+// and publishes them through a bot to the chat of the posted route. Callers
+// authenticate with "Authorization: Bearer <ALERT_TOKEN>".
+type AlertHandler struct {
+	bot    *Bot
+	token  string
+	routes map[string]alertRoute
+}
+
+// newAlertHandler returns nil when ALERT_TOKEN or ALERT_ROUTES is unset
+func newAlertHandler(bots []*Bot, cfg *Config) (*AlertHandler, error) {
+	if cfg.AlertToken == "" || len(cfg.AlertRoutes) == 0 || len(bots) == 0 {
+		return nil, nil
+	}
+	routes, err := parseAlertRoutes(cfg.AlertRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_ROUTES: %v", err)
+	}
+	return &AlertHandler{bot: bots[0], token: cfg.AlertToken, routes: routes}, nil
+}
+
+func (h *AlertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, alertPathPrefix), "/"))
+	if name == "" {
+		name = "default"
+	}
+	route, ok := h.routes[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAlertBody))
+	if err != nil {
+		http.Error(w, "failed to read alert", http.StatusBadRequest)
+		return
+	}
+	alert := externalAlert{Text: string(data)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		alert = externalAlert{}
+		if err := json.Unmarshal(data, &alert); err != nil {
+			http.Error(w, "invalid alert", http.StatusBadRequest)
+			return
+		}
+	}
+	text := alert.format()
+	if text == "" {
+		http.Error(w, "empty alert", http.StatusBadRequest)
+		return
+	}
+	if runes := []rune(text); len(runes) > 4096 {
+		text = string(runes[:4093]) + "..."
+	}
+
+	if route.ThreadID != 0 {
+		h.bot.outbox.enqueueRaw(route.ChatID, "sendMessage", tgbotapi.Params{
+			"chat_id":           strconv.FormatInt(route.ChatID, 10),
+			"message_thread_id": strconv.Itoa(route.ThreadID),
+			"text":              text,
+		})
+	} else {
+		h.bot.outbox.enqueue(tgbotapi.NewMessage(route.ChatID, text))
+	}
+	metrics.Add("external_alerts", 1)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveAlerts accepts alerts on addr until the listener fails
+func serveAlerts(addr string, handler *AlertHandler) {
+	mux := http.NewServeMux()
+	mux.Handle(alertPathPrefix, handler)
+	mux.Handle(alertPathPrefix+"/", handler)
+	log.Printf("Accepting alerts on http://%s%s", addr, alertPathPrefix)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Alert server stopped: %v", err)
+	}
+}
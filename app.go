@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"spambot/config"
+)
+
+// App holds the state shared by every bot running in this process
+type App struct {
+	db       *Store
+	detector *SpamDetector
+	flags    *FeatureFlags
+	settings *ChatSettings
+	reporter *ErrorReporter
+	cas      *CASClient
+	aptos    *AptosClient
+	prices   *PriceClient
+	discord  *DiscordMirror
+	sheets   *GoogleSheets
+	audit    *auditPublisher
+	phishing *phishingSync
+	// Hot wallet /tip pays from; nil means admins pay from their own wallets
+	tipper *aptosAccount
+	// Wallet ban appeal bonds are paid into and out of; nil disables bonds
+	bonder   *aptosAccount
+	mentions *mentionCache
+	tokens   *tokenCache
+	lua      *luaLimiter
+
+	// Active config, swapped by the watcher and /reload
+	config   atomic.Pointer[Config]
+	reloadMu sync.Mutex
+}
+
+// Config returns the currently active configuration
+func (a *App) Config() *Config {
+	return a.config.Load()
+}
+
+// Reload re-reads the .env file and swaps in the new detector rules and settings
+func (a *App) Reload() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	current := a.config.Load()
+	next, err := LoadConfig(current.EnvFile)
+	if err != nil {
+		log.Printf("Config reload failed, keeping previous settings: %v", err)
+		return err
+	}
+	current.Overrides.apply(next)
+	if changed := current.restartRequired(next); len(changed) > 0 {
+		log.Printf("Config reload: %s changed but requires a restart", strings.Join(changed, ", "))
+	}
+	a.detector.Reload(next)
+	a.flags.Reload(next)
+	a.settings.Reload(next)
+	a.config.Store(next)
+	log.Printf("Configuration reloaded (%d keywords, ban threshold %d)", len(next.SpamKeywords), next.BanThreshold)
+	return nil
+}
+
+// appDeps are external dependencies that can be replaced, e.g. by integration tests
+type appDeps struct {
+	// Transport for Bot API calls; nil uses the real network
+	TelegramClient tgbotapi.HTTPClient
+	// Detector replaces the spam detector's engine, clock or HTTP client
+	Detector detectorDeps
+}
+
+// newApp sets up logging, storage, error reporting and one Bot per configured token.
+// The returned close function releases everything newApp opened.
+func newApp(cfg *Config) (*App, []*Bot, func(), error) {
+	return newAppWith(cfg, appDeps{})
+}
+
+// newAppWith is newApp with injectable dependencies
+func newAppWith(cfg *Config, deps appDeps) (*App, []*Bot, func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	// Create log file ("-" logs to stderr)
+	if cfg.LogFile != "-" {
+		logFile, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		closers = append(closers, func() { logFile.Close() })
+		log.SetOutput(logFile)
+	}
+
+	if len(cfg.Tokens) == 0 {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("TELEGRAM_BOT_TOKEN environment variable is not set")
+	}
+
+	db, err := OpenDatabase(cfg.DBDriver, cfg.DBPath, cfg.DBTimeout)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, fmt.Errorf("failed to create spam detector: %v", err)
+	}
+	closers = append(closers, func() { db.Close() })
+
+	reporter, err := NewErrorReporter(cfg)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+
+	audit, err := newAuditPublisher(cfg)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+
+	sheets, err := newGoogleSheets(cfg)
+	if err != nil {
+		closeAll()
+		return nil, nil, nil, err
+	}
+
+	var tipWallet *aptosAccount
+	if cfg.TipWalletKey != "" {
+		if tipWallet, err = parseAptosAccount(cfg.TipWalletKey); err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("TIP_WALLET_KEY: %v", err)
+		}
+	}
+	var bondWallet *aptosAccount
+	if cfg.BondWalletKey != "" {
+		if bondWallet, err = parseAptosAccount(cfg.BondWalletKey); err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("BOND_WALLET_KEY: %v", err)
+		}
+		if tipWallet != nil && bondWallet.address == tipWallet.address {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("BOND_WALLET_KEY must not be the TIP_WALLET_KEY wallet")
+		}
+	}
+
+	flags := NewFeatureFlags(db, cfg)
+	settings := NewChatSettings(db, cfg)
+	app := &App{
+		db:       db,
+		detector: NewSpamDetector(db, flags, settings, cfg, deps.Detector),
+		flags:    flags,
+		settings: settings,
+		reporter: reporter,
+		cas:      NewCASClient(cfg),
+		aptos:    NewAptosClient(cfg),
+		prices:   NewPriceClient(cfg),
+		discord:  NewDiscordMirror(),
+		sheets:   sheets,
+		audit:    audit,
+		phishing: newPhishingSync(cfg),
+		tipper:   tipWallet,
+		bonder:   bondWallet,
+		mentions: newMentionCache(),
+		tokens:   newTokenCache(),
+		lua:      newLuaLimiter(),
+	}
+	app.config.Store(cfg)
+
+	var bots []*Bot
+	for i, token := range cfg.Tokens {
+		api, err := newBotAPI(token, cfg, deps.TelegramClient)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("failed to create bot #%d: %v", i+1, err)
+		}
+		log.Printf("Authorized on account %s", api.Self.UserName)
+		bots = append(bots, newBot(api, app))
+	}
+	return app, bots, closeAll, nil
+}
+
+// runApp starts one update loop per configured bot token and blocks until they all stop
+func runApp(cfg *Config) error {
+	app, bots, closeApp, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(cfg.MetricsAddr)
+	}
+	if cfg.AlertAddr != "" {
+		alerts, err := newAlertHandler(bots, cfg)
+		if err != nil {
+			return err
+		}
+		if alerts != nil {
+			go serveAlerts(cfg.AlertAddr, alerts)
+		}
+	}
+	if cfg.DashboardAddr != "" {
+		go serveDashboard(cfg.DashboardAddr, newDashboardHandler(bots, cfg))
+	}
+	if cfg.ModerationAddr != "" {
+		go serveModeration(cfg.ModerationAddr, newModerationService(app, cfg))
+	}
+	if cfg.StatsAddr != "" {
+		go serveStats(cfg.StatsAddr, newStatsHandler(bots))
+	}
+	if cfg.WatchInterval > 0 {
+		go config.Watch(cfg.EnvFile, cfg.WatchInterval, func() { app.Reload() })
+	}
+
+	// Stop polling cleanly on Ctrl+C / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if app.audit != nil {
+		go app.runAuditPublisher(ctx)
+	}
+	if app.phishing != nil {
+		go app.runPhishingFeeds(ctx)
+	}
+
+	var wg sync.WaitGroup
+	for _, bot := range bots {
+		wg.Add(1)
+		go func(bot *Bot) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Bot @%s crashed: %v\n%s", bot.api.Self.UserName, r, debug.Stack())
+					app.reporter.Panic(r, debug.Stack(), ErrorContext{Bot: bot.api.Self.UserName})
+					panic(r)
+				}
+			}()
+			bot.run(ctx)
+		}(bot)
+	}
+	go runWatchdog(ctx, bots)
+	if err := sdNotify("READY=1"); err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+	}
+
+	wg.Wait()
+	log.Printf("Shutting down")
+	sdNotify("STOPPING=1")
+	return nil
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha3"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aptosCoin is the asset type of the native APT coin
+const aptosCoin = "0x1::aptos_coin::AptosCoin"
+
+// AptosClient queries an Aptos fullnode REST API, and the indexer GraphQL API
+// for NFT ownership. A nil client is returned when APTOS_NODE_URL is "off";
+// callers must check before relying on it.
+type AptosClient struct {
+	baseURL    string
+	indexerURL string
+	client     *http.Client
+}
+
+// NewAptosClient returns nil when APTOS_NODE_URL is "off"
+func NewAptosClient(cfg *Config) *AptosClient {
+	if cfg.AptosNodeURL == "off" {
+		return nil
+	}
+	return &AptosClient{
+		baseURL:    cfg.AptosNodeURL,
+		indexerURL: cfg.AptosIndexerURL,
+		client:     &http.Client{Timeout: cfg.TelegramTimeout},
+	}
+}
+
+// Balance returns how much of assetType (a coin type or fungible asset
+// address) address holds, in the asset's smallest unit. Accounts that don't
+// exist on chain hold nothing.
+func (c *AptosClient) Balance(ctx context.Context, address, assetType string) (uint64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("Aptos node is not configured")
+	}
+	endpoint := c.baseURL + "/accounts/" + url.PathEscape(address) + "/balance/" + url.PathEscape(assetType)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query Aptos node: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to query Aptos node: unexpected status %s", resp.Status)
+	}
+	// The balance is a bare JSON number, which can exceed float64 precision
+	var balance json.Number
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		return 0, fmt.Errorf("failed to decode Aptos balance: %v", err)
+	}
+	return strconv.ParseUint(balance.String(), 10, 64)
+}
+
+// collectionOwnershipQuery finds one token of a collection held by an owner
+const collectionOwnershipQuery = `query($owner: String!, $collection: String!) {
+  current_token_ownerships_v2(
+    where: {owner_address: {_eq: $owner}, amount: {_gt: 0}, current_token_data: {collection_id: {_eq: $collection}}}
+    limit: 1
+  ) { token_data_id }
+}`
+
+// OwnsCollectionToken reports whether address holds at least one NFT from the
+// collection with the given collection id (its on-chain address)
+func (c *AptosClient) OwnsCollectionToken(ctx context.Context, address, collection string) (bool, error) {
+	var data struct {
+		Ownerships []struct {
+			TokenDataID string `json:"token_data_id"`
+		} `json:"current_token_ownerships_v2"`
+	}
+	err := c.queryIndexer(ctx, collectionOwnershipQuery, map[string]interface{}{"owner": address, "collection": collection}, &data)
+	return len(data.Ownerships) > 0, err
+}
+
+// queryIndexer runs a GraphQL query against the indexer and decodes its data into out
+func (c *AptosClient) queryIndexer(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	if c == nil || c.indexerURL == "off" {
+		return fmt.Errorf("Aptos indexer is not configured")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.indexerURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query Aptos indexer: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to query Aptos indexer: unexpected status %s", resp.Status)
+	}
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode Aptos indexer response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("Aptos indexer error: %s", result.Errors[0].Message)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("failed to decode Aptos indexer response: %v", err)
+	}
+	return nil
+}
+
+// assetActivitiesQuery lists successful deposits and withdrawals of some assets by an owner
+const assetActivitiesQuery = `query($owner: String!, $assets: [String!], $after: bigint!) {
+  fungible_asset_activities(
+    where: {owner_address: {_eq: $owner}, asset_type: {_in: $assets}, transaction_version: {_gt: $after}, is_transaction_success: {_eq: true}}
+    order_by: {transaction_version: asc}
+    limit: 100
+  ) { transaction_version type amount }
+}`
+
+// assetActivity is a deposit to or withdrawal from an account
+type assetActivity struct {
+	Version int64
+	Deposit bool
+	Amount  uint64
+}
+
+// AssetActivities returns owner's deposits and withdrawals of assetTypes after
+// ledger version after, oldest first, at most 100 at a time
+func (c *AptosClient) AssetActivities(ctx context.Context, owner string, assetTypes []string, after int64) ([]assetActivity, error) {
+	var data struct {
+		Activities []struct {
+			Version int64       `json:"transaction_version"`
+			Type    string      `json:"type"`
+			Amount  json.Number `json:"amount"`
+		} `json:"fungible_asset_activities"`
+	}
+	err := c.queryIndexer(ctx, assetActivitiesQuery, map[string]interface{}{"owner": owner, "assets": assetTypes, "after": after}, &data)
+	if err != nil {
+		return nil, err
+	}
+	var activities []assetActivity
+	for _, a := range data.Activities {
+		// Gas fees and other activity types are neither
+		deposit, withdraw := strings.HasSuffix(a.Type, "Deposit") || strings.HasSuffix(a.Type, "DepositEvent"),
+			strings.HasSuffix(a.Type, "Withdraw") || strings.HasSuffix(a.Type, "WithdrawEvent")
+		if !deposit && !withdraw {
+			continue
+		}
+		amount, _ := strconv.ParseUint(a.Amount.String(), 10, 64)
+		activities = append(activities, assetActivity{Version: a.Version, Deposit: deposit, Amount: amount})
+	}
+	return activities, nil
+}
+
+// firstDepositQuery finds the oldest successful APT deposit to an owner
+const firstDepositQuery = `query($owner: String!, $assets: [String!]) {
+  fungible_asset_activities(
+    where: {owner_address: {_eq: $owner}, asset_type: {_in: $assets}, type: {_ilike: "%Deposit%"}, is_transaction_success: {_eq: true}}
+    order_by: {transaction_version: asc}
+    limit: 1
+  ) { transaction_version }
+}`
+
+// fundingSourceMaxTxns is how many transactions a funding source may have
+// sent before it counts as shared infrastructure, like an exchange hot
+// wallet or a faucet, that funds unrelated accounts
+const fundingSourceMaxTxns = 1000
+
+// FundingSource returns the sender of the transaction that first deposited
+// APT to address, or "" if there is none, the account funded itself, or the
+// sender is too busy to tie accounts together
+func (c *AptosClient) FundingSource(ctx context.Context, address string) (string, error) {
+	var data struct {
+		Activities []struct {
+			Version int64 `json:"transaction_version"`
+		} `json:"fungible_asset_activities"`
+	}
+	err := c.queryIndexer(ctx, firstDepositQuery, map[string]interface{}{"owner": address, "assets": []string{aptosCoin, aptosFungibleAsset}}, &data)
+	if err != nil || len(data.Activities) == 0 {
+		return "", err
+	}
+	var txn struct {
+		Sender string `json:"sender"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/transactions/by_version/"+strconv.FormatInt(data.Activities[0].Version, 10), nil, &txn); err != nil {
+		return "", err
+	}
+	if txn.Sender == "" || normalizeAddress(txn.Sender) == normalizeAddress(address) {
+		return "", nil
+	}
+	var account struct {
+		SequenceNumber string `json:"sequence_number"`
+	}
+	funder := normalizeAddress(txn.Sender)
+	if err := c.call(ctx, http.MethodGet, "/accounts/"+url.PathEscape(funder), nil, &account); err != nil {
+		return "", err
+	}
+	if sent, _ := strconv.ParseUint(account.SequenceNumber, 10, 64); sent > fundingSourceMaxTxns {
+		return "", nil
+	}
+	return funder, nil
+}
+
+// eventsQuery lists events of some Move types
+const eventsQuery = `query($types: [String!], $after: bigint!) {
+  events(
+    where: {indexed_type: {_in: $types}, transaction_version: {_gt: $after}}
+    order_by: {transaction_version: asc}
+    limit: 100
+  ) { transaction_version indexed_type data }
+}`
+
+// chainEvent is an emitted Move event
+type chainEvent struct {
+	Version int64           `json:"transaction_version"`
+	Type    string          `json:"indexed_type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Events returns events of the given Move types emitted after ledger version
+// after, oldest first, at most 100 at a time
+func (c *AptosClient) Events(ctx context.Context, types []string, after int64) ([]chainEvent, error) {
+	var data struct {
+		Events []chainEvent `json:"events"`
+	}
+	err := c.queryIndexer(ctx, eventsQuery, map[string]interface{}{"types": types, "after": after}, &data)
+	return data.Events, err
+}
+
+// LedgerVersion returns the fullnode's latest ledger version
+func (c *AptosClient) LedgerVersion(ctx context.Context) (int64, error) {
+	if c == nil {
+		return 0, fmt.Errorf("Aptos node is not configured")
+	}
+	var info struct {
+		LedgerVersion string `json:"ledger_version"`
+	}
+	if err := c.call(ctx, http.MethodGet, "", nil, &info); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(info.LedgerVersion, 10, 64)
+}
+
+// explorerLink links a transaction version in the Aptos explorer, on the
+// network the configured fullnode serves
+func (c *AptosClient) explorerLink(version int64) string {
+	network := "mainnet"
+	for _, n := range []string{"testnet", "devnet"} {
+		if strings.Contains(c.baseURL, n) {
+			network = n
+		}
+	}
+	return fmt.Sprintf("https://explorer.aptoslabs.com/txn/%d?network=%s", version, network)
+}
+
+// aptosAccount is a single-key Ed25519 account the bot signs transactions with
+type aptosAccount struct {
+	key     ed25519.PrivateKey
+	address string
+}
+
+// parseAptosAccount reads a hex Ed25519 private key, optionally in the
+// "ed25519-priv-0x..." form wallets export
+func parseAptosAccount(privateKey string) (*aptosAccount, error) {
+	seed, err := decodeHex(strings.TrimPrefix(strings.TrimSpace(privateKey), "ed25519-priv-"))
+	if err != nil || (len(seed) != ed25519.SeedSize && len(seed) != ed25519.PrivateKeySize) {
+		return nil, fmt.Errorf("not a hex Ed25519 private key")
+	}
+	key := ed25519.NewKeyFromSeed(seed[:ed25519.SeedSize])
+	return &aptosAccount{key: key, address: aptosAddress(key.Public().(ed25519.PublicKey))}, nil
+}
+
+// Gas limits for the small entry function calls the bot submits
+const (
+	aptosMaxGas       = 2000
+	aptosGasUnitPrice = 100
+)
+
+// aptosAPIError is a non-2xx answer from the fullnode
+type aptosAPIError struct {
+	Request string
+	Status  int
+	Message string
+}
+
+func (e *aptosAPIError) Error() string {
+	return fmt.Sprintf("Aptos node %s: %d %s", e.Request, e.Status, e.Message)
+}
+
+// isNotFound reports whether err is the fullnode answering that the account or resource doesn't exist
+func isNotFound(err error) bool {
+	var apiErr *aptosAPIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// call sends a JSON request to the fullnode and decodes the JSON answer into out
+func (c *AptosClient) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Aptos node: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &aptosAPIError{Request: method + " " + path, Status: resp.StatusCode, Message: apiErr.Message}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Aptos node response: %v", err)
+	}
+	return nil
+}
+
+// signedTransaction is a transaction the bot signed, with the hash it will
+// have once submitted
+type signedTransaction struct {
+	Hash     string
+	Sequence uint64
+	Expires  time.Time
+	body     map[string]interface{}
+}
+
+// Submit signs and submits a transaction calling the entry function
+// ("<address>::<module>::<function>") with JSON-encoded args from account,
+// and returns the transaction hash
+func (c *AptosClient) Submit(ctx context.Context, account *aptosAccount, function string, args []interface{}) (string, error) {
+	txn, err := c.Sign(ctx, account, function, args)
+	if err != nil {
+		return "", err
+	}
+	err = c.SubmitSigned(ctx, txn)
+	return txn.Hash, err
+}
+
+// sequenceNumber is the sequence number address's next transaction must have
+func (c *AptosClient) sequenceNumber(ctx context.Context, address string) (uint64, error) {
+	var account struct {
+		SequenceNumber string `json:"sequence_number"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/accounts/"+url.PathEscape(address), nil, &account); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(account.SequenceNumber, 10, 64)
+}
+
+// Sign builds and signs a transaction like Submit's without submitting it,
+// so callers can record its hash first: if the answer to the submission is
+// lost, the hash tells whether it was committed. The node encodes the signing
+// message, so no BCS implementation is needed here.
+func (c *AptosClient) Sign(ctx context.Context, account *aptosAccount, function string, args []interface{}) (*signedTransaction, error) {
+	if c == nil {
+		return nil, fmt.Errorf("Aptos node is not configured")
+	}
+	sequence, err := c.sequenceNumber(ctx, account.address)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(time.Minute)
+	txn := map[string]interface{}{
+		"sender":                    account.address,
+		"sequence_number":           strconv.FormatUint(sequence, 10),
+		"max_gas_amount":            strconv.Itoa(aptosMaxGas),
+		"gas_unit_price":            strconv.Itoa(aptosGasUnitPrice),
+		"expiration_timestamp_secs": strconv.FormatInt(expires.Unix(), 10),
+		"payload": map[string]interface{}{
+			"type":           "entry_function_payload",
+			"function":       function,
+			"type_arguments": []string{},
+			"arguments":      args,
+		},
+	}
+	var signingMessage string
+	if err := c.call(ctx, http.MethodPost, "/transactions/encode_submission", txn, &signingMessage); err != nil {
+		return nil, err
+	}
+	message, err := decodeHex(signingMessage)
+	if err != nil || len(message) <= sha3.New256().Size() {
+		return nil, fmt.Errorf("invalid signing message from Aptos node: %q", signingMessage)
+	}
+	publicKey := account.key.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(account.key, message)
+	txn["signature"] = map[string]string{
+		"type":       "ed25519_signature",
+		"public_key": "0x" + hex.EncodeToString(publicKey),
+		"signature":  "0x" + hex.EncodeToString(signature),
+	}
+	return &signedTransaction{
+		Hash:     userTransactionHash(message, publicKey, signature),
+		Sequence: sequence,
+		Expires:  expires,
+		body:     txn,
+	}, nil
+}
+
+// userTransactionHash is the hash of a signed user transaction:
+// SHA3-256(SHA3-256("APTOS::Transaction") | 0 | raw transaction | Ed25519
+// authenticator), where the signing message is SHA3-256("APTOS::RawTransaction")
+// followed by the BCS raw transaction
+func userTransactionHash(signingMessage []byte, publicKey ed25519.PublicKey, signature []byte) string {
+	prefix := sha3.Sum256([]byte("APTOS::Transaction"))
+	h := sha3.New256()
+	h.Write(prefix[:])
+	h.Write([]byte{0}) // Transaction::UserTransaction
+	h.Write(signingMessage[h.Size():])
+	h.Write([]byte{0}) // TransactionAuthenticator::Ed25519
+	h.Write([]byte{byte(len(publicKey))})
+	h.Write(publicKey)
+	h.Write([]byte{byte(len(signature))})
+	h.Write(signature)
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// SubmitSigned submits txn. An error doesn't mean txn won't be committed:
+// the node may have taken it before the answer was lost.
+func (c *AptosClient) SubmitSigned(ctx context.Context, txn *signedTransaction) error {
+	var pending struct {
+		Hash string `json:"hash"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/transactions", txn.body, &pending); err != nil {
+		return err
+	}
+	if pending.Hash != "" && pending.Hash != txn.Hash {
+		// The node's word wins; a mismatch means the local hash is wrong
+		log.Printf("Aptos node hashed transaction %s as %s", txn.Hash, pending.Hash)
+		txn.Hash = pending.Hash
+	}
+	return nil
+}
+
+// AccountTransaction returns the hash of address's committed transaction
+// with sequence number sequence; ok is false if it has none yet
+func (c *AptosClient) AccountTransaction(ctx context.Context, address string, sequence uint64) (hash string, ok bool, err error) {
+	var txns []struct {
+		Hash string `json:"hash"`
+	}
+	path := fmt.Sprintf("/accounts/%s/transactions?start=%d&limit=1", url.PathEscape(address), sequence)
+	if err := c.call(ctx, http.MethodGet, path, nil, &txns); err != nil {
+		if isNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if len(txns) == 0 {
+		return "", false, nil
+	}
+	return txns[0].Hash, true, nil
+}
+
+// userTransaction is a committed entry function call as the fullnode reports it
+type userTransaction struct {
+	Sender    string
+	Success   bool
+	Timestamp time.Time
+	Function  string
+	Arguments []interface{}
+}
+
+// Transaction looks up a committed transaction by hash; ok is false if it is
+// unknown or still pending
+func (c *AptosClient) Transaction(ctx context.Context, hash string) (txn userTransaction, ok bool, err error) {
+	if c == nil {
+		return txn, false, fmt.Errorf("Aptos node is not configured")
+	}
+	var result struct {
+		Type      string `json:"type"`
+		Success   bool   `json:"success"`
+		Sender    string `json:"sender"`
+		Timestamp string `json:"timestamp"`
+		Payload   struct {
+			Function  string        `json:"function"`
+			Arguments []interface{} `json:"arguments"`
+		} `json:"payload"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/transactions/by_hash/"+url.PathEscape(hash), nil, &result); err != nil {
+		if isNotFound(err) {
+			return txn, false, nil
+		}
+		return txn, false, err
+	}
+	if result.Type != "user_transaction" {
+		return txn, false, nil
+	}
+	micros, _ := strconv.ParseInt(result.Timestamp, 10, 64)
+	return userTransaction{
+		Sender:    result.Sender,
+		Success:   result.Success,
+		Timestamp: time.UnixMicro(micros),
+		Function:  result.Payload.Function,
+		Arguments: result.Payload.Arguments,
+	}, true, nil
+}
+
+// contractInfo is what the fullnode tells about an address referenced in a message
+type contractInfo struct {
+	// Whether modules are published at the address
+	Contract bool
+	// When the account sent its first transaction; zero if it never did
+	Deployed time.Time
+	// Name and symbol of the coin, for coin type lookups
+	Name, Symbol string
+}
+
+// Contract looks up whether address hosts modules and when it first transacted
+func (c *AptosClient) Contract(ctx context.Context, address string) (contractInfo, error) {
+	var info contractInfo
+	if c == nil {
+		return info, fmt.Errorf("Aptos node is not configured")
+	}
+	var modules []json.RawMessage
+	if err := c.call(ctx, http.MethodGet, "/accounts/"+url.PathEscape(address)+"/modules?limit=1", nil, &modules); err != nil {
+		if isNotFound(err) {
+			return info, nil
+		}
+		return info, err
+	}
+	info.Contract = len(modules) > 0
+	var txns []struct {
+		Timestamp string `json:"timestamp"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/accounts/"+url.PathEscape(address)+"/transactions?start=0&limit=1", nil, &txns); err != nil {
+		return info, err
+	}
+	if len(txns) > 0 {
+		if usec, err := strconv.ParseInt(txns[0].Timestamp, 10, 64); err == nil {
+			info.Deployed = time.UnixMicro(usec)
+		}
+	}
+	return info, nil
+}
+
+// CoinInfo returns the name and symbol registered for coinType; ok is false if
+// no such coin exists
+func (c *AptosClient) CoinInfo(ctx context.Context, coinType string) (name, symbol string, ok bool, err error) {
+	if c == nil {
+		return "", "", false, fmt.Errorf("Aptos node is not configured")
+	}
+	address, _, _ := strings.Cut(coinType, "::")
+	var resource struct {
+		Data struct {
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		} `json:"data"`
+	}
+	path := "/accounts/" + url.PathEscape(address) + "/resource/" + url.PathEscape("0x1::coin::CoinInfo<"+coinType+">")
+	if err := c.call(ctx, http.MethodGet, path, nil, &resource); err != nil {
+		if isNotFound(err) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	return resource.Data.Name, resource.Data.Symbol, true, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/sha3"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// auditLease makes sure only one instance publishes the audit log head
+const auditLease = "audit-publisher"
+
+// auditEntry is one moderation decision in the hash-chained audit log
+type auditEntry struct {
+	Action    string `json:"action"`
+	ChatID    int64  `json:"chat_id"`
+	UserID    int64  `json:"user_id"`
+	MessageID int    `json:"message_id,omitempty"`
+	Reason    string `json:"reason"`
+	At        int64  `json:"at"`
+}
+
+// AppendAudit adds entry to the audit log. Each entry's hash covers the
+// previous entry's hash, so publishing the latest hash commits to all of them.
+func (s *Store) AppendAudit(ctx context.Context, entry auditEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	// Another instance appending at the same moment takes the same seq; retry on top of it
+	for attempt := 0; ; attempt++ {
+		err = s.appendAudit(ctx, payload)
+		if err == nil || attempt == 2 {
+			return err
+		}
+	}
+}
+
+func (s *Store) appendAudit(ctx context.Context, payload []byte) error {
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	var prev string
+	err = tx.QueryRowContext(ctx, `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&seq, &prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	prevHash, _ := hex.DecodeString(prev)
+	sum := sha3.Sum256(append(prevHash, payload...))
+	if _, err := tx.ExecContext(ctx, s.Rebind(`
+		INSERT INTO audit_log (seq, hash, payload, published_tx) VALUES (?, ?, ?, '')
+	`), seq+1, hex.EncodeToString(sum[:]), string(payload)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AuditHead returns the newest entry not yet published on chain; ok is false
+// when everything is published
+func (s *Store) AuditHead(ctx context.Context) (seq int64, hash string, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var published string
+	err = s.QueryRowContext(ctx, `SELECT seq, hash, published_tx FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&seq, &hash, &published)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, nil
+	}
+	return seq, hash, err == nil && published == "", err
+}
+
+// MarkAuditPublished records the transaction that committed entries up to seq
+func (s *Store) MarkAuditPublished(ctx context.Context, seq int64, txHash string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `UPDATE audit_log SET published_tx = ? WHERE seq <= ? AND published_tx = ''`, txHash, seq)
+	return err
+}
+
+// auditPublisher periodically writes the audit log head to the configured Aptos
+// module, which must expose an entry function
+//
+//	public entry fun record(account: &signer, head: vector<u8>, seq: u64)
+//
+// storing or emitting the pair. Anyone, including federated bots, can then
+// check a copy of the log against the chain.
+type auditPublisher struct {
+	function string
+	account  *aptosAccount
+	interval time.Duration
+	holder   string
+	// Unix seconds of the last publish attempt in webhook mode
+	last atomic.Int64
+}
+
+// newAuditPublisher returns nil when AUDIT_LOG_MODULE is unset
+func newAuditPublisher(cfg *Config) (*auditPublisher, error) {
+	if cfg.AuditLogModule == "" {
+		return nil, nil
+	}
+	if cfg.AptosNodeURL == "off" {
+		return nil, fmt.Errorf("AUDIT_LOG_MODULE needs APTOS_NODE_URL")
+	}
+	account, err := parseAptosAccount(cfg.AuditLogKey)
+	if err != nil {
+		return nil, fmt.Errorf("AUDIT_LOG_KEY: %v", err)
+	}
+	return &auditPublisher{
+		function: cfg.AuditLogModule + "::record",
+		account:  account,
+		interval: cfg.AuditLogInterval,
+		holder:   newInstanceID(),
+	}, nil
+}
+
+// audit records a moderation decision for the chat's digest, mirrors it to
+// Discord, exports it to Google Sheets and appends it to the audit log, if
+// those are enabled
+func (b *Bot) audit(ctx context.Context, action string, chatID, userID int64, messageID int, reason string) {
+	if err := b.app.db.RecordModeration(ctx, chatID, userID, action, moderationRule(reason), b.app.settings.Now(ctx, chatID)); err != nil {
+		b.logf("Failed to record moderation for the digest: %v", err)
+	}
+	if action == "delete" || action == "overturn" {
+		if err := b.app.db.RecordRuleOutcome(ctx, chatID, moderationRule(reason), action == "overturn"); err != nil {
+			b.logf("Failed to record the outcome of rule %q: %v", moderationRule(reason), err)
+		}
+	}
+	b.mirrorToDiscord(ctx, action, chatID, userID, reason)
+	b.exportToSheet(ctx, action, chatID, userID, messageID, reason)
+	if action == "ban" {
+		b.app.reporter.Event("bans", fmt.Sprintf("🔨 Banned user %d from chat %d: %s", userID, chatID, reason),
+			ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+	}
+	if b.app.audit == nil {
+		return
+	}
+	entry := auditEntry{Action: action, ChatID: chatID, UserID: userID, MessageID: messageID, Reason: reason, At: time.Now().Unix()}
+	if err := b.app.db.AppendAudit(ctx, entry); err != nil {
+		b.logf("Failed to append to the audit log: %v", err)
+		b.app.reporter.Failure("db.appendAudit", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+	}
+}
+
+// publishAudit submits the current audit log head, unless it is already on chain
+func (a *App) publishAudit(ctx context.Context) {
+	if ok, err := a.db.AcquireLease(ctx, auditLease, a.audit.holder, 2*a.audit.interval); err != nil || !ok {
+		return
+	}
+	seq, hash, ok, err := a.db.AuditHead(ctx)
+	if err != nil {
+		log.Printf("Failed to read the audit log head: %v", err)
+		a.reporter.Failure("db.auditHead", err, ErrorContext{})
+		return
+	}
+	if !ok {
+		return
+	}
+	txHash, err := a.aptos.Submit(ctx, a.audit.account, a.audit.function, []interface{}{"0x" + hash, strconv.FormatInt(seq, 10)})
+	if err != nil {
+		log.Printf("Failed to publish audit log entry %d: %v", seq, err)
+		a.reporter.Failure("aptos.publishAudit", err, ErrorContext{})
+		return
+	}
+	if err := a.db.MarkAuditPublished(ctx, seq, txHash); err != nil {
+		log.Printf("Failed to record audit log transaction %s: %v", txHash, err)
+		a.reporter.Failure("db.markAuditPublished", err, ErrorContext{})
+		return
+	}
+	log.Printf("Published audit log head %d in transaction %s", seq, txHash)
+	metrics.Add("audit_heads_published", 1)
+}
+
+// runAuditPublisher publishes the audit log head every AUDIT_LOG_INTERVAL until ctx is cancelled
+func (a *App) runAuditPublisher(ctx context.Context) {
+	for sleepContext(ctx, a.audit.interval) {
+		a.publishAudit(ctx)
+	}
+}
+
+// maybePublishAudit publishes at most once per AUDIT_LOG_INTERVAL, for webhook
+// mode where no background loop runs
+func (a *App) maybePublishAudit(ctx context.Context) {
+	if a.audit == nil {
+		return
+	}
+	now := time.Now().Unix()
+	last := a.audit.last.Load()
+	if now-last < int64(a.audit.interval/time.Second) || !a.audit.last.CompareAndSwap(last, now) {
+		return
+	}
+	a.publishAudit(ctx)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// banAlertTextLimit caps how much of the triggering message a ban alert quotes
+const banAlertTextLimit = 1000
+
+// banAlertRecipients are the admins of chatID that ban_alerts names: all of
+// its human admins, or the one with the configured user id
+func (b *Bot) banAlertRecipients(ctx context.Context, chatID int64) []int64 {
+	value := b.app.settings.Get(ctx, chatID, settingBanAlerts)
+	if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return []int64{id}
+	}
+	if value != "admins" {
+		return nil
+	}
+	admins, err := b.chatAdmins(ctx, chatID)
+	if err != nil {
+		b.logf("Failed to list admins of chat %d for a ban alert: %v", chatID, err)
+		b.app.reporter.Failure("telegram.getChatAdministrators", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID})
+		return nil
+	}
+	var ids []int64
+	for _, admin := range admins {
+		if admin.User != nil && !admin.User.IsBot {
+			ids = append(ids, admin.User.ID)
+		}
+	}
+	return ids
+}
+
+// alertBan tells the admins of chatID in private that the bot banned user,
+// quoting the message that triggered it (empty if none) with an Unban button,
+// so a wrongful ban is undone before the member gives up on the chat
+func (b *Bot) alertBan(ctx context.Context, chatID int64, user tgbotapi.User, reason, text string) {
+	recipients := b.banAlertRecipients(ctx, chatID)
+	if len(recipients) == 0 {
+		return
+	}
+	title := strconv.FormatInt(chatID, 10)
+	if chat, err := lookup(ctx, b.lookups, fmt.Sprintf("getChat:%d", chatID), func() (tgbotapi.Chat, error) {
+		return b.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}})
+	}); err == nil && chat.Title != "" {
+		title = chat.Title
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.UserName != "" {
+		name += " (@" + user.UserName + ")"
+	}
+	alert := b.tr(ctx, chatID, "ban_alert.text", name, user.ID, title, reason)
+	if text != "" {
+		if runes := []rune(text); len(runes) > banAlertTextLimit {
+			text = string(runes[:banAlertTextLimit]) + "…"
+		}
+		alert += "\n\n" + b.tr(ctx, chatID, "ban_alert.message", text)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "ban_alert.button_unban"), fmt.Sprintf("unban:%d:%d", chatID, user.ID)),
+	))
+	for _, id := range recipients {
+		// Admins who never started the bot can't be messaged; the outbox logs and drops those
+		msg := tgbotapi.NewMessage(id, alert)
+		msg.ReplyMarkup = markup
+		b.outbox.enqueue(msg)
+	}
+	metrics.Add("ban_alerts", 1)
+}
+
+// handleUnbanCallback lifts a ban from a ban alert's button. The alert is in
+// the admin's private chat, so the group comes with the button data.
+func (b *Bot) handleUnbanCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	chat, user, _ := strings.Cut(args, ":")
+	chatID, err1 := strconv.ParseInt(chat, 10, 64)
+	userID, err2 := strconv.ParseInt(user, 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "ban_alert.admins_only")))
+		return
+	}
+	unban := tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID}, OnlyIfBanned: true}
+	if _, err := b.request(ctx, unban); err != nil {
+		b.logf("Failed to unban %d from chat %d: %v", userID, chatID, err)
+		b.app.reporter.Failure("telegram.unbanChatMember", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	// Otherwise their next slip would ban them again at once
+	if err := b.app.detector.ClearSpam(ctx, chatID, userID); err != nil {
+		b.logf("Failed to clear spam strikes of %d in chat %d: %v", userID, chatID, err)
+	}
+	metrics.Add("ban_alert_unbans", 1)
+	b.audit(ctx, "unban", chatID, userID, 0, "ban alert, unbanned by admin "+query.From.UserName)
+	b.logf("Unbanned %d from chat %d on a ban alert by %s", userID, chatID, query.From.UserName)
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "ban_alert.unbanned")))
+	b.request(ctx, tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "ban_alert.unbanned_by", query.From.FirstName)))
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// appealStartPrefix marks a /start deep link that begins a ban appeal for a chat
+const appealStartPrefix = "appeal_"
+
+// maxBondAPT bounds ban_bond_apt, far below where its octas overflow
+const maxBondAPT = 100000
+
+// Outcomes of a bond in ban_bonds, which is "pending" until the bond
+// transaction is confirmed and "locked" while admins review the appeal
+const (
+	bondRefunded  = "refunded"
+	bondForfeited = "forfeited"
+)
+
+// banBond is a banned member's appeal backed by APT held in the bot's wallet
+type banBond struct {
+	ChatID  int64
+	UserID  int64
+	Octas   uint64
+	Status  string
+	Sender  string
+	TxHash  string
+	Created time.Time
+}
+
+// OpenBond starts an appeal by userID in chatID for a bond of octas; false
+// means an earlier bond is still under review or being paid out
+func (s *Store) OpenBond(ctx context.Context, chatID, userID int64, octas uint64) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO ban_bonds (chat_id, user_id, octas, status, sender, tx_hash, payout_tx, created_at)
+		VALUES (?, ?, ?, 'pending', '', '', '', ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET octas = excluded.octas, status = 'pending', sender = '',
+			tx_hash = '', payout_tx = '', payout_state = '', created_at = excluded.created_at
+		WHERE ban_bonds.status <> 'locked' AND ban_bonds.payout_tx <> 'pending' AND ban_bonds.payout_state <> 'submitted'
+	`, chatID, userID, int64(octas), time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// PendingBond returns userID's most recent appeal still waiting for its bond; ok is false if there is none
+func (s *Store) PendingBond(ctx context.Context, userID int64) (bond banBond, ok bool, err error) {
+	return s.bond(ctx, `WHERE user_id = ? AND status = 'pending' ORDER BY created_at DESC LIMIT 1`, userID)
+}
+
+// Bond returns userID's appeal in chatID; ok is false if they never appealed
+func (s *Store) Bond(ctx context.Context, chatID, userID int64) (bond banBond, ok bool, err error) {
+	return s.bond(ctx, `WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+}
+
+func (s *Store) bond(ctx context.Context, where string, args ...interface{}) (bond banBond, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var octas, created int64
+	err = s.QueryRowContext(ctx, `SELECT chat_id, user_id, octas, status, sender, tx_hash, created_at FROM ban_bonds `+where, args...).
+		Scan(&bond.ChatID, &bond.UserID, &octas, &bond.Status, &bond.Sender, &bond.TxHash, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bond, false, nil
+	}
+	bond.Octas, bond.Created = uint64(octas), time.Unix(created, 0)
+	return bond, err == nil, err
+}
+
+// LockBond records the transaction that paid a pending bond. False means the
+// bond isn't pending or the transaction already paid another bond.
+func (s *Store) LockBond(ctx context.Context, chatID, userID int64, sender, txHash string) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE ban_bonds SET status = 'locked', sender = ?, tx_hash = ?
+		WHERE chat_id = ? AND user_id = ? AND status = 'pending'
+		AND NOT EXISTS (SELECT 1 FROM ban_bonds b WHERE b.tx_hash = ?)
+	`, sender, txHash, chatID, userID, txHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SettleBond moves a locked bond to status ("refunded" or "forfeited") before
+// it is paid out, so two admins can't both decide; false means it was already decided
+func (s *Store) SettleBond(ctx context.Context, chatID, userID int64, status string) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE ban_bonds SET status = ?, payout_tx = 'pending', payout_state = '' WHERE chat_id = ? AND user_id = ? AND status = 'locked'
+	`, status, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RecordBondPayout stores the signed transaction paying out a settled bond
+// before it is submitted, so a payout whose outcome is unknown is reconciled
+// by its hash rather than sent again
+func (s *Store) RecordBondPayout(ctx context.Context, chatID, userID int64, txn *signedTransaction) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE ban_bonds SET payout_tx = ?, payout_state = 'submitted', payout_seq = ?, payout_expires = ?
+		WHERE chat_id = ? AND user_id = ? AND payout_tx = 'pending'
+	`, txn.Hash, int64(txn.Sequence), txn.Expires.Unix(), chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SetBondPayout replaces the recorded hash of a submitted payout with the one the node reported
+func (s *Store) SetBondPayout(ctx context.Context, chatID, userID int64, recorded, txHash string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `UPDATE ban_bonds SET payout_tx = ? WHERE chat_id = ? AND user_id = ? AND payout_tx = ?`,
+		txHash, chatID, userID, recorded)
+	return err
+}
+
+// ConfirmBondPayout marks a submitted payout committed; false means it was
+// already confirmed or reopened
+func (s *Store) ConfirmBondPayout(ctx context.Context, chatID, userID int64, txHash string) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE ban_bonds SET payout_state = 'confirmed' WHERE chat_id = ? AND user_id = ? AND payout_tx = ? AND payout_state = 'submitted'
+	`, chatID, userID, txHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReopenBond puts a settled bond back under review once its payout, recorded
+// as payoutTx ("pending" before one was signed), is known not to have gone
+// through; false means it was confirmed or reopened meanwhile
+func (s *Store) ReopenBond(ctx context.Context, chatID, userID int64, payoutTx string) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE ban_bonds SET status = 'locked', payout_tx = '', payout_state = '', payout_seq = 0, payout_expires = 0
+		WHERE chat_id = ? AND user_id = ? AND payout_tx = ? AND payout_state <> 'confirmed'
+	`, chatID, userID, payoutTx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// bondPayout is a submitted bond payout waiting to be seen on chain
+type bondPayout struct {
+	ChatID   int64
+	UserID   int64
+	Octas    uint64
+	Status   string
+	TxHash   string
+	Sequence uint64
+	Expires  time.Time
+}
+
+// SubmittedBondPayouts lists the unconfirmed payouts of bonds in the chats botID is in
+func (s *Store) SubmittedBondPayouts(ctx context.Context, botID int64) ([]bondPayout, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT b.chat_id, b.user_id, b.octas, b.status, b.payout_tx, b.payout_seq, b.payout_expires FROM ban_bonds b
+		JOIN bot_chats c ON c.chat_id = b.chat_id AND c.bot_id = ?
+		WHERE b.payout_state = 'submitted'
+	`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var payouts []bondPayout
+	for rows.Next() {
+		var p bondPayout
+		var octas, sequence, expires int64
+		if err := rows.Scan(&p.ChatID, &p.UserID, &octas, &p.Status, &p.TxHash, &sequence, &expires); err != nil {
+			return nil, err
+		}
+		p.Octas, p.Sequence, p.Expires = uint64(octas), uint64(sequence), time.Unix(expires, 0)
+		payouts = append(payouts, p)
+	}
+	return payouts, rows.Err()
+}
+
+// appealLink is the deep link admins share so members banned from chatID can appeal with a bond
+func (b *Bot) appealLink(chatID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", b.api.Self.UserName, appealStartPrefix, chatID)
+}
+
+// bondTransfer is the transaction that pays a bond to the bot's bond wallet
+func (b *Bot) bondTransfer(octas uint64) string {
+	payload, _ := json.MarshalIndent(map[string]interface{}{
+		"function":       aptTransferFunction,
+		"type_arguments": []string{},
+		"arguments":      []interface{}{b.app.bonder.address, strconv.FormatUint(octas, 10)},
+	}, "", "  ")
+	return string(payload)
+}
+
+// cmdAppeal starts an appeal in private, from the appeal link or "/appeal <chat id>":
+// a member banned from a chat with the ban_bonds flag locks ban_bond_apt APT,
+// from the wallet they verified for the chat, which is refunded to it if
+// admins find the ban was wrong and goes to the community_wallet otherwise
+func (b *Bot) cmdAppeal(ctx context.Context, message *Message, arg string) {
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(arg), appealStartPrefix), 10, 64)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "appeal.usage"))
+		return
+	}
+	bondAPT, _ := strconv.ParseUint(b.app.settings.Get(ctx, chatID, settingBanBondAPT), 10, 64)
+	if !b.app.flags.Enabled(ctx, chatID, flagBanBonds) || bondAPT == 0 || bondAPT > maxBondAPT || b.app.bonder == nil || b.app.aptos == nil {
+		b.reply(message, b.trFor(ctx, message, "appeal.unavailable"))
+		return
+	}
+	member, err := b.getChatMember(ctx, chatID, message.From.ID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "appeal.member_failed"))
+		return
+	}
+	if member.Status != "kicked" {
+		b.reply(message, b.trFor(ctx, message, "appeal.not_banned"))
+		return
+	}
+	// Bonds must come from, and are refunded to, the member's verified wallet
+	if wallet, err := b.app.db.Wallet(ctx, chatID, message.From.ID); err != nil || wallet == "" {
+		b.reply(message, b.trFor(ctx, message, "bond.no_wallet", b.verifyLink(chatID)))
+		return
+	}
+
+	octas := bondAPT * octasPerAPT
+	opened, err := b.app.db.OpenBond(ctx, chatID, message.From.ID, octas)
+	if err != nil {
+		b.logf("Failed to open ban bond for %d in chat %d: %v", message.From.ID, chatID, err)
+		b.app.reporter.Failure("db.openBond", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "appeal.failed"))
+		return
+	}
+	if !opened {
+		b.reply(message, b.trFor(ctx, message, "appeal.pending"))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "appeal.instructions", formatAPT(octas), b.bondTransfer(octas)))
+}
+
+// cmdBond checks the transaction paying the caller's pending bond and sends the appeal to the admins
+func (b *Bot) cmdBond(ctx context.Context, message *Message) {
+	hash := strings.TrimSpace(message.CommandArguments())
+	if hash == "" || strings.ContainsAny(hash, " \n") {
+		b.reply(message, b.trFor(ctx, message, "bond.usage"))
+		return
+	}
+	bond, ok, err := b.app.db.PendingBond(ctx, message.From.ID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "bond.lookup_failed"))
+		return
+	}
+	if !ok || b.app.bonder == nil {
+		b.reply(message, b.trFor(ctx, message, "bond.no_appeal"))
+		return
+	}
+	wallet, err := b.app.db.Wallet(ctx, bond.ChatID, message.From.ID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "bond.lookup_failed"))
+		return
+	}
+	if wallet == "" {
+		b.reply(message, b.trFor(ctx, message, "bond.no_wallet", b.verifyLink(bond.ChatID)))
+		return
+	}
+
+	txn, found, err := b.app.aptos.Transaction(ctx, hash)
+	if err != nil {
+		b.logf("Failed to look up bond transaction %s: %v", hash, err)
+		b.reply(message, b.trFor(ctx, message, "bond.txn_failed"))
+		return
+	}
+	if !found {
+		b.reply(message, b.trFor(ctx, message, "bond.txn_pending"))
+		return
+	}
+	var recipient string
+	var amount uint64
+	if len(txn.Arguments) == 2 {
+		recipient, _ = txn.Arguments[0].(string)
+		if s, ok := txn.Arguments[1].(string); ok {
+			amount, _ = strconv.ParseUint(s, 10, 64)
+		}
+	}
+	switch {
+	case !txn.Success:
+		b.reply(message, b.trFor(ctx, message, "bond.txn_unsuccessful"))
+		return
+	case txn.Function != aptTransferFunction || recipient == "" || normalizeAddress(recipient) != normalizeAddress(b.app.bonder.address):
+		b.reply(message, b.trFor(ctx, message, "bond.wrong_recipient", b.app.bonder.address))
+		return
+	case normalizeAddress(txn.Sender) != normalizeAddress(wallet):
+		// Otherwise anyone could claim someone else's transfer and have it refunded to them
+		b.reply(message, b.trFor(ctx, message, "bond.wrong_sender", wallet))
+		return
+	case amount < bond.Octas:
+		b.reply(message, b.trFor(ctx, message, "bond.wrong_amount", formatAPT(bond.Octas), formatAPT(amount)))
+		return
+	case txn.Timestamp.Before(bond.Created):
+		b.reply(message, b.trFor(ctx, message, "bond.txn_too_old"))
+		return
+	}
+
+	locked, err := b.app.db.LockBond(ctx, bond.ChatID, message.From.ID, normalizeAddress(txn.Sender), hash)
+	if err != nil {
+		b.logf("Failed to lock ban bond for %d in chat %d: %v", message.From.ID, bond.ChatID, err)
+		b.app.reporter.Failure("db.lockBond", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "bond.save_failed"))
+		return
+	}
+	if !locked {
+		b.reply(message, b.trFor(ctx, message, "bond.txn_reused"))
+		return
+	}
+	metrics.Add("ban_bonds_locked", 1)
+	b.reply(message, b.trFor(ctx, message, "bond.locked"))
+
+	name := message.From.FirstName
+	if message.From.UserName != "" {
+		name += " (@" + message.From.UserName + ")"
+	}
+	b.sendBondReview(ctx, bond.ChatID, message.From.ID, b.tr(ctx, bond.ChatID, "bond.review",
+		name, message.From.ID, bond.ChatID, formatAPT(bond.Octas), hash))
+}
+
+// sendBondReview asks the admins to decide on userID's bonded appeal in
+// chatID, in admin_log_chat if set, like other admin notices, else in the group
+func (b *Bot) sendBondReview(ctx context.Context, chatID, userID int64, text string) {
+	target := chatID
+	if id, err := strconv.ParseInt(b.app.settings.Get(ctx, chatID, settingAdminLogChat), 10, 64); err == nil {
+		target = id
+	}
+	review := tgbotapi.NewMessage(target, text)
+	data := fmt.Sprintf(":%d:%d", chatID, userID)
+	review.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "bond.button_refund"), "bond:refund"+data),
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "bond.button_forfeit"), "bond:forfeit"+data),
+	))
+	b.outbox.enqueue(review)
+}
+
+// handleBondCallback carries out an admin's decision on a bonded appeal:
+// "refund" unbans the member and returns the bond, "forfeit" sends it to the
+// community_wallet. The member hears of it once reconcileBondPayouts sees the
+// payout committed.
+func (b *Bot) handleBondCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	parts := strings.Split(args, ":")
+	if len(parts) != 3 || (parts[0] != "refund" && parts[0] != "forfeit") {
+		return
+	}
+	chatID, err1 := strconv.ParseInt(parts[1], 10, 64)
+	userID, err2 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.admins_only")))
+		return
+	}
+	bond, ok, err := b.app.db.Bond(ctx, chatID, userID)
+	if err != nil || !ok || b.app.bonder == nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.not_found")))
+		return
+	}
+	recipient, status := bond.Sender, bondRefunded
+	if parts[0] == "forfeit" {
+		recipient, status = b.app.settings.Get(ctx, chatID, settingCommunityWallet), bondForfeited
+		if recipient == "" || checkAptosAddress(recipient) != nil {
+			b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.no_community_wallet")))
+			return
+		}
+	}
+	if ok, err := b.app.db.SettleBond(ctx, chatID, userID, status); err != nil || !ok {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.already_decided")))
+		return
+	}
+
+	ec := ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID}
+	if status == bondRefunded {
+		unban := tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID}, OnlyIfBanned: true}
+		if _, err := b.request(ctx, unban); err != nil {
+			b.logf("Failed to unban %d after appeal: %v", userID, err)
+			b.app.reporter.Failure("telegram.unbanChatMember", err, ec)
+			b.app.db.ReopenBond(ctx, chatID, userID, "pending")
+			b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+			return
+		}
+		b.audit(ctx, "unban", chatID, userID, 0, "ban appeal upheld by admin "+query.From.UserName)
+	}
+	txn, err := b.app.aptos.Sign(ctx, b.app.bonder, aptTransferFunction, []interface{}{recipient, strconv.FormatUint(bond.Octas, 10)})
+	if err == nil {
+		var recorded bool
+		if recorded, err = b.app.db.RecordBondPayout(ctx, chatID, userID, txn); err == nil && !recorded {
+			err = fmt.Errorf("bond is no longer awaiting its payout")
+		}
+	}
+	if err != nil {
+		// Nothing was sent, so the bond can safely go back under review
+		b.logf("Failed to pay out ban bond of %d in chat %d: %v", userID, chatID, err)
+		b.app.reporter.Failure("aptos.banBond", err, ec)
+		b.app.db.ReopenBond(ctx, chatID, userID, "pending")
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.payout_failed", err)))
+		return
+	}
+	recorded := txn.Hash
+	if err := b.app.aptos.SubmitSigned(ctx, txn); err != nil {
+		// The node may have taken it anyway: reconcileBondPayouts finds out by its hash
+		b.logf("Ban bond payout %s of %d in chat %d may not have been submitted: %v", txn.Hash, userID, chatID, err)
+		b.app.reporter.Failure("aptos.banBond", err, ec)
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.payout_unconfirmed")))
+	} else {
+		if txn.Hash != recorded {
+			b.app.db.SetBondPayout(ctx, chatID, userID, recorded, txn.Hash)
+		}
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "bond.decided_"+status)))
+	}
+	b.logf("Ban bond of %d in chat %d %s by %s, transaction %s", userID, chatID, status, query.From.UserName, txn.Hash)
+	b.request(ctx, tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "bond.decided_by_"+status, query.From.FirstName, txn.Hash)))
+}
+
+// bondPayoutSweepInterval is how often submitted bond payouts are looked up on chain
+const bondPayoutSweepInterval = 30 * time.Second
+
+// bondPayoutGrace is how long past its expiry a payout may take to show up
+// at the fullnode before it is taken to have been dropped
+const bondPayoutGrace = time.Minute
+
+// runBondPayouts reconciles submitted bond payouts until ctx is cancelled
+func (b *Bot) runBondPayouts(ctx context.Context) {
+	for sleepContext(ctx, bondPayoutSweepInterval) {
+		b.reconcileBondPayouts(ctx)
+	}
+}
+
+// reconcileBondPayouts looks up each submitted payout by its hash: committed
+// ones are confirmed and their members told, and ones that failed or expired
+// unused put the bond back under review, so no payout is ever sent twice
+func (b *Bot) reconcileBondPayouts(ctx context.Context) {
+	if b.app.bonder == nil || b.app.aptos == nil {
+		return
+	}
+	payouts, err := b.app.db.SubmittedBondPayouts(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to load submitted bond payouts: %v", err)
+		return
+	}
+	for _, p := range payouts {
+		txn, found, err := b.app.aptos.Transaction(ctx, p.TxHash)
+		if err != nil {
+			b.logf("Failed to look up bond payout %s: %v", p.TxHash, err)
+			continue
+		}
+		if !found {
+			if time.Now().Before(p.Expires.Add(bondPayoutGrace)) {
+				continue
+			}
+			// Past its expiry it can only have been committed at its sequence number
+			hash, used, err := b.app.aptos.AccountTransaction(ctx, b.app.bonder.address, p.Sequence)
+			if err != nil {
+				b.logf("Failed to look up bond payout %s: %v", p.TxHash, err)
+				continue
+			}
+			if !used || hash != p.TxHash {
+				b.failedBondPayout(ctx, p, "expired")
+			}
+			continue
+		}
+		if !txn.Success {
+			b.failedBondPayout(ctx, p, "failed on chain")
+			continue
+		}
+		if confirmed, err := b.app.db.ConfirmBondPayout(ctx, p.ChatID, p.UserID, p.TxHash); err != nil || !confirmed {
+			continue
+		}
+		metrics.Add("ban_bonds_"+p.Status, 1)
+		b.logf("Ban bond payout %s of %d in chat %d confirmed", p.TxHash, p.UserID, p.ChatID)
+		verdict := b.tr(ctx, p.ChatID, "bond.refunded", formatAPT(p.Octas), p.TxHash)
+		if p.Status == bondForfeited {
+			verdict = b.tr(ctx, p.ChatID, "bond.forfeited", formatAPT(p.Octas))
+		}
+		b.outbox.enqueue(tgbotapi.NewMessage(p.UserID, verdict))
+	}
+}
+
+// failedBondPayout puts a bond whose payout didn't go through back before the admins
+func (b *Bot) failedBondPayout(ctx context.Context, p bondPayout, why string) {
+	reopened, err := b.app.db.ReopenBond(ctx, p.ChatID, p.UserID, p.TxHash)
+	if err != nil || !reopened {
+		return
+	}
+	b.logf("Ban bond payout %s of %d in chat %d %s, appeal reopened", p.TxHash, p.UserID, p.ChatID, why)
+	b.app.reporter.Failure("aptos.banBond", fmt.Errorf("payout %s %s", p.TxHash, why),
+		ErrorContext{Bot: b.api.Self.UserName, ChatID: p.ChatID, UserID: p.UserID})
+	b.sendBondReview(ctx, p.ChatID, p.UserID, b.tr(ctx, p.ChatID, "bond.payout_reopened", p.UserID, p.ChatID, formatAPT(p.Octas), p.TxHash))
+}
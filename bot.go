@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Bot is a single Telegram bot identity with its own update loop
+type Bot struct {
+	api     *tgbotapi.BotAPI
+	app     *App
+	limiter *rateLimiter
+	outbox  *outbox
+	// Subscribers to what happens in the bot's chats
+	bus *eventBus
+	// Unix nanos of the last update loop iteration, for the systemd watchdog
+	heartbeat atomic.Int64
+	// chat id -> time.Time of the last registry write
+	seenChats sync.Map
+	albums    *albumTracker
+	voice     *voiceLimiter
+	business  *businessConnections
+	events    *eventCache
+	admins    *adminCache
+	lookups   *lookupGroup
+	rights    *missingRights
+	// Stages of handling a new message, and of moderating a group one
+	messages   *pipeline
+	moderation *pipeline
+	// Unix seconds of the last rules reminder, token gate, on-chain, event mode,
+	// digest and webhook health checks in webhook mode
+	lastReminders    atomic.Int64
+	lastTokenGates   atomic.Int64
+	lastOnchain      atomic.Int64
+	lastEvents       atomic.Int64
+	lastDigests      atomic.Int64
+	lastWebhookCheck atomic.Int64
+}
+
+func newBot(api *tgbotapi.BotAPI, app *App) *Bot {
+	b := &Bot{api: api, app: app, limiter: newRateLimiter(), albums: newAlbumTracker(), voice: newVoiceLimiter(), business: newBusinessConnections(), events: newEventCache(), admins: newAdminCache(), lookups: newLookupGroup(), rights: newMissingRights(), bus: newEventBus()}
+	b.outbox = newOutbox(b)
+	b.subscribe()
+	b.messages, b.moderation = b.messagePipeline(), b.moderationPipeline()
+	b.beat()
+	return b
+}
+
+// beat records that the update loop is alive
+func (b *Bot) beat() {
+	b.heartbeat.Store(time.Now().UnixNano())
+}
+
+func (b *Bot) sinceHeartbeat() time.Duration {
+	return time.Since(time.Unix(0, b.heartbeat.Load()))
+}
+
+// logf logs with the bot's username as prefix so multi-bot logs stay readable
+func (b *Bot) logf(format string, args ...interface{}) {
+	log.Printf("[@%s] %s", b.api.Self.UserName, fmt.Sprintf(format, args...))
+}
+
+// adminLog logs a moderation finding and posts it to the chat's admin_log_chat, if set
+func (b *Bot) adminLog(ctx context.Context, chatID int64, text string) {
+	b.logf("Chat %d: %s", chatID, text)
+	if id, err := strconv.ParseInt(b.app.settings.Get(ctx, chatID, settingAdminLogChat), 10, 64); err == nil {
+		b.outbox.enqueue(tgbotapi.NewMessage(id, text))
+	}
+}
+
+// errorContext describes the bot and, if given, the message for error reports
+func (b *Bot) errorContext(message *tgbotapi.Message) ErrorContext {
+	ctx := ErrorContext{Bot: b.api.Self.UserName}
+	if message != nil {
+		ctx.ChatID = message.Chat.ID
+		if message.From != nil {
+			ctx.UserID = message.From.ID
+		}
+	}
+	return ctx
+}
+
+// run polls for updates until ctx is cancelled. In cluster mode only the instance
+// holding this bot's poll lease polls; the others stand by and take over when the lease expires.
+func (b *Bot) run(ctx context.Context) {
+	cfg := b.app.Config()
+	if !cfg.Cluster {
+		b.poll(ctx, 60)
+		return
+	}
+
+	lease := fmt.Sprintf("poll:%d", b.api.Self.ID)
+	for ctx.Err() == nil {
+		held, err := b.app.db.AcquireLease(ctx, lease, cfg.InstanceID, leaseTTL)
+		if err != nil {
+			b.logf("Failed to acquire poll lease: %v", err)
+			b.app.reporter.Failure("db.lease", err, b.errorContext(nil))
+		}
+		if !held {
+			// A standby has no update loop to stall; once it polls, only
+			// poll reports in, so a stuck loop trips the watchdog
+			b.beat()
+			sleepContext(ctx, leaseTTL/3)
+			continue
+		}
+
+		b.logf("Instance %s is now polling", cfg.InstanceID)
+		pollCtx, stop := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			// Long-poll timeout stays well below the lease TTL so a deposed
+			// leader stops fetching before its successor starts
+			b.poll(pollCtx, int(leaseTTL/time.Second)/3)
+			close(done)
+		}()
+
+		for held && sleepContext(ctx, leaseTTL/3) {
+			held, err = b.app.db.AcquireLease(ctx, lease, cfg.InstanceID, leaseTTL)
+			if err != nil {
+				b.logf("Failed to renew poll lease: %v", err)
+				b.app.reporter.Failure("db.lease", err, b.errorContext(nil))
+			}
+			if err := b.app.db.PruneUpdates(ctx); err != nil {
+				b.logf("Failed to prune processed updates: %v", err)
+			}
+		}
+		stop()
+		<-done
+		if ctx.Err() != nil {
+			// Shutting down: hand the lease over right away
+			b.app.db.ReleaseLease(context.Background(), lease, cfg.InstanceID)
+			return
+		}
+		b.logf("Instance %s lost the poll lease, standing by", cfg.InstanceID)
+	}
+}
+
+// poll fetches updates until ctx is cancelled
+func (b *Bot) poll(ctx context.Context, timeout int) {
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = timeout
+	u.AllowedUpdates = allowedUpdates
+
+	// Only the polling instance runs the startup self-check and the background jobs,
+	// so clusters alert, delete and post reminders once
+	go b.checkAllPermissions(ctx)
+	go b.runDeletions(ctx)
+	go b.runReminders(ctx)
+	go b.runTokenGates(ctx)
+	go b.runOnchainEvents(ctx)
+	go b.runEvents(ctx)
+	go b.runDigests(ctx)
+	go b.runBondPayouts(ctx)
+
+	for ctx.Err() == nil {
+		b.beat()
+		updates, err := b.getUpdates(ctx, u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logf("Failed to get updates, retrying in 3 seconds: %v", err)
+			b.app.reporter.Failure("telegram.getUpdates", err, b.errorContext(nil))
+			sleepContext(ctx, 3*time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID < u.Offset {
+				continue
+			}
+			u.Offset = update.UpdateID + 1
+			if b.app.Config().Cluster {
+				claimed, err := b.app.db.ClaimUpdate(ctx, b.api.Self.ID, update.UpdateID)
+				if err != nil {
+					b.logf("Failed to claim update %d: %v", update.UpdateID, err)
+					b.app.reporter.Failure("db.claimUpdate", err, ErrorContext{Bot: b.api.Self.UserName, UpdateID: update.UpdateID})
+				} else if !claimed {
+					continue // Already handled by another instance
+				}
+			}
+			b.safeHandleUpdate(ctx, update)
+			b.beat()
+		}
+	}
+}
+
+// safeHandleUpdate handles one update under the per-update deadline, recovering
+// from panics so a single bad update can't stop moderation for every chat
+func (b *Bot) safeHandleUpdate(ctx context.Context, update Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			b.logf("Recovered from panic while handling update %d: %v\n%s", update.UpdateID, r, stack)
+			metrics.Add("panics_recovered", 1)
+
+			ec := b.errorContext(update.Message)
+			ec.UpdateID = update.UpdateID
+			go b.app.reporter.Panic(r, stack, ec)
+		}
+	}()
+	metrics.Add("updates_handled", 1)
+
+	ctx, cancel := context.WithTimeout(ctx, b.app.Config().UpdateTimeout)
+	defer cancel()
+	b.handleUpdate(ctx, update)
+	if ctx.Err() == context.DeadlineExceeded {
+		b.logf("Handling update %d timed out", update.UpdateID)
+		metrics.Add("updates_timed_out", 1)
+	}
+}
+
+func (b *Bot) handleUpdate(ctx context.Context, update Update) {
+	if update.MyChatMember != nil {
+		b.handleMyChatMember(ctx, update.MyChatMember)
+		return
+	}
+	if update.ChatMember != nil {
+		b.handleChatMember(update.ChatMember)
+		return
+	}
+	if update.ChatJoinRequest != nil {
+		b.handleJoinRequest(ctx, update.ChatJoinRequest)
+		return
+	}
+	if update.ext.MessageReaction != nil {
+		b.handleReaction(ctx, update.ext.MessageReaction)
+		return
+	}
+	if update.ext.MessageReactionCount != nil {
+		b.handleReactionCount(ctx, update.ext.MessageReactionCount)
+		return
+	}
+	if update.ext.BusinessConnection != nil {
+		b.handleBusinessConnection(update.ext.BusinessConnection)
+		return
+	}
+	if update.ext.BusinessMessage != nil {
+		b.handleBusinessMessage(ctx, update.ext.BusinessMessage)
+		return
+	}
+	if update.CallbackQuery != nil {
+		b.handleCallback(ctx, update.CallbackQuery)
+		return
+	}
+	if update.EditedMessage != nil {
+		b.handleEdit(ctx, wrapMessage(update.EditedMessage, update.ext.EditedMessage))
+		return
+	}
+	if update.Message == nil {
+		return
+	}
+	b.touchChat(ctx, update.Message.Chat)
+	b.handleMessage(ctx, wrapMessage(update.Message, update.ext.Message))
+}
+
+// handleMessage runs a new message through the message pipeline
+func (b *Bot) handleMessage(ctx context.Context, message *Message) {
+	b.messages.run(ctx, &messageContext{message: message})
+}
+
+// handleEdit re-checks group messages edited soon after posting, so a message
+// can't pass as clean and have its link edited in later. The window is measured
+// from the post date Telegram includes in every edit, so no tracking is needed.
+func (b *Bot) handleEdit(ctx context.Context, message *Message) {
+	if (message.Chat.Type != "group" && message.Chat.Type != "supergroup") || message.From == nil || message.IsAutomaticForward {
+		return
+	}
+	minutes, _ := strconv.Atoi(b.setting(ctx, message, settingEditRecheckMinutes))
+	if minutes == 0 || message.EditDate-message.Date > minutes*60 {
+		return
+	}
+	text := messageText(message)
+	if text == "" && !hasPolicyContent(message) {
+		return
+	}
+	if b.isChatAdmin(ctx, message.Chat.ID, message.From.ID) {
+		return
+	}
+	b.logf("Re-checking message %d edited by %s: %s", message.MessageID, message.From.UserName, text)
+	metrics.Add("edits_rechecked", 1)
+	b.moderate(ctx, message, text)
+}
+
+// moderate applies the chat's content policies and the spam detector to a
+// group message from a non-admin
+func (b *Bot) moderate(ctx context.Context, message *Message, text string) {
+	b.moderation.run(ctx, &messageContext{message: message, text: text})
+}
+
+// isChatAdmin reports whether userID is an administrator or the creator of chatID
+func (b *Bot) isChatAdmin(ctx context.Context, chatID, userID int64) bool {
+	admins, err := b.chatAdmins(ctx, chatID)
+	if err != nil {
+		b.app.reporter.Failure("telegram.getChatAdministrators", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		return false
+	}
+	for _, admin := range admins {
+		if admin.User != nil && admin.User.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// chatAdminUsernames lists the lower-cased usernames of chatID's administrators
+func (b *Bot) chatAdminUsernames(ctx context.Context, chatID int64) ([]string, error) {
+	admins, err := b.chatAdmins(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, admin := range admins {
+		if admin.User != nil && admin.User.UserName != "" {
+			names = append(names, strings.ToLower(admin.User.UserName))
+		}
+	}
+	return names, nil
+}
+
+// messageAge is how long ago the message was posted, or last edited
+func messageAge(message *Message) time.Duration {
+	at := message.Date
+	if message.EditDate > at {
+		at = message.EditDate
+	}
+	return time.Since(time.Unix(int64(at), 0))
+}
+
+// punish deletes a spam message and bans the sender once they reach the threshold
+func (b *Bot) punish(ctx context.Context, message *Message, reason string) {
+	b.punishMessage(ctx, message, reason, false)
+}
+
+// punishMessage is punish; with instant the sender is banned on the first offence
+func (b *Bot) punishMessage(ctx context.Context, message *Message, reason string, instant bool) {
+	// Backlog after downtime: members have long seen the message, and striking or
+	// banning for it hours later only confuses them
+	cfg := b.app.Config()
+	if age := messageAge(message); cfg.MaxMessageAge > 0 && age > cfg.MaxMessageAge {
+		metrics.Add("stale_spam", 1)
+		if cfg.StaleMessageAction == "delete" {
+			b.deleteMessage(ctx, message, reason+", stale")
+			return
+		}
+		b.logf("Not acting on %s old spam from %s (reason: %s)", age.Round(time.Second), message.From.UserName, reason)
+		return
+	}
+
+	// A duplicate update, a second bot in the chat or another instance must not
+	// strike, warn or ban for the same message again
+	if !b.claimIntent(ctx, messageIntentTTL, "punish:%d:%d", message.Chat.ID, message.MessageID) {
+		return
+	}
+	metrics.Add("spam_detected", 1)
+	b.bus.publish(ctx, SpamDetected{ChatID: message.Chat.ID, UserID: message.From.ID, MessageID: message.MessageID, Reason: reason, Instant: instant, Message: message})
+	if b.cannotModerate(message.Chat.ID, "permission.delete_messages") {
+		return
+	}
+	// Delete the spam message
+	b.logf("Detected spam from %s (reason: %s), attempting to delete...",
+		message.From.UserName, reason)
+	deleteMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
+	_, err := b.request(ctx, deleteMsg)
+	if err != nil {
+		b.logf("Failed to delete message ID %d from chat %d: %v",
+			message.MessageID, message.Chat.ID, err)
+		b.app.reporter.Failure("telegram.deleteMessage", err, b.errorContext(message.Message))
+		b.checkRightsAfter(ctx, message.Chat.ID, err)
+		// A transient failure is retried in the background; the strike stands
+		if !b.retryDelete(ctx, message, reason, err) {
+			return
+		}
+	} else {
+		b.logf("Successfully deleted spam message from %s (reason: %s)",
+			message.From.UserName, reason)
+		metrics.Add("messages_deleted", 1)
+		b.audit(ctx, "delete", message.Chat.ID, message.From.ID, message.MessageID, reason)
+	}
+	b.deleteAlbum(ctx, message)
+
+	// Record spam and check if user should be banned
+	count, shouldBan, err := b.app.detector.RecordSpam(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		b.logf("%v", err)
+		b.app.reporter.Failure("db.recordSpam", err, b.errorContext(message.Message))
+	}
+
+	if (shouldBan || instant) && !b.cannotModerate(message.Chat.ID, "permission.ban_users") {
+		// Ban the user
+		banConfig := tgbotapi.BanChatMemberConfig{
+			ChatMemberConfig: tgbotapi.ChatMemberConfig{
+				ChatID: message.Chat.ID,
+				UserID: message.From.ID,
+			},
+		}
+		_, banErr := b.request(ctx, banConfig)
+		if banErr != nil {
+			b.logf("Failed to ban user %s: %v", message.From.UserName, banErr)
+			b.app.reporter.Failure("telegram.banChatMember", banErr, b.errorContext(message.Message))
+			b.checkRightsAfter(ctx, message.Chat.ID, banErr)
+			b.retryBan(ctx, message.Chat.ID, message.From.ID, reason, banErr)
+		} else {
+			b.logf("Banned user %s for spam (reason: %s)", message.From.UserName, reason)
+			metrics.Add("users_banned", 1)
+			b.bus.publish(ctx, UserBanned{ChatID: message.Chat.ID, User: *message.From, Reason: reason, Message: message, Strikes: count})
+		}
+		return
+	}
+	b.notifyPunishment(ctx, message, settingWarnMessage, count, reason)
+}
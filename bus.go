@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// busEvent is something that happened in a chat, for the bus's subscribers
+type busEvent interface {
+	eventName() string
+}
+
+// SpamDetected: a message is about to be removed as spam, with a strike or,
+// if Instant, a ban
+type SpamDetected struct {
+	ChatID    int64    `json:"chat_id"`
+	UserID    int64    `json:"user_id"`
+	MessageID int      `json:"message_id"`
+	Reason    string   `json:"reason"`
+	Instant   bool     `json:"instant"`
+	Message   *Message `json:"-"`
+}
+
+// UserBanned: a member was banned, by the bot or by an admin pressing a
+// report button (ByAdmin)
+type UserBanned struct {
+	ChatID int64         `json:"chat_id"`
+	User   tgbotapi.User `json:"user"`
+	// The message the ban is recorded against in the audit log, if any
+	MessageID int    `json:"message_id,omitempty"`
+	Reason    string `json:"reason"`
+	ByAdmin   bool   `json:"by_admin"`
+	// Message that got the member banned and their strikes before it; nil
+	// for bans of new members and retried bans
+	Message *Message `json:"-"`
+	Strikes int      `json:"strikes,omitempty"`
+}
+
+// MemberJoined: a member joined and passed screening
+type MemberJoined struct {
+	ChatID  int64         `json:"chat_id"`
+	Member  tgbotapi.User `json:"member"`
+	Message *Message      `json:"-"`
+}
+
+// SettingChanged: an admin set a setting, or reset it with Value "default";
+// secret values are "(secret)"
+type SettingChanged struct {
+	ChatID   int64  `json:"chat_id"`
+	ThreadID int    `json:"thread_id,omitempty"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	// Who changed it, for the logs, e.g. "alice" or "user 42 on the dashboard"
+	By string `json:"by"`
+}
+
+func (SpamDetected) eventName() string   { return "spam_detected" }
+func (UserBanned) eventName() string     { return "user_banned" }
+func (MemberJoined) eventName() string   { return "member_joined" }
+func (SettingChanged) eventName() string { return "setting_changed" }
+
+// eventBus hands each event a bot publishes to the subscribers of its kind,
+// in the order they subscribed. Detection and commands publish what happened;
+// what follows from it (audit entries, alerts, notices, welcomes, webhooks)
+// lives in subscribers.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+type subscriber struct {
+	name   string
+	handle func(ctx context.Context, event busEvent)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[string][]subscriber)}
+}
+
+// subscribe has handle called with every event of type E
+func subscribe[E busEvent](bus *eventBus, name string, handle func(ctx context.Context, event E)) {
+	var zero E
+	bus.add(zero.eventName(), subscriber{name, func(ctx context.Context, event busEvent) { handle(ctx, event.(E)) }})
+}
+
+// subscribeAll has handle called with every event, after the subscribers of its kind
+func (bus *eventBus) subscribeAll(name string, handle func(ctx context.Context, event busEvent)) {
+	bus.add("*", subscriber{name, handle})
+}
+
+func (bus *eventBus) add(kind string, s subscriber) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.subscribers[kind] = append(bus.subscribers[kind], s)
+}
+
+// publish runs the subscribers of event before returning
+func (bus *eventBus) publish(ctx context.Context, event busEvent) {
+	metrics.Add("events."+event.eventName(), 1)
+	bus.mu.RLock()
+	subscribers := append(append([]subscriber(nil), bus.subscribers[event.eventName()]...), bus.subscribers["*"]...)
+	bus.mu.RUnlock()
+	for _, s := range subscribers {
+		s.handle(ctx, event)
+	}
+}
+
+// subscribe wires up the bot's own subscribers
+func (b *Bot) subscribe() {
+	subscribe(b.bus, "audit", func(ctx context.Context, e UserBanned) {
+		b.audit(ctx, "ban", e.ChatID, e.User.ID, e.MessageID, e.Reason)
+	})
+	subscribe(b.bus, "scam_photos", func(ctx context.Context, e UserBanned) {
+		b.recordScamPhoto(ctx, e.ChatID, e.User.ID)
+	})
+	subscribe(b.bus, "ban_alerts", func(ctx context.Context, e UserBanned) {
+		// An admin's own ban needs no alert to the admins
+		if e.ByAdmin {
+			return
+		}
+		text := ""
+		if e.Message != nil {
+			text = messageText(e.Message)
+		}
+		b.alertBan(ctx, e.ChatID, e.User, e.Reason, text)
+	})
+	subscribe(b.bus, "ban_notice", func(ctx context.Context, e UserBanned) {
+		if e.Message != nil {
+			b.notifyPunishment(ctx, e.Message, settingBanMessage, e.Strikes, e.Reason)
+		}
+	})
+	subscribe(b.bus, "welcome", func(ctx context.Context, e MemberJoined) {
+		if b.eventRestricts(ctx, e.ChatID, "captcha") {
+			b.challengeMember(ctx, e.Message, e.Member)
+		} else {
+			b.welcome(ctx, e.Message, e.Member)
+		}
+	})
+	subscribe(b.bus, "token_gate", func(ctx context.Context, e MemberJoined) {
+		b.gateNewMember(ctx, e.Message, e.Member)
+	})
+	subscribe(b.bus, "settings_log", func(ctx context.Context, e SettingChanged) {
+		where := fmt.Sprintf("chat %d", e.ChatID)
+		if e.ThreadID != 0 {
+			where = fmt.Sprintf("topic %d of chat %d", e.ThreadID, e.ChatID)
+		}
+		b.logf("Setting %s set to %q for %s by %s", e.Key, e.Value, where, e.By)
+	})
+	b.bus.subscribeAll("webhook", b.postEvent)
+}
+
+// eventWebhookTimeout bounds one EVENT_WEBHOOK_URL post
+const eventWebhookTimeout = 10 * time.Second
+
+var eventWebhookClient = &http.Client{Timeout: eventWebhookTimeout}
+
+// postEvent posts event as JSON to EVENT_WEBHOOK_URL, if set, in the
+// background so a slow receiver doesn't hold up moderation
+func (b *Bot) postEvent(ctx context.Context, event busEvent) {
+	url := b.app.Config().EventWebhookURL
+	if url == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event": event.eventName(),
+		"bot":   b.api.Self.UserName,
+		"time":  time.Now().UTC().Format(time.RFC3339),
+		"data":  event,
+	})
+	if err != nil {
+		b.logf("Failed to encode %s event: %v", event.eventName(), err)
+		return
+	}
+	go func() {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			b.logf("Failed to post %s event: %v", event.eventName(), err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := eventWebhookClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("unexpected status %s", resp.Status)
+			}
+		}
+		if err != nil {
+			b.logf("Failed to post %s event: %v", event.eventName(), err)
+			metrics.Add("event_webhook_failures", 1)
+		}
+	}()
+}
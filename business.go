@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// businessMessage is a message in a chat of a Telegram Business account that
+// connected this bot; its JSON is a regular message plus business_connection_id
+type businessMessage struct {
+	Message      *tgbotapi.Message
+	Ext          messageExt
+	ConnectionID string
+}
+
+func (m *businessMessage) UnmarshalJSON(data []byte) error {
+	m.Message = new(tgbotapi.Message)
+	if err := json.Unmarshal(data, m.Message); err != nil {
+		return err
+	}
+	var ext struct {
+		messageExt
+		ConnectionID string `json:"business_connection_id"`
+	}
+	if err := json.Unmarshal(data, &ext); err != nil {
+		return err
+	}
+	m.Ext, m.ConnectionID = ext.messageExt, ext.ConnectionID
+	return nil
+}
+
+// businessConnection is a Business account's link to the bot
+type businessConnection struct {
+	ID        string        `json:"id"`
+	User      tgbotapi.User `json:"user"`
+	IsEnabled bool          `json:"is_enabled"`
+	Rights    struct {
+		CanDeleteAllMessages bool `json:"can_delete_all_messages"`
+	} `json:"rights"`
+}
+
+// businessConnections caches connections by id; they change only via business_connection updates
+type businessConnections struct {
+	mu    sync.Mutex
+	conns map[string]businessConnection
+}
+
+func newBusinessConnections() *businessConnections {
+	return &businessConnections{conns: make(map[string]businessConnection)}
+}
+
+func (c *businessConnections) get(id string) (businessConnection, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn, ok := c.conns[id]
+	return conn, ok
+}
+
+func (c *businessConnections) put(conn businessConnection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[conn.ID] = conn
+}
+
+// handleBusinessConnection refreshes a connection when the account changes its settings
+func (b *Bot) handleBusinessConnection(conn *businessConnection) {
+	b.business.put(*conn)
+	b.logf("Business connection %s of user %d: enabled=%t, can delete=%t",
+		conn.ID, conn.User.ID, conn.IsEnabled, conn.Rights.CanDeleteAllMessages)
+}
+
+// businessConnection returns a connection, fetching it if it predates this process
+func (b *Bot) businessConnection(ctx context.Context, id string) (businessConnection, error) {
+	if conn, ok := b.business.get(id); ok {
+		return conn, nil
+	}
+	resp, err := b.callRaw(ctx, 0, "getBusinessConnection", tgbotapi.Params{"business_connection_id": id})
+	if err != nil {
+		return businessConnection{}, err
+	}
+	var conn businessConnection
+	if err := json.Unmarshal(resp.Result, &conn); err != nil {
+		return businessConnection{}, err
+	}
+	b.business.put(conn)
+	return conn, nil
+}
+
+// handleBusinessMessage filters spam that customers send to a Business account.
+// There is no group to ban from, so spam is only deleted, if the account allows it.
+func (b *Bot) handleBusinessMessage(ctx context.Context, bm *businessMessage) {
+	message := wrapMessage(bm.Message, &bm.Ext)
+	if message.From == nil {
+		return
+	}
+	conn, err := b.businessConnection(ctx, bm.ConnectionID)
+	if err != nil {
+		b.logf("Failed to get business connection %s: %v", bm.ConnectionID, err)
+		b.app.reporter.Failure("telegram.getBusinessConnection", err, b.errorContext(message.Message))
+		return
+	}
+	// The account owner's own replies are never filtered
+	if !conn.IsEnabled || message.From.ID == conn.User.ID {
+		return
+	}
+
+	text := messageText(message)
+	if text == "" {
+		return
+	}
+	verdict := b.app.detector.IsSpam(ctx, message.Chat.ID, 0, text)
+	if !verdict.Spam() {
+		return
+	}
+	reason := verdict.Reason
+	metrics.Add("spam_detected", 1)
+	if !conn.Rights.CanDeleteAllMessages {
+		b.logf("Spam from %s in business chat of %d (reason: %s), but deleting isn't allowed",
+			message.From.UserName, conn.User.ID, reason)
+		return
+	}
+
+	params := tgbotapi.Params{"business_connection_id": conn.ID}
+	if err := params.AddInterface("message_ids", []int{message.MessageID}); err != nil {
+		return
+	}
+	if _, err := b.callRaw(ctx, message.Chat.ID, "deleteBusinessMessages", params); err != nil {
+		b.logf("Failed to delete business message %d: %v", message.MessageID, err)
+		b.app.reporter.Failure("telegram.deleteBusinessMessages", err, b.errorContext(message.Message))
+		return
+	}
+	b.logf("Deleted spam from %s in business chat of %d (reason: %s)", message.From.UserName, conn.User.ID, reason)
+	metrics.Add("messages_deleted", 1)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// casCacheTTL is how long a CAS verdict is reused for the same user
+const casCacheTTL = time.Hour
+
+// casCacheSize bounds the users whose CAS verdicts are kept
+const casCacheSize = 50000
+
+// CASClient queries the Combot Anti-Spam (CAS) ban list. A nil client never reports a ban.
+type CASClient struct {
+	baseURL string
+	client  *http.Client
+	// Whether each recently checked user is banned
+	cache *lruCache[int64, bool]
+}
+
+// NewCASClient returns nil when CAS_API_URL is "off"
+func NewCASClient(cfg *Config) *CASClient {
+	if cfg.CASAPIURL == "off" {
+		return nil
+	}
+	return &CASClient{
+		baseURL: cfg.CASAPIURL,
+		client:  &http.Client{Timeout: cfg.TelegramTimeout},
+		cache:   newLRUCache[int64, bool]("cas", casCacheSize, casCacheTTL),
+	}
+}
+
+// Banned reports whether userID is on the CAS ban list
+func (c *CASClient) Banned(ctx context.Context, userID int64) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	if banned, ok := c.cache.get(userID); ok {
+		return banned, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/check?user_id="+strconv.FormatInt(userID, 10), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to query CAS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to query CAS: unexpected status %s", resp.Status)
+	}
+	// {"ok": true, "result": {...}} for banned users, {"ok": false, ...} otherwise
+	var result struct {
+		OK bool `json:"ok"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode CAS response: %v", err)
+	}
+
+	c.cache.put(userID, result.OK)
+	return result.OK, nil
+}
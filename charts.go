@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// statsChartDays is how many days /stats charts, today included; moderation
+// events are kept a little longer
+const statsChartDays = 7
+
+// Chart geometry in pixels; digits are drawn from digitGlyphs scaled by chartDigitScale
+const (
+	chartWidth      = 640
+	chartHeight     = 320
+	chartMargin     = 24
+	chartAxisSpace  = 28
+	chartDigitScale = 3
+)
+
+// chartOther is the series the rules beyond the chart's palette are added up in
+const chartOther = "other"
+
+// chartSeries are the bar colors, each with the square emoji that stands for
+// it in the caption, since the image itself has no text besides numbers; the
+// last one is chartOther
+var chartSeries = []struct {
+	Color  color.RGBA
+	Legend string
+}{
+	{color.RGBA{0xe7, 0x4c, 0x3c, 0xff}, "🟥"},
+	{color.RGBA{0xe6, 0x7e, 0x22, 0xff}, "🟧"},
+	{color.RGBA{0xf1, 0xc4, 0x0f, 0xff}, "🟨"},
+	{color.RGBA{0x2e, 0xcc, 0x71, 0xff}, "🟩"},
+	{color.RGBA{0x34, 0x98, 0xdb, 0xff}, "🟦"},
+	{color.RGBA{0x8d, 0x6e, 0x63, 0xff}, "🟫"},
+}
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartInk        = color.RGBA{0x55, 0x55, 0x55, 0xff}
+	chartGrid       = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+)
+
+// digitGlyphs is a 3x5 pixel font for the chart's day numbers and scale
+var digitGlyphs = [10][5]string{
+	{"###", "#.#", "#.#", "#.#", "###"},
+	{".#.", "##.", ".#.", ".#.", "###"},
+	{"###", "..#", "###", "#..", "###"},
+	{"###", "..#", "###", "..#", "###"},
+	{"#.#", "#.#", "###", "..#", "..#"},
+	{"###", "#..", "###", "..#", "###"},
+	{"###", "#..", "###", "#.#", "###"},
+	{"###", "..#", "..#", "..#", "..#"},
+	{"###", "#.#", "###", "#.#", "###"},
+	{"###", "#.#", "###", "..#", "###"},
+}
+
+// ModerationRulesDaily counts chatID's detections since since by day in since's
+// location (numbered as by localDay) and rule
+func (s *Store) ModerationRulesDaily(ctx context.Context, chatID int64, since time.Time) (map[int64]map[string]int, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT (at + ?) / 86400, rule, COUNT(*) FROM moderation_events
+		WHERE chat_id = ? AND at >= ? AND action IN ('delete', 'react')
+		GROUP BY (at + ?) / 86400, rule
+	`, zoneOffset(since), chatID, since.Unix(), zoneOffset(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	days := make(map[int64]map[string]int)
+	for rows.Next() {
+		var day int64
+		var rule string
+		var count int
+		if err := rows.Scan(&day, &rule, &count); err != nil {
+			return nil, err
+		}
+		if rule == "" {
+			rule = chartOther
+		}
+		if days[day] == nil {
+			days[day] = make(map[string]int)
+		}
+		days[day][rule] += count
+	}
+	return days, rows.Err()
+}
+
+// chartRules orders the rules of daily by their totals, most first, folding
+// those beyond the palette into chartOther
+func chartRules(daily map[int64]map[string]int) ([]string, map[string]int) {
+	totals := make(map[string]int)
+	for _, rules := range daily {
+		for rule, count := range rules {
+			totals[rule] += count
+		}
+	}
+	var rules []string
+	for rule := range totals {
+		if rule != chartOther {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if totals[rules[i]] != totals[rules[j]] {
+			return totals[rules[i]] > totals[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+	if named := len(chartSeries) - 1; len(rules) > named {
+		for _, rule := range rules[named:] {
+			totals[chartOther] += totals[rule]
+			delete(totals, rule)
+		}
+		rules = rules[:named]
+	}
+	if totals[chartOther] > 0 {
+		rules = append(rules, chartOther)
+	}
+	return rules, totals
+}
+
+// renderStatsChart draws daily detections since since as bars stacked by
+// rule, in the order of rules, with each day's date under its bar
+func renderStatsChart(daily map[int64]map[string]int, rules []string, since time.Time) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	series := func(rule string) int {
+		for i, r := range rules {
+			if r == rule && r != chartOther {
+				return i
+			}
+		}
+		return len(chartSeries) - 1
+	}
+	most := 1
+	for _, counts := range daily {
+		day := 0
+		for _, count := range counts {
+			day += count
+		}
+		most = max(most, day)
+	}
+
+	top, bottom := chartMargin+chartAxisSpace, chartHeight-chartMargin-chartAxisSpace
+	left, right := chartMargin+chartAxisSpace, chartWidth-chartMargin
+	fill(img, image.Rect(left, top, right, top+1), chartGrid)
+	drawNumber(img, chartMargin, top-2*chartDigitScale, most)
+	fill(img, image.Rect(left, bottom, right, bottom+2), chartInk)
+
+	slot := (right - left) / statsChartDays
+	for i := 0; i < statsChartDays; i++ {
+		date := since.AddDate(0, 0, i)
+		x := left + i*slot + slot/4
+		y := bottom
+		counts := daily[localDay(date)]
+		for _, rule := range rules {
+			// chartOther collects every rule folded into it
+			count := counts[rule]
+			if rule == chartOther {
+				count = 0
+				for r, c := range counts {
+					if series(r) == len(chartSeries)-1 {
+						count += c
+					}
+				}
+			}
+			height := count * (bottom - top) / most
+			fill(img, image.Rect(x, y-height, x+slot/2, y), chartSeries[series(rule)].Color)
+			y -= height
+		}
+		drawNumber(img, x, bottom+chartAxisSpace/3, date.Day())
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// drawNumber writes n with digitGlyphs, its top left corner at x, y
+func drawNumber(img *image.RGBA, x, y, n int) {
+	for _, digit := range strconv.Itoa(n) {
+		for row, line := range digitGlyphs[digit-'0'] {
+			for col, pixel := range line {
+				if pixel == '#' {
+					px, py := x+col*chartDigitScale, y+row*chartDigitScale
+					fill(img, image.Rect(px, py, px+chartDigitScale, py+chartDigitScale), chartInk)
+				}
+			}
+		}
+		x += 4 * chartDigitScale
+	}
+}
+
+// cmdStats handles "/stats" from admins: a chart of the chat's detections
+// per day and rule over the last statsChartDays days
+func (b *Bot) cmdStats(ctx context.Context, message *Message) {
+	today := dayStart(b.app.settings.Now(ctx, message.Chat.ID))
+	since := today.AddDate(0, 0, 1-statsChartDays)
+	daily, err := b.app.db.ModerationRulesDaily(ctx, message.Chat.ID, since)
+	if err != nil {
+		b.logf("Failed to load detections of chat %d for /stats: %v", message.Chat.ID, err)
+		b.app.reporter.Failure("db.moderationRulesDaily", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "stats.failed"))
+		return
+	}
+	rules, totals := chartRules(daily)
+	if len(rules) == 0 {
+		b.reply(message, b.trFor(ctx, message, "stats.quiet", statsChartDays))
+		return
+	}
+	chart, err := renderStatsChart(daily, rules, since)
+	if err != nil {
+		b.logf("Failed to render the /stats chart for chat %d: %v", message.Chat.ID, err)
+		b.reply(message, b.trFor(ctx, message, "stats.failed"))
+		return
+	}
+
+	var caption strings.Builder
+	caption.WriteString(b.trFor(ctx, message, "stats.title", message.Chat.Title, since.Format("01-02"), today.Format("01-02"), today.Location()))
+	total := 0
+	for i, rule := range rules {
+		legend := chartSeries[i].Legend
+		name := rule
+		if rule == chartOther {
+			legend, name = chartSeries[len(chartSeries)-1].Legend, b.trFor(ctx, message, "stats.other")
+		}
+		caption.WriteString(fmt.Sprintf("\n%s %s: %d", legend, name, totals[rule]))
+		total += totals[rule]
+	}
+	caption.WriteString("\n\n" + b.trFor(ctx, message, "stats.total", total))
+
+	photo := tgbotapi.NewPhoto(message.Chat.ID, tgbotapi.FileBytes{Name: "stats.png", Bytes: chart})
+	photo.Caption = caption.String()
+	b.outbox.enqueue(photo)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatTouchInterval throttles how often a chat's last_seen is written
+const chatTouchInterval = time.Hour
+
+// knownChat is a group the bot has seen activity in
+type knownChat struct {
+	ID    int64
+	Title string
+	Type  string
+}
+
+// TouchChat records that botID is present in chat
+func (s *Store) TouchChat(ctx context.Context, botID int64, chat *tgbotapi.Chat) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO bot_chats (bot_id, chat_id, title, type, last_seen) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bot_id, chat_id) DO UPDATE SET title = excluded.title, type = excluded.type, last_seen = excluded.last_seen
+	`, botID, chat.ID, chat.Title, chat.Type, time.Now().Unix())
+	return err
+}
+
+// ForgetChat removes a chat the bot has left or been removed from
+func (s *Store) ForgetChat(ctx context.Context, botID, chatID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM bot_chats WHERE bot_id = ? AND chat_id = ?`, botID, chatID)
+	return err
+}
+
+// KnownChats lists the groups botID has been seen in
+func (s *Store) KnownChats(ctx context.Context, botID int64) ([]knownChat, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `SELECT chat_id, title, type FROM bot_chats WHERE bot_id = ? ORDER BY chat_id`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []knownChat
+	for rows.Next() {
+		var c knownChat
+		if err := rows.Scan(&c.ID, &c.Title, &c.Type); err != nil {
+			return nil, err
+		}
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+// touchChat registers a group chat at most once per chatTouchInterval
+func (b *Bot) touchChat(ctx context.Context, chat *tgbotapi.Chat) {
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		return
+	}
+	if last, ok := b.seenChats.Load(chat.ID); ok && time.Since(last.(time.Time)) < chatTouchInterval {
+		return
+	}
+	if err := b.app.db.TouchChat(ctx, b.api.Self.ID, chat); err != nil {
+		b.logf("Failed to record chat %d: %v", chat.ID, err)
+		return
+	}
+	b.seenChats.Store(chat.ID, time.Now())
+}
+
+// forgetChat drops a chat from the registry
+func (b *Bot) forgetChat(ctx context.Context, chatID int64) {
+	b.seenChats.Delete(chatID)
+	if err := b.app.db.ForgetChat(ctx, b.api.Self.ID, chatID); err != nil {
+		b.logf("Failed to forget chat %d: %v", chatID, err)
+	}
+}
+
+// errBotNotInChat means the bot was removed from the chat, so it should be forgotten
+var errBotNotInChat = errors.New("bot is not a member of the chat")
+
+// missingPermissions returns the catalog keys of the moderation rights the bot lacks in chatID
+func (b *Bot) missingPermissions(ctx context.Context, chatID int64) ([]string, error) {
+	member, err := b.getChatMember(ctx, chatID, b.api.Self.ID)
+	if err != nil {
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403) {
+			return nil, errBotNotInChat
+		}
+		return nil, err
+	}
+
+	switch member.Status {
+	case "left", "kicked":
+		return nil, errBotNotInChat
+	case "creator":
+		return nil, nil
+	}
+
+	var missing []string
+	if member.Status != "administrator" {
+		missing = append(missing, "permission.admin")
+	} else {
+		if !member.CanDeleteMessages {
+			missing = append(missing, "permission.delete_messages")
+		}
+		if !member.CanRestrictMembers {
+			missing = append(missing, "permission.ban_users")
+		}
+	}
+	return missing, nil
+}
+
+// permissionNames lists missing permissions by the names Telegram shows admins in locale
+func permissionNames(locale string, missing []string) string {
+	names := make([]string, len(missing))
+	for i, key := range missing {
+		names[i] = translate(locale, key)
+	}
+	return strings.Join(names, ", ")
+}
+
+// permissionWarning is the notice posted to a chat where the bot can't moderate
+func (b *Bot) permissionWarning(ctx context.Context, chatID int64, missing []string) string {
+	locale := b.app.settings.Get(ctx, chatID, settingLocale)
+	return translate(locale, "permission.warning", permissionNames(locale, missing))
+}
+
+// checkAllPermissions verifies every known chat and warns admins where rights are missing
+func (b *Bot) checkAllPermissions(ctx context.Context) {
+	chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list known chats: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		b.checkChatPermissions(ctx, chat.ID, chat.Title)
+	}
+}
+
+// checkChatPermissions checks one chat, posting a warning if rights are missing
+func (b *Bot) checkChatPermissions(ctx context.Context, chatID int64, title string) {
+	missing, err := b.missingPermissions(ctx, chatID)
+	if err == errBotNotInChat {
+		b.logf("No longer in chat %s (%d), forgetting it", title, chatID)
+		b.forgetChat(ctx, chatID)
+		return
+	}
+	if err != nil {
+		b.logf("Failed to check permissions in chat %s (%d): %v", title, chatID, err)
+		return
+	}
+	if len(missing) > 0 {
+		b.logf("Missing permissions in chat %s (%d): %s", title, chatID, permissionNames("en", missing))
+		b.outbox.enqueue(tgbotapi.NewMessage(chatID, b.permissionWarning(ctx, chatID, missing)))
+	}
+}
+
+// handleMyChatMember tracks the bot being added to, promoted in, or removed from a chat
+func (b *Bot) handleMyChatMember(ctx context.Context, update *tgbotapi.ChatMemberUpdated) {
+	b.admins.forget(update.Chat.ID)
+	b.rights.forget(update.Chat.ID)
+	if update.OldChatMember.Status == "administrator" && update.NewChatMember.Status != "administrator" {
+		b.app.reporter.Event("lost_admin", fmt.Sprintf("@%s lost its admin rights in %s (%d), now %s",
+			b.api.Self.UserName, update.Chat.Title, update.Chat.ID, update.NewChatMember.Status),
+			ErrorContext{Bot: b.api.Self.UserName, ChatID: update.Chat.ID})
+	}
+	switch update.NewChatMember.Status {
+	case "left", "kicked":
+		b.logf("Removed from chat %s (%d)", update.Chat.Title, update.Chat.ID)
+		b.forgetChat(ctx, update.Chat.ID)
+	default:
+		b.logf("Membership in chat %s (%d) is now %s", update.Chat.Title, update.Chat.ID, update.NewChatMember.Status)
+		b.seenChats.Delete(update.Chat.ID)
+		b.touchChat(ctx, &update.Chat)
+		b.checkChatPermissions(ctx, update.Chat.ID, update.Chat.Title)
+	}
+}
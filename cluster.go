@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// leaseTTL is how long a poll lease stays valid without renewal
+	leaseTTL = 30 * time.Second
+	// updateRetention is how long processed update ids are kept for dedup
+	updateRetention = 24 * time.Hour
+)
+
+// newInstanceID builds a lease holder name that is unique per process
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+// AcquireLease takes the named lease, or renews it if holder already owns it.
+// Returns false while another live holder owns it.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < ?
+	`, name, holder, now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ReleaseLease gives up the lease so a standby instance can take over immediately
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	_, err := s.ExecContext(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+	return err
+}
+
+// ClaimUpdate marks an update as processed; false means some instance already handled it
+func (s *Store) ClaimUpdate(ctx context.Context, botID int64, updateID int) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO processed_updates (bot_id, update_id, processed_at) VALUES (?, ?, ?)
+		ON CONFLICT(bot_id, update_id) DO NOTHING
+	`, botID, updateID, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// PruneUpdates forgets processed update ids older than the retention window
+func (s *Store) PruneUpdates(ctx context.Context) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	_, err := s.ExecContext(ctx, `DELETE FROM processed_updates WHERE processed_at < ?`,
+		time.Now().Add(-updateRetention).Unix())
+	return err
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isForMe reports whether a command is addressed to this bot; "/cmd@otherbot" is not
+func (b *Bot) isForMe(message *Message) bool {
+	_, at, found := strings.Cut(message.CommandWithAt(), "@")
+	return !found || strings.EqualFold(at, b.api.Self.UserName)
+}
+
+// isOwner reports whether the sender is the configured bot owner
+func (b *Bot) isOwner(message *Message) bool {
+	ownerID := b.app.Config().OwnerID
+	return ownerID != 0 && message.From.ID == ownerID
+}
+
+// reply queues a plain-text answer in the message's chat and forum topic
+func (b *Bot) reply(message *Message, text string) {
+	if thread := message.ThreadID(); thread != 0 {
+		b.outbox.enqueueRaw(message.Chat.ID, "sendMessage", tgbotapi.Params{
+			"chat_id":           strconv.FormatInt(message.Chat.ID, 10),
+			"message_thread_id": strconv.Itoa(thread),
+			"text":              text,
+		})
+		return
+	}
+	b.outbox.enqueue(tgbotapi.NewMessage(message.Chat.ID, text))
+}
+
+// handleCommand runs a bot command; isAdmin is true for chat admins in groups
+func (b *Bot) handleCommand(ctx context.Context, message *Message, isAdmin bool) {
+	if !b.isForMe(message) {
+		return
+	}
+
+	switch message.Command() {
+	case "start":
+		if arg := message.CommandArguments(); message.Chat.Type == "private" && strings.HasPrefix(arg, verifyStartPrefix) {
+			b.startVerification(ctx, message, arg)
+			return
+		} else if message.Chat.Type == "private" && strings.HasPrefix(arg, wallStartPrefix) {
+			b.startWallDraft(ctx, message, arg)
+			return
+		} else if message.Chat.Type == "private" && strings.HasPrefix(arg, appealStartPrefix) {
+			b.cmdAppeal(ctx, message, arg)
+			return
+		}
+		b.reply(message, b.trFor(ctx, message, "start.help"))
+	case "verify":
+		b.cmdVerify(ctx, message)
+	case "wall":
+		b.cmdWall(ctx, message)
+	case "report":
+		if message.Chat.Type != "private" {
+			b.cmdReport(ctx, message)
+		}
+	case "price":
+		b.cmdPrice(ctx, message)
+	case "appeal":
+		if message.Chat.Type == "private" {
+			b.cmdAppeal(ctx, message, message.CommandArguments())
+		} else if isAdmin && b.app.flags.Enabled(ctx, message.Chat.ID, flagBanBonds) {
+			b.reply(message, b.trFor(ctx, message, "appeal.link", b.appealLink(message.Chat.ID)))
+		}
+	case "bond":
+		if message.Chat.Type == "private" {
+			b.cmdBond(ctx, message)
+		}
+	case "tip":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdTip(ctx, message)
+	case "status":
+		b.reply(message, b.trFor(ctx, message, "status.active"))
+	case "reload":
+		if !b.isOwner(message) {
+			return
+		}
+		reply := b.trFor(ctx, message, "reload.done")
+		if err := b.app.Reload(); err != nil {
+			reply = b.trFor(ctx, message, "reload.failed", err)
+		}
+		b.reply(message, reply)
+	case "unsuspect":
+		if b.isOwner(message) {
+			b.cmdUnsuspect(ctx, message)
+		}
+	case "features":
+		if !isAdmin && !b.isOwner(message) {
+			return
+		}
+		b.reply(message, b.trFor(ctx, message, "features.list", b.app.flags.Describe(ctx, message.Chat.ID)))
+	case "feature":
+		b.cmdFeature(ctx, message, isAdmin)
+	case "settings":
+		if !isAdmin && !b.isOwner(message) {
+			return
+		}
+		b.reply(message, b.trFor(ctx, message, "settings.list", b.app.settings.Describe(ctx, message.Chat.ID)))
+	case "set":
+		if !isAdmin && !b.isOwner(message) {
+			return
+		}
+		b.cmdSet(ctx, message)
+	case "topic":
+		if !isAdmin && !b.isOwner(message) {
+			return
+		}
+		b.cmdTopic(ctx, message)
+	case "rules":
+		if message.Chat.Type != "private" {
+			b.cmdRules(ctx, message)
+		}
+	case "setrules":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdSetRules(ctx, message)
+	case "pinrules", "unpinrules":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		if message.Command() == "pinrules" {
+			b.cmdPinRules(ctx, message)
+		} else {
+			b.cmdUnpinRules(ctx, message)
+		}
+	case "event":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdEvent(ctx, message)
+	case "checkperms":
+		if !isAdmin && !b.isOwner(message) {
+			return
+		}
+		b.cmdCheckPerms(ctx, message)
+	case "stats":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdStats(ctx, message)
+	case "notspam":
+		if message.Chat.Type == "private" || (!isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdNotSpam(ctx, message)
+	case "precision":
+		if (message.Chat.Type == "private" && !b.isOwner(message)) || (message.Chat.Type != "private" && !isAdmin && !b.isOwner(message)) {
+			return
+		}
+		b.cmdPrecision(ctx, message)
+	}
+}
+
+// handleCallback dispatches inline button presses by their "<kind>:" data prefix
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		return
+	}
+	kind, args, _ := strings.Cut(query.Data, ":")
+	switch kind {
+	case "join":
+		b.handleJoinCallback(ctx, query, args)
+	case "flag":
+		b.handleFlagCallback(ctx, query, args)
+	case "wall":
+		b.handleWallCallback(ctx, query, args)
+	case "report":
+		b.handleReportCallback(ctx, query, args)
+	case "bond":
+		b.handleBondCallback(ctx, query, args)
+	case "captcha":
+		b.handleCaptchaCallback(ctx, query, args)
+	case "unban":
+		b.handleUnbanCallback(ctx, query, args)
+	case "dispute":
+		b.handleDisputeCallback(ctx, query, args)
+	default:
+		b.request(ctx, tgbotapi.NewCallback(query.ID, ""))
+	}
+}
+
+// cmdCheckPerms reports the bot's moderation rights; in private the owner gets every known chat
+func (b *Bot) cmdCheckPerms(ctx context.Context, message *Message) {
+	if message.Chat.Type == "private" {
+		chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+		if err != nil {
+			b.reply(message, b.trFor(ctx, message, "checkperms.list_failed", err))
+			return
+		}
+		var report strings.Builder
+		for _, chat := range chats {
+			missing, err := b.missingPermissions(ctx, chat.ID)
+			switch {
+			case err == errBotNotInChat:
+				b.forgetChat(ctx, chat.ID)
+				continue
+			case err != nil:
+				report.WriteString(b.trFor(ctx, message, "checkperms.chat_failed", chat.Title, err) + "\n")
+			case len(missing) > 0:
+				report.WriteString(b.trFor(ctx, message, "checkperms.chat_missing", chat.Title, permissionNames(b.messageLocale(ctx, message), missing)) + "\n")
+			default:
+				report.WriteString(b.trFor(ctx, message, "checkperms.chat_ok", chat.Title) + "\n")
+			}
+		}
+		if report.Len() == 0 {
+			report.WriteString(b.trFor(ctx, message, "checkperms.no_chats"))
+		}
+		b.reply(message, report.String())
+		return
+	}
+
+	missing, err := b.missingPermissions(ctx, message.Chat.ID)
+	switch {
+	case err != nil:
+		b.reply(message, b.trFor(ctx, message, "checkperms.failed", err))
+	case len(missing) > 0:
+		b.reply(message, b.permissionWarning(ctx, message.Chat.ID, missing))
+	default:
+		b.reply(message, b.trFor(ctx, message, "checkperms.ok"))
+	}
+}
+
+// cmdSet handles "/set <key> <value|default>"
+func (b *Bot) cmdSet(ctx context.Context, message *Message) {
+	key, value, ok := strings.Cut(strings.TrimSpace(message.CommandArguments()), " ")
+	value = strings.TrimSpace(value)
+	if !ok || key == "" || value == "" {
+		b.reply(message, b.trFor(ctx, message, "set.usage"))
+		return
+	}
+
+	var err error
+	if value == "default" {
+		err = b.app.settings.Reset(ctx, message.Chat.ID, key)
+	} else if err = b.checkWallTarget(ctx, key, value, message.From.ID); err == nil {
+		err = b.app.settings.Set(ctx, message.Chat.ID, key, value)
+	}
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "set.failed", err))
+		return
+	}
+	if knownSettings[key].Secret {
+		// Keep the value out of the chat history and the logs
+		b.request(ctx, tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID))
+		value = "(secret)"
+	}
+	b.bus.publish(ctx, SettingChanged{ChatID: message.Chat.ID, Key: key, Value: value, By: message.From.UserName})
+	b.reply(message, b.trFor(ctx, message, "set.done", key))
+}
+
+// cmdTopic handles "/topic" and "/topic set <key> <value|default>" inside a forum topic;
+// topic values override the chat-wide ones from /set
+func (b *Bot) cmdTopic(ctx context.Context, message *Message) {
+	thread := message.ThreadID()
+	if thread == 0 {
+		b.reply(message, b.trFor(ctx, message, "topic.outside"))
+		return
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		overrides := b.app.settings.DescribeTopic(ctx, message.Chat.ID, thread)
+		if overrides == "" {
+			overrides = b.trFor(ctx, message, "topic.no_overrides") + "\n"
+		}
+		b.reply(message, b.trFor(ctx, message, "topic.list", overrides))
+		return
+	}
+	if len(args) != 3 || args[0] != "set" {
+		b.reply(message, b.trFor(ctx, message, "topic.usage"))
+		return
+	}
+
+	key, value := args[1], args[2]
+	var err error
+	if value == "default" {
+		err = b.app.settings.ResetTopic(ctx, message.Chat.ID, thread, key)
+	} else if err = b.checkWallTarget(ctx, key, value, message.From.ID); err == nil {
+		err = b.app.settings.SetTopic(ctx, message.Chat.ID, thread, key, value)
+	}
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "set.failed", err))
+		return
+	}
+	b.bus.publish(ctx, SettingChanged{ChatID: message.Chat.ID, ThreadID: thread, Key: key, Value: value, By: message.From.UserName})
+	b.reply(message, b.trFor(ctx, message, "topic.done", key))
+}
+
+// cmdFeature handles "/feature [global] <name> on|off|default".
+// Chat admins change the AdminToggle flags of their chat; "global" and the
+// other flags are reserved for the owner.
+func (b *Bot) cmdFeature(ctx context.Context, message *Message, isAdmin bool) {
+	args := strings.Fields(message.CommandArguments())
+	chatID := message.Chat.ID
+	if len(args) > 0 && args[0] == "global" {
+		if !b.isOwner(message) {
+			return
+		}
+		chatID, args = globalFlagChat, args[1:]
+	} else if !isAdmin && !b.isOwner(message) {
+		return
+	}
+	if len(args) != 2 {
+		b.reply(message, b.trFor(ctx, message, "feature.usage"))
+		return
+	}
+
+	name, value := args[0], strings.ToLower(args[1])
+	if flag, ok := knownFlags[name]; ok && !flag.AdminToggle && !b.isOwner(message) {
+		b.reply(message, b.trFor(ctx, message, "feature.owner_only", name))
+		return
+	}
+	var err error
+	if value == "default" {
+		err = b.app.flags.Clear(ctx, chatID, name)
+	} else {
+		err = b.app.flags.Set(ctx, chatID, name, parseSwitch(value))
+	}
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "feature.failed", err))
+		return
+	}
+	b.logf("Flag %s set to %s for chat %d by %s", name, value, chatID, message.From.UserName)
+	b.reply(message, b.trFor(ctx, message, "feature.done", name, value))
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"spambot/config"
+	"spambot/detector"
+	"spambot/telegram"
+)
+
+// Config holds the runtime settings, loaded from the environment (.env)
+type Config struct {
+	EnvFile string
+	// Settings given on the command line, re-applied over every reload
+	Overrides cliOverrides
+	// One token per bot identity; all bots share storage and detection
+	Tokens []string
+	// "sqlite" (DBPath is a file) or "postgres" (DBPath is a connection URL)
+	DBDriver     string
+	DBPath       string
+	LogFile      string
+	BanThreshold int
+	OwnerID      int64
+	SpamKeywords []string
+	// Aptos addresses always treated as scams (see scam_address_policy)
+	AptosScamAddresses []string
+	// Extra lures that, with a link, count as crypto scams (see crypto_scam_policy)
+	CryptoScamPhrases []string
+	// JSON file of extra detector rules (see rulepacks.go), loaded into Rules
+	RulesFile string
+	Rules     []detector.Check
+	// Where to POST every bus event as JSON (see bus.go); empty disables it
+	EventWebhookURL string
+	// YAML file of moderation rules (see yamlrules.go), compiled into YAMLRules
+	RulesYAML string
+	YAMLRules []yamlRule
+	// Directory of Lua moderation scripts (see luarules.go), compiled into
+	// LuaRules by chat id, luaAllChats for all.lua
+	LuaRulesDir string
+	LuaRules    map[int64]*lua.FunctionProto
+	// Global feature flag defaults, "name" or "name=false"
+	FeatureFlags []string
+	// Global defaults for per-chat settings, keyed by setting name
+	SettingDefaults map[string]string
+	// How often the .env file is checked for changes; 0 disables watching
+	WatchInterval time.Duration
+	// Cluster mode: instances sharing a database elect one poller per bot and dedup updates
+	Cluster    bool
+	InstanceID string
+	// Optional error reporting; failures are reported after ErrorReportThreshold repeats
+	SentryDSN            string
+	ErrorWebhookURL      string
+	ErrorReportThreshold int
+	// Slack incoming webhook for high-severity events, and which of slackEvents it gets
+	SlackWebhookURL string
+	SlackEvents     []string
+	// SMTP server (host:port) that mails the emailAlerts conditions in EmailAlerts to AlertEmails
+	SMTPAddr     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+	AlertEmails  []string
+	EmailAlerts  []string
+	// Address for the /debug/vars metrics endpoint; empty disables it
+	MetricsAddr string
+	// External alert ingestion: POST /alerts/<route> with "Authorization: Bearer
+	// <AlertToken>". AlertRoutes are name=chat_id[/topic_id]; in run mode the
+	// endpoint listens on AlertAddr, in webhook mode next to the updates.
+	AlertAddr   string
+	AlertToken  string
+	AlertRoutes []string
+	// Web dashboard where chat admins sign in with Telegram (the bot's domain
+	// must be set with BotFather's /setdomain). DashboardSecret signs sessions and
+	// enables it; in run mode it listens on DashboardAddr, in webhook mode next to
+	// the updates.
+	DashboardAddr   string
+	DashboardSecret string
+	// Detection and moderation records for other services (proto/moderation.proto),
+	// authenticated with "authorization: Bearer <ModerationToken>": in run mode
+	// over gRPC on ModerationAddr, in webhook mode as JSON at
+	// /moderation.v1.Moderation/<Method> next to the updates.
+	ModerationAddr  string
+	ModerationToken string
+	// Service account key file (JSON) the google_sheet export signs in with;
+	// empty disables the export
+	GoogleSheetsCredentials string
+	// Read-only JSON stats of chats that set public_stats, at /stats/; in run
+	// mode on StatsAddr, in webhook mode always next to the updates
+	StatsAddr string
+	// CAS (Combot Anti-Spam) API base URL; "off" disables lookups
+	CASAPIURL string
+	// CoinGecko-compatible API for /price and its optional demo API key; "off" disables /price
+	PriceAPIURL string
+	PriceAPIKey string
+	// Aptos fullnode REST API for wallet balance checks; "off" disables them
+	AptosNodeURL string
+	// Aptos module ("<address>::<module>") the audit log head is published to,
+	// signed with AuditLogKey every AuditLogInterval; empty disables it
+	AuditLogModule   string
+	AuditLogKey      string
+	AuditLogInterval time.Duration
+	// Private key of the hot wallet /tip sends APT from; empty makes admins
+	// pay themselves
+	TipWalletKey string
+	// Private key of the wallet that holds ban appeal bonds, kept apart from
+	// the tip wallet; empty disables bonds
+	BondWalletKey string
+	// Most /tip may send from that wallet at once, and to one chat's
+	// reporters in 24 hours, in octas
+	TipMax      uint64
+	TipDailyCap uint64
+	// Phishing domain lists (JSON arrays or one domain per line) synced into
+	// the phishing_domains deny list every PhishingFeedInterval; "off" disables it
+	PhishingFeeds        []string
+	PhishingFeedInterval time.Duration
+	// Aptos indexer GraphQL endpoint for NFT gating; "off" disables it
+	AptosIndexerURL string
+	// Shared secret Telegram echoes in X-Telegram-Bot-Api-Secret-Token for
+	// webhooks; webhook mode refuses to start without it
+	WebhookSecret string
+	// Bot API server base URL, e.g. a self-hosted telegram-bot-api instance
+	TelegramAPIURL string
+	// File download base URL; defaults to TelegramAPIURL + /file
+	TelegramFileURL string
+	// Local Bot API server running with --local: file paths are readable from disk
+	TelegramLocalFiles bool
+	// Talk to Telegram's test DC; test accounts and tokens are separate from production
+	TelegramTestEnv bool
+	// What to do with spam sent to the bot in private: report, suspect, block
+	PrivateSpamActions []string
+	// Messages older than this (e.g. a backlog after downtime) are not punished;
+	// StaleMessageAction is "log" (leave them) or "delete" (delete without a strike)
+	MaxMessageAge      time.Duration
+	StaleMessageAction string
+	// Per-operation timeouts
+	TelegramTimeout time.Duration
+	DBTimeout       time.Duration
+	UpdateTimeout   time.Duration
+}
+
+// cliOverrides are the settings command-line flags replace; nil fields were
+// not given
+type cliOverrides struct {
+	DBPath          *string
+	LogFile         *string
+	BanThreshold    *int
+	TelegramTestEnv bool
+}
+
+// apply sets the overridden settings in cfg and keeps them for its reloads
+func (o cliOverrides) apply(cfg *Config) {
+	cfg.Overrides = o
+	if o.DBPath != nil {
+		cfg.DBPath = *o.DBPath
+	}
+	if o.LogFile != nil {
+		cfg.LogFile = *o.LogFile
+	}
+	if o.BanThreshold != nil {
+		cfg.BanThreshold = *o.BanThreshold
+	}
+	if o.TelegramTestEnv {
+		cfg.TelegramTestEnv = true
+	}
+}
+
+// LoadConfig reads the .env file (if present) and resolves settings from the environment.
+// The process environment is not modified, so the file can be re-read on reload.
+func LoadConfig(envFile string) (*Config, error) {
+	env, err := config.Read(envFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		EnvFile:       envFile,
+		Tokens:        env.GetList("TELEGRAM_BOT_TOKENS", env.GetList("TELEGRAM_BOT_TOKEN", nil)),
+		DBDriver:      env.GetDefault("DB_DRIVER", "sqlite"),
+		DBPath:        env.GetDefault("DB_PATH", "spambot.db"),
+		LogFile:       env.GetDefault("LOG_FILE", "bot.log"),
+		BanThreshold:  env.GetInt("BAN_THRESHOLD", 3),
+		OwnerID:       int64(env.GetInt("OWNER_ID", 0)),
+		SpamKeywords:  env.GetList("SPAM_KEYWORDS", detector.DefaultSpamKeywords),
+		FeatureFlags:  env.GetList("FEATURE_FLAGS", nil),
+		WatchInterval: time.Duration(env.GetInt("CONFIG_WATCH_INTERVAL", 10)) * time.Second,
+		Cluster:       env.GetBool("CLUSTER", false),
+		InstanceID:    env.GetDefault("INSTANCE_ID", newInstanceID()),
+
+		SentryDSN:               env.Get("SENTRY_DSN"),
+		ErrorWebhookURL:         env.Get("ERROR_WEBHOOK_URL"),
+		ErrorReportThreshold:    env.GetInt("ERROR_REPORT_THRESHOLD", 5),
+		SlackWebhookURL:         env.Get("SLACK_WEBHOOK_URL"),
+		SlackEvents:             env.GetList("SLACK_EVENTS", slackEvents),
+		SMTPAddr:                env.Get("SMTP_ADDR"),
+		SMTPUser:                env.Get("SMTP_USER"),
+		SMTPPassword:            env.Get("SMTP_PASSWORD"),
+		SMTPFrom:                env.Get("SMTP_FROM"),
+		AlertEmails:             env.GetList("ALERT_EMAILS", nil),
+		EmailAlerts:             env.GetList("EMAIL_ALERTS", emailAlerts),
+		MetricsAddr:             env.Get("METRICS_ADDR"),
+		AlertAddr:               env.Get("ALERT_ADDR"),
+		AlertToken:              env.Get("ALERT_TOKEN"),
+		AlertRoutes:             env.GetList("ALERT_ROUTES", nil),
+		DashboardAddr:           env.Get("DASHBOARD_ADDR"),
+		DashboardSecret:         env.Get("DASHBOARD_SECRET"),
+		ModerationAddr:          env.Get("MODERATION_ADDR"),
+		ModerationToken:         env.Get("MODERATION_TOKEN"),
+		StatsAddr:               env.Get("STATS_ADDR"),
+		GoogleSheetsCredentials: env.Get("GOOGLE_SHEETS_CREDENTIALS"),
+		WebhookSecret:           env.Get("WEBHOOK_SECRET"),
+		CASAPIURL:               strings.TrimSuffix(env.GetDefault("CAS_API_URL", "https://api.cas.chat"), "/"),
+		PriceAPIURL:             strings.TrimSuffix(env.GetDefault("PRICE_API_URL", "https://api.coingecko.com/api/v3"), "/"),
+		PriceAPIKey:             env.Get("PRICE_API_KEY"),
+		AptosNodeURL:            strings.TrimSuffix(env.GetDefault("APTOS_NODE_URL", "https://fullnode.mainnet.aptoslabs.com/v1"), "/"),
+		AptosIndexerURL:         env.GetDefault("APTOS_INDEXER_URL", "https://api.mainnet.aptoslabs.com/v1/graphql"),
+		AptosScamAddresses:      env.GetList("APTOS_SCAM_ADDRESSES", nil),
+		CryptoScamPhrases:       env.GetList("CRYPTO_SCAM_PHRASES", nil),
+		RulesFile:               env.Get("RULES_FILE"),
+		RulesYAML:               env.Get("RULES_YAML"),
+		LuaRulesDir:             env.Get("LUA_RULES_DIR"),
+		EventWebhookURL:         env.Get("EVENT_WEBHOOK_URL"),
+		AuditLogModule:          env.Get("AUDIT_LOG_MODULE"),
+		AuditLogKey:             env.Get("AUDIT_LOG_KEY"),
+		AuditLogInterval:        time.Duration(env.GetInt("AUDIT_LOG_INTERVAL", 3600)) * time.Second,
+		TipWalletKey:            env.Get("TIP_WALLET_KEY"),
+		BondWalletKey:           env.Get("BOND_WALLET_KEY"),
+		PhishingFeeds:           env.GetList("PHISHING_FEEDS", defaultPhishingFeeds),
+		PhishingFeedInterval:    time.Duration(env.GetInt("PHISHING_FEED_INTERVAL", 21600)) * time.Second,
+
+		TelegramAPIURL:     strings.TrimSuffix(env.GetDefault("TELEGRAM_API_URL", "https://api.telegram.org"), "/"),
+		TelegramFileURL:    strings.TrimSuffix(env.Get("TELEGRAM_FILE_URL"), "/"),
+		TelegramLocalFiles: env.GetBool("TELEGRAM_LOCAL_FILES", false),
+		TelegramTestEnv:    env.GetBool("TELEGRAM_TEST_ENV", false),
+
+		PrivateSpamActions: env.GetList("PRIVATE_SPAM_ACTIONS", []string{"report", "suspect"}),
+		MaxMessageAge:      time.Duration(env.GetInt("MAX_MESSAGE_AGE", 600)) * time.Second,
+		StaleMessageAction: env.GetDefault("STALE_MESSAGE_ACTION", "log"),
+
+		TelegramTimeout: time.Duration(env.GetInt("TELEGRAM_TIMEOUT", 10)) * time.Second,
+		DBTimeout:       time.Duration(env.GetInt("DB_TIMEOUT", 5)) * time.Second,
+		UpdateTimeout:   time.Duration(env.GetInt("UPDATE_TIMEOUT", 30)) * time.Second,
+	}
+	if len(cfg.PhishingFeeds) == 1 && cfg.PhishingFeeds[0] == "off" {
+		cfg.PhishingFeeds = nil
+	}
+	cfg.SettingDefaults = make(map[string]string)
+	for key, setting := range knownSettings {
+		if v := env.Get(setting.envKey()); v != "" {
+			if err := setting.validate(v); err != nil {
+				return nil, fmt.Errorf("%s: %v", setting.envKey(), err)
+			}
+			cfg.SettingDefaults[key] = v
+		}
+	}
+
+	if cfg.BanThreshold < 1 {
+		return nil, fmt.Errorf("BAN_THRESHOLD must be at least 1, got %d", cfg.BanThreshold)
+	}
+	for _, action := range cfg.PrivateSpamActions {
+		if !containsString(privateSpamActions, action) {
+			return nil, fmt.Errorf("PRIVATE_SPAM_ACTIONS: unknown action %q (use %s)", action, strings.Join(privateSpamActions, ", "))
+		}
+	}
+	if cfg.AuditLogModule != "" && cfg.AuditLogInterval < time.Minute {
+		return nil, fmt.Errorf("AUDIT_LOG_INTERVAL must be at least 60 seconds, got %d", int(cfg.AuditLogInterval/time.Second))
+	}
+	if len(cfg.PhishingFeeds) > 0 && cfg.PhishingFeedInterval < 5*time.Minute {
+		return nil, fmt.Errorf("PHISHING_FEED_INTERVAL must be at least 300 seconds, got %d", int(cfg.PhishingFeedInterval/time.Second))
+	}
+	if cfg.TipMax, err = parseAPT(env.GetDefault("TIP_MAX_APT", "1")); err != nil {
+		return nil, fmt.Errorf("TIP_MAX_APT: %v", err)
+	}
+	if cfg.TipDailyCap, err = parseAPT(env.GetDefault("TIP_DAILY_APT", "5")); err != nil {
+		return nil, fmt.Errorf("TIP_DAILY_APT: %v", err)
+	}
+	if cfg.RulesFile != "" {
+		rules, err := loadRules(cfg.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("RULES_FILE: %v", err)
+		}
+		cfg.Rules = rules
+	}
+	if cfg.RulesYAML != "" {
+		rules, err := loadYAMLRules(cfg.RulesYAML)
+		if err != nil {
+			return nil, fmt.Errorf("RULES_YAML: %v", err)
+		}
+		cfg.YAMLRules = rules
+	}
+	if cfg.LuaRulesDir != "" {
+		scripts, err := loadLuaRules(cfg.LuaRulesDir)
+		if err != nil {
+			return nil, fmt.Errorf("LUA_RULES_DIR: %v", err)
+		}
+		cfg.LuaRules = scripts
+	}
+	for _, event := range cfg.SlackEvents {
+		if !containsString(slackEvents, event) {
+			return nil, fmt.Errorf("SLACK_EVENTS: unknown event %q (use %s)", event, strings.Join(slackEvents, ", "))
+		}
+	}
+	for _, condition := range cfg.EmailAlerts {
+		if !containsString(emailAlerts, condition) {
+			return nil, fmt.Errorf("EMAIL_ALERTS: unknown condition %q (use %s)", condition, strings.Join(emailAlerts, ", "))
+		}
+	}
+	if cfg.SMTPAddr != "" && (cfg.SMTPFrom == "" || len(cfg.AlertEmails) == 0) {
+		return nil, fmt.Errorf("SMTP_ADDR needs SMTP_FROM and ALERT_EMAILS")
+	}
+	if (cfg.AlertAddr != "" || len(cfg.AlertRoutes) > 0) && cfg.AlertToken == "" {
+		return nil, fmt.Errorf("ALERT_ADDR and ALERT_ROUTES need ALERT_TOKEN")
+	}
+	if _, err := parseAlertRoutes(cfg.AlertRoutes); err != nil {
+		return nil, fmt.Errorf("ALERT_ROUTES: %v", err)
+	}
+	if cfg.DashboardAddr != "" && cfg.DashboardSecret == "" {
+		return nil, fmt.Errorf("DASHBOARD_ADDR needs DASHBOARD_SECRET")
+	}
+	if cfg.DashboardSecret != "" && len(cfg.DashboardSecret) < 16 {
+		return nil, fmt.Errorf("DASHBOARD_SECRET must be at least 16 characters")
+	}
+	if cfg.ModerationAddr != "" && cfg.ModerationToken == "" {
+		return nil, fmt.Errorf("MODERATION_ADDR needs MODERATION_TOKEN")
+	}
+	if cfg.StaleMessageAction != "log" && cfg.StaleMessageAction != "delete" {
+		return nil, fmt.Errorf("STALE_MESSAGE_ACTION must be log or delete, got %q", cfg.StaleMessageAction)
+	}
+	if cfg.TelegramTimeout <= 0 || cfg.DBTimeout <= 0 || cfg.UpdateTimeout <= 0 {
+		return nil, fmt.Errorf("TELEGRAM_TIMEOUT, DB_TIMEOUT and UPDATE_TIMEOUT must be positive")
+	}
+	return cfg, nil
+}
+
+// Export writes the effective configuration in .env format
+func (c *Config) Export(w io.Writer, showSecrets bool) {
+	tokens := make([]string, len(c.Tokens))
+	for i, token := range c.Tokens {
+		tokens[i] = redact(token, showSecrets)
+	}
+	fmt.Fprintf(w, "TELEGRAM_BOT_TOKENS=%s\n", strings.Join(tokens, ","))
+	fmt.Fprintf(w, "DB_DRIVER=%s\n", c.DBDriver)
+	dbPath := c.DBPath
+	if c.DBDriver == "postgres" {
+		dbPath = redact(dbPath, showSecrets)
+	}
+	fmt.Fprintf(w, "DB_PATH=%s\n", dbPath)
+	fmt.Fprintf(w, "LOG_FILE=%s\n", c.LogFile)
+	fmt.Fprintf(w, "BAN_THRESHOLD=%d\n", c.BanThreshold)
+	fmt.Fprintf(w, "OWNER_ID=%d\n", c.OwnerID)
+	fmt.Fprintf(w, "SPAM_KEYWORDS=%q\n", strings.Join(c.SpamKeywords, ","))
+	fmt.Fprintf(w, "APTOS_SCAM_ADDRESSES=%s\n", strings.Join(c.AptosScamAddresses, ","))
+	fmt.Fprintf(w, "CRYPTO_SCAM_PHRASES=%q\n", strings.Join(c.CryptoScamPhrases, ","))
+	fmt.Fprintf(w, "RULES_FILE=%s\n", c.RulesFile)
+	fmt.Fprintf(w, "RULES_YAML=%s\n", c.RulesYAML)
+	fmt.Fprintf(w, "LUA_RULES_DIR=%s\n", c.LuaRulesDir)
+	fmt.Fprintf(w, "EVENT_WEBHOOK_URL=%s\n", redact(c.EventWebhookURL, showSecrets))
+	fmt.Fprintf(w, "FEATURE_FLAGS=%s\n", strings.Join(c.FeatureFlags, ","))
+	keys := make([]string, 0, len(knownSettings))
+	for key := range knownSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := c.SettingDefaults[key]
+		if !ok {
+			value = knownSettings[key].Default
+		}
+		fmt.Fprintf(w, "%s=%s\n", knownSettings[key].envKey(), value)
+	}
+	fmt.Fprintf(w, "CONFIG_WATCH_INTERVAL=%d\n", int(c.WatchInterval/time.Second))
+	fmt.Fprintf(w, "CLUSTER=%t\n", c.Cluster)
+	fmt.Fprintf(w, "SENTRY_DSN=%s\n", redact(c.SentryDSN, showSecrets))
+	fmt.Fprintf(w, "ERROR_WEBHOOK_URL=%s\n", redact(c.ErrorWebhookURL, showSecrets))
+	fmt.Fprintf(w, "ERROR_REPORT_THRESHOLD=%d\n", c.ErrorReportThreshold)
+	fmt.Fprintf(w, "SLACK_WEBHOOK_URL=%s\n", redact(c.SlackWebhookURL, showSecrets))
+	fmt.Fprintf(w, "SLACK_EVENTS=%s\n", strings.Join(c.SlackEvents, ","))
+	fmt.Fprintf(w, "SMTP_ADDR=%s\n", c.SMTPAddr)
+	fmt.Fprintf(w, "SMTP_USER=%s\n", c.SMTPUser)
+	fmt.Fprintf(w, "SMTP_PASSWORD=%s\n", redact(c.SMTPPassword, showSecrets))
+	fmt.Fprintf(w, "SMTP_FROM=%s\n", c.SMTPFrom)
+	fmt.Fprintf(w, "ALERT_EMAILS=%s\n", strings.Join(c.AlertEmails, ","))
+	fmt.Fprintf(w, "EMAIL_ALERTS=%s\n", strings.Join(c.EmailAlerts, ","))
+	fmt.Fprintf(w, "METRICS_ADDR=%s\n", c.MetricsAddr)
+	fmt.Fprintf(w, "ALERT_ADDR=%s\n", c.AlertAddr)
+	fmt.Fprintf(w, "ALERT_TOKEN=%s\n", redact(c.AlertToken, showSecrets))
+	fmt.Fprintf(w, "ALERT_ROUTES=%s\n", strings.Join(c.AlertRoutes, ","))
+	fmt.Fprintf(w, "DASHBOARD_ADDR=%s\n", c.DashboardAddr)
+	fmt.Fprintf(w, "DASHBOARD_SECRET=%s\n", redact(c.DashboardSecret, showSecrets))
+	fmt.Fprintf(w, "MODERATION_ADDR=%s\n", c.ModerationAddr)
+	fmt.Fprintf(w, "MODERATION_TOKEN=%s\n", redact(c.ModerationToken, showSecrets))
+	fmt.Fprintf(w, "GOOGLE_SHEETS_CREDENTIALS=%s\n", c.GoogleSheetsCredentials)
+	fmt.Fprintf(w, "STATS_ADDR=%s\n", c.StatsAddr)
+	fmt.Fprintf(w, "WEBHOOK_SECRET=%s\n", redact(c.WebhookSecret, showSecrets))
+	fmt.Fprintf(w, "CAS_API_URL=%s\n", c.CASAPIURL)
+	fmt.Fprintf(w, "PRICE_API_URL=%s\n", c.PriceAPIURL)
+	fmt.Fprintf(w, "PRICE_API_KEY=%s\n", redact(c.PriceAPIKey, showSecrets))
+	fmt.Fprintf(w, "APTOS_NODE_URL=%s\n", c.AptosNodeURL)
+	fmt.Fprintf(w, "APTOS_INDEXER_URL=%s\n", c.AptosIndexerURL)
+	fmt.Fprintf(w, "AUDIT_LOG_MODULE=%s\n", c.AuditLogModule)
+	fmt.Fprintf(w, "AUDIT_LOG_KEY=%s\n", redact(c.AuditLogKey, showSecrets))
+	fmt.Fprintf(w, "AUDIT_LOG_INTERVAL=%d\n", int(c.AuditLogInterval/time.Second))
+	fmt.Fprintf(w, "TIP_WALLET_KEY=%s\n", redact(c.TipWalletKey, showSecrets))
+	fmt.Fprintf(w, "TIP_MAX_APT=%s\n", formatAPT(c.TipMax))
+	fmt.Fprintf(w, "TIP_DAILY_APT=%s\n", formatAPT(c.TipDailyCap))
+	fmt.Fprintf(w, "BOND_WALLET_KEY=%s\n", redact(c.BondWalletKey, showSecrets))
+	feeds := strings.Join(c.PhishingFeeds, ",")
+	if feeds == "" {
+		feeds = "off"
+	}
+	fmt.Fprintf(w, "PHISHING_FEEDS=%s\n", feeds)
+	fmt.Fprintf(w, "PHISHING_FEED_INTERVAL=%d\n", int(c.PhishingFeedInterval/time.Second))
+	fmt.Fprintf(w, "TELEGRAM_API_URL=%s\n", c.TelegramAPIURL)
+	fmt.Fprintf(w, "TELEGRAM_FILE_URL=%s\n", c.TelegramFileURL)
+	fmt.Fprintf(w, "TELEGRAM_LOCAL_FILES=%t\n", c.TelegramLocalFiles)
+	fmt.Fprintf(w, "TELEGRAM_TEST_ENV=%t\n", c.TelegramTestEnv)
+	fmt.Fprintf(w, "PRIVATE_SPAM_ACTIONS=%s\n", strings.Join(c.PrivateSpamActions, ","))
+	fmt.Fprintf(w, "MAX_MESSAGE_AGE=%d\n", int(c.MaxMessageAge/time.Second))
+	fmt.Fprintf(w, "STALE_MESSAGE_ACTION=%s\n", c.StaleMessageAction)
+	fmt.Fprintf(w, "TELEGRAM_TIMEOUT=%d\n", int(c.TelegramTimeout/time.Second))
+	fmt.Fprintf(w, "DB_TIMEOUT=%d\n", int(c.DBTimeout/time.Second))
+	fmt.Fprintf(w, "UPDATE_TIMEOUT=%d\n", int(c.UpdateTimeout/time.Second))
+}
+
+// apiEndpoint returns the tgbotapi endpoint format for the configured server
+func (c *Config) apiEndpoint() string {
+	return telegram.APIEndpoint(c.TelegramAPIURL, c.TelegramTestEnv)
+}
+
+// fileEndpoint returns the file download format (token, file path)
+func (c *Config) fileEndpoint() string {
+	return telegram.FileEndpoint(c.TelegramAPIURL, c.TelegramFileURL, c.TelegramTestEnv)
+}
+
+// redact hides a secret value unless showSecrets is set
+func redact(value string, showSecrets bool) string {
+	if showSecrets || value == "" {
+		return value
+	}
+	return "<redacted>"
+}
+
+// restartRequired lists settings that differ from next but only take effect on restart
+func (c *Config) restartRequired(next *Config) []string {
+	var changed []string
+	if strings.Join(c.Tokens, ",") != strings.Join(next.Tokens, ",") {
+		changed = append(changed, "TELEGRAM_BOT_TOKENS")
+	}
+	if c.DBDriver != next.DBDriver || c.DBPath != next.DBPath {
+		changed = append(changed, "DB_DRIVER/DB_PATH")
+	}
+	if c.Cluster != next.Cluster {
+		changed = append(changed, "CLUSTER")
+	}
+	if c.SentryDSN != next.SentryDSN || c.ErrorWebhookURL != next.ErrorWebhookURL ||
+		c.SlackWebhookURL != next.SlackWebhookURL || strings.Join(c.SlackEvents, ",") != strings.Join(next.SlackEvents, ",") {
+		changed = append(changed, "SENTRY_DSN/ERROR_WEBHOOK_URL/SLACK_WEBHOOK_URL/SLACK_EVENTS")
+	}
+	if c.SMTPAddr != next.SMTPAddr || c.SMTPUser != next.SMTPUser || c.SMTPPassword != next.SMTPPassword || c.SMTPFrom != next.SMTPFrom ||
+		strings.Join(c.AlertEmails, ",") != strings.Join(next.AlertEmails, ",") || strings.Join(c.EmailAlerts, ",") != strings.Join(next.EmailAlerts, ",") {
+		changed = append(changed, "SMTP_*/ALERT_EMAILS/EMAIL_ALERTS")
+	}
+	if c.MetricsAddr != next.MetricsAddr {
+		changed = append(changed, "METRICS_ADDR")
+	}
+	if c.AlertAddr != next.AlertAddr || c.AlertToken != next.AlertToken || strings.Join(c.AlertRoutes, ",") != strings.Join(next.AlertRoutes, ",") {
+		changed = append(changed, "ALERT_ADDR/ALERT_TOKEN/ALERT_ROUTES")
+	}
+	if c.DashboardAddr != next.DashboardAddr || c.DashboardSecret != next.DashboardSecret {
+		changed = append(changed, "DASHBOARD_ADDR/DASHBOARD_SECRET")
+	}
+	if c.ModerationAddr != next.ModerationAddr || c.ModerationToken != next.ModerationToken {
+		changed = append(changed, "MODERATION_ADDR/MODERATION_TOKEN")
+	}
+	if c.StatsAddr != next.StatsAddr {
+		changed = append(changed, "STATS_ADDR")
+	}
+	if c.GoogleSheetsCredentials != next.GoogleSheetsCredentials {
+		changed = append(changed, "GOOGLE_SHEETS_CREDENTIALS")
+	}
+	if c.TelegramAPIURL != next.TelegramAPIURL || c.TelegramTestEnv != next.TelegramTestEnv {
+		changed = append(changed, "TELEGRAM_API_URL/TELEGRAM_TEST_ENV")
+	}
+	if c.TelegramTimeout != next.TelegramTimeout || c.DBTimeout != next.DBTimeout {
+		changed = append(changed, "TELEGRAM_TIMEOUT/DB_TIMEOUT")
+	}
+	if c.LogFile != next.LogFile {
+		changed = append(changed, "LOG_FILE")
+	}
+	if c.CASAPIURL != next.CASAPIURL || c.AptosNodeURL != next.AptosNodeURL || c.AptosIndexerURL != next.AptosIndexerURL {
+		changed = append(changed, "CAS_API_URL/APTOS_NODE_URL/APTOS_INDEXER_URL")
+	}
+	if c.AuditLogModule != next.AuditLogModule || c.AuditLogKey != next.AuditLogKey || c.AuditLogInterval != next.AuditLogInterval {
+		changed = append(changed, "AUDIT_LOG_*")
+	}
+	if c.PriceAPIURL != next.PriceAPIURL || c.PriceAPIKey != next.PriceAPIKey {
+		changed = append(changed, "PRICE_API_URL/PRICE_API_KEY")
+	}
+	if c.TipWalletKey != next.TipWalletKey || c.BondWalletKey != next.BondWalletKey {
+		changed = append(changed, "TIP_WALLET_KEY/BOND_WALLET_KEY")
+	}
+	if strings.Join(c.PhishingFeeds, ",") != strings.Join(next.PhishingFeeds, ",") || c.PhishingFeedInterval != next.PhishingFeedInterval {
+		changed = append(changed, "PHISHING_FEEDS/PHISHING_FEED_INTERVAL")
+	}
+	if c.WatchInterval != next.WatchInterval {
+		changed = append(changed, "CONFIG_WATCH_INTERVAL")
+	}
+	return changed
+}
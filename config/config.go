@@ -0,0 +1,105 @@
+// Package config reads settings the way the bot does: from the process
+// environment, falling back to a .env file that is read without modifying
+// the environment, so the file can be re-read on reload and watched for
+// changes. Which settings exist and what they mean is up to the caller.
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Env resolves settings: real environment variables win over the .env file
+type Env map[string]string
+
+// Read loads the .env file at path; a missing file leaves only the environment
+func Read(path string) (Env, error) {
+	file, err := godotenv.Read(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load %s: %v", path, err)
+	}
+	return Env(file), nil
+}
+
+// Get returns key's value, "" if unset
+func (e Env) Get(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return e[key]
+}
+
+// GetDefault returns key's value, def if unset
+func (e Env) GetDefault(key, def string) string {
+	if v := e.Get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// GetInt returns key's value as an integer, def if unset or not a number
+func (e Env) GetInt(key string, def int) int {
+	v := e.Get(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// GetBool returns key's value as a boolean, def if unset or not one
+func (e Env) GetBool(key string, def bool) bool {
+	v := e.Get(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// GetList returns key's comma-separated items, def if unset
+func (e Env) GetList(key string, def []string) []string {
+	v := e.Get(key)
+	if v == "" {
+		return def
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Watch polls the .env file at path and calls reload whenever its modification time changes
+func Watch(path string, interval time.Duration, reload func()) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(lastMod) {
+			continue
+		}
+		lastMod = info.ModTime()
+		log.Printf("Detected change in %s, reloading configuration", path)
+		reload()
+	}
+}
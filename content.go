@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// messageText collects every piece of user-visible text in a message for detection
+func messageText(message *Message) string {
+	var parts []string
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	add(message.Text)
+	add(message.Caption)
+	addEntityText(add, message.Text, message.Entities)
+	addEntityText(add, message.Caption, message.CaptionEntities)
+	addAttachmentText(add, message.Poll, message.Venue, message.Contact)
+
+	// Quotes and external replies: spammers embed their pitch in quoted text
+	// from another chat, which the message itself doesn't repeat
+	if quote := message.ext.Quote; quote != nil {
+		add(quote.Text)
+	}
+	if reply := message.ext.ExternalReply; reply != nil {
+		addAttachmentText(add, reply.Poll, reply.Venue, reply.Contact)
+	}
+
+	// Giveaways: the free-text prize description
+	for _, g := range []*giveaway{message.ext.Giveaway, message.ext.GiveawayWinners} {
+		if g != nil {
+			add(g.PrizeDescription)
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// entityText returns the part of text an entity covers; offsets are in UTF-16 code units
+func entityText(text string, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	end := entity.Offset + entity.Length
+	if entity.Offset < 0 || end > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset:end]))
+}
+
+// addEntityText adds what formatting hides from the raw string: link targets
+// behind text_link entities, and spoilers with their whitespace removed, since
+// spammers split domains across spoiler fragments (e.g. "t. me/ x")
+// Custom emoji need nothing extra: their alt text is part of the raw string.
+func addEntityText(add func(string), text string, entities []tgbotapi.MessageEntity) {
+	for _, entity := range entities {
+		switch entity.Type {
+		case "text_link":
+			add(entity.URL)
+		case "spoiler":
+			add(strings.Join(strings.Fields(entityText(text, entity)), ""))
+		}
+	}
+}
+
+// addAttachmentText adds the free text of polls, venues and contact cards
+func addAttachmentText(add func(string), poll *tgbotapi.Poll, venue *tgbotapi.Venue, contact *tgbotapi.Contact) {
+	// Polls and quizzes: the question, every option, and the quiz explanation
+	if poll != nil {
+		add(poll.Question)
+		for _, option := range poll.Options {
+			add(option.Text)
+		}
+		add(poll.Explanation)
+	}
+
+	// Venues: promotional titles and addresses
+	if venue != nil {
+		add(venue.Title)
+		add(venue.Address)
+	}
+
+	// Contact cards: the display name is free text
+	if contact != nil {
+		add(contact.FirstName + " " + contact.LastName)
+	}
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dashboardPathPrefix is where the dashboard is served
+const dashboardPathPrefix = "/dashboard"
+
+// dashboardCookie holds the signed session of a signed-in admin
+const dashboardCookie = "dashboard_session"
+
+// dashboardSessionTTL is how long a dashboard sign-in lasts
+const dashboardSessionTTL = 12 * time.Hour
+
+// dashboardLoginMaxAge is how old Telegram login data may be when it's presented
+const dashboardLoginMaxAge = time.Hour
+
+// dashboardChartDays is how many days the detections chart covers; moderation
+// events are only kept for moderationRetention
+const dashboardChartDays = 7
+
+// dashboardChartHeight is the height in pixels of the chart's tallest bar
+const dashboardChartHeight = 120
+
+// dashboardLogLimit caps how many moderation events a chat page lists
+const dashboardLogLimit = 100
+
+// dashboardListSettings are the keyword and domain lists, shown first
+var dashboardListSettings = []string{settingSpamKeywords, settingBlockedDomains, settingOfficialLinks, settingMentionAllowlist, settingViaBotAllowlist}
+
+// moderationEvent is one recorded moderation decision
+type moderationEvent struct {
+	UserID int64
+	Action string
+	Rule   string
+	At     time.Time
+}
+
+// ModerationLog lists chatID's most recent moderation events, newest first
+func (s *Store) ModerationLog(ctx context.Context, chatID int64, limit int) ([]moderationEvent, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT user_id, action, rule, at FROM moderation_events WHERE chat_id = ? ORDER BY at DESC LIMIT ?
+	`, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []moderationEvent
+	for rows.Next() {
+		var e moderationEvent
+		var at int64
+		if err := rows.Scan(&e.UserID, &e.Action, &e.Rule, &at); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(at, 0)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ModerationDaily counts chatID's moderation events since since by day in
+// since's location (numbered as by localDay) and action
+func (s *Store) ModerationDaily(ctx context.Context, chatID int64, since time.Time) (map[int64]map[string]int, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT (at + ?) / 86400, action, COUNT(*) FROM moderation_events WHERE chat_id = ? AND at >= ? GROUP BY (at + ?) / 86400, action
+	`, zoneOffset(since), chatID, since.Unix(), zoneOffset(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	days := make(map[int64]map[string]int)
+	for rows.Next() {
+		var day int64
+		var action string
+		var count int
+		if err := rows.Scan(&day, &action, &count); err != nil {
+			return nil, err
+		}
+		if days[day] == nil {
+			days[day] = make(map[string]int)
+		}
+		days[day][action] = count
+	}
+	return days, rows.Err()
+}
+
+// verifyTelegramLogin checks the data the Telegram Login Widget redirected
+// with: its hash is an HMAC of the other fields keyed with the bot token's SHA-256
+func verifyTelegramLogin(query url.Values, token string, now time.Time) (int64, string, error) {
+	hash := query.Get("hash")
+	if hash == "" {
+		return 0, "", errors.New("login data is not signed")
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key + "=" + query.Get(key)
+	}
+	secret := sha256.Sum256([]byte(token))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(fields, "\n")))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(hash)) {
+		return 0, "", errors.New("login data signature mismatch")
+	}
+	authDate, err := strconv.ParseInt(query.Get("auth_date"), 10, 64)
+	if err != nil || now.Sub(time.Unix(authDate, 0)) > dashboardLoginMaxAge {
+		return 0, "", errors.New("login data expired")
+	}
+	userID, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		return 0, "", errors.New("login data has no user id")
+	}
+	name := query.Get("first_name")
+	if username := query.Get("username"); username != "" {
+		name = "@" + username
+	}
+	return userID, name, nil
+}
+
+// dashboardSession is a signed-in admin
+type dashboardSession struct {
+	UserID int64
+	Name   string
+	// value is the cookie the session was read from
+	value string
+}
+
+// dashboardLocale picks the first language in the browser's Accept-Language
+// there is a catalog for
+func dashboardLocale(r *http.Request) string {
+	for _, tag := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), ";")
+		language, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := locales[language]; ok {
+			return language
+		}
+	}
+	return "en"
+}
+
+// dashboardChat is a chat a signed-in admin can manage, and the bot managing it
+type dashboardChat struct {
+	BotID int64
+	Bot   string
+	ID    int64
+	Title string
+}
+
+// dashboardDay is a column of the detections chart
+type dashboardDay struct {
+	Date         string
+	Deletes      int
+	Bans         int
+	DeleteHeight int
+	BanHeight    int
+}
+
+// dashboardSetting is a setting as the chat page edits it
+type dashboardSetting struct {
+	chatSetting
+	Value string
+}
+
+// dashboardSection groups settings under a catalog heading
+type dashboardSection struct {
+	Title    string
+	Settings []dashboardSetting
+}
+
+// dashboardPage is what the page templates render
+type dashboardPage struct {
+	Locale string
+	Prefix string
+	User   dashboardSession
+	CSRF   string
+	// Bot username for the Telegram Login Widget
+	Bot      string
+	Chats    []dashboardChat
+	Chat     dashboardChat
+	Days     []dashboardDay
+	TopRules []digestCount
+	Log      []moderationEvent
+	// FeedURL links the chat's moderation log as an Atom feed
+	FeedURL  string
+	Sections []dashboardSection
+	Notice   string
+	Error    string
+}
+
+// DashboardHandler serves a web UI where chat admins, signed in with the
+// Telegram Login Widget of the first bot, see their chats' detections and
+// moderation log and change the chats' settings. Sessions are cookies signed
+// with DASHBOARD_SECRET, so any instance can serve any request. Each chat's
+// moderation log is also an Atom feed, for readers holding its signed link.
+type DashboardHandler struct {
+	bots   []*Bot
+	secret []byte
+	pages  *template.Template
+}
+
+// newDashboardHandler returns nil when DASHBOARD_SECRET is unset
+func newDashboardHandler(bots []*Bot, cfg *Config) *DashboardHandler {
+	if cfg.DashboardSecret == "" || len(bots) == 0 {
+		return nil
+	}
+	pages := template.Must(template.New("dashboard").Funcs(template.FuncMap{
+		"tr": translate,
+		"time": func(t time.Time) string {
+			return t.UTC().Format("2006-01-02 15:04")
+		},
+	}).Parse(dashboardTemplates))
+	return &DashboardHandler{bots: bots, secret: []byte(cfg.DashboardSecret), pages: pages}
+}
+
+// sign is the hex HMAC of payload under DASHBOARD_SECRET
+func (h *DashboardHandler) sign(payload string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// startSession signs userID in with a cookie of "<id>.<expiry>.<name>.<signature>"
+func (h *DashboardHandler) startSession(w http.ResponseWriter, r *http.Request, userID int64, name string) {
+	expires := time.Now().Add(dashboardSessionTTL)
+	payload := fmt.Sprintf("%d.%d.%s", userID, expires.Unix(), url.QueryEscape(name))
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardCookie,
+		Value:    payload + "." + h.sign(payload),
+		Path:     dashboardPathPrefix,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// session reads and verifies the request's session cookie
+func (h *DashboardHandler) session(r *http.Request) (dashboardSession, bool) {
+	cookie, err := r.Cookie(dashboardCookie)
+	if err != nil {
+		return dashboardSession{}, false
+	}
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 || !hmac.Equal([]byte(h.sign(cookie.Value[:i])), []byte(cookie.Value[i+1:])) {
+		return dashboardSession{}, false
+	}
+	fields := strings.SplitN(cookie.Value[:i], ".", 3)
+	if len(fields) != 3 {
+		return dashboardSession{}, false
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return dashboardSession{}, false
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return dashboardSession{}, false
+	}
+	name, _ := url.QueryUnescape(fields[2])
+	return dashboardSession{UserID: userID, Name: name, value: cookie.Value}, true
+}
+
+// csrfToken ties the settings forms to the session they were rendered for
+func (h *DashboardHandler) csrfToken(session dashboardSession) string {
+	return h.sign("csrf:" + session.value)
+}
+
+func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Frame-Options", "DENY")
+	page := dashboardPage{Locale: dashboardLocale(r), Prefix: dashboardPathPrefix, Bot: h.bots[0].api.Self.UserName}
+	switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, dashboardPathPrefix), "/") {
+	case "":
+		session, ok := h.session(r)
+		if !ok {
+			h.render(w, "login", page)
+			return
+		}
+		page.User = session
+		page.Chats = h.adminChats(r.Context(), session.UserID)
+		h.render(w, "chats", page)
+	case "/login":
+		userID, name, err := verifyTelegramLogin(r.URL.Query(), h.bots[0].api.Token, time.Now())
+		if err != nil {
+			log.Printf("Rejected dashboard login: %v", err)
+			http.Error(w, "login failed", http.StatusForbidden)
+			return
+		}
+		h.startSession(w, r, userID, name)
+		log.Printf("User %d signed in to the dashboard", userID)
+		http.Redirect(w, r, dashboardPathPrefix+"/", http.StatusSeeOther)
+	case "/logout":
+		http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Path: dashboardPathPrefix, MaxAge: -1})
+		http.Redirect(w, r, dashboardPathPrefix+"/", http.StatusSeeOther)
+	case "/chat":
+		h.serveChat(w, r, page)
+	case "/feed":
+		h.serveFeed(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *DashboardHandler) render(w http.ResponseWriter, name string, page dashboardPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.pages.ExecuteTemplate(w, name, page); err != nil {
+		log.Printf("Failed to render dashboard page %s: %v", name, err)
+	}
+}
+
+// bot returns the configured bot with botID, or nil
+func (h *DashboardHandler) bot(botID int64) *Bot {
+	for _, bot := range h.bots {
+		if bot.api.Self.ID == botID {
+			return bot
+		}
+	}
+	return nil
+}
+
+// adminChats lists the groups of every bot that userID administers
+func (h *DashboardHandler) adminChats(ctx context.Context, userID int64) []dashboardChat {
+	var chats []dashboardChat
+	for _, bot := range h.bots {
+		known, err := bot.app.db.KnownChats(ctx, bot.api.Self.ID)
+		if err != nil {
+			bot.logf("Failed to list chats for the dashboard: %v", err)
+			continue
+		}
+		for _, chat := range known {
+			if chat.Type != "group" && chat.Type != "supergroup" {
+				continue
+			}
+			if bot.isChatAdmin(ctx, chat.ID, userID) {
+				chats = append(chats, dashboardChat{BotID: bot.api.Self.ID, Bot: bot.api.Self.UserName, ID: chat.ID, Title: chat.Title})
+			}
+		}
+	}
+	return chats
+}
+
+// knownChat finds chatID among bot's known chats
+func (h *DashboardHandler) knownChat(ctx context.Context, bot *Bot, chatID int64) (dashboardChat, bool, error) {
+	known, err := bot.app.db.KnownChats(ctx, bot.api.Self.ID)
+	if err != nil {
+		bot.logf("Failed to list chats for the dashboard: %v", err)
+		return dashboardChat{}, false, err
+	}
+	for _, chat := range known {
+		if chat.ID == chatID {
+			return dashboardChat{BotID: bot.api.Self.ID, Bot: bot.api.Self.UserName, ID: chat.ID, Title: chat.Title}, true, nil
+		}
+	}
+	return dashboardChat{}, false, nil
+}
+
+// serveChat shows a chat's detections, moderation log and settings, and
+// saves a posted setting
+func (h *DashboardHandler) serveChat(w http.ResponseWriter, r *http.Request, page dashboardPage) {
+	session, ok := h.session(r)
+	if !ok {
+		http.Redirect(w, r, dashboardPathPrefix+"/", http.StatusSeeOther)
+		return
+	}
+	ctx := r.Context()
+	botID, _ := strconv.ParseInt(r.URL.Query().Get("bot"), 10, 64)
+	chatID, _ := strconv.ParseInt(r.URL.Query().Get("chat"), 10, 64)
+	bot := h.bot(botID)
+	if bot == nil || chatID >= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	var err error
+	page.Chat, ok, err = h.knownChat(ctx, bot, chatID)
+	if err != nil {
+		http.Error(w, "failed to load chat", http.StatusInternalServerError)
+		return
+	}
+	if !ok || !bot.isChatAdmin(ctx, chatID, session.UserID) {
+		http.NotFound(w, r)
+		return
+	}
+	page.User = session
+	page.CSRF = h.csrfToken(session)
+	page.FeedURL = h.feedURL(r, botID, chatID)
+
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+		if !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(page.CSRF)) {
+			http.Error(w, "invalid form", http.StatusForbidden)
+			return
+		}
+		key, value, reset := r.PostFormValue("key"), strings.TrimSpace(r.PostFormValue("value")), r.PostFormValue("reset") != ""
+		if value == "" && !reset {
+			page.Error = translate(page.Locale, "dashboard.empty_value")
+		} else if err := h.saveSetting(ctx, bot, chatID, session, key, value, reset); err != nil {
+			page.Error = translate(page.Locale, "dashboard.failed", err.Error())
+		} else {
+			query := url.Values{"bot": {strconv.FormatInt(botID, 10)}, "chat": {strconv.FormatInt(chatID, 10)}, "saved": {key}}
+			http.Redirect(w, r, dashboardPathPrefix+"/chat?"+query.Encode(), http.StatusSeeOther)
+			return
+		}
+	} else if key := r.URL.Query().Get("saved"); key != "" {
+		page.Notice = translate(page.Locale, "dashboard.saved", key)
+	}
+
+	now := bot.app.settings.Now(ctx, chatID)
+	since := dayStart(now).AddDate(0, 0, 1-dashboardChartDays)
+	daily, err := bot.app.db.ModerationDaily(ctx, chatID, since)
+	if err != nil {
+		bot.logf("Failed to count moderation in chat %d for the dashboard: %v", chatID, err)
+	}
+	page.Days = dashboardChart(daily, since)
+	if stats, err := bot.app.db.ModerationStats(ctx, chatID, since, now); err == nil {
+		page.TopRules = stats.TopRules
+	}
+	if page.Log, err = bot.app.db.ModerationLog(ctx, chatID, dashboardLogLimit); err != nil {
+		bot.logf("Failed to list moderation in chat %d for the dashboard: %v", chatID, err)
+	}
+	page.Sections = dashboardSections(ctx, bot.app.settings, chatID)
+	h.render(w, "chat", page)
+}
+
+// saveSetting sets or resets key in chatID on behalf of the signed-in admin
+func (h *DashboardHandler) saveSetting(ctx context.Context, bot *Bot, chatID int64, session dashboardSession, key, value string, reset bool) error {
+	setting, ok := knownSettings[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	var err error
+	if reset {
+		err, value = bot.app.settings.Reset(ctx, chatID, key), "default"
+	} else if err = bot.checkWallTarget(ctx, key, value, session.UserID); err == nil {
+		err = bot.app.settings.Set(ctx, chatID, key, value)
+	}
+	if err != nil {
+		return err
+	}
+	if setting.Secret {
+		value = "(secret)"
+	}
+	bot.bus.publish(ctx, SettingChanged{ChatID: chatID, Key: key, Value: value, By: fmt.Sprintf("user %d on the dashboard", session.UserID)})
+	return nil
+}
+
+// dashboardChart lays out daily removals and bans as bars scaled to the
+// busiest day, oldest day first
+func dashboardChart(daily map[int64]map[string]int, since time.Time) []dashboardDay {
+	days := make([]dashboardDay, dashboardChartDays)
+	most := 1
+	for i := range days {
+		date := since.AddDate(0, 0, i)
+		counts := daily[localDay(date)]
+		days[i] = dashboardDay{Date: date.Format("01-02"), Deletes: counts["delete"], Bans: counts["ban"]}
+		most = max(most, days[i].Deletes, days[i].Bans)
+	}
+	for i := range days {
+		days[i].DeleteHeight = days[i].Deletes * dashboardChartHeight / most
+		days[i].BanHeight = days[i].Bans * dashboardChartHeight / most
+	}
+	return days
+}
+
+// dashboardSections groups every setting with its value in chatID: keyword
+// and domain lists, then numeric thresholds, then the rest
+func dashboardSections(ctx context.Context, settings *ChatSettings, chatID int64) []dashboardSection {
+	keys := make([]string, 0, len(knownSettings))
+	for key := range knownSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lists := dashboardSection{Title: "dashboard.section_lists"}
+	thresholds := dashboardSection{Title: "dashboard.section_thresholds"}
+	other := dashboardSection{Title: "dashboard.section_other"}
+	for _, key := range dashboardListSettings {
+		lists.Settings = append(lists.Settings, dashboardSetting{chatSetting: knownSettings[key], Value: settings.Get(ctx, chatID, key)})
+	}
+	for _, key := range keys {
+		if containsString(dashboardListSettings, key) {
+			continue
+		}
+		setting := dashboardSetting{chatSetting: knownSettings[key]}
+		// Secrets are write-only; the form only says whether one is set
+		if value := settings.Get(ctx, chatID, key); !setting.Secret || value == "" {
+			setting.Value = value
+		} else {
+			setting.Value = "(set)"
+		}
+		if setting.Numeric {
+			thresholds.Settings = append(thresholds.Settings, setting)
+		} else {
+			other.Settings = append(other.Settings, setting)
+		}
+	}
+	return []dashboardSection{lists, thresholds, other}
+}
+
+// serveDashboard serves the dashboard on addr until the listener fails
+func serveDashboard(addr string, handler *DashboardHandler) {
+	mux := http.NewServeMux()
+	mux.Handle(dashboardPathPrefix, handler)
+	mux.Handle(dashboardPathPrefix+"/", handler)
+	log.Printf("Serving the dashboard on http://%s%s/", addr, dashboardPathPrefix)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Dashboard server stopped: %v", err)
+	}
+}
+
+// dashboardTemplates are the dashboard pages; charts are plain CSS bars so
+// the pages load nothing but the Telegram Login Widget
+const dashboardTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{tr .Locale "dashboard.title"}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 960px; margin: 0 auto; padding: 1em; color: #222; }
+header { display: flex; justify-content: space-between; align-items: baseline; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: .3em .5em; border-bottom: 1px solid #eee; vertical-align: top; }
+.chart { display: flex; align-items: flex-end; gap: 1em; height: 150px; }
+.day { text-align: center; font-size: .8em; }
+.bars { display: flex; align-items: flex-end; gap: 2px; justify-content: center; }
+.bar { width: 14px; }
+.delete { background: #f0ad4e; }
+.ban { background: #d9534f; }
+.notice { background: #dff0d8; padding: .5em; }
+.error { background: #f2dede; padding: .5em; }
+.description { color: #666; font-size: .85em; }
+input[type=text], input[type=password] { width: 100%; box-sizing: border-box; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{tr .Locale "dashboard.title"}}</h1>
+{{if .User.UserID}}<span>{{tr .Locale "dashboard.signed_in" .User.Name}} · <a href="{{.Prefix}}/logout">{{tr .Locale "dashboard.sign_out"}}</a></span>{{end}}
+</header>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "login"}}{{template "header" .}}
+<p>{{tr .Locale "dashboard.sign_in"}}</p>
+<script async src="https://telegram.org/js/telegram-widget.js?22" data-telegram-login="{{.Bot}}" data-size="large" data-auth-url="{{.Prefix}}/login"></script>
+{{template "footer" .}}{{end}}
+
+{{define "chats"}}{{template "header" .}}
+<h2>{{tr .Locale "dashboard.chats"}}</h2>
+{{with .Chats}}<ul>
+{{range .}}<li><a href="{{$.Prefix}}/chat?bot={{.BotID}}&chat={{.ID}}">{{.Title}}</a> (@{{.Bot}})</li>
+{{end}}</ul>{{else}}<p>{{tr .Locale "dashboard.no_chats"}}</p>{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "chat"}}{{template "header" .}}
+<p><a href="{{.Prefix}}/">← {{tr .Locale "dashboard.back"}}</a></p>
+<h2>{{.Chat.Title}}</h2>
+{{with .Notice}}<p class="notice">{{.}}</p>{{end}}
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+
+<h3>{{tr .Locale "dashboard.detections" (len .Days)}}</h3>
+<div class="chart">
+{{range .Days}}<div class="day">
+<div class="bars"><div class="bar delete" style="height: {{.DeleteHeight}}px" title="{{.Deletes}}"></div><div class="bar ban" style="height: {{.BanHeight}}px" title="{{.Bans}}"></div></div>
+{{.Date}}<br>{{.Deletes}} / {{.Bans}}
+</div>
+{{end}}</div>
+<p><span class="bar delete">&nbsp;&nbsp;&nbsp;</span> {{tr .Locale "dashboard.deletes"}} <span class="bar ban">&nbsp;&nbsp;&nbsp;</span> {{tr .Locale "dashboard.bans"}}</p>
+{{with .TopRules}}<h3>{{tr $.Locale "dashboard.top_rules"}}</h3>
+<ul>{{range .}}<li>{{.Name}}: {{.Count}}</li>{{end}}</ul>{{end}}
+
+<h3>{{tr .Locale "dashboard.log"}}</h3>
+{{with .Log}}<table>
+<tr><th>{{tr $.Locale "dashboard.log_time"}}</th><th>{{tr $.Locale "dashboard.log_action"}}</th><th>{{tr $.Locale "dashboard.log_user"}}</th><th>{{tr $.Locale "dashboard.log_rule"}}</th></tr>
+{{range .}}<tr><td>{{time .At}}</td><td>{{.Action}}</td><td>{{.UserID}}</td><td>{{.Rule}}</td></tr>
+{{end}}</table>{{else}}<p>{{tr .Locale "dashboard.log_empty"}}</p>{{end}}
+<p class="description"><a href="{{.FeedURL}}">{{tr .Locale "dashboard.feed"}}</a> · {{tr .Locale "dashboard.feed_private"}}</p>
+
+{{range .Sections}}<h3>{{tr $.Locale .Title}}</h3>
+<table>
+{{range .Settings}}<tr><td><b>{{.Key}}</b><div class="description">{{.Description}}</div></td><td>
+<form method="post" action="{{$.Prefix}}/chat?bot={{$.Chat.BotID}}&chat={{$.Chat.ID}}">
+<input type="hidden" name="csrf" value="{{$.CSRF}}">
+<input type="hidden" name="key" value="{{.Key}}">
+{{if .Allowed}}<select name="value">{{$value := .Value}}{{range .Allowed}}<option{{if eq . $value}} selected{{end}}>{{.}}</option>{{end}}</select>
+{{else if .Numeric}}<input type="number" min="0"{{if .Max}} max="{{.Max}}"{{end}} name="value" value="{{.Value}}">
+{{else if .Secret}}<input type="password" name="value" placeholder="{{.Value}}" autocomplete="off">
+{{else}}<input type="text" name="value" value="{{.Value}}">
+{{end}}<button>{{tr $.Locale "dashboard.save"}}</button> <button name="reset" value="1">{{tr $.Locale "dashboard.reset"}}</button>
+</form></td></tr>
+{{end}}</table>
+{{end}}
+{{template "footer" .}}{{end}}
+`
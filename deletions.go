@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// deletionSweepInterval is how often due scheduled deletions are carried out
+const deletionSweepInterval = 10 * time.Second
+
+// pendingDeletion is a bot message scheduled to be removed later
+type pendingDeletion struct {
+	ChatID    int64
+	MessageID int
+}
+
+// ScheduleDeletion records that botID's message should be deleted at the given time.
+// Kept in the database so restarts and serverless invocations don't leave clutter behind.
+func (s *Store) ScheduleDeletion(ctx context.Context, botID, chatID int64, messageID int, at time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO pending_deletions (bot_id, chat_id, message_id, delete_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(bot_id, chat_id, message_id) DO UPDATE SET delete_at = excluded.delete_at
+	`, botID, chatID, messageID, at.Unix())
+	return err
+}
+
+// DueDeletions lists botID's scheduled deletions whose time has come
+func (s *Store) DueDeletions(ctx context.Context, botID int64) ([]pendingDeletion, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT chat_id, message_id FROM pending_deletions WHERE bot_id = ? AND delete_at <= ? LIMIT 100
+	`, botID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []pendingDeletion
+	for rows.Next() {
+		var d pendingDeletion
+		if err := rows.Scan(&d.ChatID, &d.MessageID); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// ClaimDeletion removes a scheduled deletion; false means another instance took it
+func (s *Store) ClaimDeletion(ctx context.Context, botID int64, d pendingDeletion) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		DELETE FROM pending_deletions WHERE bot_id = ? AND chat_id = ? AND message_id = ?
+	`, botID, d.ChatID, d.MessageID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// deleteLater schedules one of the bot's own messages for deletion after delay
+func (b *Bot) deleteLater(ctx context.Context, chatID int64, messageID int, delay time.Duration) {
+	if err := b.app.db.ScheduleDeletion(ctx, b.api.Self.ID, chatID, messageID, time.Now().Add(delay)); err != nil {
+		b.logf("Failed to schedule deletion of message %d in chat %d: %v", messageID, chatID, err)
+		b.app.reporter.Failure("db.scheduleDeletion", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID})
+	}
+}
+
+// deleteNoticeLater schedules a moderation notice the bot posted in chatID for
+// deletion after the chat's notice_delete_after, unless it keeps them
+func (b *Bot) deleteNoticeLater(ctx context.Context, chatID int64, messageID int) {
+	if delay, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingNoticeDeleteAfter)); delay > 0 {
+		b.deleteLater(ctx, chatID, messageID, time.Duration(delay)*time.Second)
+	}
+}
+
+// sweepDeletions deletes every message whose scheduled time has passed
+func (b *Bot) sweepDeletions(ctx context.Context) {
+	due, err := b.app.db.DueDeletions(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list scheduled deletions: %v", err)
+		return
+	}
+	for _, d := range due {
+		if claimed, err := b.app.db.ClaimDeletion(ctx, b.api.Self.ID, d); err != nil || !claimed {
+			continue
+		}
+		if _, err := b.request(ctx, tgbotapi.NewDeleteMessage(d.ChatID, d.MessageID)); err != nil {
+			// Usually already deleted by an admin; nothing to retry
+			b.logf("Failed to delete scheduled message %d in chat %d: %v", d.MessageID, d.ChatID, err)
+		}
+	}
+}
+
+// runDeletions sweeps scheduled deletions and action retries until ctx is
+// cancelled, pruning old flag reactions along the way
+func (b *Bot) runDeletions(ctx context.Context) {
+	lastPrune := time.Now()
+	for sleepContext(ctx, deletionSweepInterval) {
+		b.sweepDeletions(ctx)
+		b.sweepRetries(ctx)
+		if time.Since(lastPrune) > time.Hour {
+			if err := b.app.db.PruneReactions(ctx); err != nil {
+				b.logf("Failed to prune reactions: %v", err)
+			}
+			if err := b.app.db.PruneIntents(ctx); err != nil {
+				b.logf("Failed to prune action intents: %v", err)
+			}
+			lastPrune = time.Now()
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"spambot/detector"
+)
+
+// SpamDetector holds spam detection rules
+type SpamDetector struct {
+	// Current rule set, swapped atomically on reload
+	rules atomic.Pointer[detectorRules]
+	// Database connection
+	db *Store
+	// Gates experimental rules per chat
+	flags *FeatureFlags
+	// Per-chat and per-topic rule settings
+	settings *ChatSettings
+	deps     detectorDeps
+}
+
+// detectorDeps are the detector's replaceable dependencies, e.g. by tests;
+// zero fields are the real ones
+type detectorDeps struct {
+	// Engine builds the detector.Detector of each rule set; detector.New if nil
+	Engine func(opts detector.Options) detector.Detector
+	// Now is the clock the rules see
+	Now func() time.Time
+	// Client makes the rules' HTTP requests
+	Client *http.Client
+}
+
+// detectorRules is an immutable snapshot of the detection settings
+type detectorRules struct {
+	// engine is the built-in detector, for the link and crypto scam helpers
+	engine *detector.Engine
+	// detect classifies messages: the engine, or the one deps.Engine built
+	detect       detector.Detector
+	banThreshold int
+}
+
+func NewSpamDetector(db *Store, flags *FeatureFlags, settings *ChatSettings, cfg *Config, deps detectorDeps) *SpamDetector {
+	sd := &SpamDetector{db: db, flags: flags, settings: settings, deps: deps}
+	sd.Reload(cfg)
+	return sd
+}
+
+// Reload replaces the rule set with one built from cfg; in-flight checks keep the old one
+func (sd *SpamDetector) Reload(cfg *Config) {
+	opts := detector.Options{
+		SpamKeywords: cfg.SpamKeywords,
+		ScamPhrases:  cfg.CryptoScamPhrases,
+		Checks:       cfg.Rules,
+		Now:          sd.deps.Now,
+		Client:       sd.deps.Client,
+	}
+	if len(cfg.PhishingFeeds) > 0 {
+		opts.Blocklist = sd.db
+	}
+	engine := detector.New(opts)
+	var detect detector.Detector = engine
+	if sd.deps.Engine != nil {
+		detect = sd.deps.Engine(opts)
+	}
+	sd.rules.Store(&detectorRules{
+		engine:       engine,
+		detect:       detect,
+		banThreshold: cfg.BanThreshold,
+	})
+}
+
+// RecordSpam increments spam count for user and returns (current count, should ban)
+func (sd *SpamDetector) RecordSpam(ctx context.Context, chatID int64, userID int64) (int, bool, error) {
+	ctx, cancel := sd.db.OpContext(ctx)
+	defer cancel()
+
+	// Upsert: insert or update spam count
+	_, err := sd.db.ExecContext(ctx, `
+		INSERT INTO spam_records (chat_id, user_id, count) VALUES (?, ?, 1)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET count = count + 1
+	`, chatID, userID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record spam: %v", err)
+	}
+
+	// Get current count
+	var count int
+	err = sd.db.QueryRowContext(ctx, `
+		SELECT count FROM spam_records WHERE chat_id = ? AND user_id = ?
+	`, chatID, userID).Scan(&count)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get spam count: %v", err)
+	}
+
+	return count, count >= sd.rules.Load().banThreshold, nil
+}
+
+// SpamCount returns the user's spam strikes in chatID
+func (sd *SpamDetector) SpamCount(ctx context.Context, chatID, userID int64) (int, error) {
+	ctx, cancel := sd.db.OpContext(ctx)
+	defer cancel()
+	var count int
+	err := sd.db.QueryRowContext(ctx, `
+		SELECT count FROM spam_records WHERE chat_id = ? AND user_id = ?
+	`, chatID, userID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
+
+// ClearSpam forgets the user's spam strikes in chatID, after a ban was lifted
+func (sd *SpamDetector) ClearSpam(ctx context.Context, chatID, userID int64) error {
+	ctx, cancel := sd.db.OpContext(ctx)
+	defer cancel()
+	_, err := sd.db.ExecContext(ctx, `DELETE FROM spam_records WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	return err
+}
+
+// ForgiveSpam takes back one of the user's spam strikes in chatID, after a
+// detection was overturned
+func (sd *SpamDetector) ForgiveSpam(ctx context.Context, chatID, userID int64) error {
+	ctx, cancel := sd.db.OpContext(ctx)
+	defer cancel()
+	_, err := sd.db.ExecContext(ctx, `UPDATE spam_records SET count = count - 1 WHERE chat_id = ? AND user_id = ? AND count > 0`, chatID, userID)
+	return err
+}
+
+// HasLink reports whether text contains something the detector treats as a link
+func (sd *SpamDetector) HasLink(text string) bool {
+	return sd.rules.Load().engine.HasLink(text)
+}
+
+// IsCryptoScam is detector.Engine.CryptoScam with the current rules
+func (sd *SpamDetector) IsCryptoScam(text string) detector.Verdict {
+	return sd.rules.Load().engine.CryptoScam(text)
+}
+
+// IsSeedPhrase is detector.SeedPhrase
+func (sd *SpamDetector) IsSeedPhrase(text string) detector.Verdict {
+	return detector.SeedPhrase(text)
+}
+
+// IsFakeSupport is detector.FakeSupport
+func (sd *SpamDetector) IsFakeSupport(text string) detector.Verdict {
+	return detector.FakeSupport(text)
+}
+
+// IsSpam classifies text posted in chatID (and forum topic threadID, 0 if none)
+// with the strike rules under the chat's settings; ctx bounds the settings
+// lookups
+func (sd *SpamDetector) IsSpam(ctx context.Context, chatID int64, threadID int, text string) detector.Verdict {
+	return sd.rules.Load().detect.Spam(ctx, text, sd.policy(ctx, chatID, threadID))
+}
+
+// Detect runs text through every rule of detector.Engine.Detect under
+// chatID's settings, the phishing feeds included when they are synced
+func (sd *SpamDetector) Detect(ctx context.Context, chatID int64, text string) (detector.Verdict, error) {
+	return sd.rules.Load().detect.Detect(ctx, text, sd.policy(ctx, chatID, 0))
+}
+
+// policy resolves the detector.Policy of chatID and forum topic threadID from
+// their settings; with link_policy "verified" the wallet gate has already
+// removed links from unverified members
+func (sd *SpamDetector) policy(ctx context.Context, chatID int64, threadID int) detector.Policy {
+	return detector.Policy{
+		LinksAreSpam:   sd.settings.GetTopic(ctx, chatID, threadID, settingLinkPolicy) == "spam",
+		BlockedDomains: listSetting(sd.settings.GetTopic(ctx, chatID, threadID, settingBlockedDomains)),
+		DMSolicitation: sd.settings.GetTopic(ctx, chatID, threadID, settingDMSolicitation),
+		SpamKeywords:   listSetting(sd.settings.GetTopic(ctx, chatID, threadID, settingSpamKeywords)),
+		KeywordOnly:    sd.flags.Enabled(ctx, chatID, flagKeywordOnly),
+	}
+}
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"strings"
+	"sync"
+)
+
+// keywordMatcher is an Aho-Corasick automaton over a list of keywords, so a
+// message is scanned once however many keywords a community adds
+type keywordMatcher struct {
+	keywords []string
+	nodes    []keywordNode
+}
+
+type keywordNode struct {
+	next map[byte]int32
+	fail int32
+	// first is the lowest index of a keyword ending here, directly or as a
+	// suffix, or -1
+	first int
+}
+
+func newKeywordMatcher(keywords []string) *keywordMatcher {
+	m := &keywordMatcher{keywords: keywords, nodes: []keywordNode{{next: map[byte]int32{}, first: -1}}}
+	for i, keyword := range keywords {
+		if keyword == "" {
+			continue
+		}
+		node := int32(0)
+		for j := 0; j < len(keyword); j++ {
+			next, ok := m.nodes[node].next[keyword[j]]
+			if !ok {
+				next = int32(len(m.nodes))
+				m.nodes = append(m.nodes, keywordNode{next: map[byte]int32{}, first: -1})
+				m.nodes[node].next[keyword[j]] = next
+			}
+			node = next
+		}
+		if m.nodes[node].first < 0 {
+			m.nodes[node].first = i
+		}
+	}
+
+	// Breadth first, so a node's failure link is final before its children's
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for c, child := range m.nodes[node].next {
+			fail := m.nodes[node].fail
+			for fail > 0 && m.nodes[fail].next[c] == 0 {
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[c]; ok {
+				m.nodes[child].fail = target
+			}
+			if inherited := m.nodes[m.nodes[child].fail].first; inherited >= 0 && (m.nodes[child].first < 0 || inherited < m.nodes[child].first) {
+				m.nodes[child].first = inherited
+			}
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// find returns the earliest listed keyword that text contains
+func (m *keywordMatcher) find(text string) (string, bool) {
+	i, ok := m.index(text)
+	if !ok {
+		return "", false
+	}
+	return m.keywords[i], true
+}
+
+// index is find, returning the keyword's index in the list
+func (m *keywordMatcher) index(text string) (int, bool) {
+	first := -1
+	node := int32(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		for {
+			if next, ok := m.nodes[node].next[c]; ok {
+				node = next
+				break
+			}
+			if node == 0 {
+				break
+			}
+			node = m.nodes[node].fail
+		}
+		if found := m.nodes[node].first; found >= 0 && (first < 0 || found < first) {
+			first = found
+			if first == 0 {
+				break
+			}
+		}
+	}
+	return first, first >= 0
+}
+
+// maxChatMatchers caps the per-chat automata an Engine keeps
+const maxChatMatchers = 1000
+
+// matcherCache reuses the automata of the chats' own keyword lists, which
+// change far less often than messages arrive
+type matcherCache struct {
+	mu       sync.Mutex
+	matchers map[string]*keywordMatcher
+}
+
+func (c *matcherCache) get(keywords []string) *keywordMatcher {
+	key := strings.Join(keywords, "\x00")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.matchers[key]; ok {
+		return m
+	}
+	if c.matchers == nil || len(c.matchers) >= maxChatMatchers {
+		c.matchers = make(map[string]*keywordMatcher)
+	}
+	m := newKeywordMatcher(keywords)
+	c.matchers[key] = m
+	return m
+}
@@ -0,0 +1,254 @@
+// Package detector is the bot's text classifier: the spam keyword, link,
+// crypto scam, seed phrase and fake support rules, plus the checks rule packs
+// Register. It has no Telegram,
+// storage or configuration dependencies, so other Go programs can embed it
+// and its rules can be exercised in isolation.
+//
+// Every check returns a Verdict; Engine.Detect runs them all, most severe first.
+package detector
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// checkTimeout bounds the HTTP requests of checks using the default client
+const checkTimeout = 10 * time.Second
+
+// HostPattern matches host names in text, including those behind text links
+var HostPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+(?:xn--[a-z0-9-]{1,59}|[a-z]{2,63})\b`)
+
+// Domains returns the host names in text plus their parent domains, so a
+// listed domain also catches its subdomains
+func Domains(text string) []string {
+	var domains []string
+	for _, host := range HostPattern.FindAllString(clip(text), -1) {
+		labels := strings.Split(strings.ToLower(host), ".")
+		for i := 0; i < len(labels)-1; i++ {
+			if domain := strings.Join(labels[i:], "."); !slices.Contains(domains, domain) {
+				domains = append(domains, domain)
+			}
+		}
+		if len(domains) > 50 {
+			break
+		}
+	}
+	return domains
+}
+
+// Options are an Engine's global rules, on top of the built-in ones
+type Options struct {
+	// SpamKeywords flag a message together with an @mention; DefaultSpamKeywords if nil
+	SpamKeywords []string
+	// ScamPhrases flag a message together with a link, like the built-in
+	// airdrop and wallet connect lures
+	ScamPhrases []string
+	// Blocklist, if set, has Detect flag links to listed phishing domains
+	Blocklist Blocklist
+	// Checks are run on top of the registered ones, e.g. rules from configuration
+	Checks []Check
+	// Now is the clock checks see; time.Now if nil
+	Now func() time.Time
+	// Client makes the checks' HTTP requests; one with a short timeout if nil
+	Client *http.Client
+}
+
+// Policy is how one chat (or forum topic) tunes the rules. The zero value is
+// not the bot's default; use DefaultPolicy.
+type Policy struct {
+	// LinksAreSpam flags every link
+	LinksAreSpam bool
+	// BlockedDomains are spam even where links are allowed, subdomains included
+	BlockedDomains []string
+	// DMSolicitation is how readily "DM me" spam is flagged: "off", "low"
+	// (with an offer and a contact), "medium" (with a contact) or "high"
+	// (the phrase alone)
+	DMSolicitation string
+	// SpamKeywords are the chat's own keywords, lower case, on top of the Engine's
+	SpamKeywords []string
+	// KeywordOnly flags a spam keyword even without an @mention
+	KeywordOnly bool
+}
+
+// DefaultPolicy is the policy of a chat that changed no settings
+func DefaultPolicy() Policy {
+	return Policy{LinksAreSpam: true, DMSolicitation: "medium"}
+}
+
+// Engine classifies text. It is immutable and safe for concurrent use;
+// build a new one to change its Options.
+type Engine struct {
+	spamKeywords *keywordMatcher
+	chatKeywords *matcherCache
+	scams        *scamMatcher
+	blocklist    Blocklist
+	checks       []Check
+	env          Env
+}
+
+// New returns an Engine with the built-in rules plus opts
+func New(opts Options) *Engine {
+	if opts.SpamKeywords == nil {
+		opts.SpamKeywords = DefaultSpamKeywords
+	}
+	keywords := make([]string, 0, len(opts.SpamKeywords))
+	for _, keyword := range opts.SpamKeywords {
+		keywords = append(keywords, strings.ToLower(keyword))
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: checkTimeout}
+	}
+	phrases := make([]string, 0, len(opts.ScamPhrases))
+	for _, phrase := range opts.ScamPhrases {
+		phrases = append(phrases, strings.ToLower(phrase))
+	}
+	return &Engine{
+		spamKeywords: newKeywordMatcher(keywords),
+		chatKeywords: &matcherCache{},
+		scams:        newScamMatcher(phrases),
+		blocklist:    opts.Blocklist,
+		checks:       engineChecks(opts.Checks),
+		env:          Env{Now: opts.Now, Client: opts.Client},
+	}
+}
+
+// HasLink reports whether text contains something the engine treats as a link
+func (e *Engine) HasLink(text string) bool {
+	return scanText(clip(text)).link
+}
+
+// CryptoScam reports whether text is a fake airdrop, wallet connect or
+// eligibility lure: one of the scam phrases together with a link. These drain
+// wallets on the first click, so they warrant an instant ban rather than a strike.
+func (e *Engine) CryptoScam(text string) Verdict {
+	text = clip(text)
+	if !scanText(text).link {
+		return Verdict{}
+	}
+	v, _ := e.scams.find(strings.ToLower(text))
+	return v
+}
+
+// SeedPhrase reports whether text asks for a seed phrase or private key, or
+// contains a mnemonic or private key itself. The latter is the bait of
+// "help me withdraw from this wallet" scams, and deleting it protects a member
+// who pasted their own. Warnings like "never share your seed phrase" pass.
+func SeedPhrase(text string) Verdict {
+	text = clip(text)
+	for _, loc := range seedSolicitationPattern.FindAllStringIndex(text, -1) {
+		if !seedNegationPattern.MatchString(text[:loc[0]]) {
+			return verdict("seed_request", "seed phrase or private key request: "+text[loc[0]:loc[1]], SeverityBan)
+		}
+	}
+	if aptosPrivateKeyPattern.MatchString(text) {
+		return verdict("private_key", "private key posted", SeverityBan)
+	}
+	if hasMnemonic(text) {
+		return verdict("mnemonic", "mnemonic posted", SeverityBan)
+	}
+	return Verdict{}
+}
+
+// FakeSupport reports whether text sends members to a support contact or
+// ticket; the caller decides whether the contact is the chat's own
+func FakeSupport(text string) Verdict {
+	text = clip(text)
+	if loc := fakeSupportPattern.FindStringIndex(text); loc != nil {
+		return verdict("fake_support", "fake support: "+text[loc[0]:loc[1]], SeverityBan)
+	}
+	return Verdict{}
+}
+
+// Spam classifies text under policy with the extra checks that ban, then the
+// strike rules (links, blocked domains, "DM me" solicitation and spam
+// keywords), then the extra checks that strike, in the order Detect uses.
+// ctx bounds the extra checks.
+func (e *Engine) Spam(ctx context.Context, text string, policy Policy) Verdict {
+	text = clip(text)
+	if v := e.runChecks(ctx, text, policy, SeverityBan); v.Spam() {
+		return v
+	}
+	if v := e.spam(text, policy); v.Spam() {
+		return v
+	}
+	return e.runChecks(ctx, text, policy, SeverityStrike)
+}
+
+func (e *Engine) spam(text string, policy Policy) Verdict {
+	lowerText := strings.ToLower(text)
+
+	// Check if message has URL or mention
+	features := scanText(text)
+	hasLink, hasMention := features.link, features.mention
+
+	// URL = spam, unless links are allowed here (e.g. a dedicated links topic)
+	if hasLink && policy.LinksAreSpam {
+		return verdict("url", "URL detected", SeverityStrike)
+	}
+	// Links to domains this chat blocks, or their subdomains
+	if len(policy.BlockedDomains) > 0 {
+		for _, domain := range Domains(text) {
+			if slices.Contains(policy.BlockedDomains, domain) {
+				return verdict("blocked_domain", "blocked domain: "+domain, SeverityStrike)
+			}
+		}
+	}
+
+	// "DM me for signals/support/whitelist": no link to catch, the contact is the payload
+	if reason, ok := isDMSolicitation(text, hasMention || userLinkPattern.MatchString(text), policy.DMSolicitation); ok {
+		return verdict("dm_solicitation", reason, SeverityStrike)
+	}
+
+	// Spam keyword + mention = spam (keyword alone with KeywordOnly)
+	if hasMention || policy.KeywordOnly {
+		keyword, found := e.chatKeywords.get(policy.SpamKeywords).find(lowerText)
+		if !found {
+			keyword, found = e.spamKeywords.find(lowerText)
+		}
+		if found && !hasMention {
+			return verdict("spam_keyword", "spam keyword: "+keyword, SeverityStrike)
+		}
+		if found {
+			return verdict("spam_keyword_mention", "spam keyword with mention: "+keyword, SeverityStrike)
+		}
+	}
+
+	return Verdict{}
+}
+
+// Detect runs text through the ban rules first (seed phrases, crypto scams,
+// listed phishing domains, then the extra checks that ban), then the strike
+// rules under policy, and returns the first match. Fake support is left out, since only the caller knows
+// whether the contact is the chat's own. err is from the Blocklist.
+func (e *Engine) Detect(ctx context.Context, text string, policy Policy) (Verdict, error) {
+	if v := SeedPhrase(text); v.Spam() {
+		return v, nil
+	}
+	if v := e.CryptoScam(text); v.Spam() {
+		return v, nil
+	}
+	if e.blocklist != nil {
+		domain, _, err := e.blocklist.PhishingDomain(ctx, Domains(text))
+		if err != nil {
+			return Verdict{}, err
+		}
+		if domain != "" {
+			return verdict("phishing_domain", "phishing domain "+domain, SeverityBan), nil
+		}
+	}
+	text = clip(text)
+	if v := e.runChecks(ctx, text, policy, SeverityBan); v.Spam() {
+		return v, nil
+	}
+	if v := e.spam(text, policy); v.Spam() {
+		return v, nil
+	}
+	return e.runChecks(ctx, text, policy, SeverityStrike), nil
+}
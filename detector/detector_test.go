@@ -0,0 +1,221 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBlocklist lists domain for any lookup that includes it
+type fakeBlocklist struct {
+	domain string
+	err    error
+	looked [][]string
+}
+
+func (f *fakeBlocklist) PhishingDomain(ctx context.Context, domains []string) (string, string, error) {
+	f.looked = append(f.looked, domains)
+	if f.err != nil {
+		return "", "", f.err
+	}
+	if slices.Contains(domains, f.domain) {
+		return f.domain, "fake-feed", nil
+	}
+	return "", "", nil
+}
+
+func TestDetectBlocklist(t *testing.T) {
+	errFeed := errors.New("feed store unavailable")
+	allowLinks := Policy{DMSolicitation: "medium"}
+	tests := []struct {
+		name      string
+		text      string
+		policy    Policy
+		blocklist *fakeBlocklist
+		want      string
+		severity  Severity
+		wantErr   error
+		looked    []string
+	}{
+		{
+			name:      "listed domain bans where links are allowed",
+			text:      "claim at https://aptos-claim.example/now",
+			policy:    allowLinks,
+			blocklist: &fakeBlocklist{domain: "aptos-claim.example"},
+			want:      "phishing_domain",
+			severity:  SeverityBan,
+			looked:    []string{"aptos-claim.example"},
+		},
+		{
+			name:      "unlisted domain falls through to the strike rules",
+			text:      "docs at https://aptos.dev",
+			policy:    DefaultPolicy(),
+			blocklist: &fakeBlocklist{domain: "aptos-claim.example"},
+			want:      "url",
+			severity:  SeverityStrike,
+			looked:    []string{"aptos.dev"},
+		},
+		{
+			name:      "unlisted domain is clean where links are allowed",
+			text:      "docs at https://aptos.dev",
+			policy:    allowLinks,
+			blocklist: &fakeBlocklist{domain: "aptos-claim.example"},
+			looked:    []string{"aptos.dev"},
+		},
+		{
+			name:      "lookup error is returned without a verdict",
+			text:      "docs at https://aptos.dev",
+			policy:    DefaultPolicy(),
+			blocklist: &fakeBlocklist{err: errFeed},
+			wantErr:   errFeed,
+			looked:    []string{"aptos.dev"},
+		},
+		{
+			name:      "seed phrase request bans before the lookup",
+			text:      "send me your seed phrase at https://aptos-claim.example",
+			policy:    allowLinks,
+			blocklist: &fakeBlocklist{domain: "aptos-claim.example"},
+			want:      "seed_request",
+			severity:  SeverityBan,
+		},
+		{
+			name:   "no blocklist",
+			text:   "claim at https://aptos-claim.example/now",
+			policy: allowLinks,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{}
+			if tt.blocklist != nil {
+				opts.Blocklist = tt.blocklist
+			}
+			var d Detector = New(opts)
+			v, err := d.Detect(context.Background(), tt.text, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if v.Rule != tt.want || v.Severity != tt.severity {
+				t.Errorf("verdict = %q (%v), want %q (%v)", v.Rule, v.Severity, tt.want, tt.severity)
+			}
+			if tt.blocklist == nil {
+				return
+			}
+			var looked []string
+			if len(tt.blocklist.looked) > 0 {
+				looked = tt.blocklist.looked[0]
+			}
+			if len(tt.blocklist.looked) > 1 || !slices.Equal(looked, tt.looked) {
+				t.Errorf("looked up %v, want [%v]", tt.blocklist.looked, tt.looked)
+			}
+		})
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		verdict Verdict
+		spam    bool
+		label   string
+		score   float64
+	}{
+		{Verdict{}, false, "", 0},
+		{verdict("url", "URL detected", SeverityStrike), true, "reason.url", 0.5},
+		{verdict("phishing_domain", "phishing domain x.example", SeverityBan), true, "reason.phishing_domain", 1},
+		{verdict("pack.custom", "custom", SeverityStrike), true, "reason.pack.custom", 0},
+	}
+	for _, tt := range tests {
+		if got := tt.verdict.Spam(); got != tt.spam {
+			t.Errorf("%+v: Spam() = %t, want %t", tt.verdict, got, tt.spam)
+		}
+		if got := tt.verdict.Label(); got != tt.label {
+			t.Errorf("%+v: Label() = %q, want %q", tt.verdict, got, tt.label)
+		}
+		if tt.verdict.Score != tt.score {
+			t.Errorf("%+v: Score = %v, want %v", tt.verdict, tt.verdict.Score, tt.score)
+		}
+	}
+}
+
+func TestChecksOrder(t *testing.T) {
+	drainers, err := PatternCheck("pack.drainers", SeverityBan, 0.9, `(?i)free mint`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presale, err := PatternCheck("pack.presale", SeverityStrike, 0.6, `(?i)presale`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(Options{Checks: []Check{drainers, presale}})
+	tests := []struct {
+		name     string
+		text     string
+		want     string
+		severity Severity
+	}{
+		{"ban check before the url rule", "free mint at https://drainer.example/claim", "pack.drainers", SeverityBan},
+		{"url rule before a strike check", "presale at https://drainer.example/claim", "url", SeverityStrike},
+		{"strike check alone", "presale opens today", "pack.presale", SeverityStrike},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := e.Spam(context.Background(), tt.text, DefaultPolicy())
+			if v.Rule != tt.want || v.Severity != tt.severity {
+				t.Errorf("Spam = %q (%v), want %q (%v)", v.Rule, v.Severity, tt.want, tt.severity)
+			}
+			d, err := e.Detect(context.Background(), tt.text, DefaultPolicy())
+			if err != nil || d != v {
+				t.Errorf("Detect = %+v, %v; want Spam's %+v", d, err, v)
+			}
+		})
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+	client := &http.Client{}
+	var got Env
+	// nightly flags presales posted between 22:00 and 06:00 by the injected clock
+	nightly := Check{Name: "pack.nightly", Severity: SeverityStrike, Match: func(_ context.Context, text string, _ Policy, env Env) (string, bool) {
+		got = env
+		hour := env.Now().Hour()
+		return "presale at night", strings.Contains(text, "presale") && (hour >= 22 || hour < 6)
+	}}
+	e := New(Options{Checks: []Check{nightly}, Now: func() time.Time { return now }, Client: client})
+	if v := e.Spam(context.Background(), "presale opens", DefaultPolicy()); v.Rule != "pack.nightly" {
+		t.Errorf("Spam at %v = %q, want pack.nightly", now, v.Rule)
+	}
+	if got.Client != client {
+		t.Errorf("check got client %p, want the injected %p", got.Client, client)
+	}
+	now = now.Add(12 * time.Hour)
+	if v := e.Spam(context.Background(), "presale opens", DefaultPolicy()); v.Spam() {
+		t.Errorf("Spam at %v = %q, want clean", now, v.Rule)
+	}
+
+	New(Options{Checks: []Check{nightly}}).Spam(context.Background(), "presale", DefaultPolicy())
+	if got.Now == nil || got.Client == nil {
+		t.Errorf("default env = %+v, want a clock and a client", got)
+	}
+}
+
+func TestChecksCancelled(t *testing.T) {
+	calls := 0
+	slow := Check{Name: "pack.slow", Severity: SeverityBan, Match: func(ctx context.Context, text string, _ Policy, _ Env) (string, bool) {
+		calls++
+		return "slow", ctx.Err() == nil
+	}}
+	e := New(Options{Checks: []Check{slow}})
+	ctx, cancel := context.WithCancel(context.Background())
+	if v := e.Spam(ctx, "hello", DefaultPolicy()); v.Rule != "pack.slow" {
+		t.Errorf("Spam = %q, want pack.slow", v.Rule)
+	}
+	cancel()
+	if v := e.Spam(ctx, "hello", DefaultPolicy()); v.Spam() || calls != 1 {
+		t.Errorf("Spam after cancel = %q with %d calls, want clean without running the check", v.Rule, calls)
+	}
+}
@@ -0,0 +1,98 @@
+package detector
+
+import "regexp"
+
+// MaxTextLength is how many characters of a text the engine looks at:
+// Telegram's own message limit, so a longer text such as a bio or a document
+// is classified by its start and a pathological one costs no more than a
+// full message
+const MaxTextLength = 4096
+
+// clip cuts text to MaxTextLength characters
+func clip(text string) string {
+	if len(text) <= MaxTextLength {
+		return text
+	}
+	n := 0
+	for i := range text {
+		if n == MaxTextLength {
+			return text[:i]
+		}
+		n++
+	}
+	return text
+}
+
+// linkPattern matches what the engine treats as a link
+var linkPattern = regexp.MustCompile(`(?i)(https?://|t\.me/|bit\.ly|tinyurl|telegram\.me|www\.|[a-z0-9][-a-z0-9]*\.(?:com|net|org|io|me|co|xyz|info|biz|tv|cc|ru|kr|cn)\b)`)
+
+// textPattern finds links (group 1) and @mentions (group 2) in one pass. Go's
+// regexp runs in linear time, so no message can make it backtrack.
+var textPattern = regexp.MustCompile(linkPattern.String() + `|(@[a-z0-9_]+)`)
+
+// textFeatures are what the strike rules look for besides keywords
+type textFeatures struct {
+	link    bool
+	mention bool
+}
+
+// scanText finds whether text has a link and an @mention, one match at a
+// time so it stops reading once it has seen both. Matches are never empty and
+// nothing in the pattern looks before where a match starts, so resuming after
+// each one finds what FindAllStringSubmatchIndex would.
+func scanText(text string) textFeatures {
+	var f textFeatures
+	for rest := text; !(f.link && f.mention); {
+		loc := textPattern.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		f.link = f.link || loc[2] >= 0
+		f.mention = f.mention || loc[4] >= 0
+		rest = rest[loc[1]:]
+	}
+	// Matches don't overlap, so a mention can hide a link it runs into, as in
+	// "name@example.com"
+	if f.mention && !f.link {
+		f.link = linkPattern.MatchString(text)
+	}
+	return f
+}
+
+// scamMatcher finds the crypto scam lures of cryptoScamRules and an Engine's
+// ScamPhrases with one automaton; rules[i] is the cryptoScamRules index of
+// phrase i, or -1 for a ScamPhrases one
+type scamMatcher struct {
+	phrases *keywordMatcher
+	rules   []int
+}
+
+func newScamMatcher(custom []string) *scamMatcher {
+	var phrases []string
+	var rules []int
+	for i, rule := range cryptoScamRules {
+		for _, phrase := range rule.phrases {
+			phrases = append(phrases, phrase)
+			rules = append(rules, i)
+		}
+	}
+	for _, phrase := range custom {
+		phrases = append(phrases, phrase)
+		rules = append(rules, -1)
+	}
+	return &scamMatcher{phrases: newKeywordMatcher(phrases), rules: rules}
+}
+
+// find returns the first lure, in rule order, that lowerText contains
+func (m *scamMatcher) find(lowerText string) (Verdict, bool) {
+	i, ok := m.phrases.index(lowerText)
+	if !ok {
+		return Verdict{}, false
+	}
+	phrase := m.phrases.keywords[i]
+	if m.rules[i] < 0 {
+		return verdict("crypto_scam", "crypto scam link: "+phrase, SeverityBan), true
+	}
+	rule := cryptoScamRules[m.rules[i]]
+	return verdict(rule.rule, rule.reason+": "+phrase, SeverityBan), true
+}
@@ -0,0 +1,93 @@
+package detector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var scanTexts = map[string]string{
+	"plain":        "good morning everyone, any news on the mainnet launch?",
+	"link":         "docs are at https://aptos.dev/en/build/get-started",
+	"mention":      "ask @support_team about it",
+	"both":         "claim your airdrop at bit.ly/free-apt and DM @aptos_helpdesk",
+	"email":        "write to name@example.com",
+	"long_plain":   strings.Repeat("lorem ipsum dolor sit amet ", 160),
+	"long_mention": strings.Repeat("@a ", 1400),
+	"pathological": strings.Repeat("a-", 2048),
+}
+
+func BenchmarkScanText(b *testing.B) {
+	for name, text := range scanTexts {
+		text = clip(text)
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			for b.Loop() {
+				scanText(text)
+			}
+		})
+	}
+}
+
+// scanTextAll is scanText reading every match, as it used to
+func scanTextAll(text string) textFeatures {
+	var f textFeatures
+	for _, loc := range textPattern.FindAllStringSubmatchIndex(text, -1) {
+		f.link = f.link || loc[2] >= 0
+		f.mention = f.mention || loc[4] >= 0
+	}
+	if f.mention && !f.link {
+		f.link = linkPattern.MatchString(text)
+	}
+	return f
+}
+
+func FuzzMatcher(f *testing.F) {
+	for _, text := range scanTexts {
+		f.Add(text)
+	}
+	f.Add("")
+	f.Add("@@@ t.me/ www. .com")
+	f.Add("connect wallet: walletconnect-app.xyz")
+	f.Add("ÄÖÜ\xff\xfe@x")
+
+	engine := New(Options{ScamPhrases: []string{"free apt"}})
+	keywords := newKeywordMatcher([]string{"airdrop", "drop", "free apt", "a"})
+	f.Fuzz(func(t *testing.T, text string) {
+		clipped := clip(text)
+		if !strings.HasPrefix(text, clipped) {
+			t.Fatalf("clip(%q) = %q, not a prefix", text, clipped)
+		}
+		if n := utf8.RuneCountInString(clipped); n > MaxTextLength || (n < MaxTextLength && clipped != text) {
+			t.Fatalf("clip kept %d characters of %d", n, utf8.RuneCountInString(text))
+		}
+
+		if got, want := scanText(clipped), scanTextAll(clipped); got != want {
+			t.Fatalf("scanText(%q) = %+v, reading every match gives %+v", clipped, got, want)
+		}
+
+		lower := strings.ToLower(clipped)
+		if i, ok := keywords.index(lower); ok {
+			if !strings.Contains(lower, keywords.keywords[i]) {
+				t.Fatalf("index(%q) found %q, which it doesn't contain", lower, keywords.keywords[i])
+			}
+			for _, earlier := range keywords.keywords[:i] {
+				if strings.Contains(lower, earlier) {
+					t.Fatalf("index(%q) found %q before the earlier listed %q", lower, keywords.keywords[i], earlier)
+				}
+			}
+		} else {
+			for _, keyword := range keywords.keywords {
+				if strings.Contains(lower, keyword) {
+					t.Fatalf("index(%q) missed %q", lower, keyword)
+				}
+			}
+		}
+
+		engine.Spam(context.Background(), text, DefaultPolicy())
+		if _, err := engine.Detect(context.Background(), text, DefaultPolicy()); err != nil {
+			t.Fatalf("Detect(%q): %v", text, err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check is an additional rule: from a compiled-in rule pack, which Registers
+// it from an init function, or declared in configuration and passed in
+// Options.Checks. Engines run the checks that ban before the built-in strike
+// rules, so a link can't hide them, and those that strike after them.
+type Check struct {
+	// Name is the Verdict.Rule of a match, e.g. "pack.presale"; it must not be
+	// a built-in rule's
+	Name     string
+	Severity Severity
+	// Score is the Verdict.Score of a match, from 0 to 1
+	Score float64
+	// Match reports whether text breaks the rule under policy, and why for
+	// the logs; it should give up once ctx is done. It must be safe for
+	// concurrent use.
+	Match func(ctx context.Context, text string, policy Policy, env Env) (reason string, ok bool)
+}
+
+// Env is what an Engine hands its checks besides the text: the clock and
+// HTTP client of its Options, so checks can be tested with fakes
+type Env struct {
+	Now    func() time.Time
+	Client *http.Client
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Check
+	// Matches per check name, across engines and reloads
+	checkMatches sync.Map
+)
+
+// Register adds c to the checks of every Engine built afterwards
+func Register(c Check) error {
+	if err := validCheck(c); err != nil {
+		return err
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, registered := range registry {
+		if registered.Name == c.Name {
+			return fmt.Errorf("detector: check %q already registered", c.Name)
+		}
+	}
+	registry = append(registry, c)
+	return nil
+}
+
+// Registered lists the registered checks in registration order
+func Registered() []Check {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Check(nil), registry...)
+}
+
+// PatternCheck is a check that matches text against a regular expression,
+// the form configuration-declared rules take
+func PatternCheck(name string, severity Severity, score float64, pattern string) (Check, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Check{}, fmt.Errorf("detector: check %q: %v", name, err)
+	}
+	c := Check{Name: name, Severity: severity, Score: score, Match: func(_ context.Context, text string, _ Policy, _ Env) (string, bool) {
+		if loc := re.FindStringIndex(text); loc != nil {
+			return name + ": " + text[loc[0]:loc[1]], true
+		}
+		return "", false
+	}}
+	return c, validCheck(c)
+}
+
+// CheckMatches counts each check's matches since the process started
+func CheckMatches() map[string]int64 {
+	counts := make(map[string]int64)
+	checkMatches.Range(func(name, n any) bool {
+		counts[name.(string)] = n.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
+
+func validCheck(c Check) error {
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("detector: check without a name")
+	case c.Match == nil:
+		return fmt.Errorf("detector: check %q has no Match", c.Name)
+	case c.Severity != SeverityStrike && c.Severity != SeverityBan:
+		return fmt.Errorf("detector: check %q must be a strike or a ban", c.Name)
+	case c.Score < 0 || c.Score > 1:
+		return fmt.Errorf("detector: check %q has a score outside 0 to 1", c.Name)
+	}
+	if _, builtIn := ruleScores[c.Name]; builtIn {
+		return fmt.Errorf("detector: check %q shadows a built-in rule", c.Name)
+	}
+	return nil
+}
+
+// engineChecks are the registered checks followed by extra; invalid ones and
+// those named like an earlier one are left out
+func engineChecks(extra []Check) []Check {
+	var checks []Check
+	seen := make(map[string]bool)
+	for _, c := range append(Registered(), extra...) {
+		if seen[c.Name] || validCheck(c) != nil {
+			continue
+		}
+		seen[c.Name] = true
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// runChecks returns the verdict of the first check of severity that text
+// breaks; none once ctx is done
+func (e *Engine) runChecks(ctx context.Context, text string, policy Policy, severity Severity) Verdict {
+	for _, c := range e.checks {
+		if c.Severity != severity {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		reason, ok := c.Match(ctx, text, policy, e.env)
+		if !ok {
+			continue
+		}
+		n, _ := checkMatches.LoadOrStore(c.Name, new(atomic.Int64))
+		n.(*atomic.Int64).Add(1)
+		return Verdict{Rule: c.Name, Reason: reason, Severity: c.Severity, Score: c.Score}
+	}
+	return Verdict{}
+}
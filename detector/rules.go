@@ -0,0 +1,141 @@
+package detector
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultSpamKeywords are the bot's keywords when SPAM_KEYWORDS is not configured
+var DefaultSpamKeywords = []string{
+	"earn money", "make money fast", "investment opportunity",
+	"double your", "guaranteed profit", "free money",
+	"click here", "join now", "limited time offer",
+	"act now", "don't miss", "exclusive deal",
+	"work from home", "be your own boss", "financial freedom",
+	"forex signal", "trading signal", "casino", "betting",
+}
+
+// cryptoScamRule is a family of wallet-draining lures; a phrase plus a link is a scam
+type cryptoScamRule struct {
+	phrases []string
+	reason  string
+	// Verdict.Rule of a match
+	rule string
+}
+
+// cryptoScamRules are the fake airdrop and claim-link lures common in Aptos groups
+var cryptoScamRules = []cryptoScamRule{
+	{
+		phrases: []string{"claim your airdrop", "claim airdrop", "airdrop is live", "claim your tokens", "claim your reward", "claim reward", "에어드랍", "에어드롭"},
+		reason:  "fake airdrop claim link",
+		rule:    "fake_airdrop",
+	},
+	{
+		phrases: []string{"connect wallet", "connect your wallet", "validate your wallet", "wallet validation", "sync your wallet", "rectify wallet", "지갑 연결", "지갑을 연결"},
+		reason:  "wallet connect phishing link",
+		rule:    "wallet_connect",
+	},
+	{
+		phrases: []string{"eligibility check", "check eligibility", "check your eligibility", "you are eligible", "자격 확인", "당첨"},
+		reason:  "fake eligibility check link",
+		rule:    "fake_eligibility",
+	},
+}
+
+// seedSolicitationPattern matches asking for a wallet's secret: "send me your
+// seed phrase", "enter your private key", "verify your 12 words"
+var seedSolicitationPattern = regexp.MustCompile(`(?i)\b(send|share|enter|provide|give|dm|drop|paste|type|submit|input|import|verify|validate|need)\b[^.!?\n]{0,30}?(\b(seed|recovery|secret|backup|mnemonic) ?(phrase|words?)\b|\bprivate ?keys?\b|\bmnemonic\b|\b(12|24)[- ]words?\b)|(시드|복구|니모닉|개인 ?키|비밀 ?키)[^.!?\n]{0,15}(보내|입력|알려|공유|주세요)`)
+
+// seedNegationPattern spots warnings such as "never share your seed phrase"
+var seedNegationPattern = regexp.MustCompile(`(?i)\b(never|don'?t|do not|not|no one|nobody|won'?t)\b[^.!?\n]{0,20}$|절대`)
+
+// aptosPrivateKeyPattern matches an AIP-80 formatted Aptos private key
+var aptosPrivateKeyPattern = regexp.MustCompile(`(?i)\bed25519-priv-0x[0-9a-f]{64}\b`)
+
+// fakeSupportPattern matches pointing members to "support" outside the chat:
+// "contact support at @xxx", "open a ticket here", "our help desk is available on"
+var fakeSupportPattern = regexp.MustCompile(`(?i)\b(contact|message|dm|reach|reach out to|write to|chat with|talk to)\s+(the |our |official |customer |technical |tech |live )*(support|help ?desk|customer (care|service)|support team|admins? team)\b|\b(open|create|submit|raise|file)\s+(a |your |an? support )?ticket\b|\b(support|help ?desk) (is )?(available )?(at|via|here|on)\b|\blive (chat|support)\b|고객 ?센터|고객 ?지원|상담원|티켓 ?(생성|열기|접수)|문의 ?티켓`)
+
+// dmSolicitationPattern matches steering members to a private chat: "DM me",
+// "contact our admin", "send me a message", "inbox me for signals"
+var dmSolicitationPattern = regexp.MustCompile(`(?i)\b(dm|pm|inbox|message|contact|text|write|ping|hit up|reach out to|talk to|chat with)\s+(me|us|@[a-z0-9_]{4,}|(our|the) (admin|support|manager|team))\b|\b(send|drop) (me|us) (a )?(dm|pm|message)\b|\b(in|via) (my |the )?(dms?|pm|inbox)\b|\bdm (for|to)\b|(디엠|DM|개인 ?메시지|개인 ?톡|텔레)\s*(주세요|주시면|문의|보내)`)
+
+// dmTopicPattern matches what "DM me" spam offers, for a DMSolicitation of "low"
+var dmTopicPattern = regexp.MustCompile(`(?i)\b(signals?|support|whitelist|wl|presale|pump|profits?|invest(ment)?|trading|recovery|recover|airdrop|giveaway|refund|withdraw(al)?)\b|시그널|리딩|화이트리스트|수익|투자`)
+
+// userLinkPattern matches a link to a Telegram account, which stands in for an @mention
+var userLinkPattern = regexp.MustCompile(`(?i)\b(t|telegram)\.me/[a-z0-9_]{4,}|tg://(user|resolve)\b`)
+
+// mnemonicWordPattern matches a token that can be a BIP-39 word: 3 to 8 lower-case letters
+var mnemonicWordPattern = regexp.MustCompile(`^[a-z]{3,8}$`)
+
+// mnemonicStopwords are common English words that are not BIP-39 words; a run
+// containing one is prose, not a mnemonic
+var mnemonicStopwords = map[string]bool{
+	"the": true, "and": true, "you": true, "your": true, "that": true, "this": true, "with": true,
+	"for": true, "are": true, "was": true, "have": true, "has": true, "had": true, "not": true,
+	"but": true, "she": true, "his": true, "her": true, "they": true, "them": true, "from": true,
+	"were": true, "been": true, "would": true, "should": true, "could": true, "just": true,
+	"its": true, "our": true, "did": true, "does": true, "can": true, "what": true, "which": true,
+	"who": true, "very": true, "really": true, "much": true, "some": true, "here": true,
+}
+
+// mnemonicContextPattern marks a message as talking about a wallet secret, so a
+// word run inside a longer line may be a mnemonic
+var mnemonicContextPattern = regexp.MustCompile(`(?i)\b(seed|mnemonic|recovery|phrase|wallet|withdraw)\b|시드|니모닉|지갑`)
+
+// mnemonicMinWords is the length of the shortest standard mnemonic
+const mnemonicMinWords = 12
+
+// mnemonicLength reports whether n is the word count of a standard BIP-39 mnemonic
+func mnemonicLength(n int) bool {
+	return n >= mnemonicMinWords && n <= 24 && n%3 == 0
+}
+
+// hasMnemonic reports whether text contains what looks like a BIP-39
+// mnemonic, plain or numbered ("1. word 2. word"): a line that is nothing but
+// 12 to 24 candidate words, or such a run anywhere in a message that talks
+// about seeds or wallets. Chat rarely is a whole line of them.
+func hasMnemonic(text string) bool {
+	inContext := mnemonicContextPattern.MatchString(text)
+	for _, line := range strings.Split(strings.ToLower(text), "\n") {
+		run, whole := 0, true
+		for _, token := range strings.Fields(line) {
+			if strings.Trim(token, "0123456789.)") == "" {
+				continue
+			}
+			token = strings.TrimRight(token, ",.;:!?")
+			if !mnemonicWordPattern.MatchString(token) || mnemonicStopwords[token] {
+				if inContext && run >= mnemonicMinWords {
+					return true
+				}
+				run, whole = 0, false
+				continue
+			}
+			run++
+		}
+		if (whole && mnemonicLength(run)) || (inContext && run >= mnemonicMinWords) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDMSolicitation applies a Policy's DMSolicitation: "low" needs a solicitation phrase,
+// an offer such as signals or support and a contact (an @mention or account
+// link), "medium" the phrase and a contact, "high" just the phrase. Warnings
+// like "admins will never DM you first" or "don't DM me" pass.
+func isDMSolicitation(text string, hasContact bool, sensitivity string) (string, bool) {
+	if sensitivity == "off" || (!hasContact && sensitivity != "high") {
+		return "", false
+	}
+	if sensitivity == "low" && !dmTopicPattern.MatchString(text) {
+		return "", false
+	}
+	for _, loc := range dmSolicitationPattern.FindAllStringIndex(text, -1) {
+		if !seedNegationPattern.MatchString(text[:loc[0]]) {
+			return "DM solicitation: " + text[loc[0]:loc[1]], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package detector
+
+import "context"
+
+// Severity is how a Verdict should be acted on
+type Severity int
+
+const (
+	// SeverityNone: the text is clean
+	SeverityNone Severity = iota
+	// SeverityStrike counts towards the ban threshold
+	SeverityStrike
+	// SeverityBan warrants banning on the first offence
+	SeverityBan
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityStrike:
+		return "strike"
+	case SeverityBan:
+		return "ban"
+	}
+	return "none"
+}
+
+// Verdict is the outcome of a check; the zero value is clean
+type Verdict struct {
+	// Rule that matched, e.g. "url" or "seed_request"
+	Rule string
+	// Reason is for the logs, with the matched phrase, link or domain
+	Reason   string
+	Severity Severity
+	// Score is how rarely the rule matches legitimate messages, from 0 to 1
+	Score float64
+}
+
+// Spam reports whether a rule matched
+func (v Verdict) Spam() bool {
+	return v.Severity != SeverityNone
+}
+
+// Label is the catalog key of the short, localized reason shown to members,
+// e.g. "reason.url"; "" for a clean verdict
+func (v Verdict) Label() string {
+	if v.Rule == "" {
+		return ""
+	}
+	return "reason." + v.Rule
+}
+
+// ruleScores are the rules' Verdict.Score: posted keys and wallet lures are
+// all but certain, bare links and keywords are often legitimate
+var ruleScores = map[string]float64{
+	"private_key":          1,
+	"phishing_domain":      1,
+	"blocked_domain":       1,
+	"fake_airdrop":         0.95,
+	"wallet_connect":       0.95,
+	"fake_eligibility":     0.95,
+	"crypto_scam":          0.9,
+	"seed_request":         0.9,
+	"mnemonic":             0.9,
+	"spam_keyword_mention": 0.8,
+	"fake_support":         0.7,
+	"dm_solicitation":      0.7,
+	"url":                  0.5,
+	"spam_keyword":         0.5,
+}
+
+func verdict(rule, reason string, severity Severity) Verdict {
+	return Verdict{Rule: rule, Reason: reason, Severity: severity, Score: ruleScores[rule]}
+}
+
+// Blocklist looks up domains on a list of known phishing sites, such as the
+// bot's synced feeds; domain is "" when none is listed
+type Blocklist interface {
+	PhishingDomain(ctx context.Context, domains []string) (domain, feed string, err error)
+}
+
+// Detector classifies text under a chat's Policy. Engine is the built-in one;
+// alternate engines and test doubles implement it too.
+type Detector interface {
+	// Detect runs every rule, most severe first
+	Detect(ctx context.Context, text string, policy Policy) (Verdict, error)
+	// Spam runs the rules moderating every message: the checks and the
+	// strike rules, without the ban rules the caller runs on its own
+	Spam(ctx context.Context, text string, policy Policy) Verdict
+}
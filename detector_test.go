@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"spambot/detector"
+)
+
+// testStore is an empty SQLite database that is removed after the test
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := OpenDatabase("sqlite", filepath.Join(t.TempDir(), "spambot.db"), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// fakeDetector records the policy it is asked to classify under
+type fakeDetector struct {
+	verdict detector.Verdict
+	err     error
+	policy  detector.Policy
+	text    string
+}
+
+func (f *fakeDetector) Detect(ctx context.Context, text string, policy detector.Policy) (detector.Verdict, error) {
+	f.text, f.policy = text, policy
+	return f.verdict, f.err
+}
+
+func (f *fakeDetector) Spam(ctx context.Context, text string, policy detector.Policy) detector.Verdict {
+	f.text, f.policy = text, policy
+	return f.verdict
+}
+
+func TestSpamDetectorDetect(t *testing.T) {
+	const chatID = -100123
+	errEngine := errors.New("engine down")
+	tests := []struct {
+		name     string
+		settings map[string]string
+		flags    map[string]bool
+		fake     fakeDetector
+		want     detector.Policy
+		wantErr  error
+	}{
+		{
+			name: "defaults",
+			fake: fakeDetector{verdict: detector.Verdict{Rule: "url", Severity: detector.SeverityStrike}},
+			want: detector.Policy{LinksAreSpam: true, DMSolicitation: "medium"},
+		},
+		{
+			name: "chat settings",
+			settings: map[string]string{
+				settingLinkPolicy:     "allow",
+				settingBlockedDomains: "bit.ly, tinyurl.com",
+				settingDMSolicitation: "high",
+				settingSpamKeywords:   "presale,whitelist",
+			},
+			flags: map[string]bool{flagKeywordOnly: false},
+			want: detector.Policy{
+				BlockedDomains: []string{"bit.ly", "tinyurl.com"},
+				DMSolicitation: "high",
+				SpamKeywords:   []string{"presale", "whitelist"},
+			},
+		},
+		{
+			name:     "keyword only",
+			settings: map[string]string{settingLinkPolicy: "verified"},
+			flags:    map[string]bool{flagKeywordOnly: true},
+			want:     detector.Policy{DMSolicitation: "medium", KeywordOnly: true},
+		},
+		{
+			name:    "detector error",
+			fake:    fakeDetector{err: errEngine},
+			want:    detector.Policy{LinksAreSpam: true, DMSolicitation: "medium"},
+			wantErr: errEngine,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testStore(t)
+			cfg := &Config{BanThreshold: 3}
+			flags, settings := NewFeatureFlags(db, cfg), NewChatSettings(db, cfg)
+			for key, value := range tt.settings {
+				if err := settings.Set(ctx, chatID, key, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for flag, enabled := range tt.flags {
+				if err := flags.Set(ctx, chatID, flag, enabled); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fake := tt.fake
+			sd := NewSpamDetector(db, flags, settings, cfg, detectorDeps{
+				Engine: func(detector.Options) detector.Detector { return &fake },
+			})
+
+			v, err := sd.Detect(ctx, chatID, "hello")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if v != tt.fake.verdict {
+				t.Errorf("Detect verdict = %+v, want the detector's %+v", v, tt.fake.verdict)
+			}
+			if fake.text != "hello" || !reflect.DeepEqual(fake.policy, tt.want) {
+				t.Errorf("Detect: detector got %q under %+v, want %q under %+v", fake.text, fake.policy, "hello", tt.want)
+			}
+
+			fake.text, fake.policy = "", detector.Policy{}
+			if v := sd.IsSpam(ctx, chatID, 0, "hello"); v != tt.fake.verdict {
+				t.Errorf("IsSpam verdict = %+v, want the detector's %+v", v, tt.fake.verdict)
+			}
+			if fake.text != "hello" || !reflect.DeepEqual(fake.policy, tt.want) {
+				t.Errorf("IsSpam: detector got %q under %+v, want %q under %+v", fake.text, fake.policy, "hello", tt.want)
+			}
+		})
+	}
+}
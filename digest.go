@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// digestCheckInterval is how often chats are checked for a due digest
+const digestCheckInterval = 10 * time.Minute
+
+// moderationRetention is how long moderation events are kept for digests;
+// a little over the longest digest period
+const moderationRetention = 8 * 24 * time.Hour
+
+// digestTopN is how many rules and members a digest lists
+const digestTopN = 3
+
+// digestPeriod is the last whole day, or week from Monday, before now in now's
+// location: the span the digest due at now covers
+func digestPeriod(frequency string, now time.Time) (since, until time.Time, ok bool) {
+	midnight := dayStart(now)
+	switch frequency {
+	case "daily":
+		return midnight.AddDate(0, 0, -1), midnight, true
+	case "weekly":
+		monday := midnight.AddDate(0, 0, -(int(midnight.Weekday())+6)%7)
+		return monday.AddDate(0, 0, -7), monday, true
+	}
+	return since, until, false
+}
+
+// RecordModeration keeps a moderation decision taken at at for chatID's
+// digest, and counts it in the chat's daily totals, which outlive the events,
+// on at's day in at's location. rule is the detector or policy that triggered
+// it, without the matched details.
+func (s *Store) RecordModeration(ctx context.Context, chatID, userID int64, action, rule string, at time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	if _, err := s.ExecContext(ctx, `
+		INSERT INTO moderation_events (chat_id, user_id, action, rule, at) VALUES (?, ?, ?, ?, ?)
+	`, chatID, userID, action, rule, at.Unix()); err != nil {
+		return err
+	}
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO moderation_counts (chat_id, day, action, count) VALUES (?, ?, ?, 1)
+		ON CONFLICT(chat_id, day, action) DO UPDATE SET count = moderation_counts.count + 1
+	`, chatID, localDay(at), action)
+	return err
+}
+
+// PruneModeration forgets moderation events older than before
+func (s *Store) PruneModeration(ctx context.Context, before time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM moderation_events WHERE at < ?`, before.Unix())
+	return err
+}
+
+// digestCount is a rule or member with how often it led to action
+type digestCount struct {
+	Name  string
+	Count int
+}
+
+// digestStats summarizes a chat's moderation over a digest period
+type digestStats struct {
+	Actions map[string]int
+	// Rules behind removals and bans, most frequent first
+	TopRules []digestCount
+	// Members with the most removed messages, most first
+	TopUsers []digestCount
+}
+
+// ModerationStats summarizes chatID's moderation events from since until until
+func (s *Store) ModerationStats(ctx context.Context, chatID int64, since, until time.Time) (digestStats, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	stats := digestStats{Actions: make(map[string]int)}
+	rows, err := s.QueryContext(ctx, `
+		SELECT action, COUNT(*) FROM moderation_events WHERE chat_id = ? AND at >= ? AND at < ? GROUP BY action
+	`, chatID, since.Unix(), until.Unix())
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var action string
+		var count int
+		if err := rows.Scan(&action, &count); err != nil {
+			return stats, err
+		}
+		stats.Actions[action] = count
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+	if stats.TopRules, err = s.topModeration(ctx, `
+		SELECT rule, COUNT(*) AS n FROM moderation_events
+		WHERE chat_id = ? AND at >= ? AND at < ? AND action IN ('delete', 'ban') AND rule <> ''
+		GROUP BY rule ORDER BY n DESC, rule LIMIT ?
+	`, chatID, since.Unix(), until.Unix(), digestTopN); err != nil {
+		return stats, err
+	}
+	stats.TopUsers, err = s.topModeration(ctx, `
+		SELECT CAST(user_id AS TEXT), COUNT(*) AS n FROM moderation_events
+		WHERE chat_id = ? AND at >= ? AND at < ? AND action = 'delete'
+		GROUP BY user_id ORDER BY n DESC, user_id LIMIT ?
+	`, chatID, since.Unix(), until.Unix(), digestTopN)
+	return stats, err
+}
+
+func (s *Store) topModeration(ctx context.Context, query string, args ...interface{}) ([]digestCount, error) {
+	rows, err := s.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var top []digestCount
+	for rows.Next() {
+		var c digestCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		top = append(top, c)
+	}
+	return top, rows.Err()
+}
+
+// ClaimDigest marks botID's digest for chatID as sent unless one was sent
+// since the period it covers ended at until; false means it isn't due, or
+// another instance sent it
+func (s *Store) ClaimDigest(ctx context.Context, botID, chatID int64, until time.Time) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO digests (chat_id, bot_id, sent_at) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, bot_id) DO UPDATE SET sent_at = excluded.sent_at WHERE digests.sent_at < ?
+	`, chatID, botID, time.Now().Unix(), until.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// moderationRule is the part of a moderation reason naming the rule, without
+// the matched phrase, link or address
+func moderationRule(reason string) string {
+	rule, _, _ := strings.Cut(reason, ":")
+	return strings.TrimSpace(rule)
+}
+
+// digestText renders a digest of stats for chat in its locale
+func (b *Bot) digestText(ctx context.Context, chat knownChat, frequency string, stats digestStats) string {
+	var text strings.Builder
+	text.WriteString(b.tr(ctx, chat.ID, "digest.title_"+frequency, chat.Title))
+	if stats.Actions["delete"] == 0 && stats.Actions["ban"] == 0 {
+		text.WriteString("\n\n" + b.tr(ctx, chat.ID, "digest.quiet"))
+		return text.String()
+	}
+	text.WriteString("\n\n" + b.tr(ctx, chat.ID, "digest.counts", stats.Actions["delete"], stats.Actions["ban"], stats.Actions["unban"]))
+	if len(stats.TopRules) > 0 {
+		text.WriteString("\n\n" + b.tr(ctx, chat.ID, "digest.top_rules"))
+		for _, rule := range stats.TopRules {
+			text.WriteString("\n" + b.tr(ctx, chat.ID, "digest.line", rule.Name, rule.Count))
+		}
+	}
+	if len(stats.TopUsers) > 0 {
+		text.WriteString("\n\n" + b.tr(ctx, chat.ID, "digest.top_users"))
+		for _, user := range stats.TopUsers {
+			text.WriteString("\n" + b.tr(ctx, chat.ID, "digest.line", user.Name, user.Count))
+		}
+	}
+	return text.String()
+}
+
+// sendDigest posts chat's digest to its admin_log_chat, or sends it to each
+// of its human admins in private when it has none
+func (b *Bot) sendDigest(ctx context.Context, chat knownChat, text string) {
+	if b.app.settings.Get(ctx, chat.ID, settingAdminLogChat) != "" {
+		b.adminLog(ctx, chat.ID, text)
+		return
+	}
+	admins, err := b.chatAdmins(ctx, chat.ID)
+	if err != nil {
+		b.logf("Failed to list admins of chat %d for its digest: %v", chat.ID, err)
+		b.app.reporter.Failure("telegram.getChatAdministrators", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chat.ID})
+		return
+	}
+	for _, admin := range admins {
+		// Admins who never started the bot can't be messaged; the outbox logs and drops those
+		if admin.User != nil && !admin.User.IsBot {
+			b.outbox.enqueue(tgbotapi.NewMessage(admin.User.ID, text))
+		}
+	}
+}
+
+// sendDueDigests sends the digest of every known chat whose digest period has passed
+func (b *Bot) sendDueDigests(ctx context.Context) {
+	chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list chats for digests: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		frequency := b.app.settings.Get(ctx, chat.ID, settingDigest)
+		since, until, ok := digestPeriod(frequency, b.app.settings.Now(ctx, chat.ID))
+		if !ok {
+			continue
+		}
+		due, err := b.app.db.ClaimDigest(ctx, b.api.Self.ID, chat.ID, until)
+		if err != nil {
+			b.logf("Failed to claim the digest of chat %d: %v", chat.ID, err)
+			b.app.reporter.Failure("db.claimDigest", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chat.ID})
+			continue
+		}
+		if !due {
+			continue
+		}
+		stats, err := b.app.db.ModerationStats(ctx, chat.ID, since, until)
+		if err != nil {
+			b.logf("Failed to summarize moderation in chat %d: %v", chat.ID, err)
+			b.app.reporter.Failure("db.moderationStats", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chat.ID})
+			continue
+		}
+		b.sendDigest(ctx, chat, b.digestText(ctx, chat, frequency, stats))
+		metrics.Add("digests_sent", 1)
+	}
+	if err := b.app.db.PruneModeration(ctx, time.Now().Add(-moderationRetention)); err != nil {
+		b.logf("Failed to prune moderation events: %v", err)
+	}
+	if err := b.app.db.PruneDetections(ctx, time.Now().Add(-moderationRetention)); err != nil {
+		b.logf("Failed to prune undisputed detections: %v", err)
+	}
+}
+
+// runDigests sends due digests until ctx is cancelled
+func (b *Bot) runDigests(ctx context.Context) {
+	for sleepContext(ctx, digestCheckInterval) {
+		b.sendDueDigests(ctx)
+	}
+}
+
+// maybeSendDigests checks digests at most once per digestCheckInterval, for
+// webhook mode where no background loop runs
+func (b *Bot) maybeSendDigests(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastDigests.Load()
+	if now-last < int64(digestCheckInterval/time.Second) || !b.lastDigests.CompareAndSwap(last, now) {
+		return
+	}
+	b.sendDueDigests(ctx)
+}
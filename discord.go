@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// discordWebhookHosts are the only hosts discord_webhook may point to, so a
+// chat admin can't make the bot post to arbitrary, possibly internal, hosts
+var discordWebhookHosts = []string{"discord.com", "discordapp.com"}
+
+// discordColors are the embed colors of the mirrored actions
+var discordColors = map[string]int{
+	"delete": 0xf1c40f,
+	"kick":   0xe67e22,
+	"ban":    0xe74c3c,
+	"unban":  0x2ecc71,
+}
+
+// discordMirrorTimeout bounds one webhook post
+const discordMirrorTimeout = 10 * time.Second
+
+// validDiscordWebhook reports whether rawURL is a Discord webhook URL
+func validDiscordWebhook(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return false
+	}
+	return containsString(discordWebhookHosts, u.Host) && strings.HasPrefix(u.Path, "/api/webhooks/")
+}
+
+// checkDiscordWebhook validates discord_webhook values
+func checkDiscordWebhook(value string) error {
+	if value != "" && !validDiscordWebhook(value) {
+		return fmt.Errorf("not a Discord webhook URL (https://discord.com/api/webhooks/...)")
+	}
+	return nil
+}
+
+// DiscordMirror posts moderation actions to Discord webhooks, for teams that
+// staff their moderation on Discord
+type DiscordMirror struct {
+	client *http.Client
+}
+
+func NewDiscordMirror() *DiscordMirror {
+	return &DiscordMirror{client: &http.Client{
+		Timeout: discordMirrorTimeout,
+		// A redirect would lead the post away from the validated host
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// discordEmbed is the part of Discord's embed object the mirror fills
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// Post sends embed to the Discord webhook URL
+func (d *DiscordMirror) Post(ctx context.Context, webhook, username string, embed discordEmbed) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"username": username,
+		"embeds":   []discordEmbed{embed},
+		// Reasons quote member messages; never let them ping anyone
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("discord webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mirrorToDiscord posts a deletion, removal, ban or unban in chatID to the
+// chat's discord_webhook, if set. It posts in the background so a slow
+// Discord doesn't hold up moderation.
+func (b *Bot) mirrorToDiscord(ctx context.Context, action string, chatID, userID int64, reason string) {
+	color, ok := discordColors[action]
+	if !ok {
+		return
+	}
+	webhook := b.app.settings.Get(ctx, chatID, settingDiscordWebhook)
+	if webhook == "" {
+		return
+	}
+	if !validDiscordWebhook(webhook) {
+		b.logf("Ignoring discord_webhook of chat %d: not a Discord webhook URL", chatID)
+		return
+	}
+	embed := discordEmbed{
+		Title:       b.tr(ctx, chatID, "discord."+action),
+		Description: reason,
+		Color:       color,
+		Fields: []discordEmbedField{
+			{Name: b.tr(ctx, chatID, "discord.chat"), Value: fmt.Sprint(chatID), Inline: true},
+			{Name: b.tr(ctx, chatID, "discord.user"), Value: fmt.Sprint(userID), Inline: true},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), discordMirrorTimeout)
+		defer cancel()
+		if err := b.app.discord.Post(ctx, webhook, b.api.Self.UserName, embed); err != nil {
+			b.logf("Failed to mirror %s in chat %d to Discord: %v", action, chatID, err)
+			b.app.reporter.Failure("discord.webhook", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+			return
+		}
+		metrics.Add("discord_mirrored", 1)
+	}()
+}
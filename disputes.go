@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Statuses of a detection in disputes: "open" while its member may still
+// dispute it, "disputed" while admins review it, then the admins' verdict
+const (
+	disputeOverturned = "overturned"
+	disputeUpheld     = "upheld"
+)
+
+// detection is a removed message its member was warned about
+type detection struct {
+	ChatID    int64
+	MessageID int
+	UserID    int64
+	Reason    string
+	Text      string
+}
+
+// SaveDetection remembers a removed message so its member can dispute it
+func (s *Store) SaveDetection(ctx context.Context, d detection) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO disputes (chat_id, message_id, user_id, reason, text, status, created_at) VALUES (?, ?, ?, ?, ?, 'open', ?)
+		ON CONFLICT(chat_id, message_id) DO NOTHING
+	`, d.ChatID, d.MessageID, d.UserID, d.Reason, d.Text, time.Now().Unix())
+	return err
+}
+
+// DisputeDetection marks userID's detection of messageID as disputed; ok is
+// false if it isn't theirs or was already disputed
+func (s *Store) DisputeDetection(ctx context.Context, chatID int64, messageID int, userID int64) (d detection, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE disputes SET status = 'disputed' WHERE chat_id = ? AND message_id = ? AND user_id = ? AND status = 'open'
+	`, chatID, messageID, userID)
+	if err != nil {
+		return d, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return d, false, err
+	}
+	d, err = s.detection(ctx, chatID, messageID)
+	return d, err == nil, err
+}
+
+// ResolveDispute records the admins' verdict on a disputed detection; ok is
+// false if it was already decided
+func (s *Store) ResolveDispute(ctx context.Context, chatID int64, messageID int, verdict string) (d detection, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE disputes SET status = ? WHERE chat_id = ? AND message_id = ? AND status = 'disputed'
+	`, verdict, chatID, messageID)
+	if err != nil {
+		return d, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return d, false, err
+	}
+	d, err = s.detection(ctx, chatID, messageID)
+	return d, err == nil, err
+}
+
+func (s *Store) detection(ctx context.Context, chatID int64, messageID int) (detection, error) {
+	d := detection{ChatID: chatID, MessageID: messageID}
+	err := s.QueryRowContext(ctx, `SELECT user_id, reason, text FROM disputes WHERE chat_id = ? AND message_id = ?`,
+		chatID, messageID).Scan(&d.UserID, &d.Reason, &d.Text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, fmt.Errorf("detection of message %d in chat %d not found", messageID, chatID)
+	}
+	return d, err
+}
+
+// PruneDetections forgets undisputed detections older than before; disputed
+// ones stay for false-positive tracking
+func (s *Store) PruneDetections(ctx context.Context, before time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM disputes WHERE status = 'open' AND created_at < ?`, before.Unix())
+	return err
+}
+
+// disputeButton lets the member warned about a removed message dispute it
+func (b *Bot) disputeButton(ctx context.Context, message *Message, reason string) *tgbotapi.InlineKeyboardMarkup {
+	err := b.app.db.SaveDetection(ctx, detection{
+		ChatID:    message.Chat.ID,
+		MessageID: message.MessageID,
+		UserID:    message.From.ID,
+		Reason:    reason,
+		Text:      messageText(message),
+	})
+	if err != nil {
+		b.logf("Failed to save the detection of message %d in chat %d: %v", message.MessageID, message.Chat.ID, err)
+		b.app.reporter.Failure("db.saveDetection", err, b.errorContext(message.Message))
+		return nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.trFor(ctx, message, "dispute.button"),
+			fmt.Sprintf("dispute:file:%d:%d", message.Chat.ID, message.MessageID)),
+	))
+	return &markup
+}
+
+// handleDisputeCallback handles a member disputing a detection ("file") and
+// the admins' verdict on it ("overturn" or "uphold")
+func (b *Bot) handleDisputeCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	fields := strings.Split(args, ":")
+	if len(fields) != 3 {
+		return
+	}
+	chatID, err1 := strconv.ParseInt(fields[1], 10, 64)
+	messageID, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil {
+		return
+	}
+	switch fields[0] {
+	case "file":
+		b.fileDispute(ctx, query, chatID, messageID)
+	case "overturn", "uphold":
+		b.decideDispute(ctx, query, chatID, messageID, fields[0])
+	}
+}
+
+// fileDispute puts a detection its member disputes in front of the admins,
+// where admin_log_chat is set or else in the chat itself
+func (b *Bot) fileDispute(ctx context.Context, query *tgbotapi.CallbackQuery, chatID int64, messageID int) {
+	d, ok, err := b.app.db.DisputeDetection(ctx, chatID, messageID, query.From.ID)
+	if err != nil {
+		b.logf("Failed to dispute the detection of message %d in chat %d: %v", messageID, chatID, err)
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	if !ok {
+		// Someone else's warning, or already disputed
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "dispute.not_yours")))
+		return
+	}
+	metrics.Add("detections_disputed", 1)
+	if err := b.app.db.RecordModeration(ctx, chatID, d.UserID, "dispute", moderationRule(d.Reason), b.app.settings.Now(ctx, chatID)); err != nil {
+		b.logf("Failed to record the dispute for the digest: %v", err)
+	}
+	b.logf("User %d disputes the removal of message %d in chat %d (%s)", d.UserID, messageID, chatID, d.Reason)
+
+	name := query.From.FirstName
+	if query.From.UserName != "" {
+		name = "@" + query.From.UserName
+	}
+	quoted := d.Text
+	if runes := []rune(quoted); len(runes) > banAlertTextLimit {
+		quoted = string(runes[:banAlertTextLimit]) + "…"
+	}
+	text := b.tr(ctx, chatID, "dispute.review", name, d.Reason, quoted)
+	target := chatID
+	if id, err := strconv.ParseInt(b.app.settings.Get(ctx, chatID, settingAdminLogChat), 10, 64); err == nil {
+		target = id
+	}
+	review := tgbotapi.NewMessage(target, text)
+	id := fmt.Sprintf("%d:%d", chatID, messageID)
+	review.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "dispute.button_overturn"), "dispute:overturn:"+id),
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "dispute.button_uphold"), "dispute:uphold:"+id),
+	))
+	b.outbox.enqueue(review)
+
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "dispute.filed")))
+	b.request(ctx, tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "dispute.filed")))
+}
+
+// decideDispute records an admin's verdict; overturning takes back the strike
+// and counts the detection as a false positive of its rule
+func (b *Bot) decideDispute(ctx context.Context, query *tgbotapi.CallbackQuery, chatID int64, messageID int, action string) {
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "dispute.admins_only")))
+		return
+	}
+	verdict := map[string]string{"overturn": disputeOverturned, "uphold": disputeUpheld}[action]
+	d, ok, err := b.app.db.ResolveDispute(ctx, chatID, messageID, verdict)
+	if err != nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	if !ok {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.already_decided")))
+		return
+	}
+	if verdict == disputeOverturned {
+		b.overturned(ctx, chatID, messageID, d)
+	}
+	b.logf("Dispute of message %d in chat %d %s by %s", messageID, chatID, verdict, query.From.UserName)
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "dispute."+verdict)))
+	b.request(ctx, tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "dispute."+verdict+"_by", query.From.FirstName)))
+	b.outbox.enqueue(tgbotapi.NewMessage(d.UserID, b.tr(ctx, chatID, "dispute.member_"+verdict)))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emailAlerts are the critical conditions EMAIL_ALERTS can select: a bot
+// losing its admin rights, database failures, Telegram failing to deliver
+// webhooks, and event mode (lockdown) starting
+var emailAlerts = []string{"lost_admin", "db_failures", "webhook_down", "event_mode"}
+
+// emailAlertInterval is the least time between two emails about one condition,
+// so a failing database doesn't flood the inbox
+const emailAlertInterval = 15 * time.Minute
+
+// emailAlerter mails critical conditions to the operators over SMTP
+type emailAlerter struct {
+	addr       string
+	auth       smtp.Auth
+	from       string
+	to         []string
+	conditions []string
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+// newEmailAlerter returns nil when SMTP_ADDR is unset
+func newEmailAlerter(cfg *Config) *emailAlerter {
+	if cfg.SMTPAddr == "" {
+		return nil
+	}
+	e := &emailAlerter{
+		addr:       cfg.SMTPAddr,
+		from:       cfg.SMTPFrom,
+		to:         cfg.AlertEmails,
+		conditions: cfg.EmailAlerts,
+		sent:       make(map[string]time.Time),
+	}
+	if cfg.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(cfg.SMTPAddr)
+		e.auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, host)
+	}
+	return e
+}
+
+// enabled reports whether condition is one EMAIL_ALERTS selects
+func (e *emailAlerter) enabled(condition string) bool {
+	return e != nil && containsString(e.conditions, condition)
+}
+
+// send mails an alert about condition unless one went out within
+// emailAlertInterval. It doesn't wait for delivery.
+func (e *emailAlerter) send(condition, subject, body string) {
+	if !e.enabled(condition) {
+		return
+	}
+	e.mu.Lock()
+	if time.Since(e.sent[condition]) < emailAlertInterval {
+		e.mu.Unlock()
+		return
+	}
+	e.sent[condition] = time.Now()
+	e.mu.Unlock()
+
+	// Header values can't span lines
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [spambot] %s\r\nDate: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		e.from, strings.Join(e.to, ", "), subject, time.Now().Format(time.RFC1123Z), body)
+	go func() {
+		if err := smtp.SendMail(e.addr, e.auth, e.from, e.to, []byte(msg)); err != nil {
+			log.Printf("Failed to email %s alert: %v", condition, err)
+		}
+	}()
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// eventSweepInterval is how often expired event modes and captchas are cleaned up
+	eventSweepInterval = 15 * time.Second
+	// eventCacheTTL is how long a chat's event mode state is reused between messages
+	eventCacheTTL = 30 * time.Second
+	// maxEventDuration caps how long one /event can tighten the rules
+	maxEventDuration = 7 * 24 * time.Hour
+)
+
+// eventRestrictions are the valid event_restrictions entries
+var eventRestrictions = []string{"links", "forwards", "captcha", "slow_mode"}
+
+// StartEvent puts chatID in event mode until endsAt, replacing a running one
+func (s *Store) StartEvent(ctx context.Context, chatID, botID, startedBy int64, endsAt time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO event_modes (chat_id, bot_id, started_by, ends_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET bot_id = excluded.bot_id, started_by = excluded.started_by, ends_at = excluded.ends_at
+	`, chatID, botID, startedBy, endsAt.Unix())
+	return err
+}
+
+// EndEvent takes chatID out of event mode; false means it wasn't in it, or
+// another instance ended it first
+func (s *Store) EndEvent(ctx context.Context, chatID int64) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `DELETE FROM event_modes WHERE chat_id = ?`, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// EventEnd returns when chatID's event mode ends; ok is false if none is running
+func (s *Store) EventEnd(ctx context.Context, chatID int64) (endsAt time.Time, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var unix int64
+	err = s.QueryRowContext(ctx, `SELECT ends_at FROM event_modes WHERE chat_id = ? AND ends_at > ?`, chatID, time.Now().Unix()).Scan(&unix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(unix, 0), err == nil, err
+}
+
+// ExpiredEvents lists the chats whose event mode botID started has run out
+func (s *Store) ExpiredEvents(ctx context.Context, botID int64) ([]int64, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `SELECT chat_id FROM event_modes WHERE bot_id = ? AND ends_at <= ?`, botID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var chats []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		chats = append(chats, id)
+	}
+	return chats, rows.Err()
+}
+
+// pendingCaptcha is a new member who hasn't passed the event mode captcha yet
+type pendingCaptcha struct {
+	ChatID    int64
+	UserID    int64
+	MessageID int
+}
+
+// SaveCaptcha records the captcha botID posted for userID, due by expiresAt
+func (s *Store) SaveCaptcha(ctx context.Context, botID int64, c pendingCaptcha, expiresAt time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO event_captchas (chat_id, user_id, bot_id, message_id, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET bot_id = excluded.bot_id, message_id = excluded.message_id, expires_at = excluded.expires_at
+	`, c.ChatID, c.UserID, botID, c.MessageID, expiresAt.Unix())
+	return err
+}
+
+// ClaimCaptcha removes userID's pending captcha; false means there was none left
+func (s *Store) ClaimCaptcha(ctx context.Context, chatID, userID int64) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `DELETE FROM event_captchas WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ExpiredCaptchas lists botID's captchas that weren't solved in time
+func (s *Store) ExpiredCaptchas(ctx context.Context, botID int64) ([]pendingCaptcha, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT chat_id, user_id, message_id FROM event_captchas WHERE bot_id = ? AND expires_at <= ? LIMIT 100
+	`, botID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var due []pendingCaptcha
+	for rows.Next() {
+		var c pendingCaptcha
+		if err := rows.Scan(&c.ChatID, &c.UserID, &c.MessageID); err != nil {
+			return nil, err
+		}
+		due = append(due, c)
+	}
+	return due, rows.Err()
+}
+
+// eventCache remembers which chats are in event mode, so every group message
+// doesn't cost a database query
+type eventCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedEvent
+	// chat:user -> time of the member's last message, for slow mode
+	posted map[string]time.Time
+}
+
+type cachedEvent struct {
+	endsAt  time.Time
+	checked time.Time
+}
+
+func newEventCache() *eventCache {
+	return &eventCache{entries: make(map[int64]cachedEvent), posted: make(map[string]time.Time)}
+}
+
+func (c *eventCache) set(chatID int64, endsAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[chatID] = cachedEvent{endsAt: endsAt, checked: time.Now()}
+}
+
+// allowPost records a message and reports whether the member's previous one
+// was at least interval ago
+func (c *eventCache) allowPost(chatID, userID int64, interval time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.posted) > 10000 {
+		for k, t := range c.posted {
+			if now.Sub(t) > interval {
+				delete(c.posted, k)
+			}
+		}
+	}
+	key := fmt.Sprintf("%d:%d", chatID, userID)
+	if last, ok := c.posted[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	c.posted[key] = now
+	return true
+}
+
+// eventEnd returns when chatID's event mode ends, or the zero time if it isn't in one
+func (b *Bot) eventEnd(ctx context.Context, chatID int64) time.Time {
+	b.events.mu.Lock()
+	cached, ok := b.events.entries[chatID]
+	b.events.mu.Unlock()
+	if ok && time.Since(cached.checked) < eventCacheTTL {
+		if time.Now().Before(cached.endsAt) {
+			return cached.endsAt
+		}
+		return time.Time{}
+	}
+	endsAt, _, err := b.app.db.EventEnd(ctx, chatID)
+	if err != nil {
+		b.logf("Failed to look up event mode of chat %d: %v", chatID, err)
+		return time.Time{}
+	}
+	b.events.set(chatID, endsAt)
+	return endsAt
+}
+
+// eventRestricts reports whether chatID is in event mode with restriction enabled
+func (b *Bot) eventRestricts(ctx context.Context, chatID int64, restriction string) bool {
+	if b.eventEnd(ctx, chatID).IsZero() {
+		return false
+	}
+	return containsString(listSetting(b.app.settings.Get(ctx, chatID, settingEventRestrictions)), restriction)
+}
+
+// parseEventDuration reads "90m", "2h" or a bare number of hours
+func parseEventDuration(s string) (time.Duration, error) {
+	if hours, err := strconv.Atoi(s); err == nil {
+		s = strconv.Itoa(hours) + "h"
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute || d > maxEventDuration {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	return d, nil
+}
+
+// describeEvent lists what event mode tightens in chatID
+func (b *Bot) describeEvent(ctx context.Context, chatID int64) string {
+	var rules []string
+	enabled := listSetting(b.app.settings.Get(ctx, chatID, settingEventRestrictions))
+	if containsString(enabled, "links") {
+		rules = append(rules, b.tr(ctx, chatID, "event.rule_links"))
+	}
+	if containsString(enabled, "forwards") {
+		rules = append(rules, b.tr(ctx, chatID, "event.rule_forwards"))
+	}
+	if containsString(enabled, "captcha") {
+		rules = append(rules, b.tr(ctx, chatID, "event.rule_captcha"))
+	}
+	if containsString(enabled, "slow_mode") {
+		rules = append(rules, b.tr(ctx, chatID, "event.rule_slow_mode", b.app.settings.Get(ctx, chatID, settingEventSlowMode)))
+	}
+	if len(rules) == 0 {
+		return b.tr(ctx, chatID, "event.no_rules")
+	}
+	return strings.Join(rules, ", ")
+}
+
+// cmdEvent handles "/event <duration>" to tighten the rules during launches
+// and airdrops, "/event off" to end it early and "/event" to show the state
+func (b *Bot) cmdEvent(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	arg := strings.TrimSpace(message.CommandArguments())
+	switch arg {
+	case "":
+		if endsAt := b.eventEnd(ctx, chatID); !endsAt.IsZero() {
+			b.reply(message, b.trFor(ctx, message, "event.status", endsAt.UTC().Format("2006-01-02 15:04"), b.describeEvent(ctx, chatID)))
+		} else {
+			b.reply(message, b.trFor(ctx, message, "event.status_off"))
+		}
+		return
+	case "off":
+		ended, err := b.app.db.EndEvent(ctx, chatID)
+		if err != nil {
+			b.logf("Failed to end event mode in chat %d: %v", chatID, err)
+			b.reply(message, b.trFor(ctx, message, "event.end_failed"))
+			return
+		}
+		b.events.set(chatID, time.Time{})
+		if !ended {
+			b.reply(message, b.trFor(ctx, message, "event.not_on"))
+			return
+		}
+		b.audit(ctx, "event_end", chatID, message.From.ID, 0, "ended early")
+		b.reply(message, b.trFor(ctx, message, "event.ended"))
+		return
+	}
+
+	duration, err := parseEventDuration(arg)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "event.usage"))
+		return
+	}
+	endsAt := time.Now().Add(duration).Truncate(time.Second)
+	if err := b.app.db.StartEvent(ctx, chatID, b.api.Self.ID, message.From.ID, endsAt); err != nil {
+		b.logf("Failed to start event mode in chat %d: %v", chatID, err)
+		b.app.reporter.Failure("db.startEvent", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "event.start_failed"))
+		return
+	}
+	b.events.set(chatID, endsAt)
+	metrics.Add("event_modes", 1)
+	b.audit(ctx, "event_start", chatID, message.From.ID, 0, "until "+endsAt.UTC().Format(time.RFC3339))
+	b.app.reporter.Event("event_mode", fmt.Sprintf("🛡 %s started event mode in %s until %s UTC",
+		message.From.UserName, message.Chat.Title, endsAt.UTC().Format("2006-01-02 15:04")), b.errorContext(message.Message))
+	b.reply(message, b.trFor(ctx, message, "event.started", endsAt.UTC().Format("2006-01-02 15:04"), b.describeEvent(ctx, chatID)))
+}
+
+// applyEventMode enforces the link, forward and slow mode restrictions of a
+// running event mode. Messages are deleted without a strike, since posting
+// a link or a second message isn't spam on a normal day. Returns true when
+// the message was removed.
+func (b *Bot) applyEventMode(ctx context.Context, message *Message, text string) bool {
+	chatID := message.Chat.ID
+	if b.eventEnd(ctx, chatID).IsZero() {
+		return false
+	}
+	enabled := listSetting(b.app.settings.Get(ctx, chatID, settingEventRestrictions))
+	reason := ""
+	switch {
+	case containsString(enabled, "forwards") && (message.ForwardDate != 0 || message.ext.ForwardOrigin != nil):
+		reason = "forward during event mode"
+	case containsString(enabled, "links") && text != "" && b.app.detector.HasLink(text):
+		reason = "link during event mode"
+	case containsString(enabled, "slow_mode"):
+		seconds, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingEventSlowMode))
+		if seconds > 0 && !b.events.allowPost(chatID, message.From.ID, time.Duration(seconds)*time.Second) {
+			reason = "slow mode during event mode"
+		}
+	}
+	if reason == "" {
+		return false
+	}
+	metrics.Add("event_mode_deletions", 1)
+	b.deleteMessage(ctx, message, reason)
+	return true
+}
+
+// challengeMember mutes a member who joined during event mode until they
+// press the captcha button, or removes them once event_captcha_minutes pass
+func (b *Bot) challengeMember(ctx context.Context, message *Message, member tgbotapi.User) {
+	chatID := message.Chat.ID
+	ec := ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: member.ID}
+	restrict := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: member.ID},
+		Permissions:      &tgbotapi.ChatPermissions{},
+	}
+	if _, err := b.request(ctx, restrict); err != nil {
+		b.logf("Failed to mute new member %d for the captcha in chat %d: %v", member.ID, chatID, err)
+		b.app.reporter.Failure("telegram.restrictChatMember", err, ec)
+		return
+	}
+	minutes, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingEventCaptchaMinutes))
+	msg := htmlMessage(chatID, b.tr(ctx, chatID, "event.captcha", mention(member), minutes))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "event.captcha_button"), "captcha:"+strconv.FormatInt(member.ID, 10)),
+	))
+	sent, err := b.send(ctx, msg)
+	if err != nil {
+		return
+	}
+	captcha := pendingCaptcha{ChatID: chatID, UserID: member.ID, MessageID: sent.MessageID}
+	if err := b.app.db.SaveCaptcha(ctx, b.api.Self.ID, captcha, time.Now().Add(time.Duration(minutes)*time.Minute)); err != nil {
+		b.logf("Failed to save captcha of %d in chat %d: %v", member.ID, chatID, err)
+		b.app.reporter.Failure("db.saveCaptcha", err, ec)
+	}
+	metrics.Add("event_captchas", 1)
+}
+
+// handleCaptchaCallback lifts the mute of a member who pressed their own captcha button
+func (b *Bot) handleCaptchaCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	chatID := query.Message.Chat.ID
+	userID, err := strconv.ParseInt(args, 10, 64)
+	if err != nil || userID != query.From.ID {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.not_for_you")))
+		return
+	}
+	claimed, err := b.app.db.ClaimCaptcha(ctx, chatID, userID)
+	if err != nil || !claimed {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	unrestrict := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID},
+		Permissions: &tgbotapi.ChatPermissions{
+			CanSendMessages:       true,
+			CanSendMediaMessages:  true,
+			CanSendPolls:          true,
+			CanSendOtherMessages:  true,
+			CanAddWebPagePreviews: true,
+			CanChangeInfo:         true,
+			CanInviteUsers:        true,
+			CanPinMessages:        true,
+		},
+	}
+	if _, err := b.request(ctx, unrestrict); err != nil {
+		b.logf("Failed to unmute member %d after the captcha in chat %d: %v", userID, chatID, err)
+		b.app.reporter.Failure("telegram.restrictChatMember", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+	}
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "event.captcha_passed")))
+	b.request(ctx, tgbotapi.NewDeleteMessage(chatID, query.Message.MessageID))
+}
+
+// sweepEvents removes members who didn't pass their captcha in time and
+// announces the end of event modes that ran out
+func (b *Bot) sweepEvents(ctx context.Context) {
+	captchas, err := b.app.db.ExpiredCaptchas(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list expired captchas: %v", err)
+	}
+	for _, c := range captchas {
+		if claimed, err := b.app.db.ClaimCaptcha(ctx, c.ChatID, c.UserID); err != nil || !claimed {
+			continue
+		}
+		b.removeMember(ctx, c.ChatID, tgbotapi.User{ID: c.UserID}, false, "didn't pass the event mode captcha")
+		b.request(ctx, tgbotapi.NewDeleteMessage(c.ChatID, c.MessageID))
+	}
+
+	chats, err := b.app.db.ExpiredEvents(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list expired event modes: %v", err)
+	}
+	for _, chatID := range chats {
+		if ended, err := b.app.db.EndEvent(ctx, chatID); err != nil || !ended {
+			continue
+		}
+		b.events.set(chatID, time.Time{})
+		b.audit(ctx, "event_end", chatID, 0, 0, "expired")
+		b.outbox.enqueue(tgbotapi.NewMessage(chatID, b.tr(ctx, chatID, "event.expired")))
+	}
+}
+
+// runEvents sweeps event modes and captchas until ctx is cancelled
+func (b *Bot) runEvents(ctx context.Context) {
+	for sleepContext(ctx, eventSweepInterval) {
+		b.sweepEvents(ctx)
+	}
+}
+
+// maybeSweepEvents sweeps at most once per eventSweepInterval, for webhook
+// mode where no background loop runs
+func (b *Bot) maybeSweepEvents(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastEvents.Load()
+	if now-last < int64(eventSweepInterval/time.Second) || !b.lastEvents.CompareAndSwap(last, now) {
+		return
+	}
+	b.sweepEvents(ctx)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// dashboardFeedLimit caps how many moderation events a feed lists
+const dashboardFeedLimit = 50
+
+// atomFeed is the Atom (RFC 4287) document a moderation feed is served as
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string `xml:"title"`
+	ID      string `xml:"id"`
+	Updated string `xml:"updated"`
+	Summary string `xml:"summary"`
+}
+
+// feedToken authenticates the feed of botID's chatID for feed readers, which
+// can't sign in; rotating DASHBOARD_SECRET revokes every feed link
+func (h *DashboardHandler) feedToken(botID, chatID int64) string {
+	return h.sign(fmt.Sprintf("feed:%d:%d", botID, chatID))
+}
+
+// dashboardURL is the absolute link to path on the dashboard, as r reached it
+func dashboardURL(r *http.Request, path string, query url.Values) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + dashboardPathPrefix + path + "?" + query.Encode()
+}
+
+// feedURL is the link to the feed of botID's chatID
+func (h *DashboardHandler) feedURL(r *http.Request, botID, chatID int64) string {
+	return dashboardURL(r, "/feed", url.Values{
+		"bot":   {strconv.FormatInt(botID, 10)},
+		"chat":  {strconv.FormatInt(chatID, 10)},
+		"token": {h.feedToken(botID, chatID)},
+	})
+}
+
+// serveFeed serves a chat's recent moderation events as an Atom feed to
+// anyone with its link, newest first, in the chat's locale
+func (h *DashboardHandler) serveFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	botID, _ := strconv.ParseInt(query.Get("bot"), 10, 64)
+	chatID, _ := strconv.ParseInt(query.Get("chat"), 10, 64)
+	bot := h.bot(botID)
+	if bot == nil || !hmac.Equal([]byte(query.Get("token")), []byte(h.feedToken(botID, chatID))) {
+		http.NotFound(w, r)
+		return
+	}
+	chat, ok, err := h.knownChat(ctx, bot, chatID)
+	if err != nil {
+		http.Error(w, "failed to load chat", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	events, err := bot.app.db.ModerationLog(ctx, chatID, dashboardFeedLimit)
+	if err != nil {
+		bot.logf("Failed to list moderation in chat %d for its feed: %v", chatID, err)
+		http.Error(w, "failed to load the moderation log", http.StatusInternalServerError)
+		return
+	}
+
+	locale := primaryLocale(bot.app.settings.Get(ctx, chatID, settingLocale))
+	page := url.Values{"bot": {strconv.FormatInt(botID, 10)}, "chat": {strconv.FormatInt(chatID, 10)}}
+	feed := atomFeed{
+		Title:   translate(locale, "feed.title", chat.Title),
+		ID:      fmt.Sprintf("urn:spambot:moderation:%d:%d", botID, chatID),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "@" + bot.api.Self.UserName},
+		Links: []atomLink{
+			{Rel: "self", Href: h.feedURL(r, botID, chatID)},
+			{Rel: "alternate", Href: dashboardURL(r, "/chat", page)},
+		},
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].At.UTC().Format(time.RFC3339)
+	}
+	for _, e := range events {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   translate(locale, "feed.entry", e.Action, e.UserID),
+			ID:      fmt.Sprintf("urn:spambot:moderation:%d:%d:%d:%d:%s:%s", botID, chatID, e.At.Unix(), e.UserID, e.Action, url.QueryEscape(e.Rule)),
+			Updated: e.At.UTC().Format(time.RFC3339),
+			Summary: translate(locale, "feed.summary", e.Action, e.UserID, e.Rule),
+		})
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		bot.logf("Failed to write the moderation feed of chat %d: %v", chatID, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// featureFlag describes a switch gating an experimental detector or action
+type featureFlag struct {
+	Name        string
+	Description string
+	Default     bool
+	// Whether chat admins may switch it in their chat; the rest, such as
+	// those that move APT from the bot's wallets, are for the owner only
+	AdminToggle bool
+}
+
+// knownFlags is the registry of flags; resolving an unregistered name is always false
+var knownFlags = map[string]featureFlag{}
+
+func registerFlag(name, description string, def, adminToggle bool) string {
+	knownFlags[name] = featureFlag{Name: name, Description: description, Default: def, AdminToggle: adminToggle}
+	return name
+}
+
+var (
+	flagKeywordOnly = registerFlag("keyword_only", "flag spam keywords even without a mention", false, true)
+	flagCASCheck    = registerFlag("cas_check", "decline join requests from users on the CAS ban list", true, true)
+	flagBanBonds    = registerFlag("ban_bonds", "let banned members appeal by locking ban_bond_apt APT, via /start appeal_<chat id>", false, false)
+	flagTips        = registerFlag("tips", "let admins pay /tip rewards from the bot's TIP_WALLET_KEY wallet", false, false)
+)
+
+// flagCacheTTL bounds how stale per-chat flags can be when other instances change them
+const flagCacheTTL = time.Minute
+
+// flagCacheSize bounds the chats whose overrides are kept
+const flagCacheSize = 10000
+
+// globalFlagChat is the chat_id under which global overrides are stored
+const globalFlagChat = 0
+
+// FeatureFlags resolves flags: per-chat DB override > global DB override > FEATURE_FLAGS env > default
+type FeatureFlags struct {
+	db  *Store
+	env map[string]bool
+
+	mu    sync.Mutex
+	cache *lruCache[int64, map[string]bool]
+}
+
+// NewFeatureFlags parses FEATURE_FLAGS entries of the form "name" or "name=false"
+func NewFeatureFlags(db *Store, cfg *Config) *FeatureFlags {
+	f := &FeatureFlags{db: db, cache: newLRUCache[int64, map[string]bool]("flags", flagCacheSize, flagCacheTTL)}
+	f.Reload(cfg)
+	return f
+}
+
+// Reload replaces the env-level defaults
+func (f *FeatureFlags) Reload(cfg *Config) {
+	env := make(map[string]bool)
+	for _, entry := range cfg.FeatureFlags {
+		name, value, hasValue := strings.Cut(entry, "=")
+		env[strings.TrimSpace(name)] = !hasValue || parseSwitch(value)
+	}
+	f.mu.Lock()
+	f.env = env
+	f.mu.Unlock()
+}
+
+// parseSwitch accepts on/off style values
+func parseSwitch(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "on", "true", "yes", "enable", "enabled":
+		return true
+	}
+	return false
+}
+
+// Enabled reports whether flag is on for chatID
+func (f *FeatureFlags) Enabled(ctx context.Context, chatID int64, flag string) bool {
+	def, ok := knownFlags[flag]
+	if !ok {
+		return false
+	}
+	if v, ok := f.overrides(ctx, chatID)[flag]; ok {
+		return v
+	}
+	if v, ok := f.overrides(ctx, globalFlagChat)[flag]; ok {
+		return v
+	}
+	f.mu.Lock()
+	v, ok := f.env[flag]
+	f.mu.Unlock()
+	if ok {
+		return v
+	}
+	return def.Default
+}
+
+// overrides loads the DB overrides for a chat, cached for flagCacheTTL
+func (f *FeatureFlags) overrides(ctx context.Context, chatID int64) map[string]bool {
+	if values, ok := f.cache.get(chatID); ok {
+		return values
+	}
+
+	values := make(map[string]bool)
+	ctx, cancel := f.db.OpContext(ctx)
+	defer cancel()
+	rows, err := f.db.QueryContext(ctx, `SELECT name, enabled FROM feature_flags WHERE chat_id = ?`, chatID)
+	if err != nil {
+		// Keep serving the stale copy rather than flipping flags on a DB hiccup
+		stale, _ := f.cache.stale(chatID)
+		return stale
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err == nil {
+			values[name] = enabled
+		}
+	}
+
+	f.cache.put(chatID, values)
+	return values
+}
+
+// Set stores an override for chatID (globalFlagChat for all chats)
+func (f *FeatureFlags) Set(ctx context.Context, chatID int64, flag string, enabled bool) error {
+	if _, ok := knownFlags[flag]; !ok {
+		return fmt.Errorf("unknown flag %q", flag)
+	}
+	ctx, cancel := f.db.OpContext(ctx)
+	defer cancel()
+	_, err := f.db.ExecContext(ctx, `
+		INSERT INTO feature_flags (chat_id, name, enabled) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, name) DO UPDATE SET enabled = excluded.enabled
+	`, chatID, flag, enabled)
+	f.invalidate(chatID)
+	return err
+}
+
+// Clear removes an override so the chat falls back to the next level
+func (f *FeatureFlags) Clear(ctx context.Context, chatID int64, flag string) error {
+	ctx, cancel := f.db.OpContext(ctx)
+	defer cancel()
+	_, err := f.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE chat_id = ? AND name = ?`, chatID, flag)
+	f.invalidate(chatID)
+	return err
+}
+
+func (f *FeatureFlags) invalidate(chatID int64) {
+	f.cache.remove(chatID)
+}
+
+// Describe lists every flag with its effective state for chatID
+func (f *FeatureFlags) Describe(ctx context.Context, chatID int64) string {
+	names := make([]string, 0, len(knownFlags))
+	for name := range knownFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		state := "off"
+		if f.Enabled(ctx, chatID, name) {
+			state = "on"
+		}
+		owner := ""
+		if !knownFlags[name].AdminToggle {
+			owner = " (owner only)"
+		}
+		fmt.Fprintf(&b, "%s: %s%s - %s\n", name, state, owner, knownFlags[name].Description)
+	}
+	return b.String()
+}
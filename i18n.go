@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// locales are the message catalogs by locale. English has every message and
+// is the fallback for any a translation lacks; add new messages there first.
+var locales = map[string]map[string]string{
+	"en": messagesEN,
+	"ko": messagesKO,
+}
+
+// bilingualLocales are the locale values that render every message in two
+// languages, the first one first, for chats whose members don't share one
+var bilingualLocales = []string{"ko+en", "en+ko"}
+
+// bilingualLabelLimit is the longest single-line rendering joined with " / "
+// rather than a blank line, so buttons and labels stay on one line
+const bilingualLabelLimit = 40
+
+// translate renders the catalog message key in locale with fmt verbs filled
+// from args. Unknown keys render as themselves so a typo shows up in chat
+// rather than an empty message. A bilingual locale such as "ko+en" renders
+// the message in each language, once if they read the same.
+func translate(locale, key string, args ...interface{}) string {
+	if first, second, ok := strings.Cut(locale, "+"); ok {
+		a, b := translate(first, key, args...), translate(second, key, args...)
+		switch {
+		case a == b:
+			return a
+		case !strings.Contains(a+b, "\n") && utf8.RuneCountInString(a) <= bilingualLabelLimit && utf8.RuneCountInString(b) <= bilingualLabelLimit:
+			return a + " / " + b
+		}
+		return a + "\n\n" + b
+	}
+	format, ok := locales[locale][key]
+	if !ok {
+		if format, ok = messagesEN[key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// primaryLocale is the first language of a bilingual locale, for pages that
+// show one language
+func primaryLocale(locale string) string {
+	first, _, _ := strings.Cut(locale, "+")
+	return first
+}
+
+// tr renders a message in chatID's locale, for notices that aren't answers to a message
+func (b *Bot) tr(ctx context.Context, chatID int64, key string, args ...interface{}) string {
+	return translate(b.app.settings.Get(ctx, chatID, settingLocale), key, args...)
+}
+
+// messageLocale is the locale to answer message in: its chat's, or its forum
+// topic's; in private the sender's Telegram language when there is a catalog
+// for it
+func (b *Bot) messageLocale(ctx context.Context, message *Message) string {
+	if message.Chat.Type == "private" && message.From != nil {
+		language, _, _ := strings.Cut(strings.ToLower(message.From.LanguageCode), "-")
+		if _, ok := locales[language]; ok {
+			return language
+		}
+	}
+	return b.setting(ctx, message, settingLocale)
+}
+
+// trFor renders a message in the locale to answer message in
+func (b *Bot) trFor(ctx context.Context, message *Message, key string, args ...interface{}) string {
+	return translate(b.messageLocale(ctx, message), key, args...)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// How long an action intent blocks the same action: for a message, as long
+// as processed updates are remembered; for a member, long enough to cover
+// duplicate join updates and overlapping instances, short enough that a
+// spammer who rejoins is removed again
+const (
+	messageIntentTTL = updateRetention
+	memberIntentTTL  = 10 * time.Minute
+)
+
+// ClaimIntent records the intent to take the action key names, e.g.
+// "punish:<chat>:<message>", for ttl; false means this or another instance
+// already claimed it and the action must not be taken again
+func (s *Store) ClaimIntent(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO action_intents (intent_key, expires_at) VALUES (?, ?)
+		ON CONFLICT(intent_key) DO UPDATE SET expires_at = excluded.expires_at
+		WHERE action_intents.expires_at < ?
+	`, key, now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// PruneIntents forgets expired action intents
+func (s *Store) PruneIntents(ctx context.Context) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+
+	_, err := s.ExecContext(ctx, `DELETE FROM action_intents WHERE expires_at < ?`, time.Now().Unix())
+	return err
+}
+
+// claimIntent reports whether the bot should take the action key names, i.e.
+// no instance or bot took it yet. When the database fails it acts anyway:
+// acting twice is better than not at all.
+func (b *Bot) claimIntent(ctx context.Context, ttl time.Duration, format string, args ...interface{}) bool {
+	key := fmt.Sprintf(format, args...)
+	claimed, err := b.app.db.ClaimIntent(ctx, key, ttl)
+	if err != nil {
+		b.logf("Failed to claim action %s: %v", key, err)
+		b.app.reporter.Failure("db.claimIntent", err, b.errorContext(nil))
+		return true
+	}
+	if !claimed {
+		b.logf("Skipping %s: already done", key)
+		metrics.Add("actions_deduplicated", 1)
+	}
+	return claimed
+}
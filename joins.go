@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleJoinRequest screens a request to join a chat that requires admin approval.
+// Clean requests are approved, CAS-listed users declined, and suspicious ones
+// either declined or escalated to the chat's admins with Approve/Decline buttons.
+func (b *Bot) handleJoinRequest(ctx context.Context, request *tgbotapi.ChatJoinRequest) {
+	chatID, user := request.Chat.ID, request.From
+	if b.app.settings.Get(ctx, chatID, settingJoinRequestPolicy) != "screen" {
+		return
+	}
+	metrics.Add("join_requests", 1)
+
+	switch verdict, reason := b.screenUser(ctx, chatID, user, request.Bio); verdict {
+	case screenBanned:
+		b.resolveJoinRequest(ctx, chatID, user.ID, false, reason)
+	case screenSuspicious:
+		if b.app.settings.Get(ctx, chatID, settingJoinSuspiciousAction) == "decline" {
+			b.resolveJoinRequest(ctx, chatID, user.ID, false, reason)
+			return
+		}
+		b.escalateJoinRequest(ctx, chatID, user, reason)
+	default:
+		b.resolveJoinRequest(ctx, chatID, user.ID, true, "passed screening")
+	}
+}
+
+// Screening verdicts for a joining user
+const (
+	screenClean = iota
+	screenSuspicious
+	// On a shared ban list; no human review needed
+	screenBanned
+)
+
+// screenUser checks a joining user against CAS and the suspect list, and runs
+// their name and bio through the detector
+func (b *Bot) screenUser(ctx context.Context, chatID int64, user tgbotapi.User, bio string) (int, string) {
+	if b.app.flags.Enabled(ctx, chatID, flagCASCheck) {
+		banned, err := b.app.cas.Banned(ctx, user.ID)
+		if err != nil {
+			b.logf("CAS check for user %d failed: %v", user.ID, err)
+			b.app.reporter.Failure("cas.check", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: user.ID})
+		}
+		if banned {
+			return screenBanned, "listed in CAS"
+		}
+	}
+
+	if suspect, err := b.app.db.IsSuspect(ctx, user.ID); err == nil && suspect {
+		return screenSuspicious, "on the suspect list"
+	}
+
+	if reason, ok := b.scamPhotoMatch(ctx, chatID, user.ID); ok {
+		return screenSuspicious, reason
+	}
+
+	profile := strings.TrimSpace(user.FirstName + " " + user.LastName + "\n" + bio)
+	if verdict := b.app.detector.IsSpam(ctx, chatID, 0, profile); verdict.Spam() {
+		return screenSuspicious, verdict.Reason
+	}
+	return screenClean, ""
+}
+
+// resolveJoinRequest approves or declines a pending join request
+func (b *Bot) resolveJoinRequest(ctx context.Context, chatID, userID int64, approve bool, reason string) error {
+	var c tgbotapi.Chattable = tgbotapi.DeclineChatJoinRequest{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}, UserID: userID}
+	action, metric := "decline", "join_requests_declined"
+	if approve {
+		c = tgbotapi.ApproveChatJoinRequestConfig{ChatConfig: tgbotapi.ChatConfig{ChatID: chatID}, UserID: userID}
+		action, metric = "approve", "join_requests_approved"
+	}
+	if _, err := b.request(ctx, c); err != nil {
+		b.logf("Failed to %s join request of user %d in chat %d: %v", action, userID, chatID, err)
+		b.app.reporter.Failure("telegram."+action+"ChatJoinRequest", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		return err
+	}
+	b.logf("Join request of user %d in chat %d: %sd (%s)", userID, chatID, action, reason)
+	metrics.Add(metric, 1)
+	return nil
+}
+
+// escalateJoinRequest asks the chat's admins to decide
+func (b *Bot) escalateJoinRequest(ctx context.Context, chatID int64, user tgbotapi.User, reason string) {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.UserName != "" {
+		name += " (@" + user.UserName + ")"
+	}
+	userID := strconv.FormatInt(user.ID, 10)
+	msg := tgbotapi.NewMessage(chatID, b.tr(ctx, chatID, "join.escalated", name, user.ID, reason))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "join.button_approve"), "join:approve:"+userID),
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "join.button_decline"), "join:decline:"+userID),
+	))
+	b.outbox.enqueue(msg)
+	b.logf("Escalated join request of user %d in chat %d (%s)", user.ID, chatID, reason)
+	metrics.Add("join_requests_escalated", 1)
+}
+
+// handleJoinCallback handles an admin pressing Approve/Decline on an escalated request
+func (b *Bot) handleJoinCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	action, id, _ := strings.Cut(args, ":")
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || (action != "approve" && action != "decline") {
+		return
+	}
+	chatID := query.Message.Chat.ID
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "join.admins_only")))
+		return
+	}
+
+	reason := "by admin " + query.From.UserName
+	if err := b.resolveJoinRequest(ctx, chatID, userID, action == "approve", reason); err != nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	outcome := "declined"
+	if action == "approve" {
+		outcome = "approved"
+	}
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "join."+outcome)))
+	b.request(ctx, tgbotapi.NewEditMessageText(chatID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "join."+outcome+"_by", query.From.FirstName)))
+}
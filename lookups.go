@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// maxConcurrentLookups bounds a bot's Bot API reads in flight, so a burst of
+// messages can't open a connection each and run into Telegram's limits
+const maxConcurrentLookups = 8
+
+// lookupGroup coalesces identical Bot API reads: while one getChatMember or
+// getChat for a key is in flight, callers asking for the same key wait for
+// its result instead of sending their own
+type lookupGroup struct {
+	mu    sync.Mutex
+	calls map[string]*lookupCall
+	slots chan struct{}
+}
+
+type lookupCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newLookupGroup() *lookupGroup {
+	return &lookupGroup{calls: make(map[string]*lookupCall), slots: make(chan struct{}, maxConcurrentLookups)}
+}
+
+// lookup runs call for key, or joins the one already in flight. The call
+// itself isn't bound to ctx, since other callers may be waiting on it; ctx
+// only bounds how long this caller waits.
+func lookup[T any](ctx context.Context, g *lookupGroup, key string, call func() (T, error)) (T, error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if ok {
+		metrics.Add("lookups_coalesced", 1)
+	} else {
+		c = &lookupCall{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(key, c, func() (interface{}, error) { return call() })
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		value, _ := c.value.(T)
+		return value, c.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (g *lookupGroup) run(key string, c *lookupCall, call func() (interface{}, error)) {
+	g.slots <- struct{}{}
+	c.value, c.err = call()
+	<-g.slots
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
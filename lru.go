@@ -0,0 +1,126 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lruCache is a size-bounded map whose entries go stale after ttl. Once full,
+// storing a new key evicts the least recently used one, so runtime state
+// stays bounded however many chats and users the bot sees.
+type lruCache[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	stats    *cacheCounters
+
+	mu    sync.Mutex
+	order *list.List // of *lruEntry, most recently used first
+	items map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key    K
+	value  V
+	stored time.Time
+}
+
+// cacheCounters are the lookups of every cache with one name, across bots and reloads
+type cacheCounters struct {
+	hits, misses, evictions atomic.Int64
+}
+
+var cacheStats sync.Map
+
+// newLRUCache returns an empty cache of up to capacity entries, counted under
+// name in the "caches" metric
+func newLRUCache[K comparable, V any](name string, capacity int, ttl time.Duration) *lruCache[K, V] {
+	stats, _ := cacheStats.LoadOrStore(name, new(cacheCounters))
+	return &lruCache[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		stats:    stats.(*cacheCounters),
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+	}
+}
+
+// get returns the value stored for key unless it has gone stale
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		if time.Since(entry.stored) < c.ttl {
+			c.order.MoveToFront(elem)
+			c.stats.hits.Add(1)
+			return entry.value, true
+		}
+	}
+	c.stats.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// stale returns the value stored for key even if it has gone stale, for
+// callers that would rather serve an old copy than nothing when a refresh fails
+func (c *lruCache[K, V]) stale(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		return elem.Value.(*lruEntry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// put stores value for key, evicting the least recently used entry if full
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &lruEntry[K, V]{key: key, value: value, stored: time.Now()}
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, stored: time.Now()})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+		c.stats.evictions.Add(1)
+	}
+}
+
+// remove drops key, e.g. after the value it caches changed
+func (c *lruCache[K, V]) remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// cacheMetrics reports each cache's hits, misses, evictions and hit rate
+// since the process started
+func cacheMetrics() map[string]map[string]any {
+	report := make(map[string]map[string]any)
+	cacheStats.Range(func(name, stats any) bool {
+		s := stats.(*cacheCounters)
+		hits, misses := s.hits.Load(), s.misses.Load()
+		rate := 0.0
+		if hits+misses > 0 {
+			rate = float64(hits) / float64(hits+misses)
+		}
+		report[name.(string)] = map[string]any{
+			"hits":      hits,
+			"misses":    misses,
+			"evictions": s.evictions.Load(),
+			"hit_rate":  rate,
+		}
+		return true
+	})
+	return report
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// A LUA_RULES_DIR holds moderation scripts: all.lua runs for every chat and
+// <chat id>.lua (e.g. -1001234567890.lua) for one chat, after it. A script
+// defines on_message, which is called with each message checked, e.g.
+//
+//	function on_message(msg)
+//	  if msg.text:lower():find("airdrop", 1, true) and sender("new_member") then
+//	    ban("lua: airdrop from a new member")
+//	  end
+//	end
+//
+// msg has text, chat_id, user_id, username, first_name, forwarded, reply and
+// entities (a list of entity types). sender(trait) checks one of the
+// senderTraits of RULES_YAML. delete, warn, ban and notify take a reason and
+// mute(minutes, reason) a duration too; they are carried out as the
+// RULES_YAML actions of the same names once the script returns.
+//
+// Scripts get only the base, string, table and math libraries, without
+// anything that reaches files, the OS or other scripts, and each call is cut
+// off after luaScriptTimeout. Each chat gets at most luaActionsPerMinute
+// script actions, so a runaway script can't ban a chat empty. Scripts are
+// compiled when the configuration loads, so a bad one stops the bot from
+// starting and is rejected on reload.
+const (
+	luaScriptTimeout    = 100 * time.Millisecond
+	luaActionsPerMinute = 30
+)
+
+// luaAllChats is the LuaRules key of all.lua
+const luaAllChats = 0
+
+// loadLuaRules compiles the scripts in dir, keyed by chat id
+func loadLuaRules(dir string) (map[int64]*lua.FunctionProto, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	scripts := make(map[int64]*lua.FunctionProto)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".lua")
+		chatID := int64(luaAllChats)
+		if name != "all" {
+			if chatID, err = strconv.ParseInt(name, 10, 64); err != nil || chatID == luaAllChats {
+				return nil, fmt.Errorf("%s: name scripts all.lua or <chat id>.lua", path)
+			}
+		}
+		proto, err := compileLua(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts[chatID] = proto
+	}
+	return scripts, nil
+}
+
+func compileLua(path string) (*lua.FunctionProto, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	chunk, err := parse.Parse(f, path)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, path)
+}
+
+// luaState is a fresh interpreter with only the safe libraries
+func luaState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 64, RegistrySize: 1024, RegistryMaxSize: 64 * 1024})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "print", "getfenv", "setfenv", "newproxy"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	// string.rep is the cheapest way to exhaust memory
+	L.GetGlobal("string").(*lua.LTable).RawSetString("rep", lua.LNil)
+	return L
+}
+
+// luaCall is what one on_message call asked for
+type luaCall struct {
+	actions []string
+	reason  string
+	mute    time.Duration
+}
+
+// runLuaScript calls the script's on_message with message and returns the
+// actions it took
+func (b *Bot) runLuaScript(ctx context.Context, proto *lua.FunctionProto, message *Message, text string) (*luaCall, error) {
+	L := luaState()
+	defer L.Close()
+	ctx, cancel := context.WithTimeout(ctx, luaScriptTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	call := &luaCall{}
+	act := func(action string) lua.LGFunction {
+		return func(L *lua.LState) int {
+			arg := 1
+			if action == "mute" {
+				call.mute = time.Duration(L.CheckInt(1)) * time.Minute
+				arg = 2
+			}
+			reason := L.OptString(arg, "lua script")
+			if !containsString(call.actions, action) {
+				call.actions = append(call.actions, action)
+			}
+			if call.reason == "" {
+				call.reason = reason
+			}
+			return 0
+		}
+	}
+	for _, action := range ruleActions {
+		L.SetGlobal(action, L.NewFunction(act(action)))
+	}
+	L.SetGlobal("sender", L.NewFunction(func(L *lua.LState) int {
+		trait := L.CheckString(1)
+		if !containsString(senderTraits, trait) {
+			L.ArgError(1, "unknown sender trait "+trait)
+		}
+		L.Push(lua.LBool(b.senderHasTrait(ctx, message, trait)))
+		return 1
+	}))
+
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		return nil, err
+	}
+	handler, ok := L.GetGlobal("on_message").(*lua.LFunction)
+	if !ok {
+		return call, nil
+	}
+	if err := L.CallByParam(lua.P{Fn: handler, Protect: true}, luaMessage(L, message, text)); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// luaMessage is the msg table scripts see
+func luaMessage(L *lua.LState, message *Message, text string) *lua.LTable {
+	msg := L.NewTable()
+	msg.RawSetString("text", lua.LString(text))
+	msg.RawSetString("chat_id", lua.LNumber(message.Chat.ID))
+	msg.RawSetString("user_id", lua.LNumber(message.From.ID))
+	msg.RawSetString("username", lua.LString(message.From.UserName))
+	msg.RawSetString("first_name", lua.LString(message.From.FirstName))
+	msg.RawSetString("forwarded", lua.LBool(message.ForwardDate != 0 || message.ext.ForwardOrigin != nil))
+	msg.RawSetString("reply", lua.LBool(message.ReplyToMessage != nil))
+	entities := L.NewTable()
+	for _, list := range [][]tgbotapi.MessageEntity{message.Entities, message.CaptionEntities} {
+		for _, entity := range list {
+			entities.Append(lua.LString(entity.Type))
+		}
+	}
+	msg.RawSetString("entities", entities)
+	return msg
+}
+
+// luaLimiter counts each chat's script actions per minute
+type luaLimiter struct {
+	mu      sync.Mutex
+	windows *lruCache[int64, luaWindow]
+}
+
+type luaWindow struct {
+	start   time.Time
+	actions int
+}
+
+func newLuaLimiter() *luaLimiter {
+	return &luaLimiter{windows: newLRUCache[int64, luaWindow]("lua_limits", 10000, time.Hour)}
+}
+
+// allow reports whether chatID may take another script action this minute
+func (l *luaLimiter) allow(chatID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w, ok := l.windows.get(chatID)
+	if !ok || time.Since(w.start) >= time.Minute {
+		w = luaWindow{start: time.Now()}
+	}
+	if w.actions >= luaActionsPerMinute {
+		return false
+	}
+	w.actions++
+	l.windows.put(chatID, w)
+	return true
+}
+
+// luaRulesStage runs all.lua and then the chat's script; the message stops
+// at the first that removes it
+func (b *Bot) luaRulesStage(ctx context.Context, mc *messageContext, next func()) {
+	scripts := b.app.Config().LuaRules
+	message := mc.message
+	for _, chatID := range []int64{luaAllChats, message.Chat.ID} {
+		proto, ok := scripts[chatID]
+		if !ok {
+			continue
+		}
+		call, err := b.runLuaScript(ctx, proto, message, mc.text)
+		if err != nil {
+			// A broken script lets the message through rather than stalling the chat
+			b.logf("Lua script %s failed on message %d in chat %d: %v", proto.SourceName, message.MessageID, message.Chat.ID, err)
+			metrics.Add("lua_rule_errors", 1)
+			continue
+		}
+		if len(call.actions) == 0 {
+			continue
+		}
+		if !b.app.lua.allow(message.Chat.ID) {
+			b.logf("Lua script actions in chat %d over %d a minute, ignoring %v", message.Chat.ID, luaActionsPerMinute, call.actions)
+			metrics.Add("lua_rule_throttled", 1)
+			continue
+		}
+		mute := call.mute
+		if mute <= 0 {
+			mute = time.Hour
+		}
+		rule := yamlRule{name: filepath.Base(proto.SourceName), actions: call.actions, mute: mute}
+		metrics.Add("lua_rule."+rule.name, 1)
+		if b.applyYAMLRule(ctx, message, rule, call.reason) {
+			return
+		}
+	}
+	next()
+}
@@ -1,247 +1,255 @@
 package main
 
 import (
-	"database/sql"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"regexp"
-	"strings"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/joho/godotenv"
-	_ "modernc.org/sqlite"
 )
 
-// SpamDetector holds spam detection rules
-type SpamDetector struct {
-	// Suspicious patterns
-	linkPattern    *regexp.Regexp
-	mentionPattern *regexp.Regexp
-	spamKeywords   []string
-	// Database connection
-	db           *sql.DB
-	banThreshold int
-}
+const usage = `Usage: spambot [-env FILE] [-test-env] <command> [flags]
 
-func NewSpamDetector() (*SpamDetector, error) {
-	// Open SQLite database
-	db, err := sql.Open("sqlite", "spambot.db")
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
-	}
-
-	// Create table if not exists
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS spam_records (
-			chat_id INTEGER,
-			user_id INTEGER,
-			count INTEGER DEFAULT 0,
-			PRIMARY KEY (chat_id, user_id)
-		)
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create table: %v", err)
-	}
-
-	return &SpamDetector{
-		linkPattern:    regexp.MustCompile(`(?i)(https?://|t\.me/|bit\.ly|tinyurl|telegram\.me|www\.|[a-z0-9][-a-z0-9]*\.(com|net|org|io|me|co|xyz|info|biz|tv|cc|ru|kr|cn)\b)`),
-		mentionPattern: regexp.MustCompile(`@[a-zA-Z0-9_]+`),
-		spamKeywords: []string{
-			"earn money", "make money fast", "investment opportunity",
-			"double your", "guaranteed profit", "free money",
-			"click here", "join now", "limited time offer",
-			"act now", "don't miss", "exclusive deal",
-			"work from home", "be your own boss", "financial freedom",
-			"forex signal", "trading signal", "casino", "betting",
-		},
-		db:           db,
-		banThreshold: 3,
-	}, nil
+Commands:
+  run            Start the bot (default when no command is given)
+  webhook        Serve updates over HTTP one request at a time (also runs as an AWS Lambda bootstrap)
+  migrate        Apply pending database migrations and exit
+  export-config  Print the effective configuration in .env format
+  check-token    Verify the bot token against the Telegram API
+
+Run 'spambot <command> -h' for command flags.
+`
+
+func main() {
+	if err := runCLI(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
-// RecordSpam increments spam count for user and returns (current count, should ban)
-func (sd *SpamDetector) RecordSpam(chatID int64, userID int64) (int, bool) {
-	// Upsert: insert or update spam count
-	_, err := sd.db.Exec(`
-		INSERT INTO spam_records (chat_id, user_id, count) VALUES (?, ?, 1)
-		ON CONFLICT(chat_id, user_id) DO UPDATE SET count = count + 1
-	`, chatID, userID)
-	if err != nil {
-		log.Printf("Failed to record spam: %v", err)
-		return 0, false
+func runCLI(args []string) error {
+	global := flag.NewFlagSet("spambot", flag.ContinueOnError)
+	global.Usage = func() { fmt.Fprint(global.Output(), usage) }
+	envFile := global.String("env", ".env", "path to the .env file")
+	testEnv := global.Bool("test-env", false, "use Telegram's test environment (same as TELEGRAM_TEST_ENV=true)")
+	if err := global.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
 	}
 
-	// Get current count
-	var count int
-	err = sd.db.QueryRow(`
-		SELECT count FROM spam_records WHERE chat_id = ? AND user_id = ?
-	`, chatID, userID).Scan(&count)
+	args = global.Args()
+	command := "run"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	cfg, err := LoadConfig(*envFile)
 	if err != nil {
-		log.Printf("Failed to get spam count: %v", err)
-		return 0, false
+		return err
+	}
+	if *testEnv {
+		cfg.Overrides.TelegramTestEnv = true
+		cfg.Overrides.apply(cfg)
 	}
 
-	return count, count >= sd.banThreshold
+	switch command {
+	case "run":
+		return cmdRun(cfg, args)
+	case "webhook":
+		return cmdWebhook(cfg, args)
+	case "migrate":
+		return cmdMigrate(cfg, args)
+	case "export-config":
+		return cmdExportConfig(cfg, args)
+	case "check-token":
+		return cmdCheckToken(cfg, args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
 }
 
-// Close closes the database connection
-func (sd *SpamDetector) Close() {
-	if sd.db != nil {
-		sd.db.Close()
+// parseFlags parses command flags, treating -h as a successful no-op
+func parseFlags(fs *flag.FlagSet, args []string) (bool, error) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, nil
+		}
+		return false, err
 	}
+	return true, nil
 }
 
-func (sd *SpamDetector) IsSpam(text string) (bool, string, string) {
-	lowerText := strings.ToLower(text)
+func cmdRun(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.StringVar(&cfg.DBPath, "db", cfg.DBPath, "database path or connection URL")
+	fs.StringVar(&cfg.LogFile, "log", cfg.LogFile, "path to the log file")
+	fs.IntVar(&cfg.BanThreshold, "ban-threshold", cfg.BanThreshold, "spam count before a user is banned")
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+	if cfg.BanThreshold < 1 {
+		return fmt.Errorf("ban threshold must be at least 1, got %d", cfg.BanThreshold)
+	}
+	recordOverrides(fs, cfg)
+	return runApp(cfg)
+}
 
-	// Check if message has URL or mention
-	hasLink := sd.linkPattern.MatchString(text)
-	hasMention := sd.mentionPattern.MatchString(text)
+func cmdWebhook(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("webhook", flag.ContinueOnError)
+	addr := fs.String("addr", ":"+envOr("PORT", "8080"), "listen address")
+	setURL := fs.String("set-url", "", "register this public base URL with Telegram and exit")
+	fs.StringVar(&cfg.DBPath, "db", cfg.DBPath, "database path or connection URL")
+	fs.StringVar(&cfg.LogFile, "log", "-", "path to the log file (- for stderr)")
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+	// Without it anyone who finds the URL can forge updates
+	if cfg.WebhookSecret == "" {
+		return fmt.Errorf("webhook mode needs WEBHOOK_SECRET")
+	}
 
-	// URL = always spam
-	if hasLink {
-		return true, "URL detected", "URL 감지"
+	runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if runtimeAPI != "" {
+		cfg.LogFile = "-"
 	}
+	recordOverrides(fs, cfg)
+	// -log defaults to stderr here rather than LOG_FILE
+	logFile := cfg.LogFile
+	cfg.Overrides.LogFile = &logFile
+	app, bots, closeApp, err := newApp(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
 
-	// Spam keyword + mention = spam
-	if hasMention {
-		for _, keyword := range sd.spamKeywords {
-			if strings.Contains(lowerText, keyword) {
-				return true, "spam keyword with mention: " + keyword, "멘션+스팸 키워드"
+	if *setURL != "" {
+		for _, bot := range bots {
+			if err := bot.setWebhook(*setURL, cfg.WebhookSecret); err != nil {
+				return fmt.Errorf("failed to set webhook for @%s: %v", bot.api.Self.UserName, err)
 			}
+			fmt.Printf("Webhook set for @%s\n", bot.api.Self.UserName)
 		}
+		return nil
 	}
 
-	return false, "", ""
+	// Deliver replies before the request returns; queued sends may never run
+	// once a serverless platform freezes the process
+	for _, bot := range bots {
+		bot.outbox.direct = true
+	}
+	alerts, err := newAlertHandler(bots, app.Config())
+	if err != nil {
+		return err
+	}
+	handler := newWebhookHandler(bots, app.Config().WebhookSecret, alerts, newDashboardHandler(bots, app.Config()), newModerationService(app, app.Config()))
+	if runtimeAPI != "" {
+		return runLambda(runtimeAPI, handler)
+	}
+	log.Printf("Serving webhooks on %s", *addr)
+	return http.ListenAndServe(*addr, handler)
 }
 
-func main() {
-	// Load .env file
-	godotenv.Load()
+// recordOverrides keeps the db, log and ban-threshold flags given in fs so a
+// reload of the .env file doesn't undo them
+func recordOverrides(fs *flag.FlagSet, cfg *Config) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db":
+			v := cfg.DBPath
+			cfg.Overrides.DBPath = &v
+		case "log":
+			v := cfg.LogFile
+			cfg.Overrides.LogFile = &v
+		case "ban-threshold":
+			v := cfg.BanThreshold
+			cfg.Overrides.BanThreshold = &v
+		}
+	})
+}
 
-	// Create log file
-	logFile, err := os.OpenFile("bot.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+// envOr returns the environment variable or def if unset
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
+	return def
+}
 
-	// Get bot token from environment variable
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is not set")
+func cmdMigrate(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.StringVar(&cfg.DBPath, "db", cfg.DBPath, "database path or connection URL")
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
 	}
 
-	bot, err := tgbotapi.NewBotAPI(token)
+	log.SetOutput(os.Stderr)
+	db, err := OpenDatabase(cfg.DBDriver, cfg.DBPath, cfg.DBTimeout)
 	if err != nil {
-		log.Fatalf("Failed to create bot: %v", err)
+		return err
 	}
+	defer db.Close()
 
-	log.Printf("Authorized on account %s", bot.Self.UserName)
-
-	detector, err := NewSpamDetector()
+	version, err := db.SchemaVersion()
 	if err != nil {
-		log.Fatalf("Failed to create spam detector: %v", err)
+		return err
 	}
-	defer detector.Close()
-
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := bot.GetUpdatesChan(u)
-
-	for update := range updates {
-		if update.Message == nil {
-			continue
-		}
+	fmt.Printf("Database %s is at schema version %d\n", cfg.DBPath, version)
+	return nil
+}
 
-		// Check message text
-		text := update.Message.Text
-		if update.Message.Caption != "" {
-			text = update.Message.Caption
-		}
+func cmdExportConfig(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("export-config", flag.ContinueOnError)
+	output := fs.String("o", "", "write to this file instead of stdout")
+	showSecrets := fs.Bool("show-secrets", false, "include the bot token in the output")
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
 
-		if text == "" {
-			continue
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", *output, err)
 		}
+		defer f.Close()
+		w = f
+	}
+	cfg.Export(w, *showSecrets)
+	return nil
+}
 
-		// 디버깅: 모든 수신 메시지 로깅 (관리자 확인 전으로 이동)
-		log.Printf("Received message from %s (ID: %d) in %s (%s): %s",
-			update.Message.From.UserName,
-			update.Message.From.ID,
-			update.Message.Chat.Title,
-			update.Message.Chat.Type,
-			text)
-
-		// Skip messages from admins
-		if update.Message.Chat.Type != "private" {
-			chatMember, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
-				ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
-					ChatID: update.Message.Chat.ID,
-					UserID: update.Message.From.ID,
-				},
-			})
-			if err == nil && (chatMember.Status == "administrator" || chatMember.Status == "creator") {
-				log.Printf("Ignoring message from admin %s", update.Message.From.UserName)
-				continue // Don't check admin messages
-			}
-		}
+func cmdCheckToken(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("check-token", flag.ContinueOnError)
+	token := fs.String("token", "", "token to check instead of the configured ones")
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+	tokens := cfg.Tokens
+	if *token != "" {
+		tokens = []string{*token}
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN environment variable is not set")
+	}
 
-		// Handle commands
-		if update.Message.IsCommand() {
-			switch update.Message.Command() {
-			case "start":
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-					"I'm a spam/ad blocking bot. Add me to your group as an admin and I'll help keep it clean!\n\n"+
-						"Commands:\n"+
-						"/start - Show this message\n"+
-						"/status - Check if bot is working")
-				bot.Send(msg)
-			case "status":
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Bot is active and monitoring for spam.")
-				bot.Send(msg)
-			}
+	failed := 0
+	for i, t := range tokens {
+		bot, err := newBotAPI(t, cfg, nil)
+		if err != nil {
+			fmt.Printf("Token #%d failed: %v\n", i+1, err)
+			failed++
 			continue
 		}
-
-		// Check for spam in group chats
-		if update.Message.Chat.Type == "group" || update.Message.Chat.Type == "supergroup" {
-			isSpam, reason, _ := detector.IsSpam(text)
-			if isSpam {
-				// Delete the spam message
-				log.Printf("Detected spam from %s (reason: %s), attempting to delete...",
-					update.Message.From.UserName, reason)
-				deleteMsg := tgbotapi.NewDeleteMessage(update.Message.Chat.ID, update.Message.MessageID)
-				_, err := bot.Request(deleteMsg)
-				if err != nil {
-					log.Printf("Failed to delete message ID %d from chat %d: %v",
-						update.Message.MessageID, update.Message.Chat.ID, err)
-				} else {
-					log.Printf("Successfully deleted spam message from %s (reason: %s)",
-						update.Message.From.UserName, reason)
-
-					// Record spam and check if user should be banned
-					_, shouldBan := detector.RecordSpam(update.Message.Chat.ID, update.Message.From.ID)
-
-					if shouldBan {
-						// Ban the user
-						banConfig := tgbotapi.BanChatMemberConfig{
-							ChatMemberConfig: tgbotapi.ChatMemberConfig{
-								ChatID: update.Message.Chat.ID,
-								UserID: update.Message.From.ID,
-							},
-						}
-						_, banErr := bot.Request(banConfig)
-						if banErr != nil {
-							log.Printf("Failed to ban user %s: %v", update.Message.From.UserName, banErr)
-						} else {
-							log.Printf("Banned user %s for repeated spam", update.Message.From.UserName)
-						}
-					}
-				}
-			}
-		}
+		fmt.Printf("Token #%d OK: @%s (ID: %d)\n", i+1, bot.Self.UserName, bot.Self.ID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tokens failed", failed, len(tokens))
 	}
+	return nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RecordMember stores when userID joined chatID
+func (s *Store) RecordMember(ctx context.Context, chatID, userID int64, joined time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO chat_members (chat_id, user_id, joined_at) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET joined_at = excluded.joined_at
+	`, chatID, userID, joined.Unix())
+	return err
+}
+
+// ForgetMember removes a member who left. Spam strikes are kept so leaving and
+// rejoining doesn't reset them.
+func (s *Store) ForgetMember(ctx context.Context, chatID, userID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM chat_members WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	return err
+}
+
+// handleNewMembers screens members who joined, records them and sends the welcome.
+// Join requests were already screened before approval; CAS results are cached.
+func (b *Bot) handleNewMembers(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	for _, member := range message.NewChatMembers {
+		if member.IsBot {
+			continue
+		}
+		if action := b.app.settings.Get(ctx, chatID, settingMemberScreening); action != "off" {
+			if verdict, reason := b.screenUser(ctx, chatID, member, ""); verdict != screenClean {
+				b.removeMember(ctx, chatID, member, action == "ban", reason)
+				continue
+			}
+		} else if reason, ok := b.scamPhotoMatch(ctx, chatID, member.ID); ok {
+			// Without screening, leave the call to the admins
+			b.alertAdmins(ctx, message, b.tr(ctx, chatID, "photo.new_member", member.FirstName, member.ID, reason))
+		}
+		if err := b.app.db.RecordMember(ctx, chatID, member.ID, time.Unix(int64(message.Date), 0)); err != nil {
+			b.logf("Failed to record member %d in chat %d: %v", member.ID, chatID, err)
+		}
+		b.bus.publish(ctx, MemberJoined{ChatID: chatID, Member: member, Message: message})
+	}
+	b.deleteServiceMessage(ctx, message, "join")
+}
+
+// handleLeftMember cleans up after a member who left or was removed
+func (b *Bot) handleLeftMember(ctx context.Context, message *Message) {
+	if message.LeftChatMember.ID == b.api.Self.ID {
+		return // handled via my_chat_member
+	}
+	if err := b.app.db.ForgetMember(ctx, message.Chat.ID, message.LeftChatMember.ID); err != nil {
+		b.logf("Failed to forget member %d in chat %d: %v", message.LeftChatMember.ID, message.Chat.ID, err)
+	}
+	b.deleteServiceMessage(ctx, message, "leave")
+}
+
+// removeMember bans a member who failed screening; kicks are a ban lifted right away
+func (b *Bot) removeMember(ctx context.Context, chatID int64, member tgbotapi.User, ban bool, reason string) {
+	ec := ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: member.ID}
+	config := tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: member.ID}
+	if b.cannotModerate(chatID, "permission.ban_users") ||
+		!b.claimIntent(ctx, memberIntentTTL, "remove:%d:%d", chatID, member.ID) {
+		return
+	}
+	if _, err := b.request(ctx, tgbotapi.BanChatMemberConfig{ChatMemberConfig: config}); err != nil {
+		b.logf("Failed to remove new member %s: %v", member.UserName, err)
+		b.app.reporter.Failure("telegram.banChatMember", err, ec)
+		b.checkRightsAfter(ctx, chatID, err)
+		if ban {
+			b.retryBan(ctx, chatID, member.ID, reason, err)
+		}
+		return
+	}
+	if !ban {
+		if _, err := b.request(ctx, tgbotapi.UnbanChatMemberConfig{ChatMemberConfig: config, OnlyIfBanned: true}); err != nil {
+			b.logf("Failed to lift kick of %s: %v", member.UserName, err)
+			b.app.reporter.Failure("telegram.unbanChatMember", err, ec)
+		}
+	}
+	b.logf("Removed new member %s (ID: %d) from chat %d: %s", member.UserName, member.ID, chatID, reason)
+	metrics.Add("members_screened_out", 1)
+	if ban {
+		b.bus.publish(ctx, UserBanned{ChatID: chatID, User: member, Reason: reason})
+	} else {
+		b.audit(ctx, "kick", chatID, member.ID, 0, reason)
+	}
+}
+
+// deleteServiceMessage removes a join ("join") or leave ("leave") notice if the chat asks for it
+func (b *Bot) deleteServiceMessage(ctx context.Context, message *Message, kind string) {
+	switch b.app.settings.Get(ctx, message.Chat.ID, settingDeleteServiceMessages) {
+	case kind, "all":
+		b.deleteMessage(ctx, message, kind+" service message")
+	}
+}
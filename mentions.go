@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// mentionCacheTTL is how long the looked-up kind of an @username is reused
+const mentionCacheTTL = 24 * time.Hour
+
+// mentionCacheSize bounds the @usernames whose kinds are kept
+const mentionCacheSize = 20000
+
+// Kinds of @username mentions
+const (
+	mentionUser    = "user"
+	mentionChannel = "channel"
+	mentionGroup   = "group"
+)
+
+// mentionCache remembers what each @username refers to, shared by all bots
+type mentionCache struct {
+	kinds *lruCache[string, string]
+}
+
+func newMentionCache() *mentionCache {
+	return &mentionCache{kinds: newLRUCache[string, string]("mentions", mentionCacheSize, mentionCacheTTL)}
+}
+
+func (c *mentionCache) get(username string) (string, bool) {
+	return c.kinds.get(username)
+}
+
+func (c *mentionCache) put(username, kind string) {
+	c.kinds.put(username, kind)
+}
+
+// messageMentions lists the lower-cased @usernames mentioned in the text and caption
+func messageMentions(message *tgbotapi.Message) []string {
+	var names []string
+	collect := func(text string, entities []tgbotapi.MessageEntity) {
+		for _, entity := range entities {
+			if entity.Type != "mention" {
+				continue
+			}
+			if name := strings.ToLower(strings.TrimPrefix(entityText(text, entity), "@")); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	collect(message.Text, message.Entities)
+	collect(message.Caption, message.CaptionEntities)
+	return names
+}
+
+// mentionKind looks up what @username refers to. getChat only resolves public
+// channels and groups, so anything it can't find is treated as a user.
+func (b *Bot) mentionKind(ctx context.Context, username string) string {
+	if kind, ok := b.app.mentions.get(username); ok {
+		return kind
+	}
+	chat, err := lookup(ctx, b.lookups, "getChat:@"+username, func() (tgbotapi.Chat, error) {
+		return b.api.GetChat(tgbotapi.ChatInfoConfig{ChatConfig: tgbotapi.ChatConfig{SuperGroupUsername: "@" + username}})
+	})
+	if err != nil && ctx.Err() != nil {
+		// Timed out: don't cache a guess
+		return mentionUser
+	}
+	kind := mentionUser
+	switch {
+	case err != nil:
+	case chat.IsChannel():
+		kind = mentionChannel
+	case chat.IsGroup() || chat.IsSuperGroup():
+		kind = mentionGroup
+	}
+	b.app.mentions.put(username, kind)
+	return kind
+}
+
+// applyMentionPolicy handles messages promoting other channels or groups by @username.
+// Mentioning a user stays a weak signal for the detector; promoting a chat is
+// handled by channel_mention_policy. Returns true when the message was removed.
+func (b *Bot) applyMentionPolicy(ctx context.Context, message *Message) bool {
+	mentions := messageMentions(message.Message)
+	if len(mentions) == 0 || b.setting(ctx, message, settingChannelMentionPolicy) == "allow" {
+		return false
+	}
+	allowlist := listSetting(b.setting(ctx, message, settingMentionAllowlist))
+	own := strings.ToLower(message.Chat.UserName)
+	for _, name := range mentions {
+		if name == own || name == strings.ToLower(b.api.Self.UserName) || containsString(allowlist, name) {
+			continue
+		}
+		if kind := b.mentionKind(ctx, name); kind != mentionUser {
+			return b.enforcePolicy(ctx, message, settingChannelMentionPolicy, "promotes "+kind+" @"+name)
+		}
+	}
+	return false
+}
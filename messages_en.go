@@ -0,0 +1,409 @@
+package main
+
+// messagesEN is the English catalog, the fallback for every other locale
+var messagesEN = map[string]string{
+	// Commands
+	"start.help": "I'm a spam/ad blocking bot. Add me to your group as an admin and I'll help keep it clean!\n\n" +
+		"Commands:\n" +
+		"/start - Show this message\n" +
+		"/status - Check if bot is working\n" +
+		"/checkperms - Check my admin permissions (admins)\n" +
+		"/stats - Chart this chat's detections per day and rule (admins)\n" +
+		"/notspam [user id] - Reply to my warning to overturn a removal as a false positive (admins)\n" +
+		"/precision - Show how often each rule's removals were overturned (admins)\n" +
+		"/event <duration> | off - Tighten the rules for a launch or airdrop, reverting after the duration (admins)\n" +
+		"/features - Show feature flags for this chat (admins)\n" +
+		"/settings - Show settings for this chat (admins)\n" +
+		"/set <setting> <value> - Change a setting (admins)\n" +
+		"/topic [set <setting> <value>] - Show or change settings for this forum topic (admins)\n" +
+		"/rules - Show this chat's rules\n" +
+		"/setrules <text> - Set the rules, or reply to a message with them (admins)\n" +
+		"/pinrules - Pin the replied-to message (or the rules setting) as the rules (admins)\n" +
+		"/unpinrules - Unpin the rules message (admins)\n" +
+		"/verify - Verify your Aptos wallet for this group\n" +
+		"/wall - Submit a post for this group's wall channel\n" +
+		"/report - Reply to a message to report it to the admins\n" +
+		"/price [symbol] - Show a token's price (default: this chat's token)\n" +
+		"/tip <APT> - Reply to a member whose report led to a ban to tip them (admins)\n" +
+		"/tip paid <hash> - Reply to confirm a tip you sent from your own wallet (admins)\n" +
+		"/appeal <chat id> - Appeal a ban from a group with an APT bond (in private)",
+	"status.active":           "Bot is active and monitoring for spam.",
+	"reload.done":             "Configuration reloaded.",
+	"reload.failed":           "Reload failed: %v",
+	"features.list":           "Feature flags for this chat:\n%s",
+	"settings.list":           "Settings for this chat:\n%s",
+	"checkperms.list_failed":  "Failed to list chats: %v",
+	"checkperms.chat_failed":  "%s: check failed (%v)",
+	"checkperms.chat_missing": "%s: missing %s",
+	"checkperms.chat_ok":      "%s: OK",
+	"checkperms.no_chats":     "No known chats yet.",
+	"checkperms.failed":       "Permission check failed: %v",
+	"checkperms.ok":           "✅ I have every permission I need here.",
+	"set.usage":               "Usage: /set <setting> <value|default>\nSee /settings for the list.",
+	"set.failed":              "Failed to update setting: %v",
+	"set.done":                "Setting %s updated.",
+	"topic.outside":           "Run /topic inside a forum topic. Use /set for the whole chat (and the General topic).",
+	"topic.no_overrides":      "none, chat-wide settings apply",
+	"topic.list":              "Settings overridden in this topic:\n%s",
+	"topic.usage":             "Usage: /topic set <setting> <value|default>\nSee /settings for the list.",
+	"topic.done":              "Setting %s updated for this topic.",
+	"feature.usage":           "Usage: /feature [global] <name> on|off|default",
+	"feature.failed":          "Failed to update flag: %v",
+	"feature.done":            "Flag %s set to %s.",
+
+	// Permissions
+	"permission.admin":           "admin rights",
+	"permission.delete_messages": "Delete messages",
+	"permission.ban_users":       "Ban users",
+	"permission.warning":         "⚠️ I can't moderate this chat properly. Please grant me these admin permissions: %s",
+
+	// Ban appeals
+	"appeal.link":               "Banned members can appeal here: %s",
+	"appeal.usage":              "Usage: /appeal <chat id>, or follow the appeal link of the group.",
+	"appeal.unavailable":        "That group doesn't take ban appeals here.",
+	"appeal.member_failed":      "Failed to check your membership, please try again later.",
+	"appeal.not_banned":         "You're not banned from that group.",
+	"appeal.failed":             "Failed to start your appeal, please try again later.",
+	"appeal.pending":            "Your appeal is already with the admins.",
+	"appeal.instructions":       "To appeal, lock a bond of %s APT by sending this transaction from the wallet you verified for the group:\n\n%s\n\nThen send /bond <transaction hash> here. If the admins find the ban was wrong, you're unbanned and the bond is refunded to that wallet; otherwise it goes to the community wallet.",
+	"bond.usage":                "Usage: /bond <transaction hash>",
+	"bond.lookup_failed":        "Failed to look up your appeal, please try again later.",
+	"bond.no_appeal":            "You have no appeal waiting for a bond. Start one from the group's appeal link.",
+	"bond.txn_failed":           "Failed to look up the transaction, please try again later.",
+	"bond.txn_pending":          "That transaction isn't committed yet; try again in a moment.",
+	"bond.txn_unsuccessful":     "That transaction failed on chain.",
+	"bond.wrong_recipient":      "That transaction isn't a transfer to %s.",
+	"bond.wrong_amount":         "The bond is %s APT, that transaction sent %s APT.",
+	"bond.txn_too_old":          "That transaction is older than your appeal.",
+	"bond.save_failed":          "Failed to record your bond, please try again later.",
+	"bond.txn_reused":           "That transaction already paid a bond.",
+	"bond.locked":               "Bond locked. The admins will review your ban; I'll let you know their decision.",
+	"bond.review":               "⚖️ %s (ID: %d) appeals their ban from chat %d with a %s APT bond (%s).\nWrong ban: unban and refund. Upheld: the bond goes to the community wallet.",
+	"bond.button_refund":        "✅ Wrong ban",
+	"bond.button_forfeit":       "🔨 Upheld",
+	"bond.admins_only":          "Only admins of the group can decide on appeals.",
+	"bond.not_found":            "Appeal not found.",
+	"bond.no_community_wallet":  "Set community_wallet first.",
+	"bond.payout_failed":        "Failed to send the bond: %v",
+	"bond.refunded":             "Your ban appeal was accepted: you're unbanned and your %s APT bond was refunded (transaction %s).",
+	"bond.forfeited":            "Your ban appeal was rejected; your %s APT bond went to the community wallet.",
+	"bond.decided_refunded":     "Bond refunded.",
+	"bond.decided_forfeited":    "Bond forfeited.",
+	"bond.decided_by_refunded":  "Bond refunded by %s, transaction %s.",
+	"bond.decided_by_forfeited": "Bond forfeited by %s, transaction %s.",
+
+	// Inline buttons
+	"callback.already_decided": "Already decided.",
+	"callback.failed":          "Failed: %v",
+	"callback.not_for_you":     "This button isn't for you.",
+
+	// Event mode
+	"event.rule_links":     "links are removed",
+	"event.rule_forwards":  "forwards are removed",
+	"event.rule_captcha":   "new members must pass a captcha",
+	"event.rule_slow_mode": "members may post once every %s seconds",
+	"event.no_rules":       "no extra rules are set in event_restrictions",
+	"event.status":         "Event mode is on until %s UTC: %s.",
+	"event.status_off":     "Event mode is off. Usage: /event <duration, e.g. 2h or 90m> | off",
+	"event.end_failed":     "Failed to end event mode, please try again later.",
+	"event.not_on":         "Event mode is not on.",
+	"event.ended":          "Event mode is off, the usual rules are back.",
+	"event.usage":          "Usage: /event <duration, e.g. 2h or 90m, up to 7 days> | off",
+	"event.start_failed":   "Failed to start event mode, please try again later.",
+	"event.started":        "🛡 Event mode is on until %s UTC: %s.",
+	"event.captcha":        "%s, this group is in event mode. Press the button within %d minutes to be able to post.",
+	"event.captcha_button": "✅ I'm human",
+	"event.captcha_passed": "Welcome!",
+	"event.expired":        "Event mode is over, the usual rules are back.",
+
+	// Suspect list
+	"private.spam_report": "🚨 Spam sent to me in private by %s (ID: %d), reason: %s\n\n%s\n\nUndo with /unsuspect %d",
+	"unsuspect.usage":     "Usage: /unsuspect <user id>",
+	"unsuspect.failed":    "Failed to update the suspect list: %v",
+	"unsuspect.done":      "User %d removed from the suspect list.",
+
+	// Reports
+	"report.usage":          "Reply to the message you want to report with /report.",
+	"report.escalated":      "🚩 %s reported this message. Admins, please review.",
+	"report.button_ban":     "🔨 Ban",
+	"report.button_dismiss": "✅ Dismiss",
+	"report.admins_only":    "Only admins can decide on reports.",
+	"report.banned":         "Reported user banned.",
+	"report.dismissed":      "Reported user dismissed.",
+	"report.banned_by":      "Reported user banned by %s.",
+	"report.dismissed_by":   "Reported user dismissed by %s.",
+
+	// Reaction flags
+	"flag.escalated":     "⚠️ %d members flagged this message. Admins, please review.",
+	"flag.button_delete": "🗑 Delete",
+	"flag.button_keep":   "✅ Keep",
+	"flag.admins_only":   "Only admins can decide on flagged messages.",
+	"flag.kept":          "Kept.",
+	"flag.deleted":       "Deleted.",
+	"flag.kept_by":       "Kept by %s.",
+	"flag.deleted_by":    "Deleted by %s.",
+
+	// Join requests
+	"join.escalated":      "⚠️ Join request from %s (ID: %d) looks suspicious: %s\nAdmins, please review.",
+	"join.button_approve": "✅ Approve",
+	"join.button_decline": "❌ Decline",
+	"join.admins_only":    "Only admins can decide join requests.",
+	"join.approved":       "Approved.",
+	"join.declined":       "Declined.",
+	"join.approved_by":    "Approved by %s.",
+	"join.declined_by":    "Declined by %s.",
+
+	// Prices
+	"price.disabled": "Price lookups are disabled.",
+	"price.usage":    "Usage: /price [symbol]",
+	"price.failed":   "Price lookup failed, please try again later.",
+	"price.unknown":  "I don't know a token with the symbol %s.",
+
+	// Rules
+	"rules.lookup_failed":  "Failed to look up the rules message: %v",
+	"rules.pin_failed":     "Failed to pin: %v",
+	"rules.save_failed":    "Failed to save the rules message: %v",
+	"rules.pin_usage":      "Reply to a message with /pinrules, or set the rules text first with /setrules <text>.",
+	"rules.post_failed":    "Failed to post the rules: %v",
+	"rules.nothing_pinned": "There is no rules message to unpin.",
+	"rules.forget_failed":  "Failed to forget the rules message: %v",
+	"rules.unpinned":       "Rules message unpinned.",
+	"rules.pinned":         "📌 Rules message pinned. I'll keep it pinned.",
+	"rules.set_usage":      "Usage: /setrules <text>, or reply to a message with the rules with /setrules",
+	"rules.set":            "✅ Rules saved. Members see them with /rules.",
+	"rules.set_reposted":   "✅ Rules saved, and the pinned rules message replaced.",
+	"rules.pinned_link":    "📌 Pinned rules: %s",
+	"rules.none":           "This chat has no rules set yet.",
+
+	// Tips
+	"tip.usage":          "Usage: /tip <APT> in reply to the reporter, or /tip <user id> <APT>; after paying from your wallet, /tip paid <transaction hash> in reply, or /tip paid <user id> <transaction hash>",
+	"tip.reports_failed": "Failed to look up reports: %v",
+	"tip.no_report":      "That member has no untipped report that led to a ban.",
+	"tip.wallet_failed":  "Failed to look up their wallet: %v",
+	"tip.no_wallet":      "That member hasn't verified an Aptos wallet yet; they can with /verify.",
+	"tip.record_failed":  "Failed to record the tip: %v",
+	"tip.payload":        "Send %s APT to %s from your wallet with this transaction:\n\n%s\n\nThen confirm it with /tip paid <transaction hash> within %d hours, or the report can be tipped again.",
+	"tip.already":        "That report was already tipped.",
+	"tip.not_awaiting":   "No tip to that member is waiting for a transaction.",
+	"tip.txn_failed":     "Failed to look up the transaction: %v",
+	"tip.txn_pending":    "That transaction isn't on chain yet; try again in a moment.",
+	"tip.txn_mismatch":   "That transaction doesn't send %s APT to %s after the tip was made.",
+	"tip.txn_used":       "That transaction already confirmed a tip.",
+	"tip.send_failed":    "Failed to send the tip: %v",
+	"tip.sent":           "Sent %s APT to %s, transaction %s. Thanks for the report!",
+
+	// Wall
+	"wall.use_in_group":   "Use /wall in the group whose wall you want to post to.",
+	"wall.no_channel":     "This chat has no wall channel.",
+	"wall.link":           "Submit a post for the wall in private: %s",
+	"wall.invalid_link":   "This wall link is invalid.",
+	"wall.start_failed":   "Failed to start your submission, please try again later.",
+	"wall.prompt":         "Send me your post as one text message within %d minutes. Admins will review it before it is published.",
+	"wall.text_only":      "Only text posts can be submitted. Follow the wall link again to retry.",
+	"wall.spam":           "Your post looks like spam and was not submitted.",
+	"wall.submit_failed":  "Failed to submit your post, please try again later.",
+	"wall.submitted":      "Thanks! Your post was sent to the admins for review.",
+	"wall.review":         "📝 Wall post submitted by %s:\n\n%s",
+	"wall.button_publish": "✅ Publish",
+	"wall.button_reject":  "❌ Reject",
+
+	// Wall reviews
+	"wall.gone":             "This submission no longer exists.",
+	"wall.admins_only":      "Only admins can review wall posts.",
+	"wall.already":          "Already reviewed.",
+	"wall.publish_failed":   "Failed to publish: %v",
+	"wall.published":        "Published.",
+	"wall.rejected":         "Rejected.",
+	"wall.published_by":     "Published by %s.",
+	"wall.rejected_by":      "Rejected by %s.",
+	"wall.author_published": "Your wall post was published.",
+	"wall.author_rejected":  "Your wall post was rejected.",
+
+	// Wallet verification
+	"verify.invalid_link":   "This verification link is invalid.",
+	"verify.start_failed":   "Failed to start verification, please try again later.",
+	"verify.challenge":      "Sign this message with your Aptos wallet within %d minutes:\n\n%s\n\nThen send /verify <public key> <signature> (hex) here.",
+	"verify.link":           "Verify your Aptos wallet in private: %s",
+	"verify.usage":          "Usage: /verify <public key> <signature>\nStart from the verification link in your group.",
+	"verify.lookup_failed":  "Failed to look up your verification, please try again later.",
+	"verify.none_pending":   "No pending verification, or it expired. Use the verification link in your group again.",
+	"verify.failed":         "Verification failed: %v",
+	"verify.sybil_rejected": "This wallet is already linked to another Telegram account, so it can't be verified again.",
+	"verify.save_failed":    "Failed to save your wallet, please try again later.",
+	"verify.done":           "Wallet %s verified.",
+	"verify.links_gated":    "%s, only members with a verified Aptos wallet may post links here. Verify: %s",
+
+	// Scam alerts
+	"alert.seed_phrase":  "🚨 Removed a message from %s (%s). Never share your seed phrase or private key with anyone.",
+	"alert.fake_support": "🚨 Removed a fake support message from %s pointing to %s. Admins will never ask you to open a ticket elsewhere.",
+	"alert.lookalike":    "⚠️ Removed a message from %s: %s is not ours. The official link is %s",
+
+	// Contracts
+	"token.scam_listed": "⛔ %s posted a scam-listed contract: %s",
+	"token.fresh":       "🆕 %s posted a freshly deployed contract: %s",
+	"token.posted":      "🔎 %s posted %s",
+
+	// Profile photos
+	"photo.new_member": "🖼 New member %s (%d): %s",
+
+	// Onchain announcements
+	"onchain.treasury_sent":     "💸 The treasury sent %s APT\n%s",
+	"onchain.treasury_received": "💸 The treasury received %s APT\n%s",
+
+	// Token gate
+	"gate.need_tokens": "at least %s of %s",
+	"gate.need_nft":    "an NFT from collection %s",
+	"gate.and":         " and ",
+	"gate.notice":      "%s, members of this group must hold %s in a verified Aptos wallet. Verify within %d hours to stay: %s",
+
+	// Sybil accounts
+	"sybil.notice":   "🪞 Possible sybil: %s (%d) verified %s, %s (%s)",
+	"sybil.flagged":  "flagged",
+	"sybil.rejected": "verification rejected",
+	"sybil.banned":   "banned",
+
+	// Detection labels
+	"reason.fake_airdrop":         "fake airdrop link",
+	"reason.wallet_connect":       "wallet connect phishing link",
+	"reason.fake_eligibility":     "fake eligibility check link",
+	"reason.crypto_scam":          "crypto scam link",
+	"reason.seed_request":         "seed phrase or private key request",
+	"reason.private_key":          "private key posted",
+	"reason.mnemonic":             "mnemonic posted",
+	"reason.fake_support":         "fake support",
+	"reason.url":                  "link",
+	"reason.blocked_domain":       "blocked domain",
+	"reason.phishing_domain":      "phishing domain",
+	"reason.dm_solicitation":      "DM solicitation",
+	"reason.spam_keyword":         "spam keyword",
+	"reason.spam_keyword_mention": "spam keyword with mention",
+
+	// Digests
+	"digest.title_daily":  "📊 Daily moderation digest for %s",
+	"digest.title_weekly": "📊 Weekly moderation digest for %s",
+	"digest.quiet":        "Nothing to report.",
+	"digest.counts":       "Messages removed: %d\nBans: %d\nUnbans: %d",
+	"digest.top_rules":    "Top rules:",
+	"digest.top_users":    "Members with the most removed messages:",
+	"digest.line":         "• %s: %d",
+
+	// Ban alerts
+	"ban_alert.text":         "🔨 Banned %s (ID: %d) from %s: %s",
+	"ban_alert.message":      "Message:\n%s",
+	"ban_alert.button_unban": "♻️ Unban",
+	"ban_alert.admins_only":  "Only admins of that chat can unban.",
+	"ban_alert.unbanned":     "Unbanned.",
+	"ban_alert.unbanned_by":  "Unbanned by %s.",
+
+	// Discord mirror
+	"discord.delete": "🗑 Message removed",
+	"discord.kick":   "👢 Member removed",
+	"discord.ban":    "🔨 Member banned",
+	"discord.unban":  "♻️ Member unbanned",
+	"discord.chat":   "Chat",
+	"discord.user":   "User",
+
+	// Dashboard
+	"dashboard.title":              "Moderation dashboard",
+	"dashboard.sign_in":            "Sign in with Telegram to manage the chats you administer.",
+	"dashboard.signed_in":          "Signed in as %s",
+	"dashboard.sign_out":           "Sign out",
+	"dashboard.chats":              "Your chats",
+	"dashboard.no_chats":           "You aren't an admin of any chat the bot is in.",
+	"dashboard.back":               "All chats",
+	"dashboard.detections":         "Removals and bans, last %d days",
+	"dashboard.deletes":            "Removed",
+	"dashboard.bans":               "Banned",
+	"dashboard.top_rules":          "Top rules",
+	"dashboard.log":                "Moderation log",
+	"dashboard.log_empty":          "Nothing recorded yet.",
+	"dashboard.log_time":           "Time (UTC)",
+	"dashboard.log_action":         "Action",
+	"dashboard.log_user":           "User",
+	"dashboard.log_rule":           "Rule",
+	"dashboard.section_lists":      "Keywords and domains",
+	"dashboard.section_thresholds": "Thresholds",
+	"dashboard.section_other":      "Other settings",
+	"dashboard.save":               "Save",
+	"dashboard.reset":              "Default",
+	"dashboard.saved":              "%s saved.",
+	"dashboard.failed":             "Couldn't save: %s",
+	"dashboard.empty_value":        "Enter a value, or use Default.",
+	"dashboard.feed":               "Atom feed of this log",
+	"dashboard.feed_private":       "anyone with the link can read it, so keep it private",
+
+	// Transparency page
+	"transparency.title":             "Moderation in %s",
+	"transparency.intro":             "What the bot and the admins did in the last %d days.",
+	"transparency.removed":           "Messages removed",
+	"transparency.kicks":             "Members removed",
+	"transparency.bans":              "Bans",
+	"transparency.unbans":            "Bans lifted",
+	"transparency.appeals":           "Ban appeals",
+	"transparency.appeals_review":    "Under review",
+	"transparency.appeals_refunded":  "Ban lifted, bond refunded",
+	"transparency.appeals_forfeited": "Ban upheld",
+	"transparency.daily":             "By day",
+	"transparency.date":              "Date (UTC)",
+	"transparency.audit":             "Every decision in the audit log",
+	"transparency.overturned":        "Removals overturned on dispute",
+
+	// Disputes
+	"dispute.button":            "🙋 This wasn't spam",
+	"dispute.not_yours":         "Only the member whose message was removed can dispute it, once.",
+	"dispute.filed":             "Sent to the admins for review.",
+	"dispute.review":            "🙋 %s says their removed message wasn't spam.\nReason: %s\n\nMessage:\n%s",
+	"dispute.button_overturn":   "↩️ Not spam",
+	"dispute.button_uphold":     "✅ Spam",
+	"dispute.admins_only":       "Only admins can decide on disputes.",
+	"dispute.overturned":        "Removal overturned; the strike was taken back.",
+	"dispute.upheld":            "Removal upheld.",
+	"dispute.overturned_by":     "Overturned by %s.",
+	"dispute.upheld_by":         "Upheld by %s.",
+	"dispute.member_overturned": "The admins agreed your message wasn't spam and took back the warning. Sorry about that!",
+	"dispute.member_upheld":     "The admins reviewed your removed message and kept the removal.",
+
+	// Minor violations
+	"minor.notice": "Your message in %s was marked %[3]s instead of removed (%[2]s), as the rules there don't allow it. Please edit or delete it; admins may remove it otherwise.",
+
+	// Stats chart
+	"stats.title":  "📊 Detections in %s, %s – %s (%s)",
+	"stats.other":  "other rules",
+	"stats.total":  "Total: %d",
+	"stats.quiet":  "No detections in the last %d days.",
+	"stats.failed": "Couldn't build the stats chart, please try again later.",
+
+	// Moderation feed
+	"feed.title":   "Moderation log of %s",
+	"feed.entry":   "%s: user %d",
+	"feed.summary": "Action %s on user %d, rule: %s",
+
+	// Action retries
+	"retry.delete_failed": "⚠️ I couldn't delete a message from user %d (%s) after %d attempts: %v\nPlease remove it by hand.",
+	"retry.ban_failed":    "⚠️ I couldn't ban user %d (%s) after %d attempts: %v\nPlease ban them by hand.",
+
+	// Declared rules
+	"yaml_rule.notify": "📋 Rule %s matched a message from %s (ID: %d): %s",
+
+	// False positives
+	"notspam.usage":     "Reply to my warning with /notspam, or use /notspam <user id> for the member's latest warning.",
+	"notspam.not_found": "No removal to overturn found; warnings can be overturned for about a week.",
+	"notspam.done":      "Marked as a false positive of \"%s\"; the strike was taken back.",
+	"precision.title":   "🎯 Rule precision (share of removals not overturned):",
+	"precision.row":     "%s: %.0f%% (%d of %d overturned)",
+	"precision.none":    "No removals recorded yet.",
+	"precision.failed":  "Couldn't load the rule precision, please try again later.",
+
+	// Flags only the owner may switch
+	"feature.owner_only": "Only the bot owner can switch %s.",
+
+	// Limits on tips from the bot's wallet
+	"tip.over_max":   "A tip from the bot's wallet can be at most %s APT.",
+	"tip.over_daily": "This chat has reached its limit of %s APT in tips from the bot's wallet for the last 24 hours.",
+
+	// Bonds tied to the appellant's wallet, and payouts reconciled on chain
+	"bond.no_wallet":          "Bonds must come from a wallet you verified for the group. Verify one first: %s",
+	"bond.wrong_sender":       "That transaction wasn't sent from your verified wallet %s.",
+	"bond.payout_unconfirmed": "Payout sent; waiting for the network to confirm it.",
+	"bond.payout_reopened":    "⚠️ The bond payout for %d in chat %d (%s APT, transaction %s) didn't go through. The appeal is open again.",
+}
@@ -0,0 +1,409 @@
+package main
+
+// messagesKO is the Korean catalog
+var messagesKO = map[string]string{
+	// Commands
+	"start.help": "스팸/광고 차단 봇입니다. 그룹에 관리자로 추가하시면 그룹을 깨끗하게 지켜 드릴게요!\n\n" +
+		"명령어:\n" +
+		"/start - 이 메시지 보기\n" +
+		"/status - 봇 작동 여부 확인\n" +
+		"/checkperms - 봇의 관리자 권한 확인 (관리자)\n" +
+		"/stats - 이 채팅의 일별·규칙별 감지 현황 차트 (관리자)\n" +
+		"/notspam [사용자 ID] - 봇의 경고에 답장하여 오탐지로 삭제 취소 (관리자)\n" +
+		"/precision - 규칙별 삭제가 취소된 비율 보기 (관리자)\n" +
+		"/event <기간> | off - 토큰 출시나 에어드랍 동안 규칙을 강화하고 기간이 끝나면 되돌리기 (관리자)\n" +
+		"/features - 이 채팅의 기능 플래그 보기 (관리자)\n" +
+		"/settings - 이 채팅의 설정 보기 (관리자)\n" +
+		"/set <설정> <값> - 설정 변경 (관리자)\n" +
+		"/topic [set <설정> <값>] - 이 포럼 토픽의 설정 보기 또는 변경 (관리자)\n" +
+		"/rules - 이 채팅의 규칙 보기\n" +
+		"/setrules <내용> - 규칙 설정, 또는 규칙이 담긴 메시지에 답장 (관리자)\n" +
+		"/pinrules - 답장한 메시지(또는 rules 설정)를 규칙으로 고정 (관리자)\n" +
+		"/unpinrules - 규칙 메시지 고정 해제 (관리자)\n" +
+		"/verify - 이 그룹에 사용할 Aptos 지갑 인증\n" +
+		"/wall - 이 그룹의 월 채널에 게시물 제출\n" +
+		"/report - 메시지에 답장하여 관리자에게 신고\n" +
+		"/price [심볼] - 토큰 가격 보기 (기본값: 이 채팅의 토큰)\n" +
+		"/tip <APT> - 신고로 차단을 이끈 멤버에게 답장하여 팁 보내기 (관리자)\n" +
+		"/tip paid <해시> - 답장하여 직접 지갑에서 보낸 팁 확인하기 (관리자)\n" +
+		"/appeal <채팅 ID> - APT 보증금을 걸고 그룹 차단에 이의 제기 (개인 채팅)",
+	"status.active":           "봇이 작동 중이며 스팸을 감시하고 있습니다.",
+	"reload.done":             "설정을 다시 불러왔습니다.",
+	"reload.failed":           "다시 불러오기 실패: %v",
+	"features.list":           "이 채팅의 기능 플래그:\n%s",
+	"settings.list":           "이 채팅의 설정:\n%s",
+	"checkperms.list_failed":  "채팅 목록을 불러오지 못했습니다: %v",
+	"checkperms.chat_failed":  "%s: 확인 실패 (%v)",
+	"checkperms.chat_missing": "%s: %s 권한 없음",
+	"checkperms.chat_ok":      "%s: 정상",
+	"checkperms.no_chats":     "아직 알려진 채팅이 없습니다.",
+	"checkperms.failed":       "권한 확인 실패: %v",
+	"checkperms.ok":           "✅ 필요한 권한이 모두 있습니다.",
+	"set.usage":               "사용법: /set <설정> <값|default>\n목록은 /settings 에서 확인하세요.",
+	"set.failed":              "설정 변경 실패: %v",
+	"set.done":                "%s 설정을 변경했습니다.",
+	"topic.outside":           "/topic 은 포럼 토픽 안에서 사용하세요. 채팅 전체(및 General 토픽)에는 /set 을 사용하세요.",
+	"topic.no_overrides":      "없음, 채팅 전체 설정이 적용됩니다",
+	"topic.list":              "이 토픽에서 변경된 설정:\n%s",
+	"topic.usage":             "사용법: /topic set <설정> <값|default>\n목록은 /settings 에서 확인하세요.",
+	"topic.done":              "이 토픽의 %s 설정을 변경했습니다.",
+	"feature.usage":           "사용법: /feature [global] <이름> on|off|default",
+	"feature.failed":          "플래그 변경 실패: %v",
+	"feature.done":            "%s 플래그를 %s(으)로 설정했습니다.",
+
+	// Permissions
+	"permission.admin":           "관리자 권한",
+	"permission.delete_messages": "메시지 삭제",
+	"permission.ban_users":       "사용자 차단",
+	"permission.warning":         "⚠️ 이 채팅을 제대로 관리할 수 없습니다. 다음 관리자 권한을 부여해 주세요: %s",
+
+	// Ban appeals
+	"appeal.link":               "차단된 멤버는 여기에서 이의를 제기할 수 있습니다: %s",
+	"appeal.usage":              "사용법: /appeal <채팅 ID>, 또는 그룹의 이의 제기 링크를 이용하세요.",
+	"appeal.unavailable":        "해당 그룹은 여기에서 차단 이의 제기를 받지 않습니다.",
+	"appeal.member_failed":      "멤버 상태를 확인하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"appeal.not_banned":         "해당 그룹에서 차단된 상태가 아닙니다.",
+	"appeal.failed":             "이의 제기를 시작하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"appeal.pending":            "이의 제기가 이미 관리자에게 전달되었습니다.",
+	"appeal.instructions":       "이의를 제기하려면 그룹에 인증한 지갑에서 다음 트랜잭션을 보내 %s APT 보증금을 예치하세요:\n\n%s\n\n그런 다음 여기에 /bond <트랜잭션 해시> 를 보내세요. 관리자가 차단이 잘못되었다고 판단하면 차단이 해제되고 보증금은 그 지갑으로 환불됩니다. 그렇지 않으면 커뮤니티 지갑으로 귀속됩니다.",
+	"bond.usage":                "사용법: /bond <트랜잭션 해시>",
+	"bond.lookup_failed":        "이의 제기를 조회하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"bond.no_appeal":            "보증금을 기다리는 이의 제기가 없습니다. 그룹의 이의 제기 링크에서 시작하세요.",
+	"bond.txn_failed":           "트랜잭션을 조회하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"bond.txn_pending":          "해당 트랜잭션이 아직 확정되지 않았습니다. 잠시 후 다시 시도해 주세요.",
+	"bond.txn_unsuccessful":     "해당 트랜잭션은 온체인에서 실패했습니다.",
+	"bond.wrong_recipient":      "해당 트랜잭션은 %s 로의 전송이 아닙니다.",
+	"bond.wrong_amount":         "보증금은 %s APT인데 해당 트랜잭션은 %s APT를 보냈습니다.",
+	"bond.txn_too_old":          "해당 트랜잭션은 이의 제기보다 이전의 것입니다.",
+	"bond.save_failed":          "보증금을 기록하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"bond.txn_reused":           "해당 트랜잭션은 이미 보증금으로 사용되었습니다.",
+	"bond.locked":               "보증금이 예치되었습니다. 관리자가 차단을 검토한 뒤 결정을 알려 드리겠습니다.",
+	"bond.review":               "⚖️ %s (ID: %d) 님이 %d 채팅의 차단에 %s APT 보증금(%s)을 걸고 이의를 제기했습니다.\n잘못된 차단: 차단 해제 후 환불. 차단 유지: 보증금은 커뮤니티 지갑으로 귀속.",
+	"bond.button_refund":        "✅ 잘못된 차단",
+	"bond.button_forfeit":       "🔨 차단 유지",
+	"bond.admins_only":          "그룹 관리자만 이의 제기를 결정할 수 있습니다.",
+	"bond.not_found":            "이의 제기를 찾을 수 없습니다.",
+	"bond.no_community_wallet":  "먼저 community_wallet 을 설정하세요.",
+	"bond.payout_failed":        "보증금 전송 실패: %v",
+	"bond.refunded":             "차단 이의 제기가 받아들여졌습니다. 차단이 해제되었고 %s APT 보증금이 환불되었습니다 (트랜잭션 %s).",
+	"bond.forfeited":            "차단 이의 제기가 기각되었습니다. %s APT 보증금은 커뮤니티 지갑으로 귀속되었습니다.",
+	"bond.decided_refunded":     "보증금을 환불했습니다.",
+	"bond.decided_forfeited":    "보증금을 귀속했습니다.",
+	"bond.decided_by_refunded":  "%s 님이 보증금을 환불함, 트랜잭션 %s.",
+	"bond.decided_by_forfeited": "%s 님이 보증금을 귀속함, 트랜잭션 %s.",
+
+	// Inline buttons
+	"callback.already_decided": "이미 결정되었습니다.",
+	"callback.failed":          "실패: %v",
+	"callback.not_for_you":     "이 버튼은 본인용이 아닙니다.",
+
+	// Event mode
+	"event.rule_links":     "링크는 삭제됩니다",
+	"event.rule_forwards":  "전달된 메시지는 삭제됩니다",
+	"event.rule_captcha":   "새 멤버는 캡차를 통과해야 합니다",
+	"event.rule_slow_mode": "멤버는 %s초에 한 번만 글을 쓸 수 있습니다",
+	"event.no_rules":       "event_restrictions 에 설정된 추가 규칙이 없습니다",
+	"event.status":         "이벤트 모드가 %s UTC까지 켜져 있습니다: %s.",
+	"event.status_off":     "이벤트 모드가 꺼져 있습니다. 사용법: /event <기간, 예: 2h 또는 90m> | off",
+	"event.end_failed":     "이벤트 모드를 끄지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"event.not_on":         "이벤트 모드가 켜져 있지 않습니다.",
+	"event.ended":          "이벤트 모드를 껐습니다. 평소 규칙으로 돌아갑니다.",
+	"event.usage":          "사용법: /event <기간, 예: 2h 또는 90m, 최대 7일> | off",
+	"event.start_failed":   "이벤트 모드를 켜지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"event.started":        "🛡 이벤트 모드가 %s UTC까지 켜졌습니다: %s.",
+	"event.captcha":        "%s 님, 이 그룹은 이벤트 모드입니다. 글을 쓰려면 %d분 안에 버튼을 눌러 주세요.",
+	"event.captcha_button": "✅ 사람입니다",
+	"event.captcha_passed": "환영합니다!",
+	"event.expired":        "이벤트 모드가 끝났습니다. 평소 규칙으로 돌아갑니다.",
+
+	// Suspect list
+	"private.spam_report": "🚨 %s (ID: %d) 님이 개인 채팅으로 스팸을 보냈습니다. 사유: %s\n\n%s\n\n되돌리려면 /unsuspect %d",
+	"unsuspect.usage":     "사용법: /unsuspect <사용자 ID>",
+	"unsuspect.failed":    "의심 사용자 목록을 변경하지 못했습니다: %v",
+	"unsuspect.done":      "사용자 %d 을(를) 의심 사용자 목록에서 제거했습니다.",
+
+	// Reports
+	"report.usage":          "신고할 메시지에 /report 로 답장하세요.",
+	"report.escalated":      "🚩 %s 님이 이 메시지를 신고했습니다. 관리자님, 확인해 주세요.",
+	"report.button_ban":     "🔨 차단",
+	"report.button_dismiss": "✅ 기각",
+	"report.admins_only":    "관리자만 신고를 처리할 수 있습니다.",
+	"report.banned":         "신고된 사용자를 차단했습니다.",
+	"report.dismissed":      "신고를 기각했습니다.",
+	"report.banned_by":      "%s 님이 신고된 사용자를 차단했습니다.",
+	"report.dismissed_by":   "%s 님이 신고를 기각했습니다.",
+
+	// Reaction flags
+	"flag.escalated":     "⚠️ 멤버 %d명이 이 메시지를 신고했습니다. 관리자님, 확인해 주세요.",
+	"flag.button_delete": "🗑 삭제",
+	"flag.button_keep":   "✅ 유지",
+	"flag.admins_only":   "관리자만 신고된 메시지를 처리할 수 있습니다.",
+	"flag.kept":          "유지했습니다.",
+	"flag.deleted":       "삭제했습니다.",
+	"flag.kept_by":       "%s 님이 유지함.",
+	"flag.deleted_by":    "%s 님이 삭제함.",
+
+	// Join requests
+	"join.escalated":      "⚠️ %s (ID: %d) 님의 가입 요청이 의심스럽습니다: %s\n관리자님, 확인해 주세요.",
+	"join.button_approve": "✅ 승인",
+	"join.button_decline": "❌ 거절",
+	"join.admins_only":    "관리자만 가입 요청을 처리할 수 있습니다.",
+	"join.approved":       "승인했습니다.",
+	"join.declined":       "거절했습니다.",
+	"join.approved_by":    "%s 님이 승인함.",
+	"join.declined_by":    "%s 님이 거절함.",
+
+	// Prices
+	"price.disabled": "가격 조회가 꺼져 있습니다.",
+	"price.usage":    "사용법: /price [심볼]",
+	"price.failed":   "가격 조회에 실패했습니다. 잠시 후 다시 시도해 주세요.",
+	"price.unknown":  "%s 심볼의 토큰을 알지 못합니다.",
+
+	// Rules
+	"rules.lookup_failed":  "규칙 메시지를 찾지 못했습니다: %v",
+	"rules.pin_failed":     "고정하지 못했습니다: %v",
+	"rules.save_failed":    "규칙 메시지를 저장하지 못했습니다: %v",
+	"rules.pin_usage":      "메시지에 /pinrules 로 답장하거나, 먼저 /setrules <내용> 으로 규칙을 설정하세요.",
+	"rules.post_failed":    "규칙을 게시하지 못했습니다: %v",
+	"rules.nothing_pinned": "고정 해제할 규칙 메시지가 없습니다.",
+	"rules.forget_failed":  "규칙 메시지를 삭제하지 못했습니다: %v",
+	"rules.unpinned":       "규칙 메시지 고정을 해제했습니다.",
+	"rules.pinned":         "📌 규칙 메시지를 고정했습니다. 계속 고정해 두겠습니다.",
+	"rules.set_usage":      "사용법: /setrules <내용>, 또는 규칙이 담긴 메시지에 /setrules 로 답장",
+	"rules.set":            "✅ 규칙을 저장했습니다. 멤버는 /rules 로 볼 수 있습니다.",
+	"rules.set_reposted":   "✅ 규칙을 저장하고 고정된 규칙 메시지를 교체했습니다.",
+	"rules.pinned_link":    "📌 고정된 규칙: %s",
+	"rules.none":           "이 채팅에는 아직 규칙이 없습니다.",
+
+	// Tips
+	"tip.usage":          "사용법: 신고자에게 답장으로 /tip <APT>, 또는 /tip <사용자 ID> <APT>. 지갑에서 보낸 뒤에는 답장으로 /tip paid <트랜잭션 해시>, 또는 /tip paid <사용자 ID> <트랜잭션 해시>",
+	"tip.reports_failed": "신고 내역을 조회하지 못했습니다: %v",
+	"tip.no_report":      "해당 멤버에게는 차단으로 이어진, 아직 보상하지 않은 신고가 없습니다.",
+	"tip.wallet_failed":  "지갑을 조회하지 못했습니다: %v",
+	"tip.no_wallet":      "해당 멤버는 아직 Aptos 지갑을 인증하지 않았습니다. /verify 로 인증할 수 있습니다.",
+	"tip.record_failed":  "보상을 기록하지 못했습니다: %v",
+	"tip.payload":        "다음 트랜잭션으로 지갑에서 %s APT 를 %s 에게 보내세요:\n\n%s\n\n그다음 %d시간 안에 /tip paid <트랜잭션 해시> 로 확인해 주세요. 확인하지 않으면 다시 보상할 수 있게 됩니다.",
+	"tip.already":        "이미 보상한 신고입니다.",
+	"tip.not_awaiting":   "해당 멤버에게 트랜잭션을 기다리는 보상이 없습니다.",
+	"tip.txn_failed":     "트랜잭션을 조회하지 못했습니다: %v",
+	"tip.txn_pending":    "트랜잭션이 아직 체인에 없습니다. 잠시 후 다시 시도해 주세요.",
+	"tip.txn_mismatch":   "해당 트랜잭션은 보상 이후 %s APT 를 %s 에게 보낸 트랜잭션이 아닙니다.",
+	"tip.txn_used":       "이미 다른 보상을 확인한 트랜잭션입니다.",
+	"tip.send_failed":    "보상을 보내지 못했습니다: %v",
+	"tip.sent":           "%s APT 를 %s 에게 보냈습니다. 트랜잭션 %s. 신고해 주셔서 감사합니다!",
+
+	// Wall
+	"wall.use_in_group":   "게시하려는 월이 있는 그룹에서 /wall 을 사용하세요.",
+	"wall.no_channel":     "이 채팅에는 월 채널이 없습니다.",
+	"wall.link":           "개인 채팅에서 월 게시물을 제출하세요: %s",
+	"wall.invalid_link":   "유효하지 않은 월 링크입니다.",
+	"wall.start_failed":   "제출을 시작하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"wall.prompt":         "%d분 안에 게시물을 하나의 텍스트 메시지로 보내 주세요. 게시 전에 관리자가 검토합니다.",
+	"wall.text_only":      "텍스트 게시물만 제출할 수 있습니다. 다시 하려면 월 링크를 다시 따라가세요.",
+	"wall.spam":           "게시물이 스팸으로 보여 제출되지 않았습니다.",
+	"wall.submit_failed":  "게시물을 제출하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"wall.submitted":      "감사합니다! 게시물을 관리자에게 검토 요청했습니다.",
+	"wall.review":         "📝 %s 님이 월 게시물을 제출했습니다:\n\n%s",
+	"wall.button_publish": "✅ 게시",
+	"wall.button_reject":  "❌ 거절",
+
+	// Wall reviews
+	"wall.gone":             "이 제출물은 더 이상 존재하지 않습니다.",
+	"wall.admins_only":      "관리자만 월 게시물을 검토할 수 있습니다.",
+	"wall.already":          "이미 검토했습니다.",
+	"wall.publish_failed":   "게시하지 못했습니다: %v",
+	"wall.published":        "게시했습니다.",
+	"wall.rejected":         "거절했습니다.",
+	"wall.published_by":     "%s 님이 게시함.",
+	"wall.rejected_by":      "%s 님이 거절함.",
+	"wall.author_published": "월 게시물이 게시되었습니다.",
+	"wall.author_rejected":  "월 게시물이 거절되었습니다.",
+
+	// Wallet verification
+	"verify.invalid_link":   "유효하지 않은 인증 링크입니다.",
+	"verify.start_failed":   "인증을 시작하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"verify.challenge":      "%d분 안에 Aptos 지갑으로 이 메시지에 서명하세요:\n\n%s\n\n그다음 여기에서 /verify <공개 키> <서명> (hex) 을 보내세요.",
+	"verify.link":           "개인 채팅에서 Aptos 지갑을 인증하세요: %s",
+	"verify.usage":          "사용법: /verify <공개 키> <서명>\n그룹의 인증 링크에서 시작하세요.",
+	"verify.lookup_failed":  "인증 정보를 찾지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"verify.none_pending":   "진행 중인 인증이 없거나 만료되었습니다. 그룹의 인증 링크를 다시 사용하세요.",
+	"verify.failed":         "인증에 실패했습니다: %v",
+	"verify.sybil_rejected": "이 지갑은 이미 다른 텔레그램 계정에 연결되어 있어 다시 인증할 수 없습니다.",
+	"verify.save_failed":    "지갑을 저장하지 못했습니다. 잠시 후 다시 시도해 주세요.",
+	"verify.done":           "지갑 %s 인증이 완료되었습니다.",
+	"verify.links_gated":    "%s 님, 이곳에서는 Aptos 지갑을 인증한 멤버만 링크를 올릴 수 있습니다. 인증하기: %s",
+
+	// Scam alerts
+	"alert.seed_phrase":  "🚨 %s 님의 메시지를 삭제했습니다 (%s). 시드 문구나 개인 키는 누구에게도 알려주지 마세요.",
+	"alert.fake_support": "🚨 %s 님이 %s (으)로 안내하는 가짜 고객지원 메시지를 삭제했습니다. 관리자는 절대 다른 곳에서 티켓을 열라고 요청하지 않습니다.",
+	"alert.lookalike":    "⚠️ %s 님의 메시지를 삭제했습니다: %s 는 공식 링크가 아닙니다. 공식 링크는 %s 입니다",
+
+	// Contracts
+	"token.scam_listed": "⛔ %s 님이 스캠 목록에 있는 컨트랙트를 게시했습니다: %s",
+	"token.fresh":       "🆕 %s 님이 최근 배포된 컨트랙트를 게시했습니다: %s",
+	"token.posted":      "🔎 %s 님이 게시함: %s",
+
+	// Profile photos
+	"photo.new_member": "🖼 새 멤버 %s (%d): %s",
+
+	// Onchain announcements
+	"onchain.treasury_sent":     "💸 트레저리가 %s APT 를 보냈습니다\n%s",
+	"onchain.treasury_received": "💸 트레저리가 %s APT 를 받았습니다\n%s",
+
+	// Token gate
+	"gate.need_tokens": "%s 이상의 %s",
+	"gate.need_nft":    "%s 컬렉션의 NFT",
+	"gate.and":         "와(과) ",
+	"gate.notice":      "%s 님, 이 그룹의 멤버는 인증된 Aptos 지갑에 %s 을(를) 보유해야 합니다. 남아 있으려면 %d시간 안에 인증하세요: %s",
+
+	// Sybil accounts
+	"sybil.notice":   "🪞 다중 계정 의심: %s (%d) 님이 %s 을(를) 인증함, %s (%s)",
+	"sybil.flagged":  "표시함",
+	"sybil.rejected": "인증 거부",
+	"sybil.banned":   "차단함",
+
+	// Detection labels
+	"reason.fake_airdrop":         "가짜 에어드랍 링크",
+	"reason.wallet_connect":       "지갑 연결 피싱 링크",
+	"reason.fake_eligibility":     "가짜 자격 확인 링크",
+	"reason.crypto_scam":          "암호화폐 사기 링크",
+	"reason.seed_request":         "시드 문구/개인키 요구",
+	"reason.private_key":          "개인키 게시",
+	"reason.mnemonic":             "니모닉 게시",
+	"reason.fake_support":         "가짜 고객지원",
+	"reason.url":                  "URL 감지",
+	"reason.blocked_domain":       "차단된 도메인",
+	"reason.phishing_domain":      "피싱 도메인",
+	"reason.dm_solicitation":      "DM 유도",
+	"reason.spam_keyword":         "스팸 키워드",
+	"reason.spam_keyword_mention": "멘션+스팸 키워드",
+
+	// Digests
+	"digest.title_daily":  "📊 %s 일간 관리 요약",
+	"digest.title_weekly": "📊 %s 주간 관리 요약",
+	"digest.quiet":        "보고할 내용이 없습니다.",
+	"digest.counts":       "삭제된 메시지: %d\n차단: %d\n차단 해제: %d",
+	"digest.top_rules":    "주요 규칙:",
+	"digest.top_users":    "메시지가 가장 많이 삭제된 멤버:",
+	"digest.line":         "• %s: %d",
+
+	// Ban alerts
+	"ban_alert.text":         "🔨 %s (ID: %d) 님을 %s 에서 차단했습니다: %s",
+	"ban_alert.message":      "메시지:\n%s",
+	"ban_alert.button_unban": "♻️ 차단 해제",
+	"ban_alert.admins_only":  "해당 채팅의 관리자만 차단을 해제할 수 있습니다.",
+	"ban_alert.unbanned":     "차단을 해제했습니다.",
+	"ban_alert.unbanned_by":  "%s 님이 차단을 해제함.",
+
+	// Discord mirror
+	"discord.delete": "🗑 메시지 삭제",
+	"discord.kick":   "👢 멤버 내보냄",
+	"discord.ban":    "🔨 멤버 차단",
+	"discord.unban":  "♻️ 차단 해제",
+	"discord.chat":   "채팅",
+	"discord.user":   "사용자",
+
+	// Dashboard
+	"dashboard.title":              "관리 대시보드",
+	"dashboard.sign_in":            "관리하는 채팅을 설정하려면 텔레그램으로 로그인하세요.",
+	"dashboard.signed_in":          "%s(으)로 로그인됨",
+	"dashboard.sign_out":           "로그아웃",
+	"dashboard.chats":              "내 채팅",
+	"dashboard.no_chats":           "봇이 있는 채팅 중 관리자로 있는 채팅이 없습니다.",
+	"dashboard.back":               "전체 채팅",
+	"dashboard.detections":         "최근 %d일 삭제 및 차단",
+	"dashboard.deletes":            "삭제",
+	"dashboard.bans":               "차단",
+	"dashboard.top_rules":          "주요 규칙",
+	"dashboard.log":                "관리 기록",
+	"dashboard.log_empty":          "아직 기록이 없습니다.",
+	"dashboard.log_time":           "시간 (UTC)",
+	"dashboard.log_action":         "조치",
+	"dashboard.log_user":           "사용자",
+	"dashboard.log_rule":           "규칙",
+	"dashboard.section_lists":      "키워드 및 도메인",
+	"dashboard.section_thresholds": "기준값",
+	"dashboard.section_other":      "기타 설정",
+	"dashboard.save":               "저장",
+	"dashboard.reset":              "기본값",
+	"dashboard.saved":              "%s 저장됨.",
+	"dashboard.failed":             "저장 실패: %s",
+	"dashboard.empty_value":        "값을 입력하거나 기본값을 사용하세요.",
+	"dashboard.feed":               "이 기록의 Atom 피드",
+	"dashboard.feed_private":       "링크가 있으면 누구나 읽을 수 있으니 공개하지 마세요",
+
+	// Transparency page
+	"transparency.title":             "%s 관리 현황",
+	"transparency.intro":             "최근 %d일 동안 봇과 관리자가 조치한 내역입니다.",
+	"transparency.removed":           "삭제된 메시지",
+	"transparency.kicks":             "내보낸 멤버",
+	"transparency.bans":              "차단",
+	"transparency.unbans":            "차단 해제",
+	"transparency.appeals":           "차단 이의 신청",
+	"transparency.appeals_review":    "검토 중",
+	"transparency.appeals_refunded":  "차단 해제, 보증금 반환",
+	"transparency.appeals_forfeited": "차단 유지",
+	"transparency.daily":             "일별",
+	"transparency.date":              "날짜 (UTC)",
+	"transparency.audit":             "감사 로그의 모든 조치",
+	"transparency.overturned":        "이의 신청으로 번복된 삭제",
+
+	// Disputes
+	"dispute.button":            "🙋 스팸이 아니에요",
+	"dispute.not_yours":         "메시지가 삭제된 본인만 한 번 이의를 제기할 수 있습니다.",
+	"dispute.filed":             "관리자에게 검토를 요청했습니다.",
+	"dispute.review":            "🙋 %s 님이 삭제된 메시지가 스팸이 아니라고 합니다.\n사유: %s\n\n메시지:\n%s",
+	"dispute.button_overturn":   "↩️ 스팸 아님",
+	"dispute.button_uphold":     "✅ 스팸 맞음",
+	"dispute.admins_only":       "관리자만 이의 신청을 결정할 수 있습니다.",
+	"dispute.overturned":        "삭제가 번복되어 경고가 취소되었습니다.",
+	"dispute.upheld":            "삭제가 유지되었습니다.",
+	"dispute.overturned_by":     "%s 님이 번복했습니다.",
+	"dispute.upheld_by":         "%s 님이 유지했습니다.",
+	"dispute.member_overturned": "관리자가 메시지가 스팸이 아니라고 확인해 경고를 취소했습니다. 불편을 드려 죄송합니다!",
+	"dispute.member_upheld":     "관리자가 삭제된 메시지를 검토했으며 삭제를 유지했습니다.",
+
+	// Minor violations
+	"minor.notice": "%s에 올린 메시지는 삭제하는 대신 %[3]s 표시를 했습니다 (사유: %[2]s). 그곳 규칙에서 허용하지 않는 내용입니다. 수정하거나 삭제해 주세요. 그렇지 않으면 관리자가 삭제할 수 있습니다.",
+
+	// Stats chart
+	"stats.title":  "📊 %s 감지 현황, %s – %s (%s)",
+	"stats.other":  "기타 규칙",
+	"stats.total":  "합계: %d",
+	"stats.quiet":  "최근 %d일 동안 감지된 메시지가 없습니다.",
+	"stats.failed": "통계 차트를 만들지 못했습니다. 잠시 후 다시 시도해 주세요.",
+
+	// Moderation feed
+	"feed.title":   "%s 관리 기록",
+	"feed.entry":   "%s: 사용자 %d",
+	"feed.summary": "사용자 %[2]d에 대한 %[1]s 조치, 규칙: %[3]s",
+
+	// Action retries
+	"retry.delete_failed": "⚠️ 사용자 %d 님의 메시지(%s)를 %d번 시도했지만 삭제하지 못했습니다: %v\n직접 삭제해 주세요.",
+	"retry.ban_failed":    "⚠️ 사용자 %d 님(%s)을 %d번 시도했지만 차단하지 못했습니다: %v\n직접 차단해 주세요.",
+
+	// Declared rules
+	"yaml_rule.notify": "📋 규칙 %s 이(가) %s (ID: %d) 님의 메시지와 일치했습니다: %s",
+
+	// False positives
+	"notspam.usage":     "제 경고 메시지에 /notspam 으로 답장하거나, /notspam <사용자 ID> 로 해당 멤버의 최근 경고를 지정하세요.",
+	"notspam.not_found": "취소할 삭제 기록을 찾지 못했습니다. 경고는 약 일주일 동안만 취소할 수 있습니다.",
+	"notspam.done":      "\"%s\" 규칙의 오탐지로 기록하고 경고를 취소했습니다.",
+	"precision.title":   "🎯 규칙 정확도 (취소되지 않은 삭제 비율):",
+	"precision.row":     "%s: %.0f%% (%[4]d건 중 %[3]d건 취소)",
+	"precision.none":    "아직 기록된 삭제가 없습니다.",
+	"precision.failed":  "규칙 정확도를 불러오지 못했습니다. 잠시 후 다시 시도해 주세요.",
+
+	// Flags only the owner may switch
+	"feature.owner_only": "%s 플래그는 봇 소유자만 변경할 수 있습니다.",
+
+	// Limits on tips from the bot's wallet
+	"tip.over_max":   "봇 지갑에서 보내는 팁은 최대 %s APT입니다.",
+	"tip.over_daily": "이 채팅은 최근 24시간 동안 봇 지갑에서 보낼 수 있는 팁 한도(%s APT)에 도달했습니다.",
+
+	// Bonds tied to the appellant's wallet, and payouts reconciled on chain
+	"bond.no_wallet":          "보증금은 그룹에 인증한 지갑에서 보내야 합니다. 먼저 지갑을 인증하세요: %s",
+	"bond.wrong_sender":       "그 트랜잭션은 인증된 지갑 %s 에서 보낸 것이 아닙니다.",
+	"bond.payout_unconfirmed": "지급을 전송했습니다. 네트워크 확인을 기다리는 중입니다.",
+	"bond.payout_reopened":    "⚠️ 채팅 %[2]d의 %[1]d 보증금 지급(%[3]s APT, 트랜잭션 %[4]s)이 처리되지 않았습니다. 이의 제기가 다시 열렸습니다.",
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+
+	"spambot/detector"
+)
+
+// metrics holds process-wide counters, published under "spambot" in /debug/vars
+var metrics = expvar.NewMap("spambot")
+
+func init() {
+	// Matches of the registered and configured detector checks, by name
+	metrics.Set("check_matches", expvar.Func(func() any { return detector.CheckMatches() }))
+	// Hits, misses and evictions of the runtime caches, by name
+	metrics.Set("caches", expvar.Func(func() any { return cacheMetrics() }))
+}
+
+// serveMetrics exposes expvar counters on addr (e.g. "127.0.0.1:9090")
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Printf("Serving metrics on http://%s/debug/vars", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// chatTables lists every table keyed by chat_id, with the rest of its primary
+// key, or all of it for tables like wall_posts whose key doesn't include chat_id.
+// Add new per-chat tables here so they survive a group → supergroup upgrade.
+var chatTables = []struct {
+	name string
+	key  []string
+}{
+	{"spam_records", []string{"user_id"}},
+	{"feature_flags", []string{"name"}},
+	{"bot_chats", []string{"bot_id"}},
+	{"chat_settings", []string{"key"}},
+	{"topic_settings", []string{"thread_id", "key"}},
+	{"chat_members", []string{"user_id"}},
+	{"rules_messages", []string{"bot_id"}},
+	{"wallet_links", []string{"user_id"}},
+	{"token_gate", []string{"user_id"}},
+	{"chat_addresses", []string{"address"}},
+	{"reports", []string{"message_id", "reporter_id"}},
+	{"tips", []string{"message_id", "reporter_id"}},
+	{"onchain_cursors", []string{"source"}},
+	{"ban_bonds", []string{"user_id"}},
+	{"event_modes", nil},
+	{"event_captchas", []string{"user_id"}},
+	{"scam_photos", []string{"user_id"}},
+	{"moderation_events", []string{"user_id", "action", "at"}},
+	{"digests", []string{"bot_id"}},
+	{"moderation_counts", []string{"day", "action"}},
+	{"disputes", []string{"message_id"}},
+	{"rule_outcomes", []string{"rule"}},
+	{"wall_drafts", []string{"user_id"}},
+	{"wall_posts", []string{"user_id", "message_id"}},
+}
+
+// MigrateChat moves all per-chat state from one chat id to another in one
+// transaction. Rows the new chat already has win over the old ones.
+func (s *Store) MigrateChat(ctx context.Context, from, to int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to migrate chat %d: %v", from, err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range chatTables {
+		// Tables keyed by chat_id alone only need the new chat to have no row
+		match := []string{"n.chat_id = ?"}
+		for _, col := range table.key {
+			match = append(match, fmt.Sprintf("n.%s = %s.%s", col, table.name, col))
+		}
+		update := fmt.Sprintf(`UPDATE %s SET chat_id = ? WHERE chat_id = ? AND NOT EXISTS (
+			SELECT 1 FROM %s n WHERE %s)`, table.name, table.name, strings.Join(match, " AND "))
+		if _, err := tx.ExecContext(ctx, s.Rebind(update), to, from, to); err != nil {
+			return fmt.Errorf("failed to migrate %s of chat %d: %v", table.name, from, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.Rebind(fmt.Sprintf(`DELETE FROM %s WHERE chat_id = ?`, table.name)), from); err != nil {
+			return fmt.Errorf("failed to migrate %s of chat %d: %v", table.name, from, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// handleChatMigration carries a group's state over when it is upgraded to a
+// supergroup, which gives it a new chat id. Telegram sends a service message to
+// both the old and the new chat; whichever arrives second finds nothing left to move.
+func (b *Bot) handleChatMigration(ctx context.Context, message *Message) {
+	from, to := message.Chat.ID, message.MigrateToChatID
+	if to == 0 {
+		from, to = message.MigrateFromChatID, message.Chat.ID
+	}
+	if err := b.app.db.MigrateChat(ctx, from, to); err != nil {
+		b.logf("%v", err)
+		b.app.reporter.Failure("db.migrateChat", err, b.errorContext(message.Message))
+		return
+	}
+	b.app.settings.invalidate(settingsScope{chatID: to})
+	b.app.flags.invalidate(to)
+	b.seenChats.Delete(from)
+	b.logf("Migrated chat %d to supergroup %d", from, to)
+	// Only the new chat's notice can be answered; the old group is closed
+	if message.Chat.ID == to {
+		b.repinAfterMigration(ctx, to)
+	}
+}
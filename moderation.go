@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"spambot/proto/moderationpb"
+)
+
+//go:generate protoc --go_out=. --go_opt=module=spambot --go-grpc_out=. --go-grpc_opt=module=spambot proto/moderation.proto
+
+// moderationPathPrefix is the path of the moderation service's methods, as
+// gRPC names them: /moderation.v1.Moderation/<Method>
+const moderationPathPrefix = "/moderation.v1.Moderation/"
+
+// maxModerationBody caps the size of a moderation request
+const maxModerationBody = 64 << 10
+
+// moderationActions are the actions RecordAction accepts
+var moderationActions = []string{"strike", "delete", "kick", "ban", "unban"}
+
+// UserModeration counts userID's moderation events in chatID since since by action
+func (s *Store) UserModeration(ctx context.Context, chatID, userID int64, since time.Time) (map[string]int, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT action, COUNT(*) FROM moderation_events WHERE chat_id = ? AND user_id = ? AND at >= ? GROUP BY action
+	`, chatID, userID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var action string
+		var count int
+		if err := rows.Scan(&action, &count); err != nil {
+			return nil, err
+		}
+		counts[action] = count
+	}
+	return counts, rows.Err()
+}
+
+// ModerationService is the detection engine and moderation records as the
+// Moderation service of proto/moderation.proto, for other services such as a
+// website comment filter or a Discord bot. Run mode serves it over gRPC on
+// MODERATION_ADDR; webhook mode, which has no gRPC listener, takes the proto3
+// JSON mapping on the same method paths. Callers authenticate with
+// "authorization: Bearer <MODERATION_TOKEN>".
+type ModerationService struct {
+	moderationpb.UnimplementedModerationServer
+	app   *App
+	token string
+}
+
+// newModerationService returns nil when MODERATION_TOKEN is unset
+func newModerationService(app *App, cfg *Config) *ModerationService {
+	if cfg.ModerationToken == "" {
+		return nil
+	}
+	return &ModerationService{app: app, token: cfg.ModerationToken}
+}
+
+// ClassifyText runs text through the severe rules first, then the strike rules,
+// with chatID's settings (0: the defaults)
+func (s *ModerationService) ClassifyText(ctx context.Context, req *moderationpb.ClassifyTextRequest) (*moderationpb.ClassifyTextResponse, error) {
+	verdict, err := s.app.detector.Detect(ctx, req.ChatId, req.Text)
+	if err != nil {
+		return nil, err
+	}
+	if !verdict.Spam() {
+		return &moderationpb.ClassifyTextResponse{}, nil
+	}
+	return &moderationpb.ClassifyTextResponse{
+		Spam:     true,
+		Reason:   verdict.Reason,
+		Label:    verdict.Label(),
+		Severity: verdict.Severity.String(),
+		Score:    verdict.Score,
+	}, nil
+}
+
+// RecordAction records a decision another service took, so it shows in
+// digests, counts towards BAN_THRESHOLD (strike) or lifts strikes (unban)
+func (s *ModerationService) RecordAction(ctx context.Context, req *moderationpb.RecordActionRequest) (*moderationpb.RecordActionResponse, error) {
+	chatID, userID := req.ChatId, req.UserId
+	if !containsString(moderationActions, req.Action) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown action %q (use %s)", req.Action, strings.Join(moderationActions, ", "))
+	}
+	reason := req.Reason
+	if req.Source != "" {
+		reason = req.Source + ": " + reason
+	}
+	var strikes int
+	var shouldBan bool
+	var err error
+	switch req.Action {
+	case "strike":
+		strikes, shouldBan, err = s.app.detector.RecordSpam(ctx, chatID, userID)
+	case "unban":
+		err = s.app.detector.ClearSpam(ctx, chatID, userID)
+	default:
+		strikes, err = s.app.detector.SpamCount(ctx, chatID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.Action != "strike" {
+		if err := s.app.db.RecordModeration(ctx, chatID, userID, req.Action, moderationRule(req.Reason), s.app.settings.Now(ctx, chatID)); err != nil {
+			return nil, err
+		}
+	}
+	if s.app.audit != nil {
+		entry := auditEntry{Action: req.Action, ChatID: chatID, UserID: userID, Reason: reason, At: time.Now().Unix()}
+		if err := s.app.db.AppendAudit(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Recorded %s of user %d in chat %d from the moderation service: %s", req.Action, userID, chatID, reason)
+	return &moderationpb.RecordActionResponse{Strikes: int32(strikes), ShouldBan: shouldBan}, nil
+}
+
+// GetUserRisk summarizes userID's strikes and recent moderation in chatID and
+// whether CAS lists them
+func (s *ModerationService) GetUserRisk(ctx context.Context, req *moderationpb.GetUserRiskRequest) (*moderationpb.GetUserRiskResponse, error) {
+	chatID, userID := req.ChatId, req.UserId
+	threshold := s.app.Config().BanThreshold
+	strikes, err := s.app.detector.SpamCount(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.app.db.UserModeration(ctx, chatID, userID, time.Now().Add(-moderationRetention))
+	if err != nil {
+		return nil, err
+	}
+	resp := &moderationpb.GetUserRiskResponse{
+		Strikes:        int32(strikes),
+		BanThreshold:   int32(threshold),
+		RecentRemovals: int32(counts["delete"]),
+		RecentBans:     int32(counts["ban"]),
+	}
+	if resp.CasBanned, err = s.app.cas.Banned(ctx, userID); err != nil {
+		log.Printf("Failed to check user %d on CAS for the moderation service: %v", userID, err)
+	}
+	switch {
+	case resp.CasBanned || resp.RecentBans > 0 || strikes > 0 && strikes+1 >= threshold:
+		resp.Risk = "high"
+	case strikes > 0 || resp.RecentRemovals > 0:
+		resp.Risk = "medium"
+	default:
+		resp.Risk = "low"
+	}
+	return resp, nil
+}
+
+// authorized reports whether header, an authorization value, carries the token
+func (s *ModerationService) authorized(header string) bool {
+	token, _ := strings.CutPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// result counts a call to method, and logs and reports it if it failed other
+// than by a bad request; those get an opaque internal error
+func (s *ModerationService) result(method string, err error) error {
+	if err == nil {
+		metrics.Add("moderation_service_calls", 1)
+		return nil
+	}
+	if status.Code(err) == codes.InvalidArgument {
+		return err
+	}
+	log.Printf("Moderation service %s failed: %v", method, err)
+	s.app.reporter.Failure("moderation."+method, err, ErrorContext{})
+	return status.Error(codes.Internal, "internal error")
+}
+
+// intercept authenticates gRPC calls and handles their errors as result does
+func (s *ModerationService) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) != 1 || !s.authorized(values[0]) {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	resp, err := handler(ctx, req)
+	if err = s.result(strings.TrimPrefix(info.FullMethod, moderationPathPrefix), err); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ServeHTTP serves the methods in the proto3 JSON mapping, for webhook mode
+func (s *ModerationService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r.Header.Get("Authorization")) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxModerationBody))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	method := strings.TrimPrefix(r.URL.Path, moderationPathPrefix)
+	var resp proto.Message
+	switch method {
+	case "ClassifyText":
+		req := &moderationpb.ClassifyTextRequest{}
+		if err = decodeModerationRequest(data, req); err == nil {
+			resp, err = s.ClassifyText(ctx, req)
+		}
+	case "RecordAction":
+		req := &moderationpb.RecordActionRequest{}
+		if err = decodeModerationRequest(data, req); err == nil {
+			resp, err = s.RecordAction(ctx, req)
+		}
+	case "GetUserRisk":
+		req := &moderationpb.GetUserRiskRequest{}
+		if err = decodeModerationRequest(data, req); err == nil {
+			resp, err = s.GetUserRisk(ctx, req)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err = s.result(method, err); err != nil {
+		code := http.StatusInternalServerError
+		if status.Code(err) == codes.InvalidArgument {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, status.Convert(err).Message(), code)
+		return
+	}
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// decodeModerationRequest reads a request message in the proto3 JSON mapping,
+// ignoring fields this version doesn't know
+func decodeModerationRequest(data []byte, req proto.Message) error {
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, req); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request: "+err.Error())
+	}
+	return nil
+}
+
+// serveModeration serves the moderation service over gRPC on addr until the
+// listener fails
+func serveModeration(addr string, service *ModerationService) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Moderation server stopped: %v", err)
+		return
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(service.intercept), grpc.MaxRecvMsgSize(maxModerationBody))
+	moderationpb.RegisterModerationServer(server, service)
+	log.Printf("Serving the moderation service over gRPC on %s", addr)
+	if err := server.Serve(listener); err != nil {
+		log.Printf("Moderation server stopped: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// messageLink is the t.me link to a message in chat; basic groups and
+// private chats have none
+func messageLink(chat *tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageID)
+	}
+	if id := strconv.FormatInt(chat.ID, 10); strings.HasPrefix(id, "-100") {
+		return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), messageID)
+	}
+	return ""
+}
+
+// rulesLink links to chat's tracked rules message, or is empty if there is none
+func (b *Bot) rulesLink(ctx context.Context, chat *tgbotapi.Chat) string {
+	post, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chat.ID)
+	if err != nil || !ok {
+		return ""
+	}
+	return messageLink(chat, post.MessageID)
+}
+
+// mention links user's name to their profile, which notifies them like an
+// @mention even when they have no username; only for messages sent as HTML
+func mention(user tgbotapi.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = strconv.FormatInt(user.ID, 10)
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, user.ID, html.EscapeString(name))
+}
+
+// htmlMessage is a message to chatID whose text is HTML, such as one with a
+// mention; anything else interpolated into it must go through html.EscapeString
+func htmlMessage(chatID int64, text string) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	return msg
+}
+
+// punishmentNotice is what a warning or ban notice fills its placeholders from
+type punishmentNotice struct {
+	User      tgbotapi.User
+	Count     int
+	Threshold int
+	Reason    string
+	RulesLink string
+}
+
+// noticeText fills the {user}, {count}, {threshold}, {reason} and {rules_link}
+// placeholders, as HTML with {user} a mention
+func noticeText(template string, notice punishmentNotice) string {
+	return strings.NewReplacer(
+		"{user}", mention(notice.User),
+		"{count}", strconv.Itoa(notice.Count),
+		"{threshold}", strconv.Itoa(notice.Threshold),
+		"{reason}", html.EscapeString(notice.Reason),
+		"{rules_link}", html.EscapeString(notice.RulesLink),
+		`\n`, "\n",
+	).Replace(html.EscapeString(template))
+}
+
+// notifyPunishment posts the chat's warn_message or ban_message template,
+// whichever key names, about the member whose spam was just removed. A
+// warning carries a button for the member to dispute the removal. With
+// warn_delivery=private it goes to the member alone; in the chat the notice is
+// deleted after notice_delete_after seconds like other moderation notices.
+func (b *Bot) notifyPunishment(ctx context.Context, message *Message, key string, count int, reason string) {
+	template := b.setting(ctx, message, key)
+	if template == "" {
+		return
+	}
+	text := noticeText(template, punishmentNotice{
+		User:      *message.From,
+		Count:     count,
+		Threshold: b.app.Config().BanThreshold,
+		Reason:    reason,
+		RulesLink: b.rulesLink(ctx, message.Chat),
+	})
+	var dispute *tgbotapi.InlineKeyboardMarkup
+	if key == settingWarnMessage {
+		dispute = b.disputeButton(ctx, message, reason)
+	}
+	if key == settingWarnMessage && b.setting(ctx, message, settingWarnDelivery) == "private" {
+		// Telegram refuses with 403 until the member has started the bot
+		private := htmlMessage(message.From.ID, text)
+		if dispute != nil {
+			private.ReplyMarkup = dispute
+		}
+		if _, err := b.send(ctx, private); err == nil {
+			metrics.Add("warnings_sent_privately", 1)
+			return
+		}
+		b.logf("Can't warn %s privately, warning in chat %d instead", message.From.UserName, message.Chat.ID)
+	}
+	notice := htmlMessage(message.Chat.ID, text)
+	if dispute != nil {
+		notice.ReplyMarkup = dispute
+	}
+	sent, err := b.send(ctx, notice)
+	if err != nil {
+		b.logf("Failed to notify %s in chat %d: %v", message.From.UserName, message.Chat.ID, err)
+		b.app.reporter.Failure("telegram.sendNotice", err, b.errorContext(message.Message))
+		return
+	}
+	b.deleteNoticeLater(ctx, message.Chat.ID, sent.MessageID)
+}
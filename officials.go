@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// unicodeHostPattern matches host names including internationalized ones, so
+// homoglyph domains such as "аptos.com" (Cyrillic а) are seen as hosts
+var unicodeHostPattern = regexp.MustCompile(`[\p{L}\p{N}](?:[\p{L}\p{N}-]*[\p{L}\p{N}])?(?:\.[\p{L}\p{N}](?:[\p{L}\p{N}-]*[\p{L}\p{N}])?)+`)
+
+// telegramLinkPattern matches t.me links to a chat or user, which name a handle like an @mention
+var telegramLinkPattern = regexp.MustCompile(`(?i)\b(?:t|telegram)\.me/([a-z0-9_]{4,32})\b`)
+
+// knownGoodDomains are the wallets, dapps and exchanges drainers imitate in
+// Aptos groups; IDN homographs of them are flagged in every chat
+var knownGoodDomains = []string{
+	"aptoslabs.com", "aptosfoundation.org", "aptos.dev", "aptosnames.com",
+	"petra.app", "pontem.network", "martianwallet.xyz", "nightly.app", "rimosafe.com",
+	"liquidswap.com", "thala.fi", "amnis.finance", "echelon.market", "aries.markets",
+	"panora.exchange", "hyperion.xyz", "wapal.io", "tradeport.xyz",
+	"layerzero.network", "wormhole.com", "coingecko.com", "coinmarketcap.com",
+	"binance.com", "coinbase.com", "okx.com", "bybit.com", "upbit.com", "bithumb.com",
+	"metamask.io", "telegram.org",
+}
+
+// confusables maps characters commonly swapped in for Latin letters in typosquats
+var confusables = map[rune]string{
+	'а': "a", 'е': "e", 'о': "o", 'р': "p", 'с': "c", 'х': "x", 'у': "y", 'і': "i", 'ј': "j", 'ѕ': "s", 'ԁ': "d", 'ӏ': "l", 'ɡ': "g",
+	'α': "a", 'ο': "o", 'ν': "v", 'τ': "t", 'ρ': "p", 'ι': "i", 'κ': "k", 'η': "n",
+	'0': "o", '1': "l", '3': "e", '5': "s", 'i': "l", '_': "", '-': "",
+}
+
+// skeleton folds a name to what it looks like, so "apt0s", "аptos" and "a_ptos" compare equal
+func skeleton(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := confusables[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.NewReplacer("rn", "m", "vv", "w").Replace(b.String())
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// resembles reports whether name, which is not official, imitates official:
+// the same skeleton, a typo or two away, or official plus a "-claim" style affix
+func resembles(name, official string) bool {
+	if utf8.RuneCountInString(official) < 4 {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == '-' || r == '_' }) {
+		if part == official && part != strings.ToLower(name) {
+			return true
+		}
+	}
+	a, b := skeleton(name), skeleton(official)
+	if a == b {
+		return true
+	}
+	allowed := 1
+	if len(b) >= 8 {
+		allowed = 2
+	}
+	return editDistance(a, b) <= allowed
+}
+
+// decodePunycode decodes one "xn--" label per RFC 3492; labels that don't
+// decode are returned unchanged
+func decodePunycode(label string) string {
+	encoded, ok := strings.CutPrefix(strings.ToLower(label), "xn--")
+	if !ok {
+		return label
+	}
+	const base, tmin, tmax, skew, damp = 36, 1, 26, 38, 700
+	var output []rune
+	if i := strings.LastIndexByte(encoded, '-'); i >= 0 {
+		output = []rune(encoded[:i])
+		encoded = encoded[i+1:]
+	}
+	n, bias, i := 128, 72, 0
+	for pos := 0; pos < len(encoded); {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if pos >= len(encoded) {
+				return label
+			}
+			c := encoded[pos]
+			pos++
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return label
+			}
+			i += digit * w
+			t := min(max(k-bias, tmin), tmax)
+			if digit < t {
+				break
+			}
+			w *= base - t
+		}
+		// Adapt the bias
+		delta := i - oldi
+		if oldi == 0 {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / (len(output) + 1)
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		bias = k + (base-tmin+1)*delta/(delta+skew)
+
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > utf8.MaxRune {
+			return label
+		}
+		output = append(output[:i], append([]rune{rune(n)}, output[i:]...)...)
+		i++
+	}
+	return string(output)
+}
+
+// officialLinks splits the chat's official_links into domains and @handles
+func officialLinks(value string) (domains, handles []string) {
+	for _, entry := range listSetting(value) {
+		if match := telegramLinkPattern.FindStringSubmatch(entry); match != nil {
+			handles = append(handles, match[1])
+			continue
+		}
+		if strings.Contains(entry, ".") {
+			if domain := normalizeDomain(entry); domain != "" {
+				domains = append(domains, domain)
+			}
+			continue
+		}
+		handles = append(handles, entry)
+	}
+	return domains, handles
+}
+
+// domainName splits a host into the label wallets and browsers show most
+// prominently and the rest: "claim.aptos.com" is ("aptos", "com")
+func domainName(host string) (name, tld string) {
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return host, ""
+	}
+	return labels[len(labels)-2], labels[len(labels)-1]
+}
+
+// lookalikeDomain returns the official domain host imitates, or "" if it is
+// official, a subdomain of one, or unlike all of them
+func lookalikeDomain(host string, officials []string) string {
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		labels[i] = decodePunycode(label)
+	}
+	host = strings.Join(labels, ".")
+	for _, official := range officials {
+		if host == official || strings.HasSuffix(host, "."+official) {
+			return ""
+		}
+	}
+	name, tld := domainName(host)
+	for _, official := range officials {
+		officialName, officialTLD := domainName(official)
+		// The official name on another TLD, or an imitation of it on any TLD
+		if (name == officialName && tld != officialTLD) || (name != officialName && resembles(name, officialName)) {
+			return official
+		}
+	}
+	return ""
+}
+
+// homographDomain returns the domain an internationalized host ("xn--ptos-8ve.com",
+// "аptos.com") disguises itself as, or "" for ASCII hosts and IDNs that
+// resemble none of domains. ASCII typosquats are lookalikeDomain's business.
+func homographDomain(host string, domains []string) string {
+	labels := strings.Split(strings.ToLower(host), ".")
+	idn := false
+	for i, label := range labels {
+		if decoded := decodePunycode(label); decoded != label {
+			labels[i], idn = decoded, true
+		}
+	}
+	host = strings.Join(labels, ".")
+	for _, r := range host {
+		idn = idn || r >= utf8.RuneSelf
+	}
+	if !idn {
+		return ""
+	}
+	name, _ := domainName(host)
+	for _, domain := range domains {
+		if knownName, _ := domainName(domain); resembles(name, knownName) {
+			return domain
+		}
+	}
+	return ""
+}
+
+// homographLink returns the first host in text that is a homograph of one of
+// knownGoodDomains or the chat's official_links domains, and the domain it imitates
+func (b *Bot) homographLink(ctx context.Context, chatID int64, threadID int, text string) (host, imitated string) {
+	domains, _ := officialLinks(b.app.settings.GetTopic(ctx, chatID, threadID, settingOfficialLinks))
+	domains = append(domains, knownGoodDomains...)
+	for _, host := range unicodeHostPattern.FindAllString(text, -1) {
+		if imitated := homographDomain(host, domains); imitated != "" {
+			return host, imitated
+		}
+	}
+	return "", ""
+}
+
+// applyHomographPolicy enforces homograph_policy on punycode and IDN links
+// disguised as a known-good domain, which plain link rules can't tell from the
+// real site. Returns true when the message was handled.
+func (b *Bot) applyHomographPolicy(ctx context.Context, message *Message, text string) bool {
+	if text == "" {
+		return false
+	}
+	host, imitated := b.homographLink(ctx, message.Chat.ID, message.ThreadID(), text)
+	if host == "" {
+		return false
+	}
+	metrics.Add("homograph_links", 1)
+	return b.enforceSeverePolicy(ctx, message, settingHomographPolicy, fmt.Sprintf("homograph of %s: %s", imitated, host))
+}
+
+// lookalikeHandle returns the official handle name imitates, or ""
+func lookalikeHandle(name string, officials []string) string {
+	name = strings.ToLower(name)
+	if containsString(officials, name) {
+		return ""
+	}
+	for _, official := range officials {
+		if resembles(name, official) {
+			return official
+		}
+	}
+	return ""
+}
+
+// applyOfficialLinks deletes links and @handles that imitate the chat's
+// official_links (typosquats, homoglyphs, punycode) and posts a warning naming
+// the real one. Returns true when the message was handled.
+func (b *Bot) applyOfficialLinks(ctx context.Context, message *Message, text string) bool {
+	domains, handles := officialLinks(b.setting(ctx, message, settingOfficialLinks))
+	if len(domains) == 0 && len(handles) == 0 {
+		return false
+	}
+
+	var fake, real string
+	if len(domains) > 0 {
+		for _, host := range unicodeHostPattern.FindAllString(text, -1) {
+			if official := lookalikeDomain(host, domains); official != "" {
+				fake, real = host, "https://"+official
+				break
+			}
+		}
+	}
+	if fake == "" && len(handles) > 0 {
+		names := messageMentions(message.Message)
+		for _, match := range telegramLinkPattern.FindAllStringSubmatch(text, -1) {
+			names = append(names, strings.ToLower(match[1]))
+		}
+		for _, name := range names {
+			if official := lookalikeHandle(name, handles); official != "" {
+				fake, real = "@"+name, "@"+official
+				break
+			}
+		}
+	}
+	if fake == "" {
+		return false
+	}
+
+	metrics.Add("lookalike_links", 1)
+	b.deleteMessage(ctx, message, fmt.Sprintf("%s imitates official %s", fake, real))
+	sent, err := b.send(ctx, htmlMessage(message.Chat.ID, b.trFor(ctx, message, "alert.lookalike",
+		mention(*message.From), html.EscapeString(fake), html.EscapeString(real))))
+	if err != nil {
+		return true
+	}
+	b.deleteNoticeLater(ctx, message.Chat.ID, sent.MessageID)
+	return true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// onchainPollInterval is how often chats with on-chain announcements are polled
+const onchainPollInterval = time.Minute
+
+// aptosFungibleAsset is the fungible asset address APT is tracked under after the FA migration
+const aptosFungibleAsset = "0xa"
+
+// Cursor sources in onchain_cursors
+const (
+	cursorTreasury = "treasury"
+	cursorEvents   = "events"
+)
+
+// OnchainCursor returns the ledger version a chat's announcements from source
+// are caught up to; ok is false if they never ran
+func (s *Store) OnchainCursor(ctx context.Context, chatID int64, source string) (version int64, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `SELECT version FROM onchain_cursors WHERE chat_id = ? AND source = ?`, chatID, source).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, err == nil, err
+}
+
+// SetOnchainCursor records that a chat's announcements from source are caught up to version
+func (s *Store) SetOnchainCursor(ctx context.Context, chatID int64, source string, version int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO onchain_cursors (chat_id, source, version) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id, source) DO UPDATE SET version = excluded.version
+	`, chatID, source, version)
+	return err
+}
+
+// eventSummary renders the top-level scalar fields of an event's data, e.g. "proposal_id=42, proposer=0x1"
+func eventSummary(data json.RawMessage) string {
+	var fields map[string]interface{}
+	if json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case string, float64, bool:
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// announceOnchain posts to the chat, or to its wall_channel with onchain_announce=wall
+func (b *Bot) announceOnchain(ctx context.Context, chatID int64, text string) {
+	if b.app.settings.Get(ctx, chatID, settingOnchainAnnounce) == "wall" {
+		if channel := b.app.settings.Get(ctx, chatID, settingWallChannel); channel != "" {
+			b.outbox.enqueue(wallChannelMessage(channel, text))
+			return
+		}
+	}
+	b.outbox.enqueue(tgbotapi.NewMessage(chatID, text))
+}
+
+// pollSource runs one announcement source for a chat from its cursor. The
+// first poll starts from the current ledger version so history isn't replayed.
+func (b *Bot) pollSource(ctx context.Context, chatID int64, source string, poll func(after int64) (int64, error)) {
+	after, ok, err := b.app.db.OnchainCursor(ctx, chatID, source)
+	if err != nil {
+		b.logf("Failed to read the %s cursor of chat %d: %v", source, chatID, err)
+		return
+	}
+	if !ok {
+		if after, err = b.app.aptos.LedgerVersion(ctx); err == nil {
+			err = b.app.db.SetOnchainCursor(ctx, chatID, source, after)
+		}
+		if err != nil {
+			b.logf("Failed to start %s announcements in chat %d: %v", source, chatID, err)
+		}
+		return
+	}
+	next, err := poll(after)
+	if err != nil {
+		b.logf("Failed to poll %s announcements for chat %d: %v", source, chatID, err)
+		b.app.reporter.Failure("aptos.onchainEvents", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID})
+	}
+	if next > after {
+		if err := b.app.db.SetOnchainCursor(ctx, chatID, source, next); err != nil {
+			b.logf("Failed to save the %s cursor of chat %d: %v", source, chatID, err)
+		}
+	}
+}
+
+// pollTreasury announces transfers to or from treasury_address of at least treasury_alert_apt
+func (b *Bot) pollTreasury(ctx context.Context, chatID int64) {
+	treasury := normalizeAddress(b.app.settings.Get(ctx, chatID, settingTreasuryAddress))
+	minAPT, _ := strconv.ParseUint(b.app.settings.Get(ctx, chatID, settingTreasuryAlertAPT), 10, 64)
+	b.pollSource(ctx, chatID, cursorTreasury, func(after int64) (int64, error) {
+		activities, err := b.app.aptos.AssetActivities(ctx, treasury, []string{aptosCoin, aptosFungibleAsset}, after)
+		for _, activity := range activities {
+			after = max(after, activity.Version)
+			if activity.Amount < minAPT*octasPerAPT {
+				continue
+			}
+			direction := "sent"
+			if activity.Deposit {
+				direction = "received"
+			}
+			metrics.Add("onchain_announcements", 1)
+			b.announceOnchain(ctx, chatID, b.tr(ctx, chatID, "onchain.treasury_"+direction,
+				formatAPT(activity.Amount), b.app.aptos.explorerLink(activity.Version)))
+		}
+		return after, err
+	})
+}
+
+// pollEvents announces events of the onchain_event_types, such as new governance proposals
+func (b *Bot) pollEvents(ctx context.Context, chatID int64, types []string) {
+	b.pollSource(ctx, chatID, cursorEvents, func(after int64) (int64, error) {
+		events, err := b.app.aptos.Events(ctx, types, after)
+		for _, event := range events {
+			after = max(after, event.Version)
+			text := "📜 " + event.Type
+			if summary := eventSummary(event.Data); summary != "" {
+				text += ": " + summary
+			}
+			metrics.Add("onchain_announcements", 1)
+			b.announceOnchain(ctx, chatID, text+"\n"+b.app.aptos.explorerLink(event.Version))
+		}
+		return after, err
+	})
+}
+
+// pollOnchainEvents announces new on-chain activity in every chat that configured it
+func (b *Bot) pollOnchainEvents(ctx context.Context) {
+	if b.app.aptos == nil {
+		return
+	}
+	chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list chats for on-chain announcements: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		if b.app.settings.Get(ctx, chat.ID, settingTreasuryAddress) != "" {
+			b.pollTreasury(ctx, chat.ID)
+		}
+		// Event types are case-sensitive Move identifiers, so listSetting's lower-casing won't do
+		var types []string
+		for _, t := range strings.Split(b.app.settings.Get(ctx, chat.ID, settingOnchainEventTypes), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+		if len(types) > 0 {
+			b.pollEvents(ctx, chat.ID, types)
+		}
+	}
+}
+
+// runOnchainEvents polls for on-chain announcements until ctx is cancelled
+func (b *Bot) runOnchainEvents(ctx context.Context) {
+	for sleepContext(ctx, onchainPollInterval) {
+		b.pollOnchainEvents(ctx)
+	}
+}
+
+// maybePollOnchainEvents polls at most once per onchainPollInterval, for
+// webhook mode where no background loop runs
+func (b *Bot) maybePollOnchainEvents(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastOnchain.Load()
+	if now-last < int64(onchainPollInterval/time.Second) || !b.lastOnchain.CompareAndSwap(last, now) {
+		return
+	}
+	b.pollOnchainEvents(ctx)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"spambot/telegram"
+)
+
+// Telegram's documented send limits: about 30 messages/s overall and 1 message/s per chat
+const (
+	globalSendInterval = time.Second / 30
+	chatSendInterval   = time.Second
+	// Per-chat queue workers exit after this long without messages
+	outboxIdleTimeout = time.Minute
+	outboxQueueSize   = 100
+)
+
+// rateLimiter hands out send slots honoring the global and per-chat limits
+type rateLimiter struct {
+	mu         sync.Mutex
+	nextGlobal time.Time
+	nextChat   map[int64]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{nextChat: make(map[int64]time.Time)}
+}
+
+// reserve books the next free slot and returns how long to wait for it.
+// chatID 0 only takes a global slot.
+func (l *rateLimiter) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	slot := now
+	if l.nextGlobal.After(slot) {
+		slot = l.nextGlobal
+	}
+	if chatID != 0 {
+		if next := l.nextChat[chatID]; next.After(slot) {
+			slot = next
+		}
+		l.nextChat[chatID] = slot.Add(chatSendInterval)
+	}
+	l.nextGlobal = slot.Add(globalSendInterval)
+
+	// Forget chats whose slots are in the past so the map doesn't grow forever
+	if len(l.nextChat) > 1000 {
+		for id, next := range l.nextChat {
+			if next.Before(now) {
+				delete(l.nextChat, id)
+			}
+		}
+	}
+	return slot.Sub(now)
+}
+
+// wait blocks until a send slot is available for chatID
+func (l *rateLimiter) wait(ctx context.Context, chatID int64) error {
+	if d := l.reserve(chatID); d > 0 && !sleepContext(ctx, d) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// outgoing is a queued send: a tgbotapi config, or a raw Bot API call for
+// parameters tgbotapi doesn't support (e.g. message_thread_id)
+type outgoing struct {
+	config tgbotapi.Chattable
+	method string
+	params tgbotapi.Params
+}
+
+func (o *outbox) deliver(ctx context.Context, chatID int64, m outgoing) error {
+	if m.config != nil {
+		_, err := o.bot.send(ctx, m.config)
+		return err
+	}
+	_, err := o.bot.callRaw(ctx, chatID, m.method, m.params)
+	return err
+}
+
+// outbox queues fire-and-forget messages per chat so bursts are paced instead of dropped
+type outbox struct {
+	bot    *Bot
+	mu     sync.Mutex
+	queues map[int64]chan outgoing
+	// direct sends synchronously instead of queueing (webhook/serverless mode)
+	direct bool
+}
+
+func newOutbox(bot *Bot) *outbox {
+	return &outbox{bot: bot, queues: make(map[int64]chan outgoing)}
+}
+
+// enqueue schedules c for delivery; returns false if the chat's queue is full
+func (o *outbox) enqueue(c tgbotapi.Chattable) bool {
+	return o.push(telegram.ChatIDOf(c), outgoing{config: c})
+}
+
+// enqueueRaw schedules a raw Bot API call to chatID
+func (o *outbox) enqueueRaw(chatID int64, method string, params tgbotapi.Params) bool {
+	return o.push(chatID, outgoing{method: method, params: params})
+}
+
+func (o *outbox) push(chatID int64, m outgoing) bool {
+	if o.direct {
+		ctx, cancel := context.WithTimeout(context.Background(), o.bot.app.Config().TelegramTimeout*3)
+		defer cancel()
+		if err := o.deliver(ctx, chatID, m); err != nil {
+			o.bot.logf("Failed to deliver message to chat %d: %v", chatID, err)
+		}
+		return true
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	queue, ok := o.queues[chatID]
+	if !ok {
+		queue = make(chan outgoing, outboxQueueSize)
+		o.queues[chatID] = queue
+		go o.work(chatID, queue)
+	}
+	select {
+	case queue <- m:
+		return true
+	default:
+		metrics.Add("outbox_dropped", 1)
+		return false
+	}
+}
+
+// work delivers one chat's queue in order and exits once it has been idle
+func (o *outbox) work(chatID int64, queue chan outgoing) {
+	idle := time.NewTimer(outboxIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case m := <-queue:
+			ctx, cancel := context.WithTimeout(context.Background(), o.bot.app.Config().TelegramTimeout*3)
+			if err := o.deliver(ctx, chatID, m); err != nil {
+				o.bot.logf("Failed to deliver queued message to chat %d: %v", chatID, err)
+			}
+			cancel()
+			idle.Reset(outboxIdleTimeout)
+		case <-idle.C:
+			o.mu.Lock()
+			if len(queue) > 0 {
+				o.mu.Unlock()
+				idle.Reset(outboxIdleTimeout)
+				continue
+			}
+			delete(o.queues, chatID)
+			o.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"spambot/detector"
+)
+
+// defaultPhishingFeeds is used when PHISHING_FEEDS is not configured
+var defaultPhishingFeeds = []string{
+	"https://raw.githubusercontent.com/scamsniffer/scam-database/main/blacklist/domains.json",
+	"https://raw.githubusercontent.com/Phishing-Database/Phishing.Database/master/phishing-domains-ACTIVE.txt",
+}
+
+// phishingLease makes sure only one instance downloads the feeds
+const phishingLease = "phishing-feeds"
+
+const (
+	// phishingFeedTimeout bounds one feed download; the lists run to megabytes
+	phishingFeedTimeout = 2 * time.Minute
+	// phishingFeedMaxSize caps a feed body so a broken mirror can't exhaust memory
+	phishingFeedMaxSize = 64 << 20
+	// phishingSyncBatch is how many domains are upserted per statement
+	phishingSyncBatch = 500
+)
+
+// normalizeDomain reduces a feed entry or URL to a lower-cased host name, or "" if it isn't one
+func normalizeDomain(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if i := strings.Index(entry, "://"); i >= 0 {
+		entry = entry[i+3:]
+	}
+	if i := strings.IndexAny(entry, "/?#:"); i >= 0 {
+		entry = entry[:i]
+	}
+	entry = strings.Trim(strings.TrimPrefix(entry, "*."), ".")
+	if !strings.Contains(entry, ".") || !detector.HostPattern.MatchString(entry) {
+		return ""
+	}
+	return entry
+}
+
+// parsePhishingFeed reads a feed that is either a JSON array of domains
+// (ScamSniffer) or one domain per line (phishing.database, hosts files)
+func parsePhishingFeed(body []byte) ([]string, error) {
+	body = bytes.TrimSpace(body)
+	var entries []string
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, fmt.Errorf("failed to decode feed: %v", err)
+		}
+	} else {
+		for _, line := range strings.Split(string(body), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+				continue
+			}
+			// Hosts file format: "0.0.0.0 example.com"
+			if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+				fields = fields[1:]
+			}
+			entries = append(entries, fields[0])
+		}
+	}
+	seen := make(map[string]bool, len(entries))
+	domains := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if domain := normalizeDomain(entry); domain != "" && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	return domains, nil
+}
+
+// PhishingFeed returns the ETag and time of a feed's last sync; syncedAt is
+// zero if it was never synced
+func (s *Store) PhishingFeed(ctx context.Context, feed string) (etag string, syncedAt time.Time, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var unix int64
+	err = s.QueryRowContext(ctx, `SELECT etag, synced_at FROM phishing_feeds WHERE feed = ?`, feed).Scan(&etag, &unix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return etag, time.Unix(unix, 0), nil
+}
+
+// TouchPhishingFeed records a sync that found the feed unchanged
+func (s *Store) TouchPhishingFeed(ctx context.Context, feed string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `UPDATE phishing_feeds SET synced_at = ? WHERE feed = ?`, time.Now().Unix(), feed)
+	return err
+}
+
+// SyncPhishingFeed replaces a feed's domains in the deny list. Domains are
+// upserted in batches, each within the storage timeout, and the ones the feed
+// dropped are removed once all of them are in.
+func (s *Store) SyncPhishingFeed(ctx context.Context, feed, etag string, domains []string) error {
+	start := time.Now().Unix()
+	for len(domains) > 0 {
+		batch := domains
+		if len(batch) > phishingSyncBatch {
+			batch = batch[:phishingSyncBatch]
+		}
+		domains = domains[len(batch):]
+		args := make([]interface{}, 0, 3*len(batch))
+		for _, domain := range batch {
+			args = append(args, domain, feed, start)
+		}
+		query := `INSERT INTO phishing_domains (domain, feed, synced_at) VALUES ` +
+			strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(batch)), ", ") +
+			` ON CONFLICT(domain) DO UPDATE SET feed = excluded.feed, synced_at = excluded.synced_at`
+		opCtx, cancel := s.OpContext(ctx)
+		_, err := s.ExecContext(opCtx, query, args...)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to store phishing domains: %v", err)
+		}
+	}
+
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `DELETE FROM phishing_domains WHERE feed = ? AND synced_at < ?`, feed, start)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale phishing domains: %v", err)
+	}
+	removed, _ := res.RowsAffected()
+	var count int64
+	if err := s.QueryRowContext(ctx, `SELECT COUNT(*) FROM phishing_domains WHERE feed = ?`, feed).Scan(&count); err != nil {
+		return err
+	}
+	_, err = s.ExecContext(ctx, `
+		INSERT INTO phishing_feeds (feed, etag, domains, synced_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(feed) DO UPDATE SET etag = excluded.etag, domains = excluded.domains, synced_at = excluded.synced_at
+	`, feed, etag, count, start)
+	if err == nil && removed > 0 {
+		log.Printf("Removed %d domains no longer listed by %s", removed, feed)
+	}
+	return err
+}
+
+// PhishingDomain returns the first of domains on the deny list and the feed
+// that listed it; domain is "" if none is listed
+func (s *Store) PhishingDomain(ctx context.Context, domains []string) (domain, feed string, err error) {
+	if len(domains) == 0 {
+		return "", "", nil
+	}
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	args := make([]interface{}, len(domains))
+	for i, d := range domains {
+		args[i] = d
+	}
+	err = s.QueryRowContext(ctx, `SELECT domain, feed FROM phishing_domains WHERE domain IN (`+
+		strings.TrimSuffix(strings.Repeat("?, ", len(domains)), ", ")+`) LIMIT 1`, args...).Scan(&domain, &feed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	return domain, feed, err
+}
+
+// phishingSync downloads PHISHING_FEEDS into the deny list on a schedule
+type phishingSync struct {
+	feeds    []string
+	interval time.Duration
+	client   *http.Client
+	now      func() time.Time
+	holder   string
+	// Unix seconds of the last sync attempt in webhook mode
+	last atomic.Int64
+}
+
+// newPhishingSync returns nil when PHISHING_FEEDS is "off"
+func newPhishingSync(cfg *Config) *phishingSync {
+	if len(cfg.PhishingFeeds) == 0 {
+		return nil
+	}
+	return &phishingSync{
+		feeds:    cfg.PhishingFeeds,
+		interval: cfg.PhishingFeedInterval,
+		client:   &http.Client{Timeout: phishingFeedTimeout},
+		now:      time.Now,
+		holder:   newInstanceID(),
+	}
+}
+
+// syncFeed downloads one feed, skipping the download when its ETag is unchanged
+func (a *App) syncFeed(ctx context.Context, feed string) error {
+	etag, syncedAt, err := a.db.PhishingFeed(ctx, feed)
+	if err != nil {
+		return err
+	}
+	// Already synced this round, e.g. just before a restart
+	if a.phishing.now().Sub(syncedAt) < a.phishing.interval/2 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed, nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := a.phishing.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download feed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return a.db.TouchPhishingFeed(ctx, feed)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download feed: unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, phishingFeedMaxSize))
+	if err != nil {
+		return fmt.Errorf("failed to download feed: %v", err)
+	}
+	domains, err := parsePhishingFeed(body)
+	if err != nil {
+		return err
+	}
+	// An empty answer is more likely a broken mirror than a cleaned-up list
+	if len(domains) == 0 {
+		return fmt.Errorf("feed lists no domains")
+	}
+	if err := a.db.SyncPhishingFeed(ctx, feed, resp.Header.Get("ETag"), domains); err != nil {
+		return err
+	}
+	log.Printf("Synced %d phishing domains from %s", len(domains), feed)
+	metrics.Add("phishing_domains_synced", int64(len(domains)))
+	return nil
+}
+
+// syncPhishingFeeds refreshes every feed; a failing feed keeps its cached domains
+func (a *App) syncPhishingFeeds(ctx context.Context) {
+	if ok, err := a.db.AcquireLease(ctx, phishingLease, a.phishing.holder, 2*a.phishing.interval); err != nil || !ok {
+		return
+	}
+	for _, feed := range a.phishing.feeds {
+		if err := a.syncFeed(ctx, feed); err != nil {
+			log.Printf("Failed to sync phishing feed %s: %v", feed, err)
+			a.reporter.Failure("phishing.sync", err, ErrorContext{})
+		}
+	}
+}
+
+// runPhishingFeeds syncs the feeds at startup and every PHISHING_FEED_INTERVAL until ctx is cancelled
+func (a *App) runPhishingFeeds(ctx context.Context) {
+	a.syncPhishingFeeds(ctx)
+	for sleepContext(ctx, a.phishing.interval) {
+		a.syncPhishingFeeds(ctx)
+	}
+}
+
+// maybeSyncPhishingFeeds syncs at most once per PHISHING_FEED_INTERVAL, for
+// webhook mode where no background loop runs
+func (a *App) maybeSyncPhishingFeeds(ctx context.Context) {
+	if a.phishing == nil {
+		return
+	}
+	now := a.phishing.now().Unix()
+	last := a.phishing.last.Load()
+	if now-last < int64(a.phishing.interval/time.Second) || !a.phishing.last.CompareAndSwap(last, now) {
+		return
+	}
+	a.syncPhishingFeeds(ctx)
+}
+
+// phishingDomain returns a domain in text that is on the deny list, or ""
+func (b *Bot) phishingDomain(ctx context.Context, text string) string {
+	domain, feed, err := b.app.db.PhishingDomain(ctx, detector.Domains(text))
+	if err != nil {
+		b.logf("Failed to look up phishing domains: %v", err)
+		return ""
+	}
+	if domain != "" {
+		b.logf("Phishing domain %s is listed by %s", domain, feed)
+	}
+	return domain
+}
+
+// applyPhishingPolicy enforces phishing_domain_policy on links to domains from
+// the phishing feeds. Returns true when the message was handled.
+func (b *Bot) applyPhishingPolicy(ctx context.Context, message *Message, text string) bool {
+	if b.app.phishing == nil || text == "" {
+		return false
+	}
+	domain := b.phishingDomain(ctx, text)
+	if domain == "" {
+		return false
+	}
+	metrics.Add("phishing_links", 1)
+	return b.enforceSeverePolicy(ctx, message, settingPhishingDomainPolicy, "phishing domain "+domain)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper answering from a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// feedResponse is one answer of the fake feed server
+type feedResponse struct {
+	status int
+	etag   string
+	body   string
+}
+
+func TestSyncFeed(t *testing.T) {
+	const feed = "https://feeds.example/phishing.json"
+	const interval = 12 * time.Hour
+	tests := []struct {
+		name string
+		// advance moves the clock forward before the sync
+		advance time.Duration
+		answer  *feedResponse
+		// ifNoneMatch is the ETag the request should carry; no request is
+		// expected when answer is nil
+		ifNoneMatch string
+		wantErr     bool
+		listed      string
+	}{
+		{
+			name:   "first sync downloads the feed",
+			answer: &feedResponse{status: http.StatusOK, etag: `"v1"`, body: `["aptos-claim.example", "*.drainer.example"]`},
+			listed: "aptos-claim.example",
+		},
+		{
+			name:    "synced this round, no download",
+			advance: interval / 4,
+			listed:  "drainer.example",
+		},
+		{
+			name:        "unchanged feed keeps its domains",
+			advance:     interval,
+			answer:      &feedResponse{status: http.StatusNotModified},
+			ifNoneMatch: `"v1"`,
+			listed:      "aptos-claim.example",
+		},
+		{
+			name:        "failing mirror keeps the cached domains",
+			advance:     interval,
+			answer:      &feedResponse{status: http.StatusBadGateway},
+			ifNoneMatch: `"v1"`,
+			wantErr:     true,
+			listed:      "aptos-claim.example",
+		},
+		{
+			name:        "empty feed is refused",
+			answer:      &feedResponse{status: http.StatusOK, etag: `"v2"`, body: `[]`},
+			ifNoneMatch: `"v1"`,
+			wantErr:     true,
+			listed:      "aptos-claim.example",
+		},
+		{
+			name:        "hosts file format",
+			answer:      &feedResponse{status: http.StatusOK, etag: `"v3"`, body: "0.0.0.0 wallet-sync.example\n"},
+			ifNoneMatch: `"v1"`,
+			listed:      "wallet-sync.example",
+		},
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	var answer *feedResponse
+	var requests []*http.Request
+	app := &App{db: testStore(t), phishing: &phishingSync{
+		feeds:    []string{feed},
+		interval: interval,
+		now:      func() time.Time { return now },
+		client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			if answer == nil {
+				t.Errorf("unexpected request for %s", req.URL)
+				answer = &feedResponse{status: http.StatusInternalServerError}
+			}
+			header := make(http.Header)
+			if answer.etag != "" {
+				header.Set("ETag", answer.etag)
+			}
+			return &http.Response{StatusCode: answer.status, Status: http.StatusText(answer.status), Header: header, Body: io.NopCloser(strings.NewReader(answer.body)), Request: req}, nil
+		})},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			answer, requests = tt.answer, nil
+			err := app.syncFeed(ctx, feed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("syncFeed: %v, want error %t", err, tt.wantErr)
+			}
+			switch {
+			case tt.answer == nil && len(requests) > 0:
+				t.Errorf("downloaded the feed %d times, want none", len(requests))
+			case tt.answer != nil && len(requests) != 1:
+				t.Errorf("downloaded the feed %d times, want once", len(requests))
+			case tt.answer != nil && requests[0].Header.Get("If-None-Match") != tt.ifNoneMatch:
+				t.Errorf("If-None-Match = %q, want %q", requests[0].Header.Get("If-None-Match"), tt.ifNoneMatch)
+			}
+			domain, source, err := app.db.PhishingDomain(ctx, []string{tt.listed})
+			if err != nil || domain != tt.listed || source != feed {
+				t.Errorf("PhishingDomain(%s) = %q, %q, %v; want it listed by %s", tt.listed, domain, source, err, feed)
+			}
+		})
+	}
+}
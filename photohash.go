@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math/bits"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// photoHashMaxDistance is how many of the 64 hash bits may differ for two
+// profile photos to count as the same picture, recompressed or slightly cropped
+const photoHashMaxDistance = 6
+
+// photoHashLimit caps how many recorded photos a new member is compared with
+const photoHashLimit = 20000
+
+// SaveScamPhoto records the profile photo hash of a user banned from chatID;
+// shared hashes are matched in every chat that opted into sharing
+func (s *Store) SaveScamPhoto(ctx context.Context, chatID, userID int64, hash uint64, shared bool) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO scam_photos (chat_id, user_id, hash, shared, added_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET hash = excluded.hash, shared = excluded.shared, added_at = excluded.added_at
+	`, chatID, userID, int64(hash), shared, time.Now().Unix())
+	return err
+}
+
+// scamPhoto is a recorded profile photo of a banned user
+type scamPhoto struct {
+	ChatID int64
+	UserID int64
+	Hash   uint64
+}
+
+// ScamPhotos lists the most recent photo hashes of users banned from chatID,
+// plus the shared ones of every chat when shared is set
+func (s *Store) ScamPhotos(ctx context.Context, chatID int64, shared bool) ([]scamPhoto, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	where := `chat_id = ?`
+	if shared {
+		where += ` OR shared`
+	}
+	rows, err := s.QueryContext(ctx, fmt.Sprintf(`
+		SELECT chat_id, user_id, hash FROM scam_photos WHERE %s ORDER BY added_at DESC LIMIT %d
+	`, where, photoHashLimit), chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var photos []scamPhoto
+	for rows.Next() {
+		var p scamPhoto
+		var hash int64
+		if err := rows.Scan(&p.ChatID, &p.UserID, &hash); err != nil {
+			return nil, err
+		}
+		p.Hash = uint64(hash)
+		photos = append(photos, p)
+	}
+	return photos, rows.Err()
+}
+
+// photoHash is a 64-bit difference hash: the image shrunk to 9x8 gray cells,
+// one bit per cell brighter than its right neighbour. It survives resizing
+// and recompression, which is all scammers reusing an avatar usually do.
+func photoHash(img image.Image) uint64 {
+	w, h, lum := luminance(img)
+	if w < 9 || h < 8 {
+		return 0
+	}
+	var cells [8][9]int
+	for cy := 0; cy < 8; cy++ {
+		for cx := 0; cx < 9; cx++ {
+			x0, x1 := cx*w/9, (cx+1)*w/9
+			y0, y1 := cy*h/8, (cy+1)*h/8
+			sum := 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += int(lum[y*w+x])
+				}
+			}
+			cells[cy][cx] = sum / ((x1 - x0) * (y1 - y0))
+		}
+	}
+	var hash uint64
+	for cy := 0; cy < 8; cy++ {
+		for cx := 0; cx < 8; cx++ {
+			hash <<= 1
+			if cells[cy][cx] > cells[cy][cx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// profilePhotoHash hashes userID's current profile photo; ok is false if
+// they have none, or it is hidden from the bot
+func (b *Bot) profilePhotoHash(ctx context.Context, userID int64) (uint64, bool) {
+	photos, err := lookup(ctx, b.lookups, fmt.Sprintf("getUserProfilePhotos:%d", userID), func() (tgbotapi.UserProfilePhotos, error) {
+		return b.api.GetUserProfilePhotos(tgbotapi.UserProfilePhotosConfig{UserID: userID, Limit: 1})
+	})
+	if err != nil {
+		b.logf("Failed to get profile photos of %d: %v", userID, err)
+		return 0, false
+	}
+	if len(photos.Photos) == 0 || len(photos.Photos[0]) == 0 {
+		return 0, false
+	}
+	// Sizes come smallest first; a thumbnail keeps all the hash needs
+	data, err := b.downloadFile(ctx, photos.Photos[0][0].FileID)
+	if err != nil {
+		b.logf("Failed to download profile photo of %d: %v", userID, err)
+		return 0, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, false
+	}
+	hash := photoHash(img)
+	// Blank and single-color avatars hash to all zeros and match each other
+	return hash, hash != 0
+}
+
+// recordScamPhoto remembers the profile photo of a user just banned from
+// chatID, so accounts reusing it are caught when they join
+func (b *Bot) recordScamPhoto(ctx context.Context, chatID, userID int64) {
+	mode := b.app.settings.Get(ctx, chatID, settingPhotoHashCheck)
+	if mode == "off" {
+		return
+	}
+	hash, ok := b.profilePhotoHash(ctx, userID)
+	if !ok {
+		return
+	}
+	if err := b.app.db.SaveScamPhoto(ctx, chatID, userID, hash, mode == "shared"); err != nil {
+		b.logf("Failed to record the profile photo of %d: %v", userID, err)
+		b.app.reporter.Failure("db.saveScamPhoto", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		return
+	}
+	metrics.Add("scam_photos_recorded", 1)
+}
+
+// scamPhotoMatch compares userID's profile photo with those of banned users
+// and describes the closest match; ok is false if there is none
+func (b *Bot) scamPhotoMatch(ctx context.Context, chatID, userID int64) (string, bool) {
+	mode := b.app.settings.Get(ctx, chatID, settingPhotoHashCheck)
+	if mode == "off" {
+		return "", false
+	}
+	hash, ok := b.profilePhotoHash(ctx, userID)
+	if !ok {
+		return "", false
+	}
+	photos, err := b.app.db.ScamPhotos(ctx, chatID, mode == "shared")
+	if err != nil {
+		b.logf("Failed to list scam profile photos: %v", err)
+		b.app.reporter.Failure("db.scamPhotos", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		return "", false
+	}
+	best, bestDistance := scamPhoto{}, photoHashMaxDistance+1
+	for _, photo := range photos {
+		if d := bits.OnesCount64(photo.Hash ^ hash); d < bestDistance && photo.UserID != userID {
+			best, bestDistance = photo, d
+		}
+	}
+	if bestDistance > photoHashMaxDistance {
+		return "", false
+	}
+	metrics.Add("scam_photo_matches", 1)
+	if best.ChatID == chatID {
+		return fmt.Sprintf("profile photo matches user %d banned here", best.UserID), true
+	}
+	return fmt.Sprintf("profile photo matches user %d banned from another chat", best.UserID), true
+}
+
+// luminance returns the image as 8-bit gray levels, row by row
+func luminance(img image.Image) (w, h int, lum []uint8) {
+	bounds := img.Bounds()
+	w, h = bounds.Dx(), bounds.Dy()
+	lum = make([]uint8, w*h)
+	switch src := img.(type) {
+	case *image.YCbCr:
+		for y := 0; y < h; y++ {
+			copy(lum[y*w:(y+1)*w], src.Y[src.YOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			copy(lum[y*w:(y+1)*w], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				// Colors are alpha-premultiplied; composite transparent PNG codes over white
+				lum[y*w+x] = uint8(((299*r+587*g+114*b)/1000 + 0xffff - a) >> 8)
+			}
+		}
+	}
+	return w, h, lum
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+
+	"spambot/detector"
+)
+
+// messageContext is a message on its way through a pipeline, with what the
+// earlier stages found out about it
+type messageContext struct {
+	message *Message
+	// Text, caption or poll contents; stages may add to it, e.g. a decoded QR code
+	text string
+	// Whether the sender administers the chat; false in private chats
+	isAdmin bool
+	// The spam detector's verdict, for the stages after "detector"
+	verdict detector.Verdict
+}
+
+// stage is one step of a pipeline. It calls next to hand the message on to the
+// later stages, or returns without calling it to stop the message there.
+type stage func(ctx context.Context, mc *messageContext, next func())
+
+type namedStage struct {
+	name string
+	run  stage
+}
+
+// pipeline runs messages through named stages in order. New behaviors are
+// layered in with use or insertBefore rather than by growing the stages.
+type pipeline struct {
+	stages []namedStage
+}
+
+// use appends a stage
+func (p *pipeline) use(name string, run stage) {
+	p.stages = append(p.stages, namedStage{name, run})
+}
+
+// insertBefore adds a stage ahead of the one named before, or last if there is none
+func (p *pipeline) insertBefore(before, name string, run stage) {
+	for i, s := range p.stages {
+		if s.name == before {
+			p.stages = append(p.stages[:i], append([]namedStage{{name, run}}, p.stages[i:]...)...)
+			return
+		}
+	}
+	p.use(name, run)
+}
+
+// run passes mc through the stages from the first
+func (p *pipeline) run(ctx context.Context, mc *messageContext) {
+	p.runFrom(ctx, mc, 0)
+}
+
+func (p *pipeline) runFrom(ctx context.Context, mc *messageContext, i int) {
+	if i < len(p.stages) {
+		p.stages[i].run(ctx, mc, func() { p.runFrom(ctx, mc, i+1) })
+	}
+}
+
+// policyStage adapts a policy that reports whether it handled the message
+func policyStage(apply func(ctx context.Context, message *Message, text string) bool) stage {
+	return func(ctx context.Context, mc *messageContext, next func()) {
+		if !apply(ctx, mc.message, mc.text) {
+			next()
+		}
+	}
+}
+
+// messagePipeline is how the bot handles a new message: service messages,
+// logging, the admin exemption, commands, then moderation
+func (b *Bot) messagePipeline() *pipeline {
+	p := &pipeline{}
+	p.use("albums", b.albumStage)
+	p.use("service", b.serviceStage)
+	p.use("logging", b.loggingStage)
+	p.use("admin_exempt", b.adminExemptStage)
+	p.use("commands", b.commandStage)
+	p.use("moderation", b.moderationStage)
+	return p
+}
+
+// moderationPipeline is how a group message from a non-admin is checked: the
+// content policies, each of which may act on the message and stop it, then
+// the spam detector and its actions
+func (b *Bot) moderationPipeline() *pipeline {
+	p := &pipeline{}
+	p.use("bot_policy", func(ctx context.Context, mc *messageContext, next func()) {
+		if !b.applyBotPolicy(ctx, mc.message) {
+			next()
+		}
+	})
+	p.use("content_policies", func(ctx context.Context, mc *messageContext, next func()) {
+		if !b.applyContentPolicies(ctx, mc.message) {
+			next()
+		}
+	})
+	p.use("mention_policy", func(ctx context.Context, mc *messageContext, next func()) {
+		if !b.applyMentionPolicy(ctx, mc.message) {
+			next()
+		}
+	})
+	p.use("qr_codes", func(ctx context.Context, mc *messageContext, next func()) {
+		mc.text = b.withQRCode(ctx, mc.message, mc.text)
+		next()
+	})
+	p.use("yaml_rules", b.yamlRulesStage)
+	p.use("lua_rules", b.luaRulesStage)
+	// Before the link gate, so a verified wallet doesn't shield a drainer link
+	// and an unverified scammer doesn't get away with a deletion
+	p.use("seed_phrase", policyStage(b.applySeedPhrasePolicy))
+	p.use("crypto_scam", policyStage(b.applyCryptoScamPolicy))
+	p.use("fake_support", policyStage(b.applyFakeSupportPolicy))
+	p.use("phishing", policyStage(b.applyPhishingPolicy))
+	p.use("homograph", policyStage(b.applyHomographPolicy))
+	p.use("official_links", policyStage(b.applyOfficialLinks))
+	p.use("event_mode", policyStage(b.applyEventMode))
+	p.use("link_gate", policyStage(b.applyLinkGate))
+	p.use("addresses", policyStage(b.applyAddressChecks))
+	p.use("tokens", policyStage(b.applyTokenChecks))
+	p.use("detector", b.detectorStage)
+	p.use("actions", b.actionStage)
+	return p
+}
+
+// albumStage follows the verdict of the album part that carried the
+// caption, since the other parts usually carry none
+func (b *Bot) albumStage(ctx context.Context, mc *messageContext, next func()) {
+	if !b.trackAlbumPart(ctx, mc.message) {
+		next()
+	}
+}
+
+// serviceStage handles chat migrations, pins, boosts and gifts, and member
+// joins and leaves, none of which are moderated
+func (b *Bot) serviceStage(ctx context.Context, mc *messageContext, next func()) {
+	message := mc.message
+	switch {
+	case message.MigrateToChatID != 0 || message.MigrateFromChatID != 0:
+		b.handleChatMigration(ctx, message)
+	case message.PinnedMessage != nil:
+		b.handlePinnedMessage(ctx, message)
+	case message.ext.isBoostOrGift():
+		// Boosts and gifts are goodwill, never spam; captions on them stay unscanned
+		b.thank(ctx, message)
+	case len(message.NewChatMembers) > 0:
+		b.handleNewMembers(ctx, message)
+	case message.LeftChatMember != nil:
+		b.handleLeftMember(ctx, message)
+	default:
+		next()
+	}
+}
+
+// loggingStage logs the messages there is something to check in, and drops
+// the rest along with linked channel posts and blocked private senders
+func (b *Bot) loggingStage(ctx context.Context, mc *messageContext, next func()) {
+	message := mc.message
+	// Check message text (including captions and poll contents)
+	mc.text = messageText(message)
+	if mc.text == "" && !hasPolicyContent(message) {
+		return
+	}
+	b.logf("Received message from %s (ID: %d) in %s (%s): %s",
+		message.From.UserName,
+		message.From.ID,
+		message.Chat.Title,
+		message.Chat.Type,
+		mc.text)
+
+	// Posts the linked channel forwards into its discussion group are official
+	// content; the comments under them are ordinary messages and still checked
+	if message.IsAutomaticForward {
+		b.logf("Skipping automatic forward from linked channel %s", message.Chat.Title)
+		return
+	}
+	if b.blockedInPrivate(ctx, message) {
+		return
+	}
+	next()
+}
+
+// adminExemptStage looks up whether the sender is an admin, and lets only
+// their commands through
+func (b *Bot) adminExemptStage(ctx context.Context, mc *messageContext, next func()) {
+	message := mc.message
+	mc.isAdmin = message.Chat.Type != "private" && b.isChatAdmin(ctx, message.Chat.ID, message.From.ID)
+	if mc.isAdmin && !message.IsCommand() {
+		// Addresses admins post are the ones poisoners imitate
+		b.recordAddresses(ctx, message, mc.text)
+		b.logf("Ignoring message from admin %s", message.From.UserName)
+		return
+	}
+	next()
+}
+
+func (b *Bot) commandStage(ctx context.Context, mc *messageContext, next func()) {
+	if !mc.message.IsCommand() {
+		next()
+		return
+	}
+	b.handleCommand(ctx, mc.message, mc.isAdmin)
+}
+
+// moderationStage checks group messages with the moderation pipeline, and
+// private ones as wall submissions or private spam
+func (b *Bot) moderationStage(ctx context.Context, mc *messageContext, next func()) {
+	message := mc.message
+	if message.Chat.Type == "group" || message.Chat.Type == "supergroup" {
+		defer b.countActivity(ctx, message)
+		b.moderation.run(ctx, mc)
+	} else if message.Chat.Type == "private" && !b.handleWallSubmission(ctx, message, mc.text) {
+		b.handlePrivateSpam(ctx, message, mc.text)
+	}
+	next()
+}
+
+// detectorStage runs the spam detector, passing on only spam
+func (b *Bot) detectorStage(ctx context.Context, mc *messageContext, next func()) {
+	if mc.text == "" {
+		return
+	}
+	mc.verdict = b.app.detector.IsSpam(ctx, mc.message.Chat.ID, mc.message.ThreadID(), mc.text)
+	if mc.verdict.Spam() {
+		next()
+	}
+}
+
+// actionStage acts on the detector's verdict: a ban for checks that warrant
+// one, a reaction for minor violations where the chat prefers it, otherwise
+// deletion and a strike
+func (b *Bot) actionStage(ctx context.Context, mc *messageContext, next func()) {
+	message, verdict := mc.message, mc.verdict
+	if verdict.Severity == detector.SeverityBan {
+		b.punishMessage(ctx, message, verdict.Reason, true)
+		next()
+		return
+	}
+	if containsString(minorViolations, verdict.Rule) && b.setting(ctx, message, settingMinorViolationAction) == "react" &&
+		b.reactToViolation(ctx, message, verdict.Reason, verdict.Label()) {
+		return
+	}
+	b.punish(ctx, message, verdict.Reason)
+	next()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"spambot/detector"
+)
+
+// hasPolicyContent reports whether a text-less message is still subject to a content policy
+func hasPolicyContent(message *Message) bool {
+	_, image := messageImage(message)
+	return message.Contact != nil || message.ViaBot != nil || message.Voice != nil || message.VideoNote != nil ||
+		message.ext.Giveaway != nil || message.ext.GiveawayWinners != nil || message.ext.PaidMedia != nil || image
+}
+
+// setting resolves a setting for the chat and forum topic the message was posted in
+func (b *Bot) setting(ctx context.Context, message *Message, key string) string {
+	return b.app.settings.GetTopic(ctx, message.Chat.ID, message.ThreadID(), key)
+}
+
+// applyBotPolicy decides what to do with a message posted by another bot account.
+// Returns true when the message needs no further checks.
+func (b *Bot) applyBotPolicy(ctx context.Context, message *Message) bool {
+	if !message.From.IsBot || message.From.ID == b.api.Self.ID {
+		return false
+	}
+	switch b.setting(ctx, message, settingBotPolicy) {
+	case "ignore":
+		b.logf("Ignoring message from bot %s", message.From.UserName)
+		return true
+	case "delete":
+		b.deleteMessage(ctx, message, "message from bot account")
+		return true
+	}
+	return false
+}
+
+// applyContentPolicies enforces per-chat rules for message types that carry no
+// scannable text. Returns true when the message was handled (and removed).
+func (b *Bot) applyContentPolicies(ctx context.Context, message *Message) bool {
+	if message.ViaBot != nil && !b.viaBotAllowed(ctx, message) {
+		return b.enforcePolicy(ctx, message, settingViaBotPolicy, "sent via inline bot @"+message.ViaBot.UserName)
+	}
+	if message.Contact != nil {
+		return b.enforcePolicy(ctx, message, settingContactPolicy, "contact card")
+	}
+	if message.ext.Giveaway != nil || message.ext.GiveawayWinners != nil {
+		return b.enforcePolicy(ctx, message, settingGiveawayPolicy, "giveaway")
+	}
+	if message.ext.PaidMedia != nil {
+		return b.enforcePolicy(ctx, message, settingPaidMediaPolicy, "paid media")
+	}
+	return b.applyVoicePolicy(ctx, message)
+}
+
+// applySeedPhrasePolicy enforces seed_phrase_policy on seed phrase and private
+// key requests and posted mnemonics, skipping the strike ladder, and alerts the
+// admins. Returns true when the message was handled.
+func (b *Bot) applySeedPhrasePolicy(ctx context.Context, message *Message, text string) bool {
+	verdict := b.app.detector.IsSeedPhrase(text)
+	if !verdict.Spam() || !b.enforceSeverePolicy(ctx, message, settingSeedPhrasePolicy, verdict.Reason) {
+		return false
+	}
+	metrics.Add("seed_phrase_scams", 1)
+	b.alertAdmins(ctx, message, b.trFor(ctx, message, "alert.seed_phrase",
+		message.From.FirstName, verdict.Reason))
+	return true
+}
+
+// applyFakeSupportPolicy enforces fake_support_policy on "contact support at
+// @xxx" and "open a ticket here" lures that point to someone other than the
+// chat's admins and official_links. Victims lose funds to these within
+// minutes, so admins are alerted as well. Returns true when the message was handled.
+func (b *Bot) applyFakeSupportPolicy(ctx context.Context, message *Message, text string) bool {
+	verdict := b.app.detector.IsFakeSupport(text)
+	if !verdict.Spam() {
+		return false
+	}
+	reason := verdict.Reason
+	contact := b.foreignContact(ctx, message, text)
+	if contact == "" || !b.enforceSeverePolicy(ctx, message, settingFakeSupportPolicy, reason+" ("+contact+")") {
+		return false
+	}
+	metrics.Add("fake_support_scams", 1)
+	b.alertAdmins(ctx, message, b.trFor(ctx, message, "alert.fake_support",
+		message.From.FirstName, contact))
+	return true
+}
+
+// foreignContact returns the first @mention, t.me link or other link in the
+// message that isn't one of the chat's admins or official_links, or ""
+func (b *Bot) foreignContact(ctx context.Context, message *Message, text string) string {
+	domains, handles := officialLinks(b.setting(ctx, message, settingOfficialLinks))
+	names := messageMentions(message.Message)
+	for _, match := range telegramLinkPattern.FindAllStringSubmatch(text, -1) {
+		names = append(names, strings.ToLower(match[1]))
+	}
+	if len(names) > 0 {
+		admins, err := b.chatAdminUsernames(ctx, message.Chat.ID)
+		if err != nil {
+			b.logf("Failed to list admins of chat %d: %v", message.Chat.ID, err)
+			b.app.reporter.Failure("telegram.getChatAdministrators", err, b.errorContext(message.Message))
+		}
+		for _, name := range names {
+			if !containsString(admins, name) && !containsString(handles, name) && name != strings.ToLower(b.api.Self.UserName) {
+				return "@" + name
+			}
+		}
+	}
+	for _, host := range detector.HostPattern.FindAllString(text, -1) {
+		host = strings.ToLower(host)
+		if host == "t.me" || host == "telegram.me" {
+			continue
+		}
+		official := false
+		for _, domain := range domains {
+			official = official || host == domain || strings.HasSuffix(host, "."+domain)
+		}
+		if !official {
+			return host
+		}
+	}
+	return ""
+}
+
+// alertAdmins posts a warning to the chat's admin_log_chat, or to the chat
+// itself for notice_delete_after seconds when it has none
+func (b *Bot) alertAdmins(ctx context.Context, message *Message, text string) {
+	b.alertChatAdmins(ctx, message.Chat.ID, text)
+}
+
+// alertChatAdmins is alertAdmins for chatID, when there is no message at hand
+func (b *Bot) alertChatAdmins(ctx context.Context, chatID int64, text string) {
+	if b.app.settings.Get(ctx, chatID, settingAdminLogChat) != "" {
+		b.adminLog(ctx, chatID, text)
+		return
+	}
+	b.logf("Chat %d: %s", chatID, text)
+	sent, err := b.send(ctx, tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		return
+	}
+	b.deleteNoticeLater(ctx, chatID, sent.MessageID)
+}
+
+// applyCryptoScamPolicy enforces crypto_scam_policy on fake airdrop, wallet
+// connect and eligibility check links. Returns true when the message was handled.
+func (b *Bot) applyCryptoScamPolicy(ctx context.Context, message *Message, text string) bool {
+	verdict := b.app.detector.IsCryptoScam(text)
+	if !verdict.Spam() {
+		return false
+	}
+	metrics.Add("crypto_scams", 1)
+	return b.enforceSeverePolicy(ctx, message, settingCryptoScamPolicy, verdict.Reason)
+}
+
+// enforceSeverePolicy applies a ban/spam/delete/allow policy for content that
+// warrants banning on the first offence
+func (b *Bot) enforceSeverePolicy(ctx context.Context, message *Message, setting, reason string) bool {
+	switch b.setting(ctx, message, setting) {
+	case "ban":
+		b.punishMessage(ctx, message, reason, true)
+	case "spam":
+		b.punish(ctx, message, reason)
+	case "delete":
+		b.deleteMessage(ctx, message, reason)
+	default:
+		return false
+	}
+	return true
+}
+
+// viaBotAllowed reports whether the inline bot is on the chat's allowlist
+func (b *Bot) viaBotAllowed(ctx context.Context, message *Message) bool {
+	return containsString(listSetting(b.setting(ctx, message, settingViaBotAllowlist)), strings.ToLower(message.ViaBot.UserName))
+}
+
+// enforcePolicy applies the chat's allow/delete/spam policy for one content type
+func (b *Bot) enforcePolicy(ctx context.Context, message *Message, setting, reason string) bool {
+	switch b.setting(ctx, message, setting) {
+	case "delete":
+		b.deleteMessage(ctx, message, reason)
+		return true
+	case "spam":
+		b.punish(ctx, message, reason)
+		return true
+	}
+	return false
+}
+
+// deleteMessage removes a message without counting a spam strike
+func (b *Bot) deleteMessage(ctx context.Context, message *Message, reason string) {
+	if b.cannotModerate(message.Chat.ID, "permission.delete_messages") ||
+		!b.claimIntent(ctx, messageIntentTTL, "delete:%d:%d", message.Chat.ID, message.MessageID) {
+		return
+	}
+	_, err := b.request(ctx, tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID))
+	if err != nil {
+		b.logf("Failed to delete message ID %d from chat %d: %v",
+			message.MessageID, message.Chat.ID, err)
+		b.app.reporter.Failure("telegram.deleteMessage", err, b.errorContext(message.Message))
+		b.checkRightsAfter(ctx, message.Chat.ID, err)
+		b.retryDelete(ctx, message, reason, err)
+		return
+	}
+	b.logf("Deleted message from %s (reason: %s)", message.From.UserName, reason)
+	metrics.Add("messages_deleted", 1)
+	b.audit(ctx, "delete", message.Chat.ID, message.From.ID, message.MessageID, reason)
+}
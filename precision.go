@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// precisionTopN bounds the rules /precision lists
+const precisionTopN = 10
+
+// rulePrecision is how often a rule's removals turned out to be false
+// positives, over the chat's lifetime
+type rulePrecision struct {
+	Rule           string
+	Removed        int
+	FalsePositives int
+}
+
+// Precision is the share of removals that were spam, from 0 to 1
+func (p rulePrecision) Precision() float64 {
+	if p.Removed == 0 {
+		return 1
+	}
+	return 1 - float64(p.FalsePositives)/float64(p.Removed)
+}
+
+// RecordRuleOutcome counts a removal by rule in chatID, or with falsePositive
+// one that admins overturned
+func (s *Store) RecordRuleOutcome(ctx context.Context, chatID int64, rule string, falsePositive bool) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	removed, fp := 1, 0
+	if falsePositive {
+		removed, fp = 0, 1
+	}
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO rule_outcomes (chat_id, rule, removed, false_positives) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, rule) DO UPDATE SET removed = rule_outcomes.removed + excluded.removed,
+			false_positives = rule_outcomes.false_positives + excluded.false_positives
+	`, chatID, rule, removed, fp)
+	return err
+}
+
+// RulePrecision lists chatID's rules, or every chat's with chatID 0, least
+// precise first
+func (s *Store) RulePrecision(ctx context.Context, chatID int64) ([]rulePrecision, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	query, args := `SELECT rule, SUM(removed), SUM(false_positives) FROM rule_outcomes GROUP BY rule`, []interface{}(nil)
+	if chatID != 0 {
+		query, args = `SELECT rule, SUM(removed), SUM(false_positives) FROM rule_outcomes WHERE chat_id = ? GROUP BY rule`, []interface{}{chatID}
+	}
+	rows, err := s.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []rulePrecision
+	for rows.Next() {
+		var p rulePrecision
+		if err := rows.Scan(&p.Rule, &p.Removed, &p.FalsePositives); err != nil {
+			return nil, err
+		}
+		rules = append(rules, p)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Precision() != rules[j].Precision() {
+			return rules[i].Precision() < rules[j].Precision()
+		}
+		return rules[i].Removed > rules[j].Removed
+	})
+	return rules, rows.Err()
+}
+
+// OverturnDetection marks a detection a false positive on an admin's word,
+// whether or not its member disputed it; ok is false if it was already decided
+func (s *Store) OverturnDetection(ctx context.Context, chatID int64, messageID int) (d detection, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE disputes SET status = ? WHERE chat_id = ? AND message_id = ? AND status IN ('open', 'disputed')
+	`, disputeOverturned, chatID, messageID)
+	if err != nil {
+		return d, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return d, false, err
+	}
+	d, err = s.detection(ctx, chatID, messageID)
+	return d, err == nil, err
+}
+
+// LatestDetection finds the message of userID's most recent undecided
+// detection in chatID
+func (s *Store) LatestDetection(ctx context.Context, chatID, userID int64) (messageID int, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `
+		SELECT message_id FROM disputes WHERE chat_id = ? AND user_id = ? AND status IN ('open', 'disputed')
+		ORDER BY created_at DESC, message_id DESC LIMIT 1
+	`, chatID, userID).Scan(&messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return messageID, err == nil, err
+}
+
+// overturned takes back the strike of a detection admins found to be a false
+// positive, and counts it against its rule
+func (b *Bot) overturned(ctx context.Context, chatID int64, messageID int, d detection) {
+	if err := b.app.detector.ForgiveSpam(ctx, chatID, d.UserID); err != nil {
+		b.logf("Failed to take back a spam strike of %d in chat %d: %v", d.UserID, chatID, err)
+	}
+	metrics.Add("detections_overturned", 1)
+	metrics.Add("false_positives."+moderationRule(d.Reason), 1)
+	b.audit(ctx, "overturn", chatID, d.UserID, messageID, d.Reason)
+}
+
+// cmdNotSpam handles "/notspam", in reply to the bot's warning about a removed
+// message, or "/notspam <user id>" for the member's latest warning: the
+// removal is overturned as a dispute would be, without waiting for one
+func (b *Bot) cmdNotSpam(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	messageID, found := warnedMessage(message.ReplyToMessage, chatID)
+	if !found {
+		userID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+		if err != nil {
+			b.reply(message, b.trFor(ctx, message, "notspam.usage"))
+			return
+		}
+		if messageID, found, err = b.app.db.LatestDetection(ctx, chatID, userID); err != nil {
+			b.reply(message, b.trFor(ctx, message, "callback.failed", err))
+			return
+		}
+	}
+	if !found {
+		b.reply(message, b.trFor(ctx, message, "notspam.not_found"))
+		return
+	}
+	d, ok, err := b.app.db.OverturnDetection(ctx, chatID, messageID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "callback.failed", err))
+		return
+	}
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "callback.already_decided"))
+		return
+	}
+	b.overturned(ctx, chatID, messageID, d)
+	b.logf("Detection of message %d in chat %d marked not spam by %s", messageID, chatID, message.From.UserName)
+	b.reply(message, b.trFor(ctx, message, "notspam.done", d.Reason))
+	b.outbox.enqueue(tgbotapi.NewMessage(d.UserID, b.tr(ctx, chatID, "dispute.member_overturned")))
+}
+
+// warnedMessage finds the removed message a warning is about from its dispute button
+func warnedMessage(warning *tgbotapi.Message, chatID int64) (int, bool) {
+	if warning == nil || warning.ReplyMarkup == nil {
+		return 0, false
+	}
+	prefix := fmt.Sprintf("dispute:file:%d:", chatID)
+	for _, row := range warning.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == nil || !strings.HasPrefix(*button.CallbackData, prefix) {
+				continue
+			}
+			if id, err := strconv.Atoi(strings.TrimPrefix(*button.CallbackData, prefix)); err == nil {
+				return id, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// cmdPrecision handles "/precision": how often each rule's removals in the
+// chat were overturned, least precise first; in private the owner gets every chat's
+func (b *Bot) cmdPrecision(ctx context.Context, message *Message) {
+	var chatID int64
+	if message.Chat.Type != "private" {
+		chatID = message.Chat.ID
+	}
+	rules, err := b.app.db.RulePrecision(ctx, chatID)
+	if err != nil {
+		b.logf("Failed to load rule precision for chat %d: %v", chatID, err)
+		b.app.reporter.Failure("db.rulePrecision", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "precision.failed"))
+		return
+	}
+	if len(rules) == 0 {
+		b.reply(message, b.trFor(ctx, message, "precision.none"))
+		return
+	}
+	if len(rules) > precisionTopN {
+		rules = rules[:precisionTopN]
+	}
+	var text strings.Builder
+	text.WriteString(b.trFor(ctx, message, "precision.title"))
+	for _, rule := range rules {
+		text.WriteString("\n" + b.trFor(ctx, message, "precision.row", rule.Rule, rule.Precision()*100, rule.FalsePositives, rule.Removed))
+	}
+	b.reply(message, text.String())
+}
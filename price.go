@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// priceCacheTTL is how long a quote is reused; CoinGecko's free tier updates about every minute
+	priceCacheTTL = time.Minute
+	// priceSymbolTTL is how long a symbol's resolved CoinGecko id is reused
+	priceSymbolTTL = 24 * time.Hour
+)
+
+// knownPriceIDs are CoinGecko ids for symbols whose search results are ambiguous
+var knownPriceIDs = map[string]cachedPriceID{
+	"apt":  {id: "aptos", name: "Aptos"},
+	"btc":  {id: "bitcoin", name: "Bitcoin"},
+	"eth":  {id: "ethereum", name: "Ethereum"},
+	"sol":  {id: "solana", name: "Solana"},
+	"usdc": {id: "usd-coin", name: "USDC"},
+	"usdt": {id: "tether", name: "Tether"},
+}
+
+// priceQuote is a token's USD price as reported by the price API
+type priceQuote struct {
+	Name      string
+	Symbol    string
+	USD       float64
+	Change24h float64
+	MarketCap float64
+	checked   time.Time
+}
+
+// PriceClient looks up token prices on CoinGecko. A nil client has no prices.
+type PriceClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+
+	mu     sync.Mutex
+	quotes map[string]priceQuote
+	ids    map[string]cachedPriceID
+	// Last /price answer per chat, for price_cooldown
+	asked map[int64]time.Time
+}
+
+type cachedPriceID struct {
+	checked  time.Time
+	id, name string
+}
+
+// NewPriceClient returns nil when PRICE_API_URL is "off"
+func NewPriceClient(cfg *Config) *PriceClient {
+	if cfg.PriceAPIURL == "off" {
+		return nil
+	}
+	return &PriceClient{
+		baseURL: cfg.PriceAPIURL,
+		apiKey:  cfg.PriceAPIKey,
+		client:  &http.Client{Timeout: cfg.TelegramTimeout},
+		quotes:  make(map[string]priceQuote),
+		ids:     make(map[string]cachedPriceID),
+		asked:   make(map[int64]time.Time),
+	}
+}
+
+// get fetches path from the price API into out
+func (c *PriceClient) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", c.apiKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query the price API: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to query the price API: unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode price API response: %v", err)
+	}
+	return nil
+}
+
+// resolve returns the CoinGecko id and name of symbol, preferring the coin
+// with the highest market cap; ok is false if no coin has that symbol
+func (c *PriceClient) resolve(ctx context.Context, symbol string) (id, name string, ok bool, err error) {
+	if known, ok := knownPriceIDs[symbol]; ok {
+		return known.id, known.name, true, nil
+	}
+	c.mu.Lock()
+	cached, found := c.ids[symbol]
+	c.mu.Unlock()
+	if found && time.Since(cached.checked) < priceSymbolTTL {
+		return cached.id, cached.name, cached.id != "", nil
+	}
+
+	// Results come ordered by market cap rank
+	var result struct {
+		Coins []struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		} `json:"coins"`
+	}
+	if err := c.get(ctx, "/search?query="+url.QueryEscape(symbol), &result); err != nil {
+		return "", "", false, err
+	}
+	entry := cachedPriceID{checked: time.Now()}
+	for _, coin := range result.Coins {
+		if strings.EqualFold(coin.Symbol, symbol) {
+			entry.id, entry.name = coin.ID, coin.Name
+			break
+		}
+	}
+	c.mu.Lock()
+	c.ids[symbol] = entry
+	c.mu.Unlock()
+	return entry.id, entry.name, entry.id != "", nil
+}
+
+// Quote returns the current USD price of the token with symbol; ok is false
+// if the symbol is unknown
+func (c *PriceClient) Quote(ctx context.Context, symbol string) (quote priceQuote, ok bool, err error) {
+	symbol = strings.ToLower(strings.TrimPrefix(symbol, "$"))
+	id, name, ok, err := c.resolve(ctx, symbol)
+	if err != nil || !ok {
+		return quote, false, err
+	}
+	c.mu.Lock()
+	cached, found := c.quotes[id]
+	c.mu.Unlock()
+	if found && time.Since(cached.checked) < priceCacheTTL {
+		return cached, true, nil
+	}
+
+	var result map[string]struct {
+		USD       float64 `json:"usd"`
+		Change24h float64 `json:"usd_24h_change"`
+		MarketCap float64 `json:"usd_market_cap"`
+	}
+	if err := c.get(ctx, "/simple/price?vs_currencies=usd&include_24hr_change=true&include_market_cap=true&ids="+url.QueryEscape(id), &result); err != nil {
+		return quote, false, err
+	}
+	price, found := result[id]
+	if !found {
+		return quote, false, nil
+	}
+	quote = priceQuote{Name: name, Symbol: strings.ToUpper(symbol), USD: price.USD, Change24h: price.Change24h, MarketCap: price.MarketCap, checked: time.Now()}
+	c.mu.Lock()
+	c.quotes[id] = quote
+	c.mu.Unlock()
+	return quote, true, nil
+}
+
+// allow reports whether chatID may get another /price answer, at most one per cooldown
+func (c *PriceClient) allow(chatID int64, cooldown time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.asked[chatID]; ok && time.Since(last) < cooldown {
+		return false
+	}
+	c.asked[chatID] = time.Now()
+	if len(c.asked) > 10000 {
+		for id, last := range c.asked {
+			if time.Since(last) >= cooldown {
+				delete(c.asked, id)
+			}
+		}
+	}
+	return true
+}
+
+// formatUSD renders a price with precision suited to its size
+func formatUSD(v float64) string {
+	switch {
+	case v >= 1e9:
+		return fmt.Sprintf("$%.2fB", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("$%.2fM", v/1e6)
+	case v >= 1:
+		return "$" + strconv.FormatFloat(v, 'f', 2, 64)
+	case v <= 0:
+		return "$0"
+	default:
+		// Four significant digits, without exponent notation for tiny meme coin prices
+		return "$" + strconv.FormatFloat(v, 'f', 3-int(math.Floor(math.Log10(v))), 64)
+	}
+}
+
+// cmdPrice answers "/price [symbol]" with the token's price, so members have
+// no reason to paste third-party price bot links; without a symbol it quotes
+// the chat's price_symbol
+func (b *Bot) cmdPrice(ctx context.Context, message *Message) {
+	if b.app.prices == nil {
+		b.reply(message, b.trFor(ctx, message, "price.disabled"))
+		return
+	}
+	symbol := strings.TrimSpace(message.CommandArguments())
+	if symbol == "" {
+		symbol = b.setting(ctx, message, settingPriceSymbol)
+	}
+	if fields := strings.Fields(symbol); len(fields) != 1 || len(symbol) > 20 {
+		b.reply(message, b.trFor(ctx, message, "price.usage"))
+		return
+	}
+	cooldown, _ := strconv.Atoi(b.setting(ctx, message, settingPriceCooldown))
+	if message.Chat.Type != "private" && !b.app.prices.allow(message.Chat.ID, time.Duration(cooldown)*time.Second) {
+		b.deleteMessage(ctx, message, "price command cooldown")
+		return
+	}
+
+	quote, ok, err := b.app.prices.Quote(ctx, symbol)
+	if err != nil {
+		b.logf("Failed to look up the price of %s: %v", symbol, err)
+		b.reply(message, b.trFor(ctx, message, "price.failed"))
+		return
+	}
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "price.unknown", strings.ToUpper(symbol)))
+		return
+	}
+	metrics.Add("price_lookups", 1)
+	text := fmt.Sprintf("%s (%s): %s (%+.2f%% 24h)", quote.Name, quote.Symbol, formatUSD(quote.USD), quote.Change24h)
+	if quote.MarketCap > 0 {
+		text += "\nMarket cap: " + formatUSD(quote.MarketCap)
+	}
+	b.reply(message, text+"\nSource: CoinGecko")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// privateSpamActions are the valid PRIVATE_SPAM_ACTIONS entries
+var privateSpamActions = []string{"report", "suspect", "block"}
+
+// AddSuspect puts userID on the global suspect list, shared by every bot and chat
+func (s *Store) AddSuspect(ctx context.Context, userID int64, reason string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO suspects (user_id, reason, added_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET reason = excluded.reason, added_at = excluded.added_at
+	`, userID, reason, time.Now().Unix())
+	return err
+}
+
+// RemoveSuspect clears userID from the suspect list
+func (s *Store) RemoveSuspect(ctx context.Context, userID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM suspects WHERE user_id = ?`, userID)
+	return err
+}
+
+// IsSuspect reports whether userID is on the suspect list
+func (s *Store) IsSuspect(ctx context.Context, userID int64) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var n int
+	err := s.QueryRowContext(ctx, `SELECT COUNT(*) FROM suspects WHERE user_id = ?`, userID).Scan(&n)
+	return n > 0, err
+}
+
+// privateAction reports whether PRIVATE_SPAM_ACTIONS includes action
+func (b *Bot) privateAction(action string) bool {
+	return containsString(b.app.Config().PrivateSpamActions, action)
+}
+
+// blockedInPrivate reports whether the sender is a suspect the bot no longer talks to
+func (b *Bot) blockedInPrivate(ctx context.Context, message *Message) bool {
+	if message.Chat.Type != "private" || !b.privateAction("block") || b.isOwner(message) {
+		return false
+	}
+	suspect, err := b.app.db.IsSuspect(ctx, message.From.ID)
+	return err == nil && suspect
+}
+
+// handlePrivateSpam checks a message sent to the bot in private and applies
+// PRIVATE_SPAM_ACTIONS: report the account to the owner, add it to the suspect
+// list (which join screening treats as suspicious), and with "block" ignore it from then on
+func (b *Bot) handlePrivateSpam(ctx context.Context, message *Message, text string) {
+	if text == "" || b.isOwner(message) {
+		return
+	}
+	verdict := b.app.detector.IsSpam(ctx, message.Chat.ID, 0, text)
+	if !verdict.Spam() {
+		return
+	}
+	reason := verdict.Reason
+	metrics.Add("private_spam", 1)
+	b.logf("Private spam from %s (ID: %d): %s", message.From.UserName, message.From.ID, reason)
+
+	if b.privateAction("suspect") || b.privateAction("block") {
+		if err := b.app.db.AddSuspect(ctx, message.From.ID, "private spam: "+reason); err != nil {
+			b.logf("Failed to add suspect %d: %v", message.From.ID, err)
+			b.app.reporter.Failure("db.addSuspect", err, b.errorContext(message.Message))
+		}
+	}
+	if ownerID := b.app.Config().OwnerID; ownerID != 0 && b.privateAction("report") {
+		name := message.From.FirstName
+		if message.From.UserName != "" {
+			name += " (@" + message.From.UserName + ")"
+		}
+		b.outbox.enqueue(tgbotapi.NewMessage(ownerID, b.tr(ctx, ownerID, "private.spam_report",
+			name, message.From.ID, reason, text, message.From.ID)))
+	}
+}
+
+// cmdUnsuspect handles "/unsuspect <user id>" from the owner
+func (b *Bot) cmdUnsuspect(ctx context.Context, message *Message) {
+	userID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "unsuspect.usage"))
+		return
+	}
+	if err := b.app.db.RemoveSuspect(ctx, userID); err != nil {
+		b.reply(message, b.trFor(ctx, message, "unsuspect.failed", err))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "unsuspect.done", userID))
+}
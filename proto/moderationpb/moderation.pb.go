@@ -0,0 +1,531 @@
+// Moderation exposes the bot's detection rules and moderation records to
+// other services. The bot serves it over gRPC on MODERATION_ADDR; webhook
+// mode, which has no gRPC listener, takes the request message in the proto3
+// JSON mapping POSTed to /moderation.v1.Moderation/<Method> instead. Either
+// way callers send "authorization: Bearer <MODERATION_TOKEN>".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: proto/moderation.proto
+
+package moderationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ClassifyTextRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassifyTextRequest) Reset() {
+	*x = ClassifyTextRequest{}
+	mi := &file_proto_moderation_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassifyTextRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassifyTextRequest) ProtoMessage() {}
+
+func (x *ClassifyTextRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassifyTextRequest.ProtoReflect.Descriptor instead.
+func (*ClassifyTextRequest) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ClassifyTextRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *ClassifyTextRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ClassifyTextResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Spam  bool                   `protobuf:"varint,1,opt,name=spam,proto3" json:"spam,omitempty"`
+	// Why, with the matched phrase, link or address
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Rule that matched, e.g. "reason.url"
+	Label string `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	// "strike" counts towards BAN_THRESHOLD, "ban" warrants banning on the first offence
+	Severity string `protobuf:"bytes,4,opt,name=severity,proto3" json:"severity,omitempty"`
+	// How rarely the rule matches legitimate messages, from 0 to 1
+	Score         float64 `protobuf:"fixed64,5,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassifyTextResponse) Reset() {
+	*x = ClassifyTextResponse{}
+	mi := &file_proto_moderation_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassifyTextResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassifyTextResponse) ProtoMessage() {}
+
+func (x *ClassifyTextResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassifyTextResponse.ProtoReflect.Descriptor instead.
+func (*ClassifyTextResponse) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClassifyTextResponse) GetSpam() bool {
+	if x != nil {
+		return x.Spam
+	}
+	return false
+}
+
+func (x *ClassifyTextResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ClassifyTextResponse) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *ClassifyTextResponse) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *ClassifyTextResponse) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type RecordActionRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	ChatId int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	UserId int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// strike, delete, kick, ban or unban
+	Action string `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Reason string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Service the action was taken by, e.g. "discord"
+	Source        string `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordActionRequest) Reset() {
+	*x = RecordActionRequest{}
+	mi := &file_proto_moderation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordActionRequest) ProtoMessage() {}
+
+func (x *RecordActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordActionRequest.ProtoReflect.Descriptor instead.
+func (*RecordActionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RecordActionRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *RecordActionRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *RecordActionRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *RecordActionRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *RecordActionRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type RecordActionResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The user's strikes in the chat after the action
+	Strikes int32 `protobuf:"varint,1,opt,name=strikes,proto3" json:"strikes,omitempty"`
+	// Whether strikes reached BAN_THRESHOLD
+	ShouldBan     bool `protobuf:"varint,2,opt,name=should_ban,json=shouldBan,proto3" json:"should_ban,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordActionResponse) Reset() {
+	*x = RecordActionResponse{}
+	mi := &file_proto_moderation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordActionResponse) ProtoMessage() {}
+
+func (x *RecordActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordActionResponse.ProtoReflect.Descriptor instead.
+func (*RecordActionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RecordActionResponse) GetStrikes() int32 {
+	if x != nil {
+		return x.Strikes
+	}
+	return 0
+}
+
+func (x *RecordActionResponse) GetShouldBan() bool {
+	if x != nil {
+		return x.ShouldBan
+	}
+	return false
+}
+
+type GetUserRiskRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	UserId        int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserRiskRequest) Reset() {
+	*x = GetUserRiskRequest{}
+	mi := &file_proto_moderation_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserRiskRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserRiskRequest) ProtoMessage() {}
+
+func (x *GetUserRiskRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserRiskRequest.ProtoReflect.Descriptor instead.
+func (*GetUserRiskRequest) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetUserRiskRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *GetUserRiskRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+type GetUserRiskResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Strikes      int32                  `protobuf:"varint,1,opt,name=strikes,proto3" json:"strikes,omitempty"`
+	BanThreshold int32                  `protobuf:"varint,2,opt,name=ban_threshold,json=banThreshold,proto3" json:"ban_threshold,omitempty"`
+	// Removals and bans of the user in the chat over the last 8 days
+	RecentRemovals int32 `protobuf:"varint,3,opt,name=recent_removals,json=recentRemovals,proto3" json:"recent_removals,omitempty"`
+	RecentBans     int32 `protobuf:"varint,4,opt,name=recent_bans,json=recentBans,proto3" json:"recent_bans,omitempty"`
+	CasBanned      bool  `protobuf:"varint,5,opt,name=cas_banned,json=casBanned,proto3" json:"cas_banned,omitempty"`
+	// low, medium or high
+	Risk          string `protobuf:"bytes,6,opt,name=risk,proto3" json:"risk,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserRiskResponse) Reset() {
+	*x = GetUserRiskResponse{}
+	mi := &file_proto_moderation_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserRiskResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserRiskResponse) ProtoMessage() {}
+
+func (x *GetUserRiskResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_moderation_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserRiskResponse.ProtoReflect.Descriptor instead.
+func (*GetUserRiskResponse) Descriptor() ([]byte, []int) {
+	return file_proto_moderation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetUserRiskResponse) GetStrikes() int32 {
+	if x != nil {
+		return x.Strikes
+	}
+	return 0
+}
+
+func (x *GetUserRiskResponse) GetBanThreshold() int32 {
+	if x != nil {
+		return x.BanThreshold
+	}
+	return 0
+}
+
+func (x *GetUserRiskResponse) GetRecentRemovals() int32 {
+	if x != nil {
+		return x.RecentRemovals
+	}
+	return 0
+}
+
+func (x *GetUserRiskResponse) GetRecentBans() int32 {
+	if x != nil {
+		return x.RecentBans
+	}
+	return 0
+}
+
+func (x *GetUserRiskResponse) GetCasBanned() bool {
+	if x != nil {
+		return x.CasBanned
+	}
+	return false
+}
+
+func (x *GetUserRiskResponse) GetRisk() string {
+	if x != nil {
+		return x.Risk
+	}
+	return ""
+}
+
+var File_proto_moderation_proto protoreflect.FileDescriptor
+
+const file_proto_moderation_proto_rawDesc = "" +
+	"\n" +
+	"\x16proto/moderation.proto\x12\rmoderation.v1\"B\n" +
+	"\x13ClassifyTextRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"\x8a\x01\n" +
+	"\x14ClassifyTextResponse\x12\x12\n" +
+	"\x04spam\x18\x01 \x01(\bR\x04spam\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x1a\n" +
+	"\bseverity\x18\x04 \x01(\tR\bseverity\x12\x14\n" +
+	"\x05score\x18\x05 \x01(\x01R\x05score\"\x8f\x01\n" +
+	"\x13RecordActionRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\"O\n" +
+	"\x14RecordActionResponse\x12\x18\n" +
+	"\astrikes\x18\x01 \x01(\x05R\astrikes\x12\x1d\n" +
+	"\n" +
+	"should_ban\x18\x02 \x01(\bR\tshouldBan\"F\n" +
+	"\x12GetUserRiskRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\"\xd1\x01\n" +
+	"\x13GetUserRiskResponse\x12\x18\n" +
+	"\astrikes\x18\x01 \x01(\x05R\astrikes\x12#\n" +
+	"\rban_threshold\x18\x02 \x01(\x05R\fbanThreshold\x12'\n" +
+	"\x0frecent_removals\x18\x03 \x01(\x05R\x0erecentRemovals\x12\x1f\n" +
+	"\vrecent_bans\x18\x04 \x01(\x05R\n" +
+	"recentBans\x12\x1d\n" +
+	"\n" +
+	"cas_banned\x18\x05 \x01(\bR\tcasBanned\x12\x12\n" +
+	"\x04risk\x18\x06 \x01(\tR\x04risk2\x94\x02\n" +
+	"\n" +
+	"Moderation\x12W\n" +
+	"\fClassifyText\x12\".moderation.v1.ClassifyTextRequest\x1a#.moderation.v1.ClassifyTextResponse\x12W\n" +
+	"\fRecordAction\x12\".moderation.v1.RecordActionRequest\x1a#.moderation.v1.RecordActionResponse\x12T\n" +
+	"\vGetUserRisk\x12!.moderation.v1.GetUserRiskRequest\x1a\".moderation.v1.GetUserRiskResponseB\x1cZ\x1aspambot/proto/moderationpbb\x06proto3"
+
+var (
+	file_proto_moderation_proto_rawDescOnce sync.Once
+	file_proto_moderation_proto_rawDescData []byte
+)
+
+func file_proto_moderation_proto_rawDescGZIP() []byte {
+	file_proto_moderation_proto_rawDescOnce.Do(func() {
+		file_proto_moderation_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_moderation_proto_rawDesc), len(file_proto_moderation_proto_rawDesc)))
+	})
+	return file_proto_moderation_proto_rawDescData
+}
+
+var file_proto_moderation_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_moderation_proto_goTypes = []any{
+	(*ClassifyTextRequest)(nil),  // 0: moderation.v1.ClassifyTextRequest
+	(*ClassifyTextResponse)(nil), // 1: moderation.v1.ClassifyTextResponse
+	(*RecordActionRequest)(nil),  // 2: moderation.v1.RecordActionRequest
+	(*RecordActionResponse)(nil), // 3: moderation.v1.RecordActionResponse
+	(*GetUserRiskRequest)(nil),   // 4: moderation.v1.GetUserRiskRequest
+	(*GetUserRiskResponse)(nil),  // 5: moderation.v1.GetUserRiskResponse
+}
+var file_proto_moderation_proto_depIdxs = []int32{
+	0, // 0: moderation.v1.Moderation.ClassifyText:input_type -> moderation.v1.ClassifyTextRequest
+	2, // 1: moderation.v1.Moderation.RecordAction:input_type -> moderation.v1.RecordActionRequest
+	4, // 2: moderation.v1.Moderation.GetUserRisk:input_type -> moderation.v1.GetUserRiskRequest
+	1, // 3: moderation.v1.Moderation.ClassifyText:output_type -> moderation.v1.ClassifyTextResponse
+	3, // 4: moderation.v1.Moderation.RecordAction:output_type -> moderation.v1.RecordActionResponse
+	5, // 5: moderation.v1.Moderation.GetUserRisk:output_type -> moderation.v1.GetUserRiskResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_proto_moderation_proto_init() }
+func file_proto_moderation_proto_init() {
+	if File_proto_moderation_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_moderation_proto_rawDesc), len(file_proto_moderation_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_moderation_proto_goTypes,
+		DependencyIndexes: file_proto_moderation_proto_depIdxs,
+		MessageInfos:      file_proto_moderation_proto_msgTypes,
+	}.Build()
+	File_proto_moderation_proto = out.File
+	file_proto_moderation_proto_goTypes = nil
+	file_proto_moderation_proto_depIdxs = nil
+}
@@ -0,0 +1,209 @@
+// Moderation exposes the bot's detection rules and moderation records to
+// other services. The bot serves it over gRPC on MODERATION_ADDR; webhook
+// mode, which has no gRPC listener, takes the request message in the proto3
+// JSON mapping POSTed to /moderation.v1.Moderation/<Method> instead. Either
+// way callers send "authorization: Bearer <MODERATION_TOKEN>".
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: proto/moderation.proto
+
+package moderationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Moderation_ClassifyText_FullMethodName = "/moderation.v1.Moderation/ClassifyText"
+	Moderation_RecordAction_FullMethodName = "/moderation.v1.Moderation/RecordAction"
+	Moderation_GetUserRisk_FullMethodName  = "/moderation.v1.Moderation/GetUserRisk"
+)
+
+// ModerationClient is the client API for Moderation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ModerationClient interface {
+	// ClassifyText runs text through the rules of a chat, or the defaults for chat_id 0
+	ClassifyText(ctx context.Context, in *ClassifyTextRequest, opts ...grpc.CallOption) (*ClassifyTextResponse, error)
+	// RecordAction records a moderation decision taken elsewhere
+	RecordAction(ctx context.Context, in *RecordActionRequest, opts ...grpc.CallOption) (*RecordActionResponse, error)
+	// GetUserRisk summarizes what is known about a user
+	GetUserRisk(ctx context.Context, in *GetUserRiskRequest, opts ...grpc.CallOption) (*GetUserRiskResponse, error)
+}
+
+type moderationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewModerationClient(cc grpc.ClientConnInterface) ModerationClient {
+	return &moderationClient{cc}
+}
+
+func (c *moderationClient) ClassifyText(ctx context.Context, in *ClassifyTextRequest, opts ...grpc.CallOption) (*ClassifyTextResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClassifyTextResponse)
+	err := c.cc.Invoke(ctx, Moderation_ClassifyText_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *moderationClient) RecordAction(ctx context.Context, in *RecordActionRequest, opts ...grpc.CallOption) (*RecordActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordActionResponse)
+	err := c.cc.Invoke(ctx, Moderation_RecordAction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *moderationClient) GetUserRisk(ctx context.Context, in *GetUserRiskRequest, opts ...grpc.CallOption) (*GetUserRiskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserRiskResponse)
+	err := c.cc.Invoke(ctx, Moderation_GetUserRisk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ModerationServer is the server API for Moderation service.
+// All implementations must embed UnimplementedModerationServer
+// for forward compatibility.
+type ModerationServer interface {
+	// ClassifyText runs text through the rules of a chat, or the defaults for chat_id 0
+	ClassifyText(context.Context, *ClassifyTextRequest) (*ClassifyTextResponse, error)
+	// RecordAction records a moderation decision taken elsewhere
+	RecordAction(context.Context, *RecordActionRequest) (*RecordActionResponse, error)
+	// GetUserRisk summarizes what is known about a user
+	GetUserRisk(context.Context, *GetUserRiskRequest) (*GetUserRiskResponse, error)
+	mustEmbedUnimplementedModerationServer()
+}
+
+// UnimplementedModerationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedModerationServer struct{}
+
+func (UnimplementedModerationServer) ClassifyText(context.Context, *ClassifyTextRequest) (*ClassifyTextResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ClassifyText not implemented")
+}
+func (UnimplementedModerationServer) RecordAction(context.Context, *RecordActionRequest) (*RecordActionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RecordAction not implemented")
+}
+func (UnimplementedModerationServer) GetUserRisk(context.Context, *GetUserRiskRequest) (*GetUserRiskResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserRisk not implemented")
+}
+func (UnimplementedModerationServer) mustEmbedUnimplementedModerationServer() {}
+func (UnimplementedModerationServer) testEmbeddedByValue()                    {}
+
+// UnsafeModerationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ModerationServer will
+// result in compilation errors.
+type UnsafeModerationServer interface {
+	mustEmbedUnimplementedModerationServer()
+}
+
+func RegisterModerationServer(s grpc.ServiceRegistrar, srv ModerationServer) {
+	// If the following call panics, it indicates UnimplementedModerationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Moderation_ServiceDesc, srv)
+}
+
+func _Moderation_ClassifyText_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClassifyTextRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModerationServer).ClassifyText(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Moderation_ClassifyText_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModerationServer).ClassifyText(ctx, req.(*ClassifyTextRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Moderation_RecordAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModerationServer).RecordAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Moderation_RecordAction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModerationServer).RecordAction(ctx, req.(*RecordActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Moderation_GetUserRisk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRiskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModerationServer).GetUserRisk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Moderation_GetUserRisk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModerationServer).GetUserRisk(ctx, req.(*GetUserRiskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Moderation_ServiceDesc is the grpc.ServiceDesc for Moderation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Moderation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "moderation.v1.Moderation",
+	HandlerType: (*ModerationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ClassifyText",
+			Handler:    _Moderation_ClassifyText_Handler,
+		},
+		{
+			MethodName: "RecordAction",
+			Handler:    _Moderation_RecordAction_Handler,
+		},
+		{
+			MethodName: "GetUserRisk",
+			Handler:    _Moderation_GetUserRisk_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/moderation.proto",
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The images in testdata/qr were made with github.com/skip2/go-qrcode, an
+// encoder independent of the decoder, at forced versions and error
+// correction levels
+func TestDecodeQR(t *testing.T) {
+	v5 := "https://aptos-airdrop.example/claim?wallet=connect"
+	tests := []struct {
+		file string
+		want string
+		ok   bool
+	}{
+		{"v1_l.png", "https://t.me/x", true},
+		{"v2_m.png", "https://bit.ly/free-apt", true},
+		{"v5_q.png", v5, true},
+		{"v10_h.png", "https://aptos-airdrop.example/claim?wallet=connect&ref=1234567890", true},
+		{"v25_l.png", "https://example.com/" + strings.Repeat("abcdefghij", 60), true},
+		{"v40_m.png", "https://example.com/" + strings.Repeat("0123456789", 150), true},
+		{"v4_m_utf8.png", "에어드랍 받기 https://t.me/airdrop_kr", true},
+		{"v5_q_rotated.png", v5, true},
+		{"v5_q_small.jpg", v5, true},
+		{"v5_q_transparent.png", v5, true},
+		{"no_code.png", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", "qr", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			img, _, err := image.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := decodeQR(img)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("decodeQR = %q, %t; want %q, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
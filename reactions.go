@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// reactionRetention is how long per-user flag reactions are kept
+const reactionRetention = 7 * 24 * time.Hour
+
+// messageReactionUpdated is a member changing their reactions on a message
+type messageReactionUpdated struct {
+	Chat        tgbotapi.Chat  `json:"chat"`
+	MessageID   int            `json:"message_id"`
+	User        *tgbotapi.User `json:"user"`
+	NewReaction []reactionType `json:"new_reaction"`
+	OldReaction []reactionType `json:"old_reaction"`
+}
+
+// messageReactionCountUpdated carries the totals for messages with anonymous reactions
+type messageReactionCountUpdated struct {
+	Chat      tgbotapi.Chat   `json:"chat"`
+	MessageID int             `json:"message_id"`
+	Reactions []reactionCount `json:"reactions"`
+}
+
+type reactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+type reactionCount struct {
+	Type       reactionType `json:"type"`
+	TotalCount int          `json:"total_count"`
+}
+
+// SetFlagReaction records (flagged) or removes a member's flag reaction and
+// returns how many distinct members currently flag the message
+func (s *Store) SetFlagReaction(ctx context.Context, chatID int64, messageID int, userID int64, flagged bool) (int, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var err error
+	if flagged {
+		_, err = s.ExecContext(ctx, `
+			INSERT INTO message_reactions (chat_id, message_id, user_id, reacted_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(chat_id, message_id, user_id) DO NOTHING
+		`, chatID, messageID, userID, time.Now().Unix())
+	} else {
+		_, err = s.ExecContext(ctx, `DELETE FROM message_reactions WHERE chat_id = ? AND message_id = ? AND user_id = ?`,
+			chatID, messageID, userID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = s.QueryRowContext(ctx, `SELECT COUNT(*) FROM message_reactions WHERE chat_id = ? AND message_id = ? AND user_id <> ?`,
+		chatID, messageID, flaggedMarker).Scan(&count)
+	return count, err
+}
+
+// flaggedMarker is the user_id of the row recording that a message was acted on
+const flaggedMarker = 0
+
+// MarkFlagged records that a flagged message was acted on; false if it already was
+func (s *Store) MarkFlagged(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO message_reactions (chat_id, message_id, user_id, reacted_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, message_id, user_id) DO NOTHING
+	`, chatID, messageID, flaggedMarker, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// PruneReactions forgets flag reactions older than reactionRetention
+func (s *Store) PruneReactions(ctx context.Context) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM message_reactions WHERE reacted_at < ?`,
+		time.Now().Add(-reactionRetention).Unix())
+	return err
+}
+
+// hasFlagEmoji reports whether any reaction is one of the chat's flag emojis
+func hasFlagEmoji(reactions []reactionType, flags []string) bool {
+	for _, r := range reactions {
+		if r.Type == "emoji" && containsString(flags, r.Emoji) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleReaction counts members flagging a message with 👎/🤬-style reactions
+func (b *Bot) handleReaction(ctx context.Context, update *messageReactionUpdated) {
+	if update.User == nil || update.User.IsBot {
+		return // Anonymous admins and channels are counted via message_reaction_count
+	}
+	chatID := update.Chat.ID
+	threshold, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingReactionFlagThreshold))
+	if threshold == 0 {
+		return
+	}
+	flags := listSetting(b.app.settings.Get(ctx, chatID, settingReactionFlagEmojis))
+	flagged := hasFlagEmoji(update.NewReaction, flags)
+	if !flagged && !hasFlagEmoji(update.OldReaction, flags) {
+		return
+	}
+
+	count, err := b.app.db.SetFlagReaction(ctx, chatID, update.MessageID, update.User.ID, flagged)
+	if err != nil {
+		b.logf("Failed to record reaction on message %d in chat %d: %v", update.MessageID, chatID, err)
+		b.app.reporter.Failure("db.reaction", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: update.User.ID})
+		return
+	}
+	if flagged && count >= threshold {
+		b.actOnFlags(ctx, chatID, update.MessageID, count)
+	}
+}
+
+// handleReactionCount handles anonymous reaction totals; each member reacts
+// with an emoji at most once, so the largest flag total is a distinct-member count
+func (b *Bot) handleReactionCount(ctx context.Context, update *messageReactionCountUpdated) {
+	chatID := update.Chat.ID
+	threshold, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingReactionFlagThreshold))
+	if threshold == 0 {
+		return
+	}
+	flags := listSetting(b.app.settings.Get(ctx, chatID, settingReactionFlagEmojis))
+	count := 0
+	for _, r := range update.Reactions {
+		if r.Type.Type == "emoji" && containsString(flags, r.Type.Emoji) && r.TotalCount > count {
+			count = r.TotalCount
+		}
+	}
+	if count >= threshold {
+		b.actOnFlags(ctx, chatID, update.MessageID, count)
+	}
+}
+
+// actOnFlags applies reaction_flag_action to a message enough members flagged,
+// once per message
+func (b *Bot) actOnFlags(ctx context.Context, chatID int64, messageID, count int) {
+	if first, err := b.app.db.MarkFlagged(ctx, chatID, messageID); err != nil || !first {
+		return
+	}
+	metrics.Add("messages_crowd_flagged", 1)
+	if b.app.settings.Get(ctx, chatID, settingReactionFlagAction) == "delete" {
+		if _, err := b.request(ctx, tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+			b.logf("Failed to delete flagged message %d in chat %d: %v", messageID, chatID, err)
+			b.app.reporter.Failure("telegram.deleteMessage", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID})
+			return
+		}
+		b.logf("Deleted message %d in chat %d after %d flag reactions", messageID, chatID, count)
+		metrics.Add("messages_deleted", 1)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.tr(ctx, chatID, "flag.escalated", count))
+	msg.ReplyToMessageID = messageID
+	id := strconv.Itoa(messageID)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "flag.button_delete"), "flag:delete:"+id),
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "flag.button_keep"), "flag:keep:"+id),
+	))
+	b.outbox.enqueue(msg)
+	b.logf("Escalated message %d in chat %d after %d flag reactions", messageID, chatID, count)
+}
+
+// handleFlagCallback handles an admin's decision on a crowd-flagged message
+func (b *Bot) handleFlagCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	action, id, _ := strings.Cut(args, ":")
+	messageID, err := strconv.Atoi(id)
+	if err != nil || (action != "delete" && action != "keep") {
+		return
+	}
+	chatID := query.Message.Chat.ID
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "flag.admins_only")))
+		return
+	}
+
+	outcome := "kept"
+	if action == "delete" {
+		if _, err := b.request(ctx, tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+			b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+			return
+		}
+		metrics.Add("messages_deleted", 1)
+		outcome = "deleted"
+	}
+	b.logf("Flagged message %d in chat %d: %s by %s", messageID, chatID, outcome, query.From.UserName)
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "flag."+outcome)))
+	b.request(ctx, tgbotapi.NewEditMessageText(chatID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "flag."+outcome+"_by", query.From.FirstName)))
+}
+
+// minorViolations are IsSpam's rules that legitimate members trip too: a bare
+// link, or a spam keyword without a mention
+var minorViolations = []string{"url", "spam_keyword"}
+
+// reactToViolation marks a minor violation with minor_violation_emoji instead
+// of deleting it, and tells the member privately why. It reports false if
+// Telegram refused the reaction, leaving the caller to delete the message.
+func (b *Bot) reactToViolation(ctx context.Context, message *Message, reason, label string) bool {
+	emoji := b.setting(ctx, message, settingMinorViolationEmoji)
+	params := tgbotapi.Params{}
+	params.AddFirstValid("chat_id", message.Chat.ID)
+	params.AddNonZero("message_id", message.MessageID)
+	if err := params.AddInterface("reaction", []reactionType{{Type: "emoji", Emoji: emoji}}); err != nil {
+		return false
+	}
+	if _, err := b.callRaw(ctx, message.Chat.ID, "setMessageReaction", params); err != nil {
+		b.logf("Failed to react %s to message %d in chat %d, deleting it instead: %v", emoji, message.MessageID, message.Chat.ID, err)
+		b.app.reporter.Failure("telegram.setMessageReaction", err, b.errorContext(message.Message))
+		return false
+	}
+	b.logf("Marked a minor violation by %s with %s (reason: %s)", message.From.UserName, emoji, reason)
+	metrics.Add("minor_violations_marked", 1)
+	b.audit(ctx, "react", message.Chat.ID, message.From.ID, message.MessageID, reason)
+
+	// Telegram refuses with 403 until the member has started the bot; the
+	// reaction alone has to do then
+	notice := tgbotapi.NewMessage(message.From.ID, b.trFor(ctx, message, "minor.notice",
+		message.Chat.Title, b.trFor(ctx, message, label), emoji))
+	if _, err := b.send(ctx, notice); err != nil {
+		b.logf("Can't tell %s privately about their minor violation: %v", message.From.UserName, err)
+	}
+	return true
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failureWindow is how long repeated failures of one kind are counted before resetting
+const failureWindow = 5 * time.Minute
+
+// slackEvents are the kinds of event SLACK_EVENTS can select: bans by the bot,
+// event mode (lockdown) starting, a bot losing its admin rights, Telegram
+// failing to deliver webhooks, and repeated failures and panics
+var slackEvents = []string{"bans", "event_mode", "lost_admin", "webhook_down", "failures"}
+
+// ErrorContext identifies where a failure happened
+type ErrorContext struct {
+	Bot      string
+	ChatID   int64
+	UserID   int64
+	UpdateID int
+}
+
+// ErrorReporter forwards panics and repeated API/DB failures to Sentry, a
+// generic JSON webhook, Slack and/or email; the latter two also get
+// high-severity events. A nil reporter (nothing configured) is a no-op.
+type ErrorReporter struct {
+	sentryURL   string
+	sentryAuth  string
+	webhookURL  string
+	slackURL    string
+	slackEvents []string
+	email       *emailAlerter
+	threshold   int
+	client      *http.Client
+
+	mu       sync.Mutex
+	failures map[string]*failureCount
+}
+
+type failureCount struct {
+	since    time.Time
+	count    int
+	reported bool
+}
+
+// NewErrorReporter returns nil when none of SENTRY_DSN, ERROR_WEBHOOK_URL,
+// SLACK_WEBHOOK_URL and SMTP_ADDR is set
+func NewErrorReporter(cfg *Config) (*ErrorReporter, error) {
+	if cfg.SentryDSN == "" && cfg.ErrorWebhookURL == "" && cfg.SlackWebhookURL == "" && cfg.SMTPAddr == "" {
+		return nil, nil
+	}
+	r := &ErrorReporter{
+		webhookURL:  cfg.ErrorWebhookURL,
+		slackURL:    cfg.SlackWebhookURL,
+		slackEvents: cfg.SlackEvents,
+		email:       newEmailAlerter(cfg),
+		threshold:   cfg.ErrorReportThreshold,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		failures:    make(map[string]*failureCount),
+	}
+	if r.threshold < 1 {
+		r.threshold = 1
+	}
+	if cfg.SentryDSN != "" {
+		dsn, err := url.Parse(cfg.SentryDSN)
+		if err != nil || dsn.User == nil || dsn.Path == "" {
+			return nil, fmt.Errorf("invalid SENTRY_DSN")
+		}
+		project := strings.TrimPrefix(dsn.Path, "/")
+		r.sentryURL = fmt.Sprintf("%s://%s/api/%s/envelope/", dsn.Scheme, dsn.Host, project)
+		r.sentryAuth = fmt.Sprintf("Sentry sentry_version=7, sentry_client=spambot/1.0, sentry_key=%s", dsn.User.Username())
+	}
+	return r, nil
+}
+
+// Panic reports a recovered panic with its stack trace. It blocks until delivered,
+// since the caller may be about to crash.
+func (r *ErrorReporter) Panic(value interface{}, stack []byte, ctx ErrorContext) {
+	if r == nil {
+		return
+	}
+	r.deliver("fatal", fmt.Sprintf("panic: %v", value), ctx, map[string]interface{}{
+		"stack": string(stack),
+	})
+}
+
+// Failure counts a failure of the given kind (e.g. "telegram.deleteMessage", "db.recordSpam");
+// it is reported once the count reaches the threshold within the failure window
+func (r *ErrorReporter) Failure(kind string, err error, ctx ErrorContext) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	fc := r.failures[kind]
+	if fc == nil || now.Sub(fc.since) > failureWindow {
+		fc = &failureCount{since: now}
+		r.failures[kind] = fc
+	}
+	fc.count++
+	report := fc.count >= r.threshold && !fc.reported
+	if report {
+		fc.reported = true
+	}
+	count := fc.count
+	r.mu.Unlock()
+
+	if report {
+		go r.deliver("error", fmt.Sprintf("%s failed %d times in %s: %v", kind, count, failureWindow, err), ctx, map[string]interface{}{
+			"kind": kind,
+		})
+	}
+}
+
+// deliver sends the event to every configured destination
+func (r *ErrorReporter) deliver(level, message string, ctx ErrorContext, extra map[string]interface{}) {
+	tags := map[string]string{}
+	if ctx.Bot != "" {
+		tags["bot"] = ctx.Bot
+	}
+	if ctx.ChatID != 0 {
+		tags["chat_id"] = fmt.Sprint(ctx.ChatID)
+	}
+	if ctx.UserID != 0 {
+		tags["user_id"] = fmt.Sprint(ctx.UserID)
+	}
+	if ctx.UpdateID != 0 {
+		extra["update_id"] = ctx.UpdateID
+	}
+
+	if r.sentryURL != "" {
+		if err := r.sendSentry(level, message, tags, extra); err != nil {
+			log.Printf("Failed to report error to Sentry: %v", err)
+		}
+	}
+	if r.webhookURL != "" {
+		payload := map[string]interface{}{
+			"level":   level,
+			"message": message,
+			"tags":    tags,
+			"extra":   extra,
+			"time":    time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := r.post(r.webhookURL, "application/json", payload, nil); err != nil {
+			log.Printf("Failed to report error to webhook: %v", err)
+		}
+	}
+	if r.slackEnabled("failures") {
+		if err := r.sendSlack(fmt.Sprintf("*%s*: %s %s", level, message, slackTags(tags))); err != nil {
+			log.Printf("Failed to report error to Slack: %v", err)
+		}
+	}
+	if kind, _ := extra["kind"].(string); strings.HasPrefix(kind, "db.") {
+		r.email.send("db_failures", message, fmt.Sprintf("%s\n\n%s", message, slackTags(tags)))
+	}
+}
+
+// Event posts a high-severity event of kind to Slack if SLACK_EVENTS includes
+// it, and emails it if EMAIL_ALERTS does. It doesn't wait for delivery.
+func (r *ErrorReporter) Event(kind, message string, ctx ErrorContext) {
+	if r == nil || (!r.slackEnabled(kind) && !r.email.enabled(kind)) {
+		return
+	}
+	tags := map[string]string{"bot": ctx.Bot}
+	if ctx.ChatID != 0 {
+		tags["chat_id"] = fmt.Sprint(ctx.ChatID)
+	}
+	if ctx.UserID != 0 {
+		tags["user_id"] = fmt.Sprint(ctx.UserID)
+	}
+	r.email.send(kind, message, message+"\n\n"+slackTags(tags))
+	if !r.slackEnabled(kind) {
+		return
+	}
+	go func() {
+		if err := r.sendSlack(message + " " + slackTags(tags)); err != nil {
+			log.Printf("Failed to post %s event to Slack: %v", kind, err)
+		}
+	}()
+}
+
+func (r *ErrorReporter) slackEnabled(kind string) bool {
+	return r.slackURL != "" && containsString(r.slackEvents, kind)
+}
+
+// slackTags renders tags as a stable, compact suffix such as "(bot=x, chat_id=1)"
+func slackTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + tags[key]
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// sendSlack posts text to the Slack incoming webhook. Slack parses <...> and
+// & as markup, so they are escaped as its docs ask.
+func (r *ErrorReporter) sendSlack(text string) error {
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	return r.post(r.slackURL, "application/json", map[string]string{"text": text}, nil)
+}
+
+func (r *ErrorReporter) sendSentry(level, message string, tags map[string]string, extra map[string]interface{}) error {
+	id := make([]byte, 16)
+	rand.Read(id)
+	eventID := hex.EncodeToString(id)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	event, err := json.Marshal(map[string]interface{}{
+		"event_id":  eventID,
+		"timestamp": now,
+		"platform":  "go",
+		"level":     level,
+		"logger":    "spambot",
+		"message":   map[string]string{"formatted": message},
+		"tags":      tags,
+		"extra":     extra,
+	})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `{"event_id":%q,"sent_at":%q}`+"\n", eventID, now)
+	fmt.Fprintf(&body, `{"type":"event","length":%d}`+"\n", len(event))
+	body.Write(event)
+	body.WriteByte('\n')
+
+	return r.post(r.sentryURL, "application/x-sentry-envelope", body.Bytes(), map[string]string{
+		"X-Sentry-Auth": r.sentryAuth,
+	})
+}
+
+// post sends payload (raw bytes or a JSON-encodable value) and checks for a 2xx response
+func (r *ErrorReporter) post(target, contentType string, payload interface{}, headers map[string]string) error {
+	data, ok := payload.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
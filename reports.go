@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SaveReport records that reporterID reported userID's message; false means
+// they already reported it
+func (s *Store) SaveReport(ctx context.Context, chatID int64, messageID int, reporterID, userID int64) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO reports (chat_id, message_id, reporter_id, user_id, outcome, created_at) VALUES (?, ?, ?, ?, '', ?)
+		ON CONFLICT(chat_id, message_id, reporter_id) DO NOTHING
+	`, chatID, messageID, reporterID, userID, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ResolveReports sets the outcome ("banned" or "dismissed") of every open
+// report of a message and returns the reported user; ok is false if they were
+// already resolved
+func (s *Store) ResolveReports(ctx context.Context, chatID int64, messageID int, outcome string) (userID int64, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `SELECT user_id FROM reports WHERE chat_id = ? AND message_id = ? LIMIT 1`,
+		chatID, messageID).Scan(&userID)
+	if err != nil {
+		return 0, false, err
+	}
+	res, err := s.ExecContext(ctx, `UPDATE reports SET outcome = ? WHERE chat_id = ? AND message_id = ? AND outcome = ''`,
+		outcome, chatID, messageID)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := res.RowsAffected()
+	return userID, n > 0, err
+}
+
+// cmdReport escalates the replied-to message to the chat's admins
+func (b *Bot) cmdReport(ctx context.Context, message *Message) {
+	reported := message.ReplyToMessage
+	if reported == nil || reported.From == nil {
+		b.reply(message, b.trFor(ctx, message, "report.usage"))
+		return
+	}
+	if reported.From.ID == message.From.ID || reported.From.ID == b.api.Self.ID || b.isChatAdmin(ctx, message.Chat.ID, reported.From.ID) {
+		return
+	}
+	first, err := b.app.db.SaveReport(ctx, message.Chat.ID, reported.MessageID, message.From.ID, reported.From.ID)
+	if err != nil {
+		b.logf("Failed to save report in chat %d: %v", message.Chat.ID, err)
+		b.app.reporter.Failure("db.saveReport", err, b.errorContext(message.Message))
+		return
+	}
+	// The /report itself is noise once recorded
+	b.deleteMessage(ctx, message, "report command")
+	if !first {
+		return
+	}
+	metrics.Add("reports", 1)
+
+	msg := htmlMessage(message.Chat.ID, b.trFor(ctx, message, "report.escalated", mention(*message.From)))
+	msg.ReplyToMessageID = reported.MessageID
+	id := strconv.Itoa(reported.MessageID)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.trFor(ctx, message, "report.button_ban"), "report:ban:"+id),
+		tgbotapi.NewInlineKeyboardButtonData(b.trFor(ctx, message, "report.button_dismiss"), "report:dismiss:"+id),
+	))
+	b.outbox.enqueue(msg)
+}
+
+// handleReportCallback handles an admin's decision on a reported message;
+// reports that led to a ban make their reporters eligible for /tip
+func (b *Bot) handleReportCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	action, id, _ := strings.Cut(args, ":")
+	messageID, err := strconv.Atoi(id)
+	if err != nil || (action != "ban" && action != "dismiss") {
+		return
+	}
+	chatID := query.Message.Chat.ID
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "report.admins_only")))
+		return
+	}
+
+	outcome := map[string]string{"ban": "banned", "dismiss": "dismissed"}[action]
+	userID, ok, err := b.app.db.ResolveReports(ctx, chatID, messageID, outcome)
+	if err != nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	if !ok {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.already_decided")))
+		return
+	}
+	if action == "ban" {
+		b.request(ctx, tgbotapi.NewDeleteMessage(chatID, messageID))
+		ban := tgbotapi.BanChatMemberConfig{ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID}}
+		if _, err := b.request(ctx, ban); err != nil {
+			b.logf("Failed to ban reported user %d: %v", userID, err)
+			b.app.reporter.Failure("telegram.banChatMember", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+			b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+			return
+		}
+		metrics.Add("users_banned", 1)
+		b.bus.publish(ctx, UserBanned{ChatID: chatID, User: tgbotapi.User{ID: userID}, MessageID: messageID,
+			Reason: "reported, banned by admin " + query.From.UserName, ByAdmin: true})
+	}
+	b.logf("Report of message %d in chat %d: %s by %s", messageID, chatID, outcome, query.From.UserName)
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "report."+outcome)))
+	b.request(ctx, tgbotapi.NewEditMessageText(chatID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "report."+outcome+"_by", query.From.FirstName)))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Retries of deletions and bans that failed with a transient error: the first
+// waits retryBaseDelay, each next one twice as long, up to maxActionAttempts
+// attempts in all over about eight minutes
+const (
+	retryBaseDelay    = 15 * time.Second
+	maxActionAttempts = 6
+)
+
+// actionRetry is a deletion ("delete", Target a message id) or ban ("ban",
+// Target a user id) to try again
+type actionRetry struct {
+	ChatID   int64
+	Action   string
+	Target   int64
+	UserID   int64
+	Reason   string
+	Attempts int
+}
+
+// QueueRetry schedules botID's retry of an action for at
+func (s *Store) QueueRetry(ctx context.Context, botID int64, r actionRetry, at time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO action_retries (bot_id, chat_id, action, target, user_id, reason, attempts, next_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bot_id, chat_id, action, target) DO UPDATE SET attempts = excluded.attempts, next_at = excluded.next_at
+	`, botID, r.ChatID, r.Action, r.Target, r.UserID, r.Reason, r.Attempts, at.Unix())
+	return err
+}
+
+// DueRetries lists botID's retries whose time has come
+func (s *Store) DueRetries(ctx context.Context, botID int64) ([]actionRetry, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT chat_id, action, target, user_id, reason, attempts FROM action_retries WHERE bot_id = ? AND next_at <= ? LIMIT 100
+	`, botID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var due []actionRetry
+	for rows.Next() {
+		var r actionRetry
+		if err := rows.Scan(&r.ChatID, &r.Action, &r.Target, &r.UserID, &r.Reason, &r.Attempts); err != nil {
+			return nil, err
+		}
+		due = append(due, r)
+	}
+	return due, rows.Err()
+}
+
+// ClaimRetry removes a due retry; false means another instance took it
+func (s *Store) ClaimRetry(ctx context.Context, botID int64, r actionRetry) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		DELETE FROM action_retries WHERE bot_id = ? AND chat_id = ? AND action = ? AND target = ?
+	`, botID, r.ChatID, r.Action, r.Target)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// transientError reports whether a failed Bot API call may succeed later:
+// network errors, timeouts, flood waits and Telegram's own 5xx. Other API
+// errors, such as a message that is already gone, won't change on retry.
+func transientError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 429 || apiErr.Code >= 500
+	}
+	return err != nil
+}
+
+// retryLater queues another try of an action that failed with err, if err is
+// transient; it reports whether it did
+func (b *Bot) retryLater(ctx context.Context, r actionRetry, err error) bool {
+	if !transientError(err) || r.Attempts >= maxActionAttempts {
+		return false
+	}
+	delay := retryBaseDelay << (r.Attempts - 1)
+	if err := b.app.db.QueueRetry(ctx, b.api.Self.ID, r, time.Now().Add(delay)); err != nil {
+		b.logf("Failed to queue a retry of %s %d in chat %d: %v", r.Action, r.Target, r.ChatID, err)
+		b.app.reporter.Failure("db.queueRetry", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: r.ChatID, UserID: r.UserID})
+		return false
+	}
+	b.logf("Will retry %s %d in chat %d in %s", r.Action, r.Target, r.ChatID, delay)
+	metrics.Add("actions_retried", 1)
+	return true
+}
+
+// retryDelete queues the deletion of a spam message that failed with err for
+// another try; it reports whether it did
+func (b *Bot) retryDelete(ctx context.Context, message *Message, reason string, err error) bool {
+	return b.retryLater(ctx, actionRetry{
+		ChatID: message.Chat.ID, Action: "delete", Target: int64(message.MessageID),
+		UserID: message.From.ID, Reason: reason, Attempts: 1,
+	}, err)
+}
+
+// retryBan queues a ban of userID in chatID that failed with err for another
+// try; it reports whether it did
+func (b *Bot) retryBan(ctx context.Context, chatID, userID int64, reason string, err error) bool {
+	return b.retryLater(ctx, actionRetry{
+		ChatID: chatID, Action: "ban", Target: userID, UserID: userID, Reason: reason, Attempts: 1,
+	}, err)
+}
+
+// sweepRetries tries the due deletions and bans again, and tells the chat's
+// admins about those that still fail on the last attempt
+func (b *Bot) sweepRetries(ctx context.Context) {
+	due, err := b.app.db.DueRetries(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list action retries: %v", err)
+		return
+	}
+	for _, r := range due {
+		if claimed, err := b.app.db.ClaimRetry(ctx, b.api.Self.ID, r); err != nil || !claimed {
+			continue
+		}
+		var c tgbotapi.Chattable = tgbotapi.NewDeleteMessage(r.ChatID, int(r.Target))
+		if r.Action == "ban" {
+			c = tgbotapi.BanChatMemberConfig{ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: r.ChatID, UserID: r.Target}}
+		}
+		_, err := b.request(ctx, c)
+		if err == nil {
+			b.logf("Retried %s %d in chat %d: done on attempt %d", r.Action, r.Target, r.ChatID, r.Attempts+1)
+			metrics.Add("actions_retry_succeeded", 1)
+			if r.Action == "ban" {
+				b.bus.publish(ctx, UserBanned{ChatID: r.ChatID, User: tgbotapi.User{ID: r.UserID}, Reason: r.Reason})
+			} else {
+				b.audit(ctx, r.Action, r.ChatID, r.UserID, int(r.Target), r.Reason)
+			}
+			continue
+		}
+		r.Attempts++
+		if b.retryLater(ctx, r, err) {
+			continue
+		}
+		b.logf("Giving up on %s %d in chat %d after %d attempts: %v", r.Action, r.Target, r.ChatID, r.Attempts, err)
+		metrics.Add("actions_failed", 1)
+		b.app.reporter.Failure("telegram.retry."+r.Action, err, ErrorContext{Bot: b.api.Self.UserName, ChatID: r.ChatID, UserID: r.UserID})
+		b.alertChatAdmins(ctx, r.ChatID, b.tr(ctx, r.ChatID, "retry."+r.Action+"_failed", r.UserID, r.Reason, r.Attempts, err))
+	}
+}
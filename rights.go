@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// rightsRecheckInterval is how long the bot stops deleting or banning in a
+// chat where it lacks the right, unless a my_chat_member update says it was
+// promoted sooner
+const rightsRecheckInterval = time.Hour
+
+// missingRights remembers the chats where a deletion or ban failed for lack
+// of rights, so the bot stops trying (and logging) there and warns only once
+type missingRights struct {
+	mu      sync.Mutex
+	entries map[int64]lackingRights
+}
+
+type lackingRights struct {
+	// Catalog keys of the missing permissions, as missingPermissions returns them
+	missing []string
+	checked time.Time
+}
+
+func newMissingRights() *missingRights {
+	return &missingRights{entries: make(map[int64]lackingRights)}
+}
+
+// lacks reports whether the bot recently found it lacks permission in chatID
+func (r *missingRights) lacks(chatID int64, permission string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[chatID]
+	return ok && time.Since(entry.checked) < rightsRecheckInterval &&
+		(containsString(entry.missing, permission) || containsString(entry.missing, "permission.admin"))
+}
+
+// record stores what chatID lacks; it reports whether this is news, i.e. the
+// admins haven't been told yet
+func (r *missingRights) record(chatID int64, missing []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, known := r.entries[chatID]
+	r.entries[chatID] = lackingRights{missing: missing, checked: time.Now()}
+	return !known
+}
+
+// forget drops chatID after the bot's rights there changed
+func (r *missingRights) forget(chatID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, chatID)
+}
+
+// rightsError reports whether a failed deletion or ban may be down to the
+// bot's rights: Telegram says so outright for bans, but only "message can't
+// be deleted" for deletions
+func rightsError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || (apiErr.Code != 400 && apiErr.Code != 403) {
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	for _, hint := range []string{"not enough rights", "chat_admin_required", "have no rights", "need administrator rights", "message can't be deleted"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// cannotModerate reports whether the bot should skip an action needing
+// permission in chatID, having found it lacks the right
+func (b *Bot) cannotModerate(chatID int64, permission string) bool {
+	if !b.rights.lacks(chatID, permission) {
+		return false
+	}
+	metrics.Add("actions_skipped_no_rights", 1)
+	return true
+}
+
+// checkRightsAfter confirms whether a deletion or ban in chatID failed with
+// err for lack of rights, and the first time tells the chat's admins which
+// permission to grant
+func (b *Bot) checkRightsAfter(ctx context.Context, chatID int64, err error) {
+	if !rightsError(err) {
+		return
+	}
+	missing, err := b.missingPermissions(ctx, chatID)
+	if err != nil || len(missing) == 0 {
+		// A message that really can't be deleted, e.g. one already gone
+		return
+	}
+	if !b.rights.record(chatID, missing) {
+		return
+	}
+	b.logf("Missing permissions in chat %d: %s; not deleting or banning there until promoted", chatID, permissionNames("en", missing))
+	b.outbox.enqueue(tgbotapi.NewMessage(chatID, b.permissionWarning(ctx, chatID, missing)))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"spambot/detector"
+)
+
+// ruleSpec is a detector rule as RULES_FILE declares it, e.g.
+//
+//	[{"name": "pack.presale", "severity": "ban", "pattern": "(?i)presale is live", "score": 0.9},
+//	 {"name": "pack.drainers", "severity": "ban", "wasm": "/etc/spambot/drainers.wasm"}]
+//
+// Patterns are Go regular expressions; a rule with "wasm" instead calls the
+// module's classify export (see wasmCheck for the ABI). Rules are loaded with
+// the rest of the configuration, so editing the file takes effect on the next
+// .env reload.
+type ruleSpec struct {
+	Name     string  `json:"name"`
+	Severity string  `json:"severity"`
+	Pattern  string  `json:"pattern"`
+	Wasm     string  `json:"wasm"`
+	Score    float64 `json:"score"`
+}
+
+// loadRules reads the rules declared in path, compiling their WASM modules
+func loadRules(path string) ([]detector.Check, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []ruleSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, err
+	}
+	checks := make([]detector.Check, 0, len(specs))
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("rule %q declared twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		severity := detector.SeverityStrike
+		switch spec.Severity {
+		case "", "strike":
+		case "ban":
+			severity = detector.SeverityBan
+		default:
+			return nil, fmt.Errorf("rule %q: severity must be strike or ban, got %q", spec.Name, spec.Severity)
+		}
+		if spec.Score == 0 {
+			spec.Score = 0.5
+		}
+		var check detector.Check
+		switch {
+		case spec.Wasm != "" && spec.Pattern != "":
+			return nil, fmt.Errorf("rule %q: give either a pattern or a wasm module", spec.Name)
+		case spec.Wasm != "":
+			check, err = wasmCheck(spec.Name, severity, spec.Score, spec.Wasm)
+		default:
+			check, err = detector.PatternCheck(spec.Name, severity, spec.Score, spec.Pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
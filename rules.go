@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// reminderCheckInterval is how often chats are checked for a due rules reminder
+const reminderCheckInterval = time.Minute
+
+// rulesPost is a chat's pinned rules message: one the bot posted (Own), or an
+// admin's message chosen with /pinrules
+type rulesPost struct {
+	MessageID int
+	PostedAt  time.Time
+	Own       bool
+}
+
+// RulesPost returns botID's current rules message in chatID; ok is false if there is none
+func (s *Store) RulesPost(ctx context.Context, botID, chatID int64) (post rulesPost, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var posted int64
+	err = s.QueryRowContext(ctx, `SELECT message_id, posted_at, own FROM rules_messages WHERE bot_id = ? AND chat_id = ?`,
+		botID, chatID).Scan(&post.MessageID, &posted, &post.Own)
+	if err == sql.ErrNoRows {
+		return rulesPost{}, false, nil
+	}
+	if err != nil {
+		return rulesPost{}, false, err
+	}
+	post.PostedAt = time.Unix(posted, 0)
+	return post, true, nil
+}
+
+// SaveRulesPost records chatID's rules message
+func (s *Store) SaveRulesPost(ctx context.Context, botID, chatID int64, post rulesPost) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO rules_messages (bot_id, chat_id, message_id, posted_at, own) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bot_id, chat_id) DO UPDATE SET message_id = excluded.message_id, posted_at = excluded.posted_at, own = excluded.own
+	`, botID, chatID, post.MessageID, post.PostedAt.Unix(), post.Own)
+	return err
+}
+
+// DeleteRulesPost stops tracking chatID's rules message
+func (s *Store) DeleteRulesPost(ctx context.Context, botID, chatID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM rules_messages WHERE bot_id = ? AND chat_id = ?`, botID, chatID)
+	return err
+}
+
+// pinRules pins a message quietly
+func (b *Bot) pinRules(ctx context.Context, chatID int64, messageID int) error {
+	_, err := b.request(ctx, tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: messageID, DisableNotification: true})
+	return err
+}
+
+// postRules posts the chat's rules, pins the new copy and retires the previous
+// one: the bot's own copies are deleted, an admin's message is only unpinned
+func (b *Bot) postRules(ctx context.Context, chatID int64, rules string, previous *rulesPost) error {
+	sent, err := b.send(ctx, tgbotapi.NewMessage(chatID, rules))
+	if err != nil {
+		return err
+	}
+	if err := b.pinRules(ctx, chatID, sent.MessageID); err != nil {
+		b.logf("Failed to pin rules in chat %d: %v", chatID, err)
+	}
+	if previous != nil {
+		var retire tgbotapi.Chattable = tgbotapi.NewDeleteMessage(chatID, previous.MessageID)
+		if !previous.Own {
+			retire = tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: previous.MessageID}
+		}
+		if _, err := b.request(ctx, retire); err != nil {
+			b.logf("Failed to retire previous rules message %d in chat %d: %v", previous.MessageID, chatID, err)
+		}
+	}
+	return b.app.db.SaveRulesPost(ctx, b.api.Self.ID, chatID, rulesPost{MessageID: sent.MessageID, PostedAt: time.Now(), Own: true})
+}
+
+// cmdPinRules handles "/pinrules": pin the replied-to message as the rules, or
+// post and pin the rules setting when not replying
+func (b *Bot) cmdPinRules(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	previous, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chatID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "rules.lookup_failed", err))
+		return
+	}
+	var prev *rulesPost
+	if ok {
+		prev = &previous
+	}
+
+	if reply := message.ReplyToMessage; reply != nil {
+		if err := b.pinRules(ctx, chatID, reply.MessageID); err != nil {
+			b.reply(message, b.trFor(ctx, message, "rules.pin_failed", err))
+			return
+		}
+		if err := b.app.db.SaveRulesPost(ctx, b.api.Self.ID, chatID, rulesPost{MessageID: reply.MessageID, PostedAt: time.Now()}); err != nil {
+			b.reply(message, b.trFor(ctx, message, "rules.save_failed", err))
+			return
+		}
+		if prev != nil && prev.MessageID != reply.MessageID {
+			b.request(ctx, tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: prev.MessageID})
+		}
+		b.reply(message, b.trFor(ctx, message, "rules.pinned"))
+		return
+	}
+
+	rules := b.app.settings.Get(ctx, chatID, settingRules)
+	if rules == "" {
+		b.reply(message, b.trFor(ctx, message, "rules.pin_usage"))
+		return
+	}
+	if err := b.postRules(ctx, chatID, rules, prev); err != nil {
+		b.reply(message, b.trFor(ctx, message, "rules.post_failed", err))
+		return
+	}
+}
+
+// cmdSetRules handles "/setrules <text>", or "/setrules" replying to a message
+// with the text: save it as the rules setting, and if the pinned rules are
+// the bot's own copy, replace them with the new text
+func (b *Bot) cmdSetRules(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	rules := strings.TrimSpace(message.CommandArguments())
+	if reply := message.ReplyToMessage; rules == "" && reply != nil {
+		rules = strings.TrimSpace(reply.Text)
+	}
+	if rules == "" {
+		b.reply(message, b.trFor(ctx, message, "rules.set_usage"))
+		return
+	}
+	if err := b.app.settings.Set(ctx, chatID, settingRules, rules); err != nil {
+		b.reply(message, b.trFor(ctx, message, "set.failed", err))
+		return
+	}
+	b.bus.publish(ctx, SettingChanged{ChatID: chatID, Key: settingRules, Value: rules, By: message.From.UserName})
+
+	post, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chatID)
+	if err != nil || !ok || !post.Own {
+		b.reply(message, b.trFor(ctx, message, "rules.set"))
+		return
+	}
+	if err := b.postRules(ctx, chatID, rules, &post); err != nil {
+		b.reply(message, b.trFor(ctx, message, "rules.post_failed", err))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "rules.set_reposted"))
+}
+
+// cmdRules handles "/rules": show the chat's rules, with a link to the pinned
+// rules message when there is one
+func (b *Bot) cmdRules(ctx context.Context, message *Message) {
+	rules := b.app.settings.Get(ctx, message.Chat.ID, settingRules)
+	link := b.rulesLink(ctx, message.Chat)
+	switch {
+	case rules != "" && link != "":
+		b.reply(message, rules+"\n\n"+b.trFor(ctx, message, "rules.pinned_link", link))
+	case rules != "":
+		b.reply(message, rules)
+	case link != "":
+		b.reply(message, b.trFor(ctx, message, "rules.pinned_link", link))
+	default:
+		b.reply(message, b.trFor(ctx, message, "rules.none"))
+	}
+}
+
+// cmdUnpinRules handles "/unpinrules": unpin the rules message and stop protecting it
+func (b *Bot) cmdUnpinRules(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	post, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chatID)
+	if err != nil || !ok {
+		b.reply(message, b.trFor(ctx, message, "rules.nothing_pinned"))
+		return
+	}
+	b.request(ctx, tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: post.MessageID})
+	if err := b.app.db.DeleteRulesPost(ctx, b.api.Self.ID, chatID); err != nil {
+		b.reply(message, b.trFor(ctx, message, "rules.forget_failed", err))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "rules.unpinned"))
+}
+
+// handlePinnedMessage undoes pins by non-admins, which would push the rules
+// out of the chat header, and pins the rules again
+func (b *Bot) handlePinnedMessage(ctx context.Context, message *Message) {
+	chatID := message.Chat.ID
+	post, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chatID)
+	if err != nil || !ok || message.PinnedMessage.MessageID == post.MessageID {
+		return
+	}
+	if message.From == nil || message.From.ID == b.api.Self.ID || b.isChatAdmin(ctx, chatID, message.From.ID) {
+		return
+	}
+	if _, err := b.request(ctx, tgbotapi.UnpinChatMessageConfig{ChatID: chatID, MessageID: message.PinnedMessage.MessageID}); err != nil {
+		b.logf("Failed to undo pin by %s in chat %d: %v", message.From.UserName, chatID, err)
+		return
+	}
+	if err := b.pinRules(ctx, chatID, post.MessageID); err != nil {
+		b.logf("Failed to re-pin rules in chat %d: %v", chatID, err)
+	}
+	b.deleteMessage(ctx, message, "pin by non-admin")
+	b.logf("Undid pin by %s in chat %d and re-pinned the rules", message.From.UserName, chatID)
+}
+
+// repinAfterMigration posts the rules again in a group's new supergroup, whose
+// message ids don't carry over from the old group
+func (b *Bot) repinAfterMigration(ctx context.Context, chatID int64) {
+	if _, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chatID); err != nil || !ok {
+		return
+	}
+	rules := b.app.settings.Get(ctx, chatID, settingRules)
+	if rules == "" {
+		// An admin's message can't be recreated; they need to run /pinrules again
+		b.app.db.DeleteRulesPost(ctx, b.api.Self.ID, chatID)
+		return
+	}
+	if err := b.postRules(ctx, chatID, rules, nil); err != nil {
+		b.logf("Failed to re-pin rules in migrated chat %d: %v", chatID, err)
+	}
+}
+
+// postDueReminders reposts the rules in every known chat whose rules_reminder_hours have passed
+func (b *Bot) postDueReminders(ctx context.Context) {
+	chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list chats for rules reminders: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		hours, _ := strconv.Atoi(b.app.settings.Get(ctx, chat.ID, settingRulesReminderHours))
+		rules := b.app.settings.Get(ctx, chat.ID, settingRules)
+		if hours == 0 || rules == "" {
+			continue
+		}
+		post, ok, err := b.app.db.RulesPost(ctx, b.api.Self.ID, chat.ID)
+		if err != nil {
+			b.logf("Failed to look up rules message in chat %d: %v", chat.ID, err)
+			continue
+		}
+		if ok && time.Since(post.PostedAt) < time.Duration(hours)*time.Hour {
+			continue
+		}
+		var previous *rulesPost
+		if ok {
+			previous = &post
+		}
+		if err := b.postRules(ctx, chat.ID, rules, previous); err != nil {
+			b.logf("Failed to post rules reminder in chat %d: %v", chat.ID, err)
+			b.app.reporter.Failure("telegram.rulesReminder", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chat.ID})
+			continue
+		}
+		metrics.Add("rules_reminders_posted", 1)
+	}
+}
+
+// runReminders posts due rules reminders until ctx is cancelled
+func (b *Bot) runReminders(ctx context.Context) {
+	for sleepContext(ctx, reminderCheckInterval) {
+		b.postDueReminders(ctx)
+	}
+}
+
+// maybePostReminders checks reminders at most once per reminderCheckInterval,
+// for webhook mode where no background loop runs
+func (b *Bot) maybePostReminders(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastReminders.Load()
+	if now-last < int64(reminderCheckInterval/time.Second) || !b.lastReminders.CompareAndSwap(last, now) {
+		return
+	}
+	b.postDueReminders(ctx)
+}
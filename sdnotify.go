@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state string (e.g. "READY=1") to systemd. It is a no-op when
+// the bot isn't running under a systemd unit with Type=notify.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns how often to ping systemd (half of WatchdogSec), or 0 if disabled
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// runWatchdog pings systemd only while every bot's update loop is making progress,
+// so systemd restarts the process if polling stalls
+func runWatchdog(ctx context.Context, bots []*Bot) {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	// A loop is stalled if it hasn't reported in for a full watchdog period
+	// (plus the long-poll timeout, during which it legitimately blocks)
+	stallAfter := 2*interval + 60*time.Second
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		healthy := true
+		for _, bot := range bots {
+			if since := bot.sinceHeartbeat(); since > stallAfter {
+				bot.logf("Update loop stalled for %s, withholding watchdog ping", since.Round(time.Second))
+				healthy = false
+			}
+		}
+		if healthy {
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				log.Printf("Failed to ping systemd watchdog: %v", err)
+			}
+		}
+	}
+}
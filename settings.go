@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chatSetting describes a per-chat option admins can change with /set
+type chatSetting struct {
+	Key         string
+	Description string
+	Default     string
+	// Allowed values; empty accepts anything
+	Allowed []string
+	// Numeric settings only accept non-negative integers
+	Numeric bool
+	// Max, if set, is the largest value of a Numeric setting
+	Max int
+	// Secret values, such as webhook URLs, are never shown or logged
+	Secret bool
+	// Check, if set, validates values no list of allowed ones can describe
+	Check func(value string) error
+}
+
+// envKey is the environment variable holding the global default for the setting
+func (s chatSetting) envKey() string {
+	return strings.ToUpper(s.Key)
+}
+
+func (s chatSetting) validate(value string) error {
+	if s.Check != nil {
+		if err := s.Check(value); err != nil {
+			return fmt.Errorf("%s: %v", s.Key, err)
+		}
+		return nil
+	}
+	if s.Numeric {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative number", s.Key)
+		}
+		if s.Max > 0 && n > s.Max {
+			return fmt.Errorf("%s must be at most %d", s.Key, s.Max)
+		}
+		return nil
+	}
+	if len(s.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range s.Allowed {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of: %s", s.Key, strings.Join(s.Allowed, ", "))
+}
+
+// knownSettings is the registry of per-chat settings
+var knownSettings = map[string]chatSetting{}
+
+func registerSetting(key, description, def string, allowed ...string) string {
+	knownSettings[key] = chatSetting{Key: key, Description: description, Default: def, Allowed: allowed}
+	return key
+}
+
+func registerSecretSetting(key, description string, check func(string) error) string {
+	knownSettings[key] = chatSetting{Key: key, Description: description, Secret: true, Check: check}
+	return key
+}
+
+func registerCheckedSetting(key, description, def string, check func(string) error) string {
+	knownSettings[key] = chatSetting{Key: key, Description: description, Default: def, Check: check}
+	return key
+}
+
+func registerNumericSetting(key, description string, def int) string {
+	knownSettings[key] = chatSetting{Key: key, Description: description, Default: strconv.Itoa(def), Numeric: true}
+	return key
+}
+
+func registerBoundedSetting(key, description string, def, max int) string {
+	knownSettings[key] = chatSetting{Key: key, Description: description, Default: strconv.Itoa(def), Numeric: true, Max: max}
+	return key
+}
+
+// Content policies: allow, delete (no strike) or spam (delete and count a strike)
+var contentPolicies = []string{"allow", "delete", "spam"}
+
+var (
+	settingTimezone = registerCheckedSetting("timezone", "IANA time zone (e.g. Asia/Seoul) the chat's days start in, for digests, /stats, the dashboard and the stats API", "UTC", checkTimezone)
+	settingLocale   = registerSetting("locale", "language of the bot's messages (ko+en, en+ko: both in one message, the first one first); in private it answers in the member's Telegram language when it has a catalog for it", "en", append([]string{"en", "ko"}, bilingualLocales...)...)
+
+	settingContactPolicy   = registerSetting("contact_policy", "shared contact cards from non-admins", "allow", contentPolicies...)
+	settingViaBotPolicy    = registerSetting("via_bot_policy", "messages sent via inline bots not on via_bot_allowlist", "allow", contentPolicies...)
+	settingViaBotAllowlist = registerSetting("via_bot_allowlist", "comma-separated inline bot usernames that are always allowed", "")
+	settingGiveawayPolicy  = registerSetting("giveaway_policy", "giveaway announcements and results from non-admins", "allow", contentPolicies...)
+	settingPaidMediaPolicy = registerSetting("paid_media_policy", "paid (Telegram Stars) media posts from non-admins", "allow", contentPolicies...)
+	settingBotPolicy       = registerSetting("bot_message_policy", "messages from other bot accounts", "scan", "scan", "ignore", "delete")
+	settingLinkPolicy      = registerSetting("link_policy", "links in messages from non-admins (verified: only members with a verified Aptos wallet)", "spam", "spam", "allow", "verified")
+
+	settingChannelMentionPolicy = registerSetting("channel_mention_policy", "@mentions of other channels and public groups", "spam", contentPolicies...)
+	settingMentionAllowlist     = registerSetting("mention_allowlist", "comma-separated channel/group usernames that may always be mentioned", "")
+	settingDMSolicitation       = registerSetting("dm_solicitation", "\"DM me for signals/support\" spam (low: with an offer and a contact, medium: with an @mention or account link, high: the phrase alone)", "medium", "off", "low", "medium", "high")
+	settingQRScan               = registerSetting("qr_scan", "decode QR codes in images and check their links like message text", "on", "on", "off")
+	settingOfficialLinks        = registerSetting("official_links", "comma-separated official domains and @handles; links imitating them are deleted with a warning", "")
+	settingSpamKeywords         = registerSetting("spam_keywords", "comma-separated spam keywords for this chat, on top of SPAM_KEYWORDS", "")
+	settingBlockedDomains       = registerSetting("blocked_domains", "comma-separated domains whose links, subdomains included, are spam even where link_policy allows links", "")
+	settingScamAddressPolicy    = registerSetting("scam_address_policy", "Aptos addresses on the scam list or imitating one posted earlier", "spam", contentPolicies...)
+	settingCryptoScamPolicy     = registerSetting("crypto_scam_policy", "fake airdrop, wallet connect and eligibility check links (ban: on the first offence)", "ban", "ban", "spam", "delete", "allow")
+	settingSeedPhrasePolicy     = registerSetting("seed_phrase_policy", "requests for seed phrases or private keys, and posted mnemonics (ban: on the first offence, alerting admins)", "ban", "ban", "spam", "delete", "allow")
+	settingFakeSupportPolicy    = registerSetting("fake_support_policy", "\"contact support at @xxx\" and \"open a ticket\" lures pointing outside the admins and official_links (alerting admins)", "delete", "ban", "spam", "delete", "allow")
+	settingHomographPolicy      = registerSetting("homograph_policy", "punycode/IDN links disguised as known wallets, dapps and exchanges or official_links (ban: on the first offence)", "ban", "ban", "spam", "delete", "allow")
+	settingPhishingDomainPolicy = registerSetting("phishing_domain_policy", "links to domains from PHISHING_FEEDS (ban: on the first offence)", "ban", "ban", "spam", "delete", "allow")
+
+	settingJoinRequestPolicy    = registerSetting("join_request_policy", "join requests: leave to admins or screen them automatically", "manual", "manual", "screen")
+	settingJoinSuspiciousAction = registerSetting("join_suspicious_action", "screened join requests that look like spam", "escalate", "escalate", "decline")
+
+	settingEventRestrictions   = registerSetting("event_restrictions", "comma-separated rules /event turns on: links, forwards, captcha, slow_mode", strings.Join(eventRestrictions, ","))
+	settingEventSlowMode       = registerNumericSetting("event_slow_mode", "seconds a member must wait between messages in event mode", 30)
+	settingEventCaptchaMinutes = registerNumericSetting("event_captcha_minutes", "minutes a member joining in event mode has to press the captcha button before being removed", 5)
+
+	settingMemberScreening       = registerSetting("member_screening", "members joining directly who are on CAS or have spammy names", "off", "off", "kick", "ban")
+	settingDeleteServiceMessages = registerSetting("delete_service_messages", "join/leave notices to delete", "none", "none", "join", "leave", "all")
+	settingPhotoHashCheck        = registerSetting("photo_hash_check", "flag joining members whose profile photo matches one of a user banned here (chat) or, sharing this chat's, in any chat that opted in (shared)", "chat", "off", "chat", "shared")
+
+	settingReactionFlagThreshold = registerNumericSetting("reaction_flag_threshold", "distinct members flagging a message by reaction before action is taken, 0 disables", 0)
+	settingReactionFlagEmojis    = registerSetting("reaction_flag_emojis", "comma-separated reactions that count as a flag", "👎,🤬")
+	settingReactionFlagAction    = registerSetting("reaction_flag_action", "what to do with crowd-flagged messages", "escalate", "escalate", "delete")
+	settingMinorViolationAction  = registerSetting("minor_violation_action", "hits of the low-severity rules (a bare link, a spam keyword without a mention): delete with a strike, or react with minor_violation_emoji and tell the member privately", "delete", "delete", "react")
+	settingMinorViolationEmoji   = registerSetting("minor_violation_emoji", "reaction minor_violation_action=react marks messages with; it must be one Telegram allows as a reaction", "👀")
+
+	settingEditRecheckMinutes = registerNumericSetting("edit_recheck_minutes", "re-check messages edited within this many minutes of posting, 0 disables", 10)
+
+	settingNewMemberHours = registerNumericSetting("new_member_hours", "hours after joining that a member counts as new (low reputation)", 24)
+	settingVoicePolicy    = registerSetting("voice_policy", "voice messages and video notes from new members and users with strikes", "allow", "allow", "first_message", "limit", "delete")
+	settingVoiceRateLimit = registerNumericSetting("voice_rate_limit", "voice messages/video notes per hour allowed by voice_policy=limit", 1)
+
+	settingRules              = registerSetting("rules", "chat rules, set with /setrules and shown by /rules and the {rules} welcome placeholder", "")
+	settingRulesReminderHours = registerNumericSetting("rules_reminder_hours", "repost and pin the rules every this many hours, 0 disables", 0)
+	settingWelcomeMessage     = registerSetting("welcome_message", "greeting for new members with {name}, {username}, {chat}, {rules} and {rules_link} placeholders; empty disables it", "")
+	settingWelcomeDeleteAfter = registerNumericSetting("welcome_delete_after", "seconds before welcome and thank-you messages are deleted, 0 keeps them", 300)
+	settingNoticeDeleteAfter  = registerNumericSetting("notice_delete_after", "seconds before warn/ban notices and other moderation notices posted in the chat are deleted, 0 keeps them", 300)
+	settingBoostThanks        = registerSetting("boost_thanks_message", "reply to boosts and gifts, with the welcome placeholders; empty disables it", "")
+	settingWarnMessage        = registerSetting("warn_message", "notice when a member's spam is deleted, with {user}, {count}, {threshold}, {reason} and {rules_link} placeholders; empty disables it", "")
+	settingWarnDelivery       = registerSetting("warn_delivery", "where warn_message goes: the chat, or a private message to the member, falling back to the chat if they haven't started the bot", "chat", "chat", "private")
+	settingBanMessage         = registerSetting("ban_message", "notice when a member is banned for spam, with the warn_message placeholders; empty disables it", "")
+
+	settingWallChannel    = registerCheckedSetting("wall_channel", "channel (@username or id) approved /wall posts are published to, where you and the bot must be admins; empty disables the wall", "", checkWallChannel)
+	settingWallReviewChat = registerCheckedSetting("wall_review_chat", "chat id wall submissions are sent to for review, where you must be an admin; empty uses this chat", "", checkWallReviewChat)
+
+	settingTokenGateMin          = registerNumericSetting("token_gate_min", "minimum token_gate_coin balance (in its smallest unit, e.g. octas) a verified wallet must hold to stay, 0 disables", 0)
+	settingTokenGateCoin         = registerSetting("token_gate_coin", "coin type or fungible asset address checked by token_gate_min", aptosCoin)
+	settingNFTGateCollection     = registerSetting("nft_gate_collection", "Aptos collection id members' verified wallets must hold an NFT from; empty disables", "")
+	settingTokenGateGraceHours   = registerNumericSetting("token_gate_grace_hours", "hours a member may fail token_gate_min or nft_gate_collection before being removed", 24)
+	settingTokenGateRecheckHours = registerNumericSetting("token_gate_recheck_hours", "re-check members' balances and NFTs every this many hours", 24)
+	settingSybilPolicy           = registerSetting("sybil_policy", "wallets verified by more than one Telegram account (flag: alert admins and add to the suspect list, reject: also refuse the link, ban: also ban the account)", "flag", "off", "flag", "reject", "ban")
+	settingSybilFunding          = registerSetting("sybil_funding", "also flag wallets first funded by the same account as another member's, ignoring busy sources like exchanges", "on", "on", "off")
+
+	settingNewTokenHours  = registerNumericSetting("new_token_hours", "Aptos contracts first seen on chain less than this many hours ago count as freshly deployed, 0 disables", 72)
+	settingNewTokenPolicy = registerSetting("new_token_policy", "coin types and contract addresses deployed within new_token_hours", "allow", contentPolicies...)
+	settingPriceSymbol    = registerSetting("price_symbol", "token /price quotes when no symbol is given", "APT")
+	settingPriceCooldown  = registerNumericSetting("price_cooldown", "seconds between /price answers in a group; extra requests are deleted", 30)
+	settingAdminLogChat   = registerSetting("admin_log_chat", "chat id that moderation findings such as token lookups are posted to; empty only logs them", "")
+	settingBanAlerts      = registerSetting("ban_alerts", "who is sent a private alert with an Unban button when the bot bans someone: off, admins, or one admin's user id", "off")
+	settingDiscordWebhook = registerSecretSetting("discord_webhook", "Discord webhook URL (https://discord.com/api/webhooks/...) that deletions, removals, bans and unbans are mirrored to; empty disables", checkDiscordWebhook)
+	settingGoogleSheet    = registerCheckedSetting("google_sheet", "id of a Google Sheet, shared with the GOOGLE_SHEETS_CREDENTIALS service account as an editor, that every moderation event is appended to as a row; empty disables", "", checkSpreadsheetID)
+	settingPublicStats    = registerSetting("public_stats", "publish this chat's moderation counts and audit log entries, with member ids, on the read-only stats API", "off", "off", "on")
+	settingDigest         = registerSetting("digest", "summary of removals, bans, top rules and offenders sent to admin_log_chat, or to each admin in private when it is empty", "off", "off", "daily", "weekly")
+
+	settingTreasuryAddress   = registerSetting("treasury_address", "Aptos address whose large APT transfers are announced; empty disables", "")
+	settingTreasuryAlertAPT  = registerNumericSetting("treasury_alert_apt", "smallest treasury transfer, in whole APT, that is announced", 1000)
+	settingOnchainEventTypes = registerSetting("onchain_event_types", "comma-separated Move event types to announce, e.g. 0x1::aptos_governance::CreateProposalEvent", "")
+	settingOnchainAnnounce   = registerSetting("onchain_announce", "where on-chain announcements are posted", "group", "group", "wall")
+
+	settingBanBondAPT      = registerBoundedSetting("ban_bond_apt", "APT a banned member locks to appeal with the ban_bonds flag, refunded if the ban was wrong", 1, maxBondAPT)
+	settingCommunityWallet = registerCheckedSetting("community_wallet", "full Aptos address (0x and 64 hex digits) bonds of upheld bans are sent to", "", checkAptosAddress)
+)
+
+// listSetting splits a comma-separated setting into lower-cased entries without a leading @
+func listSetting(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(item), "@"))
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// settingsCacheTTL bounds how stale settings can be when another instance changes them
+const settingsCacheTTL = time.Minute
+
+// settingsCacheSize bounds the chats and topics whose values are kept
+const settingsCacheSize = 10000
+
+// ChatSettings resolves settings: per-topic DB value > per-chat DB value >
+// env default (e.g. CONTACT_POLICY) > built-in default
+type ChatSettings struct {
+	db *Store
+
+	mu       sync.Mutex
+	defaults map[string]string
+	cache    *lruCache[settingsScope, map[string]string]
+}
+
+// settingsScope is a whole chat (threadID 0) or one forum topic in it
+type settingsScope struct {
+	chatID   int64
+	threadID int
+}
+
+func NewChatSettings(db *Store, cfg *Config) *ChatSettings {
+	s := &ChatSettings{db: db, cache: newLRUCache[settingsScope, map[string]string]("settings", settingsCacheSize, settingsCacheTTL)}
+	s.Reload(cfg)
+	return s
+}
+
+// Reload replaces the env-level defaults
+func (s *ChatSettings) Reload(cfg *Config) {
+	s.mu.Lock()
+	s.defaults = cfg.SettingDefaults
+	s.mu.Unlock()
+}
+
+// Get returns the effective value of key in chatID
+func (s *ChatSettings) Get(ctx context.Context, chatID int64, key string) string {
+	return s.GetTopic(ctx, chatID, 0, key)
+}
+
+// GetTopic returns the effective value of key in a forum topic of chatID
+func (s *ChatSettings) GetTopic(ctx context.Context, chatID int64, threadID int, key string) string {
+	if threadID != 0 {
+		if v, ok := s.overrides(ctx, settingsScope{chatID, threadID})[key]; ok {
+			return v
+		}
+	}
+	if v, ok := s.overrides(ctx, settingsScope{chatID, 0})[key]; ok {
+		return v
+	}
+	s.mu.Lock()
+	v, ok := s.defaults[key]
+	s.mu.Unlock()
+	if ok {
+		return v
+	}
+	return knownSettings[key].Default
+}
+
+// overrides returns the values stored for exactly this scope
+func (s *ChatSettings) overrides(ctx context.Context, scope settingsScope) map[string]string {
+	if values, ok := s.cache.get(scope); ok {
+		return values
+	}
+
+	values := make(map[string]string)
+	ctx, cancel := s.db.OpContext(ctx)
+	defer cancel()
+	var rows *sql.Rows
+	var err error
+	if scope.threadID == 0 {
+		rows, err = s.db.QueryContext(ctx, `SELECT key, value FROM chat_settings WHERE chat_id = ?`, scope.chatID)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `SELECT key, value FROM topic_settings WHERE chat_id = ? AND thread_id = ?`,
+			scope.chatID, scope.threadID)
+	}
+	if err != nil {
+		stale, _ := s.cache.stale(scope)
+		return stale
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err == nil {
+			values[key] = value
+		}
+	}
+
+	s.cache.put(scope, values)
+	return values
+}
+
+// Set validates and stores a per-chat value
+func (s *ChatSettings) Set(ctx context.Context, chatID int64, key, value string) error {
+	return s.SetTopic(ctx, chatID, 0, key, value)
+}
+
+// SetTopic validates and stores a value for one forum topic (threadID 0: the whole chat)
+func (s *ChatSettings) SetTopic(ctx context.Context, chatID int64, threadID int, key, value string) error {
+	setting, ok := knownSettings[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err := setting.validate(value); err != nil {
+		return err
+	}
+	ctx, cancel := s.db.OpContext(ctx)
+	defer cancel()
+	var err error
+	if threadID == 0 {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO chat_settings (chat_id, key, value) VALUES (?, ?, ?)
+			ON CONFLICT(chat_id, key) DO UPDATE SET value = excluded.value
+		`, chatID, key, value)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO topic_settings (chat_id, thread_id, key, value) VALUES (?, ?, ?, ?)
+			ON CONFLICT(chat_id, thread_id, key) DO UPDATE SET value = excluded.value
+		`, chatID, threadID, key, value)
+	}
+	s.invalidate(settingsScope{chatID, threadID})
+	return err
+}
+
+// Reset removes a per-chat value so the default applies again
+func (s *ChatSettings) Reset(ctx context.Context, chatID int64, key string) error {
+	return s.ResetTopic(ctx, chatID, 0, key)
+}
+
+// ResetTopic removes a topic value so the chat-wide value applies again
+func (s *ChatSettings) ResetTopic(ctx context.Context, chatID int64, threadID int, key string) error {
+	ctx, cancel := s.db.OpContext(ctx)
+	defer cancel()
+	var err error
+	if threadID == 0 {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = ? AND key = ?`, chatID, key)
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM topic_settings WHERE chat_id = ? AND thread_id = ? AND key = ?`,
+			chatID, threadID, key)
+	}
+	s.invalidate(settingsScope{chatID, threadID})
+	return err
+}
+
+func (s *ChatSettings) invalidate(scope settingsScope) {
+	s.cache.remove(scope)
+}
+
+// DescribeTopic lists the values overridden in one forum topic
+func (s *ChatSettings) DescribeTopic(ctx context.Context, chatID int64, threadID int) string {
+	values := s.overrides(ctx, settingsScope{chatID, threadID})
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, values[key])
+	}
+	return b.String()
+}
+
+// Describe lists every setting with its effective value in chatID
+func (s *ChatSettings) Describe(ctx context.Context, chatID int64) string {
+	keys := make([]string, 0, len(knownSettings))
+	for key := range knownSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		setting := knownSettings[key]
+		value := s.Get(ctx, chatID, key)
+		if setting.Secret && value != "" {
+			value = "(set)"
+		}
+		fmt.Fprintf(&b, "%s = %s - %s", key, value, setting.Description)
+		if len(setting.Allowed) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(setting.Allowed, "/"))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sheetsAPIURL is the Google Sheets API the rows are appended with
+const sheetsAPIURL = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+// sheetsScope is the OAuth scope the service account asks for
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// sheetsTimeout bounds one token exchange or append
+const sheetsTimeout = 15 * time.Second
+
+// spreadsheetIDPattern matches Google spreadsheet ids, the part of a sheet's
+// URL after /d/
+var spreadsheetIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+
+// checkSpreadsheetID keeps google_sheet to ids, which go into API paths
+func checkSpreadsheetID(value string) error {
+	if value != "" && !spreadsheetIDPattern.MatchString(value) {
+		return fmt.Errorf("not a spreadsheet id (the part of the sheet's URL after /d/)")
+	}
+	return nil
+}
+
+// serviceAccount is the part of a Google service account key file the
+// exporter needs
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// GoogleSheets appends moderation events to the Google Sheets chats set in
+// google_sheet, signed in as the service account of GOOGLE_SHEETS_CREDENTIALS;
+// each sheet must be shared with the account's email as an editor
+type GoogleSheets struct {
+	client  *http.Client
+	account serviceAccount
+	key     *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newGoogleSheets reads the key file at GOOGLE_SHEETS_CREDENTIALS, returning
+// nil when it is unset
+func newGoogleSheets(cfg *Config) (*GoogleSheets, error) {
+	if cfg.GoogleSheetsCredentials == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.GoogleSheetsCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: %v", err)
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: %v", err)
+	}
+	if account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: not a service account key file")
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GOOGLE_SHEETS_CREDENTIALS: private key is not RSA")
+	}
+	return &GoogleSheets{client: &http.Client{Timeout: sheetsTimeout}, account: account, key: key}, nil
+}
+
+// accessToken returns a cached OAuth token, exchanging a signed JWT for a
+// new one shortly before the old one expires
+func (g *GoogleSheets) accessToken(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token != "" && time.Now().Before(g.expires.Add(-time.Minute)) {
+		return g.token, nil
+	}
+
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   g.account.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   g.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, g.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token endpoint returned %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("google token endpoint returned no access token")
+	}
+	g.token, g.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	return g.token, nil
+}
+
+// Append adds row below the last row of the first sheet of spreadsheetID
+func (g *GoogleSheets) Append(ctx context.Context, spreadsheetID string, row []string) error {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{"values": [][]string{row}})
+	if err != nil {
+		return err
+	}
+	endpoint := sheetsAPIURL + url.PathEscape(spreadsheetID) + "/values/A1:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("google sheets returned %s", resp.Status)
+	}
+	return nil
+}
+
+// exportToSheet appends a moderation event in chatID to the chat's
+// google_sheet, if set: its time in the chat's timezone, the chat, action,
+// member, message and reason. Like the Discord mirror it posts in the
+// background.
+func (b *Bot) exportToSheet(ctx context.Context, action string, chatID, userID int64, messageID int, reason string) {
+	spreadsheetID := b.app.settings.Get(ctx, chatID, settingGoogleSheet)
+	if spreadsheetID == "" {
+		return
+	}
+	if b.app.sheets == nil {
+		b.logf("Ignoring google_sheet of chat %d: GOOGLE_SHEETS_CREDENTIALS is not set", chatID)
+		return
+	}
+	message := ""
+	if messageID != 0 {
+		message = strconv.Itoa(messageID)
+	}
+	row := []string{
+		b.app.settings.Now(ctx, chatID).Format("2006-01-02 15:04:05"),
+		strconv.FormatInt(chatID, 10),
+		action,
+		strconv.FormatInt(userID, 10),
+		message,
+		reason,
+		"@" + b.api.Self.UserName,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*sheetsTimeout)
+		defer cancel()
+		if err := b.app.sheets.Append(ctx, spreadsheetID, row); err != nil {
+			b.logf("Failed to export %s in chat %d to its Google Sheet: %v", action, chatID, err)
+			b.app.reporter.Failure("sheets.append", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+			return
+		}
+		metrics.Add("sheet_rows_exported", 1)
+	}()
+}
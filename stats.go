@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsPathPrefix is where the read-only stats API is served
+const statsPathPrefix = "/stats"
+
+// statsMaxDays caps the span of a counts query
+const statsMaxDays = 365
+
+// statsAuditLimit caps how many audit log entries one query returns, and
+// statsAuditScan how many entries of other chats it skips looking for them
+const (
+	statsAuditLimit = 100
+	statsAuditScan  = 5000
+)
+
+// statsDay is one chat's moderation totals for a UTC day
+type statsDay struct {
+	Date    string         `json:"date"`
+	Actions map[string]int `json:"actions"`
+}
+
+// ModerationCounts lists chatID's daily moderation totals since since, oldest first
+func (s *Store) ModerationCounts(ctx context.Context, chatID int64, since time.Time) ([]statsDay, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT day, action, count FROM moderation_counts WHERE chat_id = ? AND day >= ? ORDER BY day
+	`, chatID, localDay(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []statsDay
+	for rows.Next() {
+		var day int64
+		var action string
+		var count int
+		if err := rows.Scan(&day, &action, &count); err != nil {
+			return nil, err
+		}
+		date := time.Unix(day*86400, 0).UTC().Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, statsDay{Date: date, Actions: make(map[string]int)})
+		}
+		days[len(days)-1].Actions[action] = count
+	}
+	return days, rows.Err()
+}
+
+// AppealOutcomes counts chatID's bonded ban appeals opened since since by
+// status, leaving out those whose bond was never paid
+func (s *Store) AppealOutcomes(ctx context.Context, chatID int64, since time.Time) (map[string]int, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT status, COUNT(*) FROM ban_bonds WHERE chat_id = ? AND created_at >= ? AND status <> 'pending' GROUP BY status
+	`, chatID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	outcomes := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		outcomes[status] = count
+	}
+	return outcomes, rows.Err()
+}
+
+// statsAuditEntry is an audit log entry with its place in the hash chain
+type statsAuditEntry struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+	auditEntry
+	// Aptos transaction that published this entry or a later one, once published
+	PublishedTx string `json:"published_tx,omitempty"`
+}
+
+// ChatAuditLog lists up to limit of chatID's audit log entries after seq
+// after, oldest first. Entries aren't indexed by chat, so it gives up after
+// scanning statsAuditScan entries; next is where to continue either way.
+func (s *Store) ChatAuditLog(ctx context.Context, chatID, after int64, limit int) (entries []statsAuditEntry, next int64, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT seq, hash, payload, published_tx FROM audit_log WHERE seq > ? ORDER BY seq LIMIT ?
+	`, after, statsAuditScan)
+	if err != nil {
+		return nil, after, err
+	}
+	defer rows.Close()
+	next = after
+	for rows.Next() && len(entries) < limit {
+		var entry statsAuditEntry
+		var payload string
+		if err := rows.Scan(&entry.Seq, &entry.Hash, &payload, &entry.PublishedTx); err != nil {
+			return nil, after, err
+		}
+		next = entry.Seq
+		if json.Unmarshal([]byte(payload), &entry.auditEntry) == nil && entry.ChatID == chatID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, next, rows.Err()
+}
+
+// StatsHandler answers read-only queries about the moderation of chats
+// that set public_stats, for transparency pages and other dashboards:
+//
+//	GET /stats/chats                              chats with public stats
+//	GET /stats/summary?chat_id=<id>&days=<n>      totals per action over n days (default 30)
+//	GET /stats/daily?chat_id=<id>&days=<n>        totals per action and day
+//	GET /stats/audit?chat_id=<id>&after=<seq>     the chat's audit log entries
+//	GET /stats/page?chat_id=<id>&days=<n>         transparency page for members
+//
+// Nothing on it can change state, so it needs no credentials.
+type StatsHandler struct {
+	bots []*Bot
+	page *template.Template
+}
+
+func newStatsHandler(bots []*Bot) *StatsHandler {
+	if len(bots) == 0 {
+		return nil
+	}
+	page := template.Must(template.New("transparency").Funcs(template.FuncMap{"tr": translate}).Parse(transparencyTemplate))
+	return &StatsHandler{bots: bots, page: page}
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	app := h.bots[0].app
+	query := r.URL.Query()
+	route := strings.Trim(strings.TrimPrefix(r.URL.Path, statsPathPrefix), "/")
+	if route == "chats" {
+		h.writeJSON(w, map[string]interface{}{"chats": h.publicChats(ctx)})
+		return
+	}
+
+	chatID, err := strconv.ParseInt(query.Get("chat_id"), 10, 64)
+	if err != nil || app.settings.Get(ctx, chatID, settingPublicStats) != "on" {
+		http.NotFound(w, r)
+		return
+	}
+	days, err := strconv.Atoi(query.Get("days"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	days = min(days, statsMaxDays)
+	since := dayStart(app.settings.Now(ctx, chatID)).AddDate(0, 0, 1-days)
+
+	switch route {
+	case "summary", "daily", "page":
+		counts, err := app.db.ModerationCounts(ctx, chatID, since)
+		if err != nil {
+			h.fail(w, "db.moderationCounts", err, chatID)
+			return
+		}
+		if route == "daily" {
+			h.writeJSON(w, map[string]interface{}{"chat_id": chatID, "days": counts})
+			return
+		}
+		appeals, err := app.db.AppealOutcomes(ctx, chatID, since)
+		if err != nil {
+			h.fail(w, "db.appealOutcomes", err, chatID)
+			return
+		}
+		totals := make(map[string]int)
+		for _, day := range counts {
+			for action, count := range day.Actions {
+				totals[action] += count
+			}
+		}
+		if route == "page" {
+			h.renderPage(ctx, w, chatID, days, totals, appeals, counts)
+			return
+		}
+		h.writeJSON(w, map[string]interface{}{"chat_id": chatID, "days": days, "since": since.Format("2006-01-02"), "actions": totals, "appeals": appeals})
+	case "audit":
+		after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+		entries, next, err := app.db.ChatAuditLog(ctx, chatID, after, statsAuditLimit)
+		if err != nil {
+			h.fail(w, "db.chatAuditLog", err, chatID)
+			return
+		}
+		h.writeJSON(w, map[string]interface{}{"chat_id": chatID, "entries": entries, "next": next})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// transparencyPage is what the transparency page template renders
+type transparencyPage struct {
+	Locale  string
+	Title   string
+	ChatID  int64
+	Days    int
+	Actions map[string]int
+	Appeals map[string]int
+	Daily   []statsDay
+}
+
+// renderPage shows a chat's moderation totals and appeal outcomes to its
+// members, in the chat's locale
+func (h *StatsHandler) renderPage(ctx context.Context, w http.ResponseWriter, chatID int64, days int, actions, appeals map[string]int, daily []statsDay) {
+	page := transparencyPage{
+		Locale:  primaryLocale(h.bots[0].app.settings.Get(ctx, chatID, settingLocale)),
+		ChatID:  chatID,
+		Days:    days,
+		Actions: actions,
+		Appeals: appeals,
+		Daily:   daily,
+	}
+	for _, chat := range h.publicChats(ctx) {
+		if chat.ID == chatID {
+			page.Title = chat.Title
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.page.Execute(w, page); err != nil {
+		log.Printf("Failed to render the transparency page of chat %d: %v", chatID, err)
+	}
+	metrics.Add("transparency_page_views", 1)
+}
+
+// statsChat is a chat listed by /stats/chats
+type statsChat struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// publicChats lists the chats of every bot that set public_stats
+func (h *StatsHandler) publicChats(ctx context.Context) []statsChat {
+	chats := []statsChat{}
+	seen := make(map[int64]bool)
+	for _, bot := range h.bots {
+		known, err := bot.app.db.KnownChats(ctx, bot.api.Self.ID)
+		if err != nil {
+			bot.logf("Failed to list chats for the stats API: %v", err)
+			continue
+		}
+		for _, chat := range known {
+			if !seen[chat.ID] && bot.app.settings.Get(ctx, chat.ID, settingPublicStats) == "on" {
+				seen[chat.ID] = true
+				chats = append(chats, statsChat{ID: chat.ID, Title: chat.Title})
+			}
+		}
+	}
+	return chats
+}
+
+func (h *StatsHandler) writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	// Public and read-only, so transparency pages may query it from the browser
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(body)
+	metrics.Add("stats_queries", 1)
+}
+
+func (h *StatsHandler) fail(w http.ResponseWriter, kind string, err error, chatID int64) {
+	log.Printf("Stats query for chat %d failed: %v", chatID, err)
+	h.bots[0].app.reporter.Failure(kind, err, ErrorContext{ChatID: chatID})
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// serveStats serves the stats API on addr until the listener fails
+func serveStats(addr string, handler *StatsHandler) {
+	mux := http.NewServeMux()
+	mux.Handle(statsPathPrefix+"/", handler)
+	log.Printf("Serving the stats API on http://%s%s/", addr, statsPathPrefix)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Stats server stopped: %v", err)
+	}
+}
+
+// transparencyTemplate is the public page of a chat's moderation; the audit
+// log link lets members check individual decisions
+const transparencyTemplate = `<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{tr .Locale "transparency.title" .Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 720px; margin: 0 auto; padding: 1em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: .3em .5em; border-bottom: 1px solid #eee; }
+td.n, th.n { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{tr .Locale "transparency.title" .Title}}</h1>
+<p>{{tr .Locale "transparency.intro" .Days}}</p>
+<table>
+<tr><td>{{tr .Locale "transparency.removed"}}</td><td class="n">{{index .Actions "delete"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.overturned"}}</td><td class="n">{{index .Actions "overturn"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.kicks"}}</td><td class="n">{{index .Actions "kick"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.bans"}}</td><td class="n">{{index .Actions "ban"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.unbans"}}</td><td class="n">{{index .Actions "unban"}}</td></tr>
+</table>
+<h2>{{tr .Locale "transparency.appeals"}}</h2>
+<table>
+<tr><td>{{tr .Locale "transparency.appeals_review"}}</td><td class="n">{{index .Appeals "locked"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.appeals_refunded"}}</td><td class="n">{{index .Appeals "refunded"}}</td></tr>
+<tr><td>{{tr .Locale "transparency.appeals_forfeited"}}</td><td class="n">{{index .Appeals "forfeited"}}</td></tr>
+</table>
+{{with .Daily}}<h2>{{tr $.Locale "transparency.daily"}}</h2>
+<table>
+<tr><th>{{tr $.Locale "transparency.date"}}</th><th class="n">{{tr $.Locale "transparency.removed"}}</th><th class="n">{{tr $.Locale "transparency.bans"}}</th></tr>
+{{range .}}<tr><td>{{.Date}}</td><td class="n">{{index .Actions "delete"}}</td><td class="n">{{index .Actions "ban"}}</td></tr>
+{{end}}</table>{{end}}
+<p><a href="audit?chat_id={{.ChatID}}">{{tr .Locale "transparency.audit"}}</a></p>
+</body>
+</html>
+`
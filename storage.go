@@ -0,0 +1,294 @@
+package main
+
+import (
+	"time"
+
+	"spambot/storage"
+)
+
+// migrations are applied in order; never edit an entry once released, append a new one.
+// Keep statements portable between SQLite and Postgres: BIGINT ids, unix-second timestamps.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS spam_records (
+		chat_id BIGINT,
+		user_id BIGINT,
+		count INTEGER DEFAULT 0,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS leases (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS processed_updates (
+		bot_id BIGINT,
+		update_id BIGINT,
+		processed_at BIGINT NOT NULL,
+		PRIMARY KEY (bot_id, update_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS feature_flags (
+		chat_id BIGINT,
+		name TEXT,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (chat_id, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS bot_chats (
+		bot_id BIGINT,
+		chat_id BIGINT,
+		title TEXT,
+		type TEXT,
+		last_seen BIGINT NOT NULL,
+		PRIMARY KEY (bot_id, chat_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS chat_settings (
+		chat_id BIGINT,
+		key TEXT,
+		value TEXT NOT NULL,
+		PRIMARY KEY (chat_id, key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS topic_settings (
+		chat_id BIGINT,
+		thread_id BIGINT,
+		key TEXT,
+		value TEXT NOT NULL,
+		PRIMARY KEY (chat_id, thread_id, key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS pending_deletions (
+		bot_id BIGINT,
+		chat_id BIGINT,
+		message_id BIGINT,
+		delete_at BIGINT NOT NULL,
+		PRIMARY KEY (bot_id, chat_id, message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS chat_members (
+		chat_id BIGINT,
+		user_id BIGINT,
+		joined_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS message_reactions (
+		chat_id BIGINT,
+		message_id BIGINT,
+		user_id BIGINT,
+		reacted_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, message_id, user_id)
+	)`,
+	`ALTER TABLE chat_members ADD COLUMN message_count BIGINT NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS rules_messages (
+		bot_id BIGINT,
+		chat_id BIGINT,
+		message_id BIGINT NOT NULL,
+		posted_at BIGINT NOT NULL,
+		PRIMARY KEY (bot_id, chat_id)
+	)`,
+	`ALTER TABLE rules_messages ADD COLUMN own BOOLEAN NOT NULL DEFAULT TRUE`,
+	`CREATE TABLE IF NOT EXISTS suspects (
+		user_id BIGINT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		added_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wallet_challenges (
+		user_id BIGINT PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		nonce TEXT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wallet_links (
+		chat_id BIGINT,
+		user_id BIGINT,
+		address TEXT NOT NULL,
+		verified_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS token_gate (
+		chat_id BIGINT,
+		user_id BIGINT,
+		checked_at BIGINT NOT NULL,
+		failing_since BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		seq BIGINT PRIMARY KEY,
+		hash TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		published_tx TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS chat_addresses (
+		chat_id BIGINT,
+		address TEXT,
+		user_id BIGINT NOT NULL,
+		first_seen BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, address)
+	)`,
+	`CREATE TABLE IF NOT EXISTS wall_drafts (
+		user_id BIGINT PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wall_posts (
+		user_id BIGINT,
+		message_id BIGINT,
+		chat_id BIGINT NOT NULL,
+		author TEXT NOT NULL,
+		text TEXT NOT NULL,
+		status TEXT NOT NULL,
+		submitted_at BIGINT NOT NULL,
+		PRIMARY KEY (user_id, message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS reports (
+		chat_id BIGINT,
+		message_id BIGINT,
+		reporter_id BIGINT,
+		user_id BIGINT NOT NULL,
+		outcome TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, message_id, reporter_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS tips (
+		chat_id BIGINT,
+		message_id BIGINT,
+		reporter_id BIGINT,
+		admin_id BIGINT NOT NULL,
+		address TEXT NOT NULL,
+		octas BIGINT NOT NULL,
+		tx_hash TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, message_id, reporter_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS phishing_domains (
+		domain TEXT PRIMARY KEY,
+		feed TEXT NOT NULL,
+		synced_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS onchain_cursors (
+		chat_id BIGINT,
+		source TEXT,
+		version BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, source)
+	)`,
+	`CREATE TABLE IF NOT EXISTS phishing_feeds (
+		feed TEXT PRIMARY KEY,
+		etag TEXT NOT NULL,
+		domains BIGINT NOT NULL,
+		synced_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ban_bonds (
+		chat_id BIGINT,
+		user_id BIGINT,
+		octas BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		sender TEXT NOT NULL,
+		tx_hash TEXT NOT NULL,
+		payout_tx TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`ALTER TABLE wallet_links ADD COLUMN funder TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS event_modes (
+		chat_id BIGINT PRIMARY KEY,
+		bot_id BIGINT NOT NULL,
+		started_by BIGINT NOT NULL,
+		ends_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_captchas (
+		chat_id BIGINT,
+		user_id BIGINT,
+		bot_id BIGINT NOT NULL,
+		message_id BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS scam_photos (
+		chat_id BIGINT,
+		user_id BIGINT,
+		hash BIGINT NOT NULL,
+		shared BOOLEAN NOT NULL,
+		added_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS moderation_events (
+		chat_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		action TEXT NOT NULL,
+		rule TEXT NOT NULL,
+		at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS moderation_events_chat_at ON moderation_events (chat_id, at)`,
+	`CREATE TABLE IF NOT EXISTS digests (
+		chat_id BIGINT,
+		bot_id BIGINT,
+		sent_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, bot_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS moderation_counts (
+		chat_id BIGINT,
+		day BIGINT,
+		action TEXT,
+		count BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, day, action)
+	)`,
+	`CREATE TABLE IF NOT EXISTS disputes (
+		chat_id BIGINT,
+		message_id BIGINT,
+		user_id BIGINT NOT NULL,
+		reason TEXT NOT NULL,
+		text TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS action_retries (
+		bot_id BIGINT,
+		chat_id BIGINT,
+		action TEXT,
+		target BIGINT,
+		user_id BIGINT NOT NULL,
+		reason TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_at BIGINT NOT NULL,
+		PRIMARY KEY (bot_id, chat_id, action, target)
+	)`,
+	`CREATE TABLE IF NOT EXISTS action_intents (
+		intent_key TEXT PRIMARY KEY,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS rule_outcomes (
+		chat_id BIGINT,
+		rule TEXT,
+		removed INTEGER NOT NULL,
+		false_positives INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, rule)
+	)`,
+	`ALTER TABLE ban_bonds ADD COLUMN payout_state TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE ban_bonds ADD COLUMN payout_seq BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE ban_bonds ADD COLUMN payout_expires BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE tips ADD COLUMN manual BOOLEAN NOT NULL DEFAULT FALSE`,
+	// Tips recorded without a transaction were handed to admins to pay themselves
+	`UPDATE tips SET manual = TRUE WHERE tx_hash = ''`,
+}
+
+// Store is the bot's database: a storage.DB with the bot's schema, which the
+// query methods throughout this package run against
+type Store struct {
+	*storage.DB
+}
+
+// OpenDatabase opens the database and applies pending migrations.
+// driver is "sqlite" (dsn is a file path) or "postgres" (dsn is a connection URL).
+func OpenDatabase(driver, dsn string, timeout time.Duration) (*Store, error) {
+	db, err := storage.Open(driver, dsn, timeout)
+	if err != nil {
+		return nil, err
+	}
+	store := &Store{DB: db}
+	if _, err := store.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate applies pending schema migrations and returns how many were applied
+func (s *Store) Migrate() (int, error) {
+	return s.DB.Migrate(migrations)
+}
@@ -0,0 +1,143 @@
+// Package storage is the bot's database handle: SQLite or Postgres behind
+// one *sql.DB that rewrites `?` placeholders for the driver, bounds each
+// operation by a timeout and applies numbered schema migrations. The tables
+// and queries belong to its callers; this package knows none of them.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// DB wraps the database handle, rewriting `?` placeholders for the configured driver
+type DB struct {
+	*sql.DB
+	driver string
+	// Upper bound for a single storage operation
+	timeout time.Duration
+}
+
+// Open opens the database without migrating it. driver is "sqlite" (dsn is
+// a file path) or "postgres" (dsn is a connection URL); timeout bounds the
+// operations callers start with OpContext.
+func Open(driver, dsn string, timeout time.Duration) (*DB, error) {
+	sqlDriver := driver
+	switch driver {
+	case "sqlite":
+	case "postgres":
+		sqlDriver = "pgx"
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	db, err := sql.Open(sqlDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return &DB{DB: db, driver: driver, timeout: timeout}, nil
+}
+
+// Driver is "sqlite" or "postgres"
+func (db *DB) Driver() string {
+	return db.driver
+}
+
+// Rebind converts `?` placeholders to `$n` for Postgres
+func (db *DB) Rebind(query string) string {
+	if db.driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// OpContext bounds a storage operation by the configured timeout
+func (db *DB) OpContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, db.timeout)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Rebind(query), args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.Rebind(query), args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Rebind(query), args...)
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Rebind(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Rebind(query), args...)
+}
+
+// SchemaVersion returns the number of applied migrations
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// Migrate applies the migrations past the schema version, each in its own
+// transaction, and returns how many were applied. Migration n is
+// migrations[n-1]: append new ones, never edit a released one.
+func (db *DB) Migrate(migrations []string) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return applied, fmt.Errorf("failed to begin migration %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("failed to apply migration %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(db.Rebind(`INSERT INTO schema_version (version) VALUES (?)`), i+1); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("failed to record migration %d: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, fmt.Errorf("failed to commit migration %d: %v", i+1, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
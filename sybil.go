@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// linkedAccount is another Telegram account tied to a wallet being verified
+type linkedAccount struct {
+	UserID int64
+	// Whether it verified the same wallet rather than one with the same funder
+	SameWallet bool
+}
+
+// LinkedAccounts returns the accounts other than userID that verified address,
+// or a wallet first funded by funder, in any chat
+func (s *Store) LinkedAccounts(ctx context.Context, userID int64, address, funder string) ([]linkedAccount, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT user_id, address FROM wallet_links
+		WHERE user_id <> ? AND (address = ? OR (funder <> '' AND funder = ?))
+		ORDER BY verified_at
+	`, userID, address, funder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seen := make(map[int64]int)
+	var accounts []linkedAccount
+	for rows.Next() {
+		var id int64
+		var linked string
+		if err := rows.Scan(&id, &linked); err != nil {
+			return nil, err
+		}
+		same := linked == address
+		if i, ok := seen[id]; ok {
+			accounts[i].SameWallet = accounts[i].SameWallet || same
+			continue
+		}
+		seen[id] = len(accounts)
+		accounts = append(accounts, linkedAccount{UserID: id, SameWallet: same})
+	}
+	return accounts, rows.Err()
+}
+
+// checkSybil looks for other accounts that verified the same wallet or one
+// funded from the same source, flagging the user to the admins and putting
+// them on the suspect list. With sybil_policy reject or ban a reused wallet
+// isn't linked, and with ban the account is also banned from the chat; a
+// shared funder alone is only flagged, since friends fund each other too.
+// Returns the wallet's funding source to store and whether to link it.
+func (b *Bot) checkSybil(ctx context.Context, chatID int64, user tgbotapi.User, address string) (string, bool) {
+	policy := b.app.settings.Get(ctx, chatID, settingSybilPolicy)
+	if policy == "off" {
+		return "", true
+	}
+	ec := ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: user.ID}
+	var funder string
+	if b.app.aptos != nil && b.app.settings.Get(ctx, chatID, settingSybilFunding) == "on" {
+		var err error
+		if funder, err = b.app.aptos.FundingSource(ctx, address); err != nil {
+			b.logf("Failed to look up the funding source of %s: %v", address, err)
+			b.app.reporter.Failure("aptos.fundingSource", err, ec)
+		}
+	}
+	accounts, err := b.app.db.LinkedAccounts(ctx, user.ID, address, funder)
+	if err != nil {
+		b.logf("Failed to look up accounts linked to %s: %v", address, err)
+		b.app.reporter.Failure("db.linkedAccounts", err, ec)
+		return funder, true
+	}
+	if len(accounts) == 0 {
+		return funder, true
+	}
+
+	var wallet, funded []string
+	for _, account := range accounts {
+		if account.SameWallet {
+			wallet = append(wallet, fmt.Sprint(account.UserID))
+		} else {
+			funded = append(funded, fmt.Sprint(account.UserID))
+		}
+	}
+	var reasons []string
+	if len(wallet) > 0 {
+		reasons = append(reasons, "wallet also verified by "+strings.Join(wallet, ", "))
+	}
+	if len(funded) > 0 {
+		reasons = append(reasons, "wallet funded by "+funder+" like those of "+strings.Join(funded, ", "))
+	}
+	reason := strings.Join(reasons, "; ")
+	metrics.Add("sybil_accounts", 1)
+	if err := b.app.db.AddSuspect(ctx, user.ID, "sybil: "+reason); err != nil {
+		b.logf("Failed to add %d to the suspect list: %v", user.ID, err)
+	}
+
+	allowed, action := true, "sybil.flagged"
+	if len(wallet) > 0 {
+		switch policy {
+		case "reject":
+			allowed, action = false, "sybil.rejected"
+		case "ban":
+			allowed, action = false, "sybil.banned"
+			b.removeMember(ctx, chatID, user, true, "sybil: "+reason)
+		}
+	}
+	b.audit(ctx, "sybil", chatID, user.ID, 0, reason)
+	b.adminLog(ctx, chatID, b.tr(ctx, chatID, "sybil.notice",
+		user.FirstName, user.ID, address, reason, b.tr(ctx, chatID, action)))
+	return funder, allowed
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"spambot/telegram"
+)
+
+// newBotAPI creates a Bot API client for the configured server whose HTTP calls are
+// bounded by TELEGRAM_TIMEOUT. transport is the seam for a fake Bot API in
+// integration tests; nil uses a real HTTP client.
+func newBotAPI(token string, cfg *Config, transport tgbotapi.HTTPClient) (*tgbotapi.BotAPI, error) {
+	return telegram.NewBotAPI(token, cfg.apiEndpoint(), cfg.TelegramTimeout, transport)
+}
+
+// downloadFile fetches a file's content by file_id
+func (b *Bot) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := lookup(ctx, b.lookups, "getFile:"+fileID, func() (tgbotapi.File, error) {
+		return b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg := b.app.Config()
+	return telegram.DownloadFile(ctx, b.api, file, cfg.fileEndpoint(), cfg.TelegramLocalFiles)
+}
+
+// request performs a Bot API method that doesn't return a message, paced by the global limit
+func (b *Bot) request(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return withRateLimit(ctx, b.limiter, 0, func() (*tgbotapi.APIResponse, error) {
+		return b.api.Request(c)
+	})
+}
+
+// send sends a message-producing config and returns the sent message, paced by the
+// per-chat and global limits. Use b.outbox.enqueue when the result isn't needed.
+func (b *Bot) send(ctx context.Context, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return withRateLimit(ctx, b.limiter, telegram.ChatIDOf(c), func() (tgbotapi.Message, error) {
+		return b.api.Send(c)
+	})
+}
+
+// withRateLimit waits for a send slot, makes the call, and retries once if Telegram
+// answers with a flood-wait
+func withRateLimit[T any](ctx context.Context, limiter *rateLimiter, chatID int64, call func() (T, error)) (T, error) {
+	var zero T
+	if err := limiter.wait(ctx, chatID); err != nil {
+		return zero, err
+	}
+	value, err := telegram.CallWithContext(ctx, call)
+	if wait := telegram.RetryAfter(err); wait > 0 {
+		metrics.Add("flood_waits", 1)
+		if !sleepContext(ctx, wait) {
+			return zero, ctx.Err()
+		}
+		return telegram.CallWithContext(ctx, call)
+	}
+	return value, err
+}
+
+func (b *Bot) getChatMember(ctx context.Context, chatID, userID int64) (tgbotapi.ChatMember, error) {
+	return lookup(ctx, b.lookups, fmt.Sprintf("getChatMember:%d:%d", chatID, userID), func() (tgbotapi.ChatMember, error) {
+		return b.api.GetChatMember(tgbotapi.GetChatMemberConfig{
+			ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+				ChatID: chatID,
+				UserID: userID,
+			},
+		})
+	})
+}
+
+// getUpdates fetches updates as raw JSON so fields newer than tgbotapi survive
+func (b *Bot) getUpdates(ctx context.Context, config tgbotapi.UpdateConfig) ([]Update, error) {
+	resp, err := telegram.CallWithContext(ctx, func() (*tgbotapi.APIResponse, error) {
+		return b.api.Request(config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp.Result, &raw); err != nil {
+		return nil, err
+	}
+	updates := make([]Update, 0, len(raw))
+	for _, data := range raw {
+		update, err := parseUpdate(data)
+		if err != nil {
+			b.logf("Skipping undecodable update: %v", err)
+			continue
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// callRaw invokes a Bot API method by name, for calls tgbotapi has no config type for.
+// chatID (0 if none) selects the per-chat send pacing.
+func (b *Bot) callRaw(ctx context.Context, chatID int64, method string, params tgbotapi.Params) (*tgbotapi.APIResponse, error) {
+	return withRateLimit(ctx, b.limiter, chatID, func() (*tgbotapi.APIResponse, error) {
+		return b.api.MakeRequest(method, params)
+	})
+}
+
+// sleepContext waits for d, returning false if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
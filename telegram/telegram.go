@@ -0,0 +1,170 @@
+// Package telegram is the Bot API plumbing the bot is built on: a tgbotapi
+// client whose HTTP calls are bounded by a timeout, the endpoints of the
+// cloud, self-hosted and test servers, calls that give up with their
+// context, flood-wait handling and file downloads. What to do with updates
+// is up to the caller.
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pollTimeout is the extra time getUpdates gets for its long poll
+const pollTimeout = 60 * time.Second
+
+// MaxDownloadSize caps files pulled for analysis (the cloud API serves at most 20 MB)
+const MaxDownloadSize = 20 << 20
+
+// timeoutClient bounds every Bot API HTTP call; getUpdates gets extra room for its long poll
+type timeoutClient struct {
+	client      tgbotapi.HTTPClient
+	timeout     time.Duration
+	pollTimeout time.Duration
+}
+
+func (c *timeoutClient) Do(req *http.Request) (*http.Response, error) {
+	timeout := c.timeout
+	if strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		timeout += c.pollTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the request context once the response body is consumed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// NewBotAPI creates a Bot API client for endpoint (see APIEndpoint) whose
+// HTTP calls are bounded by timeout. transport is the seam for a fake Bot
+// API in tests; nil uses a real HTTP client.
+func NewBotAPI(token, endpoint string, timeout time.Duration, transport tgbotapi.HTTPClient) (*tgbotapi.BotAPI, error) {
+	if transport == nil {
+		transport = &http.Client{}
+	}
+	client := &timeoutClient{
+		client:      transport,
+		timeout:     timeout,
+		pollTimeout: pollTimeout,
+	}
+	return tgbotapi.NewBotAPIWithClient(token, endpoint, client)
+}
+
+// APIEndpoint returns the tgbotapi endpoint format (token, method) of the
+// server at apiURL, in its test environment if testEnv is set
+func APIEndpoint(apiURL string, testEnv bool) string {
+	return apiURL + "/bot%s/" + testPrefix(testEnv) + "%s"
+}
+
+// FileEndpoint returns the file download format (token, file path): fileURL
+// if set, else the server at apiURL's /file
+func FileEndpoint(apiURL, fileURL string, testEnv bool) string {
+	if fileURL != "" {
+		return fileURL + "/bot%s/" + testPrefix(testEnv) + "%s"
+	}
+	return apiURL + "/file/bot%s/" + testPrefix(testEnv) + "%s"
+}
+
+// testPrefix is the path segment that routes requests to the test environment
+func testPrefix(testEnv bool) string {
+	if testEnv {
+		return "test/"
+	}
+	return ""
+}
+
+// DownloadFile fetches the content of file through fileEndpoint (see
+// FileEndpoint). With a local Bot API server in --local mode (localFiles),
+// file_path is an absolute path on this machine and is read directly.
+func DownloadFile(ctx context.Context, api *tgbotapi.BotAPI, file tgbotapi.File, fileEndpoint string, localFiles bool) ([]byte, error) {
+	if file.FileSize > MaxDownloadSize {
+		return nil, fmt.Errorf("file too large (%d bytes)", file.FileSize)
+	}
+	if localFiles && filepath.IsAbs(file.FilePath) {
+		return os.ReadFile(file.FilePath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(fileEndpoint, api.Token, file.FilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download failed: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, MaxDownloadSize))
+}
+
+// CallWithContext runs a blocking Bot API call, returning early when ctx is done.
+// The call itself is bounded by the HTTP client timeout, so it can't linger forever.
+func CallWithContext[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// RetryAfter returns the flood-wait Telegram asked for, if err is a 429
+func RetryAfter(err error) time.Duration {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// ChatIDOf extracts the target chat of message-producing configs; 0 if unknown
+func ChatIDOf(c tgbotapi.Chattable) int64 {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID
+	case tgbotapi.PhotoConfig:
+		return v.ChatID
+	case tgbotapi.CopyMessageConfig:
+		return v.ChatID
+	case tgbotapi.ForwardConfig:
+		return v.ChatID
+	}
+	return 0
+}
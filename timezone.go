@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	// Embedded zone data, so timezone works on hosts and images without any
+	_ "time/tzdata"
+)
+
+// checkTimezone accepts IANA time zone names such as "Asia/Seoul"
+func checkTimezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("unknown time zone %q (use an IANA name such as Asia/Seoul)", value)
+	}
+	return nil
+}
+
+// Location is chatID's timezone, UTC if it is unknown
+func (s *ChatSettings) Location(ctx context.Context, chatID int64) *time.Location {
+	loc, err := time.LoadLocation(s.Get(ctx, chatID, settingTimezone))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Now is the current time in chatID's timezone
+func (s *ChatSettings) Now(ctx context.Context, chatID int64) time.Time {
+	return time.Now().In(s.Location(ctx, chatID))
+}
+
+// dayStart is midnight of t's day in t's location
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// localDay numbers t's day in t's location like Unix time / 86400 numbers UTC
+// days, so daily totals are bucketed by the chat's day
+func localDay(t time.Time) int64 {
+	_, offset := t.Zone()
+	return (t.Unix() + int64(offset)) / 86400
+}
+
+// zoneOffset is t's offset from UTC in seconds, for bucketing stored Unix
+// times by local day in SQL; a span crossing a DST change is bucketed with
+// the offset at its start
+func zoneOffset(t time.Time) int64 {
+	_, offset := t.Zone()
+	return int64(offset)
+}
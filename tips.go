@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// octasPerAPT is the number of octas, APT's smallest unit, in one APT
+const octasPerAPT = 100_000_000
+
+// aptTransferFunction moves APT to any address, creating the account if needed
+const aptTransferFunction = "0x1::aptos_account::transfer"
+
+// parseAPT converts a decimal APT amount such as "0.5" to octas without float rounding
+func parseAPT(amount string) (uint64, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	if len(frac) > 8 {
+		return 0, fmt.Errorf("APT has at most 8 decimals")
+	}
+	w, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil && whole != "" {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	var f uint64
+	if frac != "" {
+		if f, err = strconv.ParseUint(frac+strings.Repeat("0", 8-len(frac)), 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid amount %q", amount)
+		}
+	}
+	octas := w*octasPerAPT + f
+	if octas == 0 || w > (1<<64-1)/octasPerAPT {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	return octas, nil
+}
+
+// formatAPT renders octas as a decimal APT amount
+func formatAPT(octas uint64) string {
+	frac := strings.TrimRight(fmt.Sprintf("%08d", octas%octasPerAPT), "0")
+	if frac == "" {
+		return strconv.FormatUint(octas/octasPerAPT, 10)
+	}
+	return strconv.FormatUint(octas/octasPerAPT, 10) + "." + frac
+}
+
+// manualTipTTL is how long a tip admins pay from their own wallet waits for
+// its transaction before the report can be tipped again
+const manualTipTTL = 24 * time.Hour
+
+// TippableReport returns a report by reporterID in chatID that led to a ban
+// and has not been tipped yet, or whose manual tip was never paid; ok is
+// false if there is none
+func (s *Store) TippableReport(ctx context.Context, chatID, reporterID int64) (messageID int, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `
+		SELECT r.message_id FROM reports r
+		LEFT JOIN tips t ON t.chat_id = r.chat_id AND t.message_id = r.message_id AND t.reporter_id = r.reporter_id
+		WHERE r.chat_id = ? AND r.reporter_id = ? AND r.outcome = 'banned'
+			AND (t.reporter_id IS NULL OR (t.manual AND t.tx_hash = '' AND t.created_at < ?))
+		ORDER BY r.created_at DESC LIMIT 1
+	`, chatID, reporterID, time.Now().Add(-manualTipTTL).Unix()).Scan(&messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return messageID, err == nil, err
+}
+
+// RecordTip adds a tip for a report to the ledger, replacing a manual tip
+// that was never paid. manual tips are sent by the admin from their own
+// wallet and wait for ConfirmTip with an empty txHash. False means the report
+// was already tipped.
+func (s *Store) RecordTip(ctx context.Context, chatID int64, messageID int, reporterID, adminID int64, address string, octas uint64, txHash string, manual bool) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	now := time.Now()
+	res, err := s.ExecContext(ctx, `
+		INSERT INTO tips (chat_id, message_id, reporter_id, admin_id, address, octas, tx_hash, manual, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, message_id, reporter_id) DO UPDATE SET
+			admin_id = excluded.admin_id, address = excluded.address, octas = excluded.octas,
+			tx_hash = excluded.tx_hash, manual = excluded.manual, created_at = excluded.created_at
+		WHERE tips.manual AND tips.tx_hash = '' AND tips.created_at < ?
+	`, chatID, messageID, reporterID, adminID, address, int64(octas), txHash, manual, now.Unix(), now.Add(-manualTipTTL).Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// manualTip is a tip waiting for the admin's transaction
+type manualTip struct {
+	MessageID int
+	Address   string
+	Octas     uint64
+	Created   time.Time
+}
+
+// ManualTip returns the latest manual tip to reporterID in chatID that is
+// still waiting for its transaction; ok is false if there is none
+func (s *Store) ManualTip(ctx context.Context, chatID, reporterID int64) (tip manualTip, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var octas, created int64
+	err = s.QueryRowContext(ctx, `
+		SELECT message_id, address, octas, created_at FROM tips
+		WHERE chat_id = ? AND reporter_id = ? AND manual AND tx_hash = '' AND created_at >= ?
+		ORDER BY created_at DESC LIMIT 1
+	`, chatID, reporterID, time.Now().Add(-manualTipTTL).Unix()).Scan(&tip.MessageID, &tip.Address, &octas, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tip, false, nil
+	}
+	tip.Octas, tip.Created = uint64(octas), time.Unix(created, 0)
+	return tip, err == nil, err
+}
+
+// ConfirmTip records the transaction that paid a manual tip. False means the
+// tip was confirmed meanwhile or the transaction already paid another tip.
+func (s *Store) ConfirmTip(ctx context.Context, chatID int64, messageID int, reporterID int64, txHash string) (bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `
+		UPDATE tips SET tx_hash = ?
+		WHERE chat_id = ? AND message_id = ? AND reporter_id = ? AND manual AND tx_hash = ''
+			AND NOT EXISTS (SELECT 1 FROM tips t WHERE t.tx_hash = ?)
+	`, txHash, chatID, messageID, reporterID, txHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SetTipTransaction stores the transaction that paid a tip
+func (s *Store) SetTipTransaction(ctx context.Context, chatID int64, messageID int, reporterID int64, txHash string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `UPDATE tips SET tx_hash = ? WHERE chat_id = ? AND message_id = ? AND reporter_id = ?`,
+		txHash, chatID, messageID, reporterID)
+	return err
+}
+
+// WalletTipsSince sums the tips paid, or being paid, from the tip wallet in
+// chatID since the given time; tips admins sent themselves don't count
+func (s *Store) WalletTipsSince(ctx context.Context, chatID int64, since time.Time) (uint64, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var octas int64
+	err := s.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(octas), 0) FROM tips WHERE chat_id = ? AND NOT manual AND created_at >= ?
+	`, chatID, since.Unix()).Scan(&octas)
+	return uint64(octas), err
+}
+
+// DeleteTip removes a tip whose transfer failed, so the report can be tipped again
+func (s *Store) DeleteTip(ctx context.Context, chatID int64, messageID int, reporterID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `DELETE FROM tips WHERE chat_id = ? AND message_id = ? AND reporter_id = ?`,
+		chatID, messageID, reporterID)
+	return err
+}
+
+// cmdTip rewards a member whose /report led to a ban: "/tip <APT>" in reply to
+// one of their messages, or "/tip <user id> <APT>". With TIP_WALLET_KEY and
+// the owner-only tips flag on for the chat, the bot sends the APT itself, up
+// to TIP_MAX_APT a tip and TIP_DAILY_APT a day; otherwise it answers with the
+// transfer for the admin to submit from their own wallet and confirm with
+// "/tip paid <transaction hash>".
+func (b *Bot) cmdTip(ctx context.Context, message *Message) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) > 0 && args[0] == "paid" {
+		b.cmdTipPaid(ctx, message, args[1:])
+		return
+	}
+	reporterID, args, ok := tipReporter(message, args, 1)
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "tip.usage"))
+		return
+	}
+	octas, err := parseAPT(args[0])
+	if err != nil {
+		b.reply(message, err.Error())
+		return
+	}
+
+	reportID, ok, err := b.app.db.TippableReport(ctx, message.Chat.ID, reporterID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "tip.reports_failed", err))
+		return
+	}
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "tip.no_report"))
+		return
+	}
+	address, err := b.app.db.Wallet(ctx, message.Chat.ID, reporterID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "tip.wallet_failed", err))
+		return
+	}
+	if address == "" {
+		b.reply(message, b.trFor(ctx, message, "tip.no_wallet"))
+		return
+	}
+
+	transfer := []interface{}{address, strconv.FormatUint(octas, 10)}
+	if b.app.tipper == nil || !b.app.flags.Enabled(ctx, message.Chat.ID, flagTips) {
+		// No hot wallet: hand the admin the transaction to sign in their own wallet
+		payload, _ := json.MarshalIndent(map[string]interface{}{
+			"function":       aptTransferFunction,
+			"type_arguments": []string{},
+			"arguments":      transfer,
+		}, "", "  ")
+		// Claimed until it is paid or manualTipTTL passes, so two admins can't both tip it
+		ok, err := b.app.db.RecordTip(ctx, message.Chat.ID, reportID, reporterID, message.From.ID, address, octas, "", true)
+		if err != nil {
+			b.reply(message, b.trFor(ctx, message, "tip.record_failed", err))
+			return
+		}
+		if !ok {
+			b.reply(message, b.trFor(ctx, message, "tip.already"))
+			return
+		}
+		b.reply(message, b.trFor(ctx, message, "tip.payload", formatAPT(octas), address, payload, int(manualTipTTL.Hours())))
+		return
+	}
+
+	cfg := b.app.Config()
+	if octas > cfg.TipMax {
+		b.reply(message, b.trFor(ctx, message, "tip.over_max", formatAPT(cfg.TipMax)))
+		return
+	}
+	// Claim the report before paying so two admins can't both tip it
+	if ok, err := b.app.db.RecordTip(ctx, message.Chat.ID, reportID, reporterID, message.From.ID, address, octas, "pending", false); err != nil || !ok {
+		b.reply(message, b.trFor(ctx, message, "tip.already"))
+		return
+	}
+	// Counted with this tip claimed, so admins tipping at once can't both slip under the cap
+	sent, err := b.app.db.WalletTipsSince(ctx, message.Chat.ID, time.Now().Add(-24*time.Hour))
+	if err != nil || sent > cfg.TipDailyCap {
+		b.app.db.DeleteTip(ctx, message.Chat.ID, reportID, reporterID)
+		if err != nil {
+			b.reply(message, b.trFor(ctx, message, "tip.record_failed", err))
+		} else {
+			b.reply(message, b.trFor(ctx, message, "tip.over_daily", formatAPT(cfg.TipDailyCap)))
+		}
+		return
+	}
+	txHash, err := b.app.aptos.Submit(ctx, b.app.tipper, aptTransferFunction, transfer)
+	if err != nil {
+		b.logf("Failed to send tip to %s: %v", address, err)
+		b.app.reporter.Failure("aptos.tip", err, b.errorContext(message.Message))
+		b.app.db.DeleteTip(ctx, message.Chat.ID, reportID, reporterID)
+		b.reply(message, b.trFor(ctx, message, "tip.send_failed", err))
+		return
+	}
+	b.app.db.SetTipTransaction(ctx, message.Chat.ID, reportID, reporterID, txHash)
+	metrics.Add("tips_sent", 1)
+	b.reply(message, b.trFor(ctx, message, "tip.sent", formatAPT(octas), address, txHash))
+}
+
+// tipReporter reads whom a tip command is about: the author of the replied-to
+// message, with want arguments left, or a user id before them
+func tipReporter(message *Message, args []string, want int) (int64, []string, bool) {
+	switch {
+	case len(args) == want && message.ReplyToMessage != nil && message.ReplyToMessage.From != nil:
+		return message.ReplyToMessage.From.ID, args, true
+	case len(args) == want+1:
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		return id, args[1:], err == nil
+	}
+	return 0, nil, false
+}
+
+// cmdTipPaid confirms a tip the admin sent from their own wallet: "/tip paid
+// <transaction hash>" in reply to the reporter, or "/tip paid <user id>
+// <transaction hash>". The transaction must pay the tip to the reporter's
+// wallet after it was claimed.
+func (b *Bot) cmdTipPaid(ctx context.Context, message *Message, args []string) {
+	reporterID, args, ok := tipReporter(message, args, 1)
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "tip.usage"))
+		return
+	}
+	hash := args[0]
+	tip, ok, err := b.app.db.ManualTip(ctx, message.Chat.ID, reporterID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "tip.reports_failed", err))
+		return
+	}
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "tip.not_awaiting"))
+		return
+	}
+
+	txn, found, err := b.app.aptos.Transaction(ctx, hash)
+	if err != nil {
+		b.logf("Failed to look up tip transaction %s: %v", hash, err)
+		b.reply(message, b.trFor(ctx, message, "tip.txn_failed", err))
+		return
+	}
+	if !found {
+		b.reply(message, b.trFor(ctx, message, "tip.txn_pending"))
+		return
+	}
+	var recipient string
+	var amount uint64
+	if len(txn.Arguments) == 2 {
+		recipient, _ = txn.Arguments[0].(string)
+		if s, ok := txn.Arguments[1].(string); ok {
+			amount, _ = strconv.ParseUint(s, 10, 64)
+		}
+	}
+	if !txn.Success || txn.Function != aptTransferFunction || recipient == "" ||
+		normalizeAddress(recipient) != normalizeAddress(tip.Address) || amount < tip.Octas || txn.Timestamp.Before(tip.Created) {
+		b.reply(message, b.trFor(ctx, message, "tip.txn_mismatch", formatAPT(tip.Octas), tip.Address))
+		return
+	}
+
+	confirmed, err := b.app.db.ConfirmTip(ctx, message.Chat.ID, tip.MessageID, reporterID, hash)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "tip.record_failed", err))
+		return
+	}
+	if !confirmed {
+		b.reply(message, b.trFor(ctx, message, "tip.txn_used"))
+		return
+	}
+	metrics.Add("tips_sent", 1)
+	b.reply(message, b.trFor(ctx, message, "tip.sent", formatAPT(amount), tip.Address, hash))
+}
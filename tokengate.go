@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tokenGateCheckInterval is how often gated chats are scanned for members due a re-check
+const tokenGateCheckInterval = time.Hour
+
+// MembersDueGateCheck lists members of chatID not checked since before
+func (s *Store) MembersDueGateCheck(ctx context.Context, chatID int64, before time.Time) ([]int64, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	rows, err := s.QueryContext(ctx, `
+		SELECT m.user_id FROM chat_members m
+		LEFT JOIN token_gate g ON g.chat_id = m.chat_id AND g.user_id = m.user_id
+		WHERE m.chat_id = ? AND (g.checked_at IS NULL OR g.checked_at < ?)
+	`, chatID, before.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		users = append(users, id)
+	}
+	return users, rows.Err()
+}
+
+// RecordGateCheck stores the outcome of a balance check and returns since when
+// the member has been failing it (zero if they passed), and whether this check
+// started it. Failing again doesn't restart the grace period, nor does leaving
+// and rejoining.
+func (s *Store) RecordGateCheck(ctx context.Context, chatID, userID int64, passed bool) (time.Time, bool, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	now := time.Now().Unix()
+	var failingSince int64
+	if !passed {
+		failingSince = now
+	}
+	if _, err := s.ExecContext(ctx, `
+		INSERT INTO token_gate (chat_id, user_id, checked_at, failing_since) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET checked_at = excluded.checked_at,
+			failing_since = CASE WHEN excluded.failing_since = 0 OR token_gate.failing_since = 0
+				THEN excluded.failing_since ELSE token_gate.failing_since END
+	`, chatID, userID, now, failingSince); err != nil {
+		return time.Time{}, false, err
+	}
+	if passed {
+		return time.Time{}, false, nil
+	}
+	err := s.QueryRowContext(ctx, `SELECT failing_since FROM token_gate WHERE chat_id = ? AND user_id = ?`,
+		chatID, userID).Scan(&failingSince)
+	return time.Unix(failingSince, 0), failingSince == now, err
+}
+
+// gated reports whether chatID requires a token balance or an NFT to stay
+func (b *Bot) gated(ctx context.Context, chatID int64) bool {
+	return b.app.aptos != nil && (b.app.settings.Get(ctx, chatID, settingTokenGateMin) != "0" ||
+		b.app.settings.Get(ctx, chatID, settingNFTGateCollection) != "")
+}
+
+// meetsGate reports whether userID's verified wallet holds the chat's
+// token_gate_min of token_gate_coin and an NFT from nft_gate_collection,
+// whichever are configured; members without a wallet don't
+func (b *Bot) meetsGate(ctx context.Context, chatID, userID int64) (bool, error) {
+	address, err := b.app.db.Wallet(ctx, chatID, userID)
+	if err != nil || address == "" {
+		return false, err
+	}
+	if min, _ := strconv.ParseUint(b.app.settings.Get(ctx, chatID, settingTokenGateMin), 10, 64); min > 0 {
+		balance, err := b.app.aptos.Balance(ctx, address, b.app.settings.Get(ctx, chatID, settingTokenGateCoin))
+		if err != nil || balance < min {
+			return false, err
+		}
+	}
+	if collection := b.app.settings.Get(ctx, chatID, settingNFTGateCollection); collection != "" {
+		return b.app.aptos.OwnsCollectionToken(ctx, address, collection)
+	}
+	return true, nil
+}
+
+// gateRequirement describes what a gated chat asks its members to hold
+func (b *Bot) gateRequirement(ctx context.Context, chatID int64) string {
+	var needs []string
+	if min := b.app.settings.Get(ctx, chatID, settingTokenGateMin); min != "0" {
+		needs = append(needs, b.tr(ctx, chatID, "gate.need_tokens", min, b.app.settings.Get(ctx, chatID, settingTokenGateCoin)))
+	}
+	if collection := b.app.settings.Get(ctx, chatID, settingNFTGateCollection); collection != "" {
+		needs = append(needs, b.tr(ctx, chatID, "gate.need_nft", collection))
+	}
+	return strings.Join(needs, b.tr(ctx, chatID, "gate.and"))
+}
+
+// gateMember checks one member against the chat's token/NFT gate, removing them
+// once they have failed it for longer than token_gate_grace_hours. Returns
+// true if the member failed for the first time, i.e. their grace period just began.
+func (b *Bot) gateMember(ctx context.Context, chatID int64, member tgbotapi.User) bool {
+	if !b.gated(ctx, chatID) {
+		return false
+	}
+	passed, err := b.meetsGate(ctx, chatID, member.ID)
+	if err != nil {
+		// Don't start anyone's grace period because the node is unreachable
+		b.logf("Failed to check token gate for %d in chat %d: %v", member.ID, chatID, err)
+		b.app.reporter.Failure("aptos.balance", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: member.ID})
+		return false
+	}
+	failingSince, started, err := b.app.db.RecordGateCheck(ctx, chatID, member.ID, passed)
+	if err != nil {
+		b.logf("Failed to record token gate check of %d in chat %d: %v", member.ID, chatID, err)
+		return false
+	}
+	if passed {
+		return false
+	}
+	grace, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingTokenGateGraceHours))
+	if time.Since(failingSince) >= time.Duration(grace)*time.Hour {
+		b.removeMember(ctx, chatID, member, false, "doesn't meet the token gate")
+		metrics.Add("token_gate_removed", 1)
+		return false
+	}
+	return started
+}
+
+// gateNewMember checks a member who just joined and tells them how to keep their seat
+func (b *Bot) gateNewMember(ctx context.Context, message *Message, member tgbotapi.User) {
+	if !b.gateMember(ctx, message.Chat.ID, member) {
+		return
+	}
+	grace, _ := strconv.Atoi(b.app.settings.Get(ctx, message.Chat.ID, settingTokenGateGraceHours))
+	sent, err := b.send(ctx, htmlMessage(message.Chat.ID, b.tr(ctx, message.Chat.ID, "gate.notice",
+		mention(member), html.EscapeString(b.gateRequirement(ctx, message.Chat.ID)), grace, html.EscapeString(b.verifyLink(message.Chat.ID)))))
+	if err != nil {
+		return
+	}
+	b.deleteNoticeLater(ctx, message.Chat.ID, sent.MessageID)
+}
+
+// recheckTokenGates re-checks members of every gated chat once per token_gate_recheck_hours
+func (b *Bot) recheckTokenGates(ctx context.Context) {
+	if b.app.aptos == nil {
+		return
+	}
+	chats, err := b.app.db.KnownChats(ctx, b.api.Self.ID)
+	if err != nil {
+		b.logf("Failed to list chats for token gate checks: %v", err)
+		return
+	}
+	for _, chat := range chats {
+		if !b.gated(ctx, chat.ID) {
+			continue
+		}
+		hours, _ := strconv.Atoi(b.app.settings.Get(ctx, chat.ID, settingTokenGateRecheckHours))
+		users, err := b.app.db.MembersDueGateCheck(ctx, chat.ID, time.Now().Add(-time.Duration(hours)*time.Hour))
+		if err != nil {
+			b.logf("Failed to list members due a token gate check in chat %d: %v", chat.ID, err)
+			continue
+		}
+		for _, userID := range users {
+			b.gateMember(ctx, chat.ID, tgbotapi.User{ID: userID})
+		}
+	}
+}
+
+// runTokenGates re-checks token-gated chats until ctx is cancelled
+func (b *Bot) runTokenGates(ctx context.Context) {
+	for sleepContext(ctx, tokenGateCheckInterval) {
+		b.recheckTokenGates(ctx)
+	}
+}
+
+// maybeRecheckTokenGates runs the re-check at most once per tokenGateCheckInterval,
+// for webhook mode where no background loop runs
+func (b *Bot) maybeRecheckTokenGates(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastTokenGates.Load()
+	if now-last < int64(tokenGateCheckInterval/time.Second) || !b.lastTokenGates.CompareAndSwap(last, now) {
+		return
+	}
+	b.recheckTokenGates(ctx)
+}
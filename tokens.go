@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coinTypePattern matches Move coin types such as 0x1::aptos_coin::AptosCoin
+var coinTypePattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{1,64}::[A-Za-z_][A-Za-z0-9_]*::[A-Za-z_][A-Za-z0-9_]*\b`)
+
+// tokenCacheTTL is how long a contract lookup is reused
+const tokenCacheTTL = time.Hour
+
+// tokenCacheSize bounds the contracts whose lookups are kept
+const tokenCacheSize = 10000
+
+// tokenCache remembers fullnode lookups so a contract shilled in many messages
+// is looked up once
+type tokenCache struct {
+	entries *lruCache[string, contractInfo]
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{entries: newLRUCache[string, contractInfo]("tokens", tokenCacheSize, tokenCacheTTL)}
+}
+
+// lookup returns the cached info for key, or fetches and caches it
+func (c *tokenCache) lookup(key string, fetch func() (contractInfo, error)) (contractInfo, error) {
+	if info, ok := c.entries.get(key); ok {
+		return info, nil
+	}
+	info, err := fetch()
+	if err != nil {
+		return info, err
+	}
+	c.entries.put(key, info)
+	return info, nil
+}
+
+// tokenInfo looks up a coin type ("0x..::module::Name") or contract address
+func (b *Bot) tokenInfo(ctx context.Context, ref string) (contractInfo, error) {
+	return b.app.tokens.lookup(ref, func() (contractInfo, error) {
+		address, _, isCoin := strings.Cut(ref, "::")
+		info, err := b.app.aptos.Contract(ctx, normalizeAddress(address))
+		if err != nil || !isCoin {
+			return info, err
+		}
+		info.Name, info.Symbol, _, err = b.app.aptos.CoinInfo(ctx, ref)
+		return info, err
+	})
+}
+
+// describeToken renders a lookup for the admin log
+func describeToken(ref string, info contractInfo) string {
+	var parts []string
+	if info.Symbol != "" {
+		parts = append(parts, fmt.Sprintf("%s (%s)", info.Name, info.Symbol))
+	}
+	if !info.Deployed.IsZero() {
+		parts = append(parts, "deployed "+time.Since(info.Deployed).Round(time.Minute).String()+" ago")
+	}
+	if len(parts) == 0 {
+		return ref
+	}
+	return ref + ": " + strings.Join(parts, ", ")
+}
+
+// applyTokenChecks looks up coin types and contract addresses in a message and
+// applies new_token_policy to contracts younger than new_token_hours. Findings
+// go to the admin log either way. Coin types from APTOS_SCAM_ADDRESSES follow
+// scam_address_policy. Returns true when the message was handled.
+func (b *Bot) applyTokenChecks(ctx context.Context, message *Message, text string) bool {
+	if b.app.aptos == nil {
+		return false
+	}
+	refs := coinTypePattern.FindAllString(text, -1)
+	for _, address := range messageAddresses(text) {
+		covered := false
+		for _, ref := range refs {
+			covered = covered || strings.HasPrefix(normalizeAddress(strings.Split(ref, "::")[0]), address)
+		}
+		if !covered {
+			refs = append(refs, address)
+		}
+	}
+	if len(refs) == 0 {
+		return false
+	}
+
+	hours, _ := strconv.Atoi(b.setting(ctx, message, settingNewTokenHours))
+	scamList := b.app.Config().AptosScamAddresses
+	for _, ref := range refs {
+		address, _, _ := strings.Cut(ref, "::")
+		for _, scam := range scamList {
+			if normalizeAddress(scam) == normalizeAddress(address) {
+				b.adminLog(ctx, message.Chat.ID, b.trFor(ctx, message, "token.scam_listed", message.From.FirstName, ref))
+				return b.enforcePolicy(ctx, message, settingScamAddressPolicy, "known scam contract "+ref)
+			}
+		}
+		info, err := b.tokenInfo(ctx, ref)
+		if err != nil {
+			b.logf("Failed to look up %s: %v", ref, err)
+			continue
+		}
+		if !info.Contract {
+			continue
+		}
+		finding := describeToken(ref, info)
+		if hours > 0 && !info.Deployed.IsZero() && time.Since(info.Deployed) < time.Duration(hours)*time.Hour {
+			metrics.Add("new_tokens_flagged", 1)
+			b.adminLog(ctx, message.Chat.ID, b.trFor(ctx, message, "token.fresh", message.From.FirstName, finding))
+			if b.enforcePolicy(ctx, message, settingNewTokenPolicy, "freshly deployed contract "+ref) {
+				return true
+			}
+			continue
+		}
+		b.adminLog(ctx, message.Chat.ID, b.trFor(ctx, message, "token.posted", message.From.FirstName, finding))
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Update is a Telegram update: everything tgbotapi parses, plus newer Bot API
+// fields it predates (decoded from the same JSON into ext)
+type Update struct {
+	tgbotapi.Update
+	ext updateExt
+}
+
+type updateExt struct {
+	Message              *messageExt                  `json:"message"`
+	EditedMessage        *messageExt                  `json:"edited_message"`
+	MessageReaction      *messageReactionUpdated      `json:"message_reaction"`
+	MessageReactionCount *messageReactionCountUpdated `json:"message_reaction_count"`
+	BusinessConnection   *businessConnection          `json:"business_connection"`
+	BusinessMessage      *businessMessage             `json:"business_message"`
+}
+
+// allowedUpdates are the update types requested from Telegram. Reactions and
+// chat_member (promotions, for the admin cache) are only delivered when asked
+// for explicitly, so the list must name every type handled.
+var allowedUpdates = []string{
+	"message", "edited_message", "callback_query", "my_chat_member", "chat_member", "chat_join_request",
+	"message_reaction", "message_reaction_count",
+	"business_connection", "business_message",
+}
+
+// messageExt holds message fields missing from tgbotapi.Message
+type messageExt struct {
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+	// Quoted part of the replied-to message, which may live in another chat
+	Quote *textQuote `json:"quote"`
+	// Replied-to message from another chat or topic
+	ExternalReply *externalReplyInfo `json:"external_reply"`
+	// Giveaway announcements and results, usually forwarded from a channel
+	Giveaway        *giveaway `json:"giveaway"`
+	GiveawayWinners *giveaway `json:"giveaway_winners"`
+	// Origin of a forwarded message; Bot API 7.0 replaced the forward_* fields with it
+	ForwardOrigin *json.RawMessage `json:"forward_origin"`
+	// Media unlocked by paying Telegram Stars
+	PaidMedia *paidMediaInfo `json:"paid_media"`
+	// Service messages for chat boosts and gifts
+	BoostAdded *chatBoostAdded  `json:"boost_added"`
+	Gift       *json.RawMessage `json:"gift"`
+	UniqueGift *json.RawMessage `json:"unique_gift"`
+}
+
+type chatBoostAdded struct {
+	BoostCount int `json:"boost_count"`
+}
+
+// isBoostOrGift reports whether the message is a boost or gift service message
+func (e *messageExt) isBoostOrGift() bool {
+	return e.BoostAdded != nil || e.Gift != nil || e.UniqueGift != nil
+}
+
+// giveaway covers the parts of giveaway and giveaway_winners messages we use
+type giveaway struct {
+	Chats            []tgbotapi.Chat `json:"chats"`
+	PrizeDescription string          `json:"prize_description"`
+}
+
+type paidMediaInfo struct {
+	StarCount int `json:"star_count"`
+}
+
+type textQuote struct {
+	Text string `json:"text"`
+}
+
+// externalReplyInfo holds the scannable parts of a message replied to across chats
+type externalReplyInfo struct {
+	Poll    *tgbotapi.Poll    `json:"poll"`
+	Venue   *tgbotapi.Venue   `json:"venue"`
+	Contact *tgbotapi.Contact `json:"contact"`
+}
+
+// parseUpdate decodes one update in both representations
+func parseUpdate(data []byte) (Update, error) {
+	var u Update
+	if err := json.Unmarshal(data, &u.Update); err != nil {
+		return u, err
+	}
+	if err := json.Unmarshal(data, &u.ext); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+// Message is an incoming message together with its extended fields
+type Message struct {
+	*tgbotapi.Message
+	ext *messageExt
+}
+
+func wrapMessage(message *tgbotapi.Message, ext *messageExt) *Message {
+	if ext == nil {
+		ext = &messageExt{}
+	}
+	return &Message{Message: message, ext: ext}
+}
+
+// ThreadID is the forum topic the message belongs to, or 0 outside forum topics
+func (m *Message) ThreadID() int {
+	if m.ext.IsTopicMessage {
+		return m.ext.MessageThreadID
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// voiceWindow is the period voice_rate_limit counts over
+const voiceWindow = time.Hour
+
+// voiceLimiter counts recent voice messages and video notes per chat member
+type voiceLimiter struct {
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+func newVoiceLimiter() *voiceLimiter {
+	return &voiceLimiter{sent: make(map[string][]time.Time)}
+}
+
+// allow records one message and reports whether the member is still within limit
+func (l *voiceLimiter) allow(chatID, userID int64, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for k, times := range l.sent {
+		if now.Sub(times[len(times)-1]) > voiceWindow {
+			delete(l.sent, k)
+		}
+	}
+	key := fmt.Sprintf("%d:%d", chatID, userID)
+	var recent []time.Time
+	for _, t := range l.sent[key] {
+		if now.Sub(t) <= voiceWindow {
+			recent = append(recent, t)
+		}
+	}
+	l.sent[key] = append(recent, now)
+	return len(recent) < limit
+}
+
+// CountMessage bumps the message count of a member who joined after since;
+// established members aren't tracked
+func (s *Store) CountMessage(ctx context.Context, chatID, userID int64, since time.Time) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		UPDATE chat_members SET message_count = message_count + 1
+		WHERE chat_id = ? AND user_id = ? AND joined_at >= ?
+	`, chatID, userID, since.Unix())
+	return err
+}
+
+// MemberActivity returns when a tracked member joined and how many messages they sent since
+func (s *Store) MemberActivity(ctx context.Context, chatID, userID int64) (joined time.Time, messages int, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var unix int64
+	err = s.QueryRowContext(ctx, `SELECT joined_at, message_count FROM chat_members WHERE chat_id = ? AND user_id = ?`,
+		chatID, userID).Scan(&unix, &messages)
+	if err == sql.ErrNoRows {
+		return time.Time{}, 0, false, nil
+	}
+	if err != nil {
+		return time.Time{}, 0, false, err
+	}
+	return time.Unix(unix, 0), messages, true, nil
+}
+
+// newMemberWindow is how long after joining a member counts as low-reputation
+func (b *Bot) newMemberWindow(ctx context.Context, chatID int64) time.Duration {
+	hours, _ := strconv.Atoi(b.app.settings.Get(ctx, chatID, settingNewMemberHours))
+	return time.Duration(hours) * time.Hour
+}
+
+// countActivity records a group message for the sender's reputation
+func (b *Bot) countActivity(ctx context.Context, message *Message) {
+	since := time.Now().Add(-b.newMemberWindow(ctx, message.Chat.ID))
+	if err := b.app.db.CountMessage(ctx, message.Chat.ID, message.From.ID, since); err != nil {
+		b.logf("Failed to count message from %d in chat %d: %v", message.From.ID, message.Chat.ID, err)
+	}
+}
+
+// applyVoicePolicy restricts voice messages and video notes, which carry no text
+// to scan, from low-reputation members: recent joiners and users with spam strikes.
+// Returns true when the message was removed.
+func (b *Bot) applyVoicePolicy(ctx context.Context, message *Message) bool {
+	if message.Voice == nil && message.VideoNote == nil {
+		return false
+	}
+	policy := b.setting(ctx, message, settingVoicePolicy)
+	if policy == "allow" {
+		return false
+	}
+
+	chatID, userID := message.Chat.ID, message.From.ID
+	joined, messages, tracked, err := b.app.db.MemberActivity(ctx, chatID, userID)
+	if err != nil {
+		b.logf("Failed to look up member %d in chat %d: %v", userID, chatID, err)
+		return false
+	}
+	lowRep := tracked && time.Since(joined) < b.newMemberWindow(ctx, chatID)
+	if !lowRep {
+		strikes, err := b.app.detector.SpamCount(ctx, chatID, userID)
+		lowRep = err == nil && strikes > 0
+	}
+	if !lowRep {
+		return false
+	}
+
+	switch policy {
+	case "first_message":
+		if tracked && messages == 0 {
+			b.deleteMessage(ctx, message, "voice or video note as first message")
+			return true
+		}
+	case "limit":
+		limit, _ := strconv.Atoi(b.setting(ctx, message, settingVoiceRateLimit))
+		if !b.voice.allow(chatID, userID, limit) {
+			b.deleteMessage(ctx, message, "voice/video note rate limit")
+			return true
+		}
+	case "delete":
+		b.deleteMessage(ctx, message, "voice or video note from new member")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// wallDraftTTL is how long the bot waits for a post after the member follows a wall link
+const wallDraftTTL = 30 * time.Minute
+
+// wallStartPrefix marks a /start deep link that begins a wall submission for a chat
+const wallStartPrefix = "wall_"
+
+// wallPost is a member's submission to a chat's wall channel
+type wallPost struct {
+	UserID    int64
+	MessageID int
+	ChatID    int64
+	Author    string
+	Text      string
+}
+
+// StartWallDraft records that userID's next private message is a post for chatID
+func (s *Store) StartWallDraft(ctx context.Context, chatID, userID int64) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO wall_drafts (user_id, chat_id, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET chat_id = excluded.chat_id, expires_at = excluded.expires_at
+	`, userID, chatID, time.Now().Add(wallDraftTTL).Unix())
+	return err
+}
+
+// TakeWallDraft ends userID's pending draft and returns its chat; ok is false if there was none
+func (s *Store) TakeWallDraft(ctx context.Context, userID int64) (chatID int64, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `SELECT chat_id FROM wall_drafts WHERE user_id = ? AND expires_at > ?`,
+		userID, time.Now().Unix()).Scan(&chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	_, err = s.ExecContext(ctx, `DELETE FROM wall_drafts WHERE user_id = ?`, userID)
+	return chatID, err == nil, err
+}
+
+// SaveWallPost stores a pending submission
+func (s *Store) SaveWallPost(ctx context.Context, post wallPost) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO wall_posts (user_id, message_id, chat_id, author, text, status, submitted_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', ?)
+	`, post.UserID, post.MessageID, post.ChatID, post.Author, post.Text, time.Now().Unix())
+	return err
+}
+
+// DecideWallPost moves a pending submission to status ("approved" or
+// "rejected") and returns it; ok is false if it was already decided
+func (s *Store) DecideWallPost(ctx context.Context, userID int64, messageID int, status string) (post wallPost, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	res, err := s.ExecContext(ctx, `UPDATE wall_posts SET status = ? WHERE user_id = ? AND message_id = ? AND status = 'pending'`,
+		status, userID, messageID)
+	if err != nil {
+		return post, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return post, false, err
+	}
+	post = wallPost{UserID: userID, MessageID: messageID}
+	err = s.QueryRowContext(ctx, `SELECT chat_id, author, text FROM wall_posts WHERE user_id = ? AND message_id = ?`,
+		userID, messageID).Scan(&post.ChatID, &post.Author, &post.Text)
+	return post, err == nil, err
+}
+
+// ReopenWallPost puts a submission back to pending, e.g. when publishing failed
+func (s *Store) ReopenWallPost(ctx context.Context, userID int64, messageID int) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `UPDATE wall_posts SET status = 'pending' WHERE user_id = ? AND message_id = ?`, userID, messageID)
+	return err
+}
+
+// WallPostChat returns the chat a submission was made for
+func (s *Store) WallPostChat(ctx context.Context, userID int64, messageID int) (int64, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var chatID int64
+	err := s.QueryRowContext(ctx, `SELECT chat_id FROM wall_posts WHERE user_id = ? AND message_id = ?`, userID, messageID).Scan(&chatID)
+	return chatID, err
+}
+
+// wallLink is the deep link that starts a wall submission for chatID in private
+func (b *Bot) wallLink(chatID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", b.api.Self.UserName, wallStartPrefix, chatID)
+}
+
+// cmdWall points members of a chat with a wall_channel to the private submission flow
+func (b *Bot) cmdWall(ctx context.Context, message *Message) {
+	if message.Chat.Type == "private" {
+		b.reply(message, b.trFor(ctx, message, "wall.use_in_group"))
+		return
+	}
+	if b.app.settings.Get(ctx, message.Chat.ID, settingWallChannel) == "" {
+		b.reply(message, b.trFor(ctx, message, "wall.no_channel"))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "wall.link", b.wallLink(message.Chat.ID)))
+}
+
+// startWallDraft asks for the post after the member followed a wall deep link
+func (b *Bot) startWallDraft(ctx context.Context, message *Message, arg string) {
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(arg, wallStartPrefix), 10, 64)
+	if err != nil || b.app.settings.Get(ctx, chatID, settingWallChannel) == "" {
+		b.reply(message, b.trFor(ctx, message, "wall.invalid_link"))
+		return
+	}
+	if err := b.app.db.StartWallDraft(ctx, chatID, message.From.ID); err != nil {
+		b.logf("Failed to start wall draft for %d: %v", message.From.ID, err)
+		b.reply(message, b.trFor(ctx, message, "wall.start_failed"))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "wall.prompt", int(wallDraftTTL/time.Minute)))
+}
+
+// handleWallSubmission takes a private message as a wall post if the sender
+// started a draft. Spam is refused and left to the private spam handling.
+// Returns true when the message was taken as a submission.
+func (b *Bot) handleWallSubmission(ctx context.Context, message *Message, text string) bool {
+	chatID, ok, err := b.app.db.TakeWallDraft(ctx, message.From.ID)
+	if err != nil {
+		b.logf("Failed to look up wall draft for %d: %v", message.From.ID, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if message.Text == "" {
+		b.reply(message, b.trFor(ctx, message, "wall.text_only"))
+		return true
+	}
+	verdict, err := b.app.detector.Detect(ctx, chatID, text)
+	if err != nil {
+		b.logf("Failed to look up phishing domains: %v", err)
+	}
+	homograph, _ := b.homographLink(ctx, chatID, 0, text)
+	if verdict.Spam() || homograph != "" {
+		b.reply(message, b.trFor(ctx, message, "wall.spam"))
+		return false
+	}
+
+	author := message.From.FirstName
+	if message.From.UserName != "" {
+		author += " (@" + message.From.UserName + ")"
+	}
+	post := wallPost{UserID: message.From.ID, MessageID: message.MessageID, ChatID: chatID, Author: author, Text: message.Text}
+	if err := b.app.db.SaveWallPost(ctx, post); err != nil {
+		b.logf("Failed to save wall post from %d: %v", message.From.ID, err)
+		b.app.reporter.Failure("db.saveWallPost", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "wall.submit_failed"))
+		return true
+	}
+
+	reviewChat := chatID
+	if id, err := strconv.ParseInt(b.app.settings.Get(ctx, chatID, settingWallReviewChat), 10, 64); err == nil {
+		reviewChat = id
+	}
+	review := tgbotapi.NewMessage(reviewChat, b.tr(ctx, chatID, "wall.review", author, post.Text))
+	id := fmt.Sprintf("%d:%d", post.UserID, post.MessageID)
+	review.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "wall.button_publish"), "wall:approve:"+id),
+		tgbotapi.NewInlineKeyboardButtonData(b.tr(ctx, chatID, "wall.button_reject"), "wall:reject:"+id),
+	))
+	b.outbox.enqueue(review)
+	metrics.Add("wall_posts_submitted", 1)
+	b.reply(message, b.trFor(ctx, message, "wall.submitted"))
+	return true
+}
+
+// wallChannelMessage addresses a message to a wall_channel given as @username or numeric id
+func wallChannelMessage(channel, text string) tgbotapi.MessageConfig {
+	if id, err := strconv.ParseInt(channel, 10, 64); err == nil {
+		return tgbotapi.NewMessage(id, text)
+	}
+	return tgbotapi.NewMessageToChannel("@"+strings.TrimPrefix(channel, "@"), text)
+}
+
+// channelUsernamePattern matches a public channel's @username
+var channelUsernamePattern = regexp.MustCompile(`^@?[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// checkWallChannel validates the form of wall_channel values; whether the
+// setter may publish there is checked by checkWallTarget
+func checkWallChannel(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil && value != "" && !channelUsernamePattern.MatchString(value) {
+		return fmt.Errorf("not a channel @username or id")
+	}
+	return nil
+}
+
+// checkWallReviewChat validates the form of wall_review_chat values
+func checkWallReviewChat(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil && value != "" {
+		return fmt.Errorf("not a chat id")
+	}
+	return nil
+}
+
+// checkWallTarget refuses a wall_channel or wall_review_chat that userID
+// doesn't administer or the bot can't post to. The bot is shared between
+// communities, so without it a group admin could publish into, or send
+// review buttons to, any chat where the bot happens to be.
+func (b *Bot) checkWallTarget(ctx context.Context, key, value string, userID int64) error {
+	if value == "" || (key != settingWallChannel && key != settingWallReviewChat) {
+		return nil
+	}
+	config := tgbotapi.ChatInfoConfig{}
+	if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+		config.ChatID = id
+	} else {
+		config.SuperGroupUsername = "@" + strings.TrimPrefix(value, "@")
+	}
+	lookupKey := "getChat:" + config.SuperGroupUsername
+	if config.ChatID != 0 {
+		lookupKey = "getChat:" + strconv.FormatInt(config.ChatID, 10)
+	}
+	chat, err := lookup(ctx, b.lookups, lookupKey, func() (tgbotapi.Chat, error) {
+		return b.api.GetChat(config)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: can't find %s; add the bot there first", key, value)
+	}
+	admins, err := b.chatAdmins(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("%s: can't list the admins of %s; make the bot an admin there", key, value)
+	}
+	setterIsAdmin := false
+	for _, admin := range admins {
+		if admin.User != nil && admin.User.ID == userID {
+			setterIsAdmin = true
+		}
+	}
+	if !setterIsAdmin {
+		return fmt.Errorf("%s: only admins of %s can point the wall there", key, value)
+	}
+	member, err := b.getChatMember(ctx, chat.ID, b.api.Self.ID)
+	if err != nil {
+		return fmt.Errorf("%s: can't check the bot's rights in %s: %v", key, value, err)
+	}
+	canPost := member.Status == "creator" ||
+		(member.Status == "administrator" && (!chat.IsChannel() || member.CanPostMessages)) ||
+		(!chat.IsChannel() && (member.Status == "member" || (member.Status == "restricted" && member.CanSendMessages)))
+	if !canPost {
+		return fmt.Errorf("%s: the bot can't post in %s", key, value)
+	}
+	return nil
+}
+
+// handleWallCallback publishes or rejects a submission on an admin's decision
+func (b *Bot) handleWallCallback(ctx context.Context, query *tgbotapi.CallbackQuery, args string) {
+	action, id, _ := strings.Cut(args, ":")
+	user, msg, _ := strings.Cut(id, ":")
+	userID, err1 := strconv.ParseInt(user, 10, 64)
+	messageID, err2 := strconv.Atoi(msg)
+	if err1 != nil || err2 != nil || (action != "approve" && action != "reject") {
+		return
+	}
+
+	// The review chat may be a separate admin chat; the decision belongs to the wall's group
+	chatID, err := b.app.db.WallPostChat(ctx, userID, messageID)
+	if err != nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, query.Message.Chat.ID, "wall.gone")))
+		return
+	}
+	if !b.isChatAdmin(ctx, chatID, query.From.ID) {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "wall.admins_only")))
+		return
+	}
+
+	status, outcome := "rejected", "rejected"
+	if action == "approve" {
+		status, outcome = "approved", "published"
+	}
+	post, ok, err := b.app.db.DecideWallPost(ctx, userID, messageID, status)
+	if err != nil {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "callback.failed", err)))
+		return
+	}
+	if !ok {
+		b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "wall.already")))
+		return
+	}
+	if action == "approve" {
+		channel := b.app.settings.Get(ctx, post.ChatID, settingWallChannel)
+		if _, err := b.send(ctx, wallChannelMessage(channel, post.Text+"\n\n— "+post.Author)); err != nil {
+			b.logf("Failed to publish wall post to %s: %v", channel, err)
+			b.app.reporter.Failure("telegram.publishWallPost", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: post.ChatID, UserID: userID})
+			b.app.db.ReopenWallPost(ctx, userID, messageID)
+			b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "wall.publish_failed", err)))
+			return
+		}
+		metrics.Add("wall_posts_published", 1)
+	}
+	b.logf("Wall post %d from %d: %s by %s", messageID, userID, outcome, query.From.UserName)
+	b.request(ctx, tgbotapi.NewCallback(query.ID, b.tr(ctx, chatID, "wall."+outcome)))
+	b.request(ctx, tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		query.Message.Text+"\n\n"+b.tr(ctx, chatID, "wall."+outcome+"_by", query.From.FirstName)))
+	b.outbox.enqueue(tgbotapi.NewMessage(userID, b.tr(ctx, chatID, "wall.author_"+outcome)))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha3"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// walletChallengeTTL is how long a verification nonce can be signed and submitted
+const walletChallengeTTL = 15 * time.Minute
+
+// verifyStartPrefix marks a /start deep link that begins wallet verification for a chat
+const verifyStartPrefix = "verify_"
+
+// SaveWalletChallenge stores a fresh nonce for userID to sign for chatID,
+// replacing any earlier one
+func (s *Store) SaveWalletChallenge(ctx context.Context, chatID, userID int64, nonce string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	_, err := s.ExecContext(ctx, `
+		INSERT INTO wallet_challenges (user_id, chat_id, nonce, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET chat_id = excluded.chat_id, nonce = excluded.nonce, expires_at = excluded.expires_at
+	`, userID, chatID, nonce, time.Now().Add(walletChallengeTTL).Unix())
+	return err
+}
+
+// WalletChallenge returns userID's pending, unexpired challenge; ok is false if there is none
+func (s *Store) WalletChallenge(ctx context.Context, userID int64) (chatID int64, nonce string, ok bool, err error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	err = s.QueryRowContext(ctx, `SELECT chat_id, nonce FROM wallet_challenges WHERE user_id = ? AND expires_at > ?`,
+		userID, time.Now().Unix()).Scan(&chatID, &nonce)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, nil
+	}
+	return chatID, nonce, err == nil, err
+}
+
+// SaveWallet records that userID proved ownership of address in chatID, and
+// the account that first funded it, consuming the challenge
+func (s *Store) SaveWallet(ctx context.Context, chatID, userID int64, address, funder string) error {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.Rebind(`
+		INSERT INTO wallet_links (chat_id, user_id, address, funder, verified_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET address = excluded.address, funder = excluded.funder, verified_at = excluded.verified_at
+	`), chatID, userID, address, funder, time.Now().Unix()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.Rebind(`DELETE FROM wallet_challenges WHERE user_id = ?`), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Wallet returns the address userID verified in chatID, or "" if they haven't
+func (s *Store) Wallet(ctx context.Context, chatID, userID int64) (string, error) {
+	ctx, cancel := s.OpContext(ctx)
+	defer cancel()
+	var address string
+	err := s.QueryRowContext(ctx, `SELECT address FROM wallet_links WHERE chat_id = ? AND user_id = ?`, chatID, userID).Scan(&address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return address, err
+}
+
+// walletChallengeText is the message a user signs to prove they own a wallet
+func walletChallengeText(chatID, userID int64, nonce string) string {
+	return fmt.Sprintf("Verify Aptos wallet for Telegram user %d in chat %d (nonce %s)", userID, chatID, nonce)
+}
+
+// aptosAddress derives the account address of a single-key Ed25519 wallet:
+// sha3-256(public key || 0x00)
+func aptosAddress(pub ed25519.PublicKey) string {
+	sum := sha3.Sum256(append(append([]byte{}, pub...), 0x00))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+// decodeHex parses a hex string with or without the 0x prefix
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x"))
+}
+
+// verifyWalletSignature checks an Ed25519 signature over the challenge and
+// returns the signer's address. Wallets implementing the Aptos signMessage
+// standard sign "APTOS\nmessage: <message>\nnonce: <nonce>" rather than the raw
+// text, so both forms are accepted.
+func verifyWalletSignature(challenge, nonce, publicKey, signature string) (string, error) {
+	pub, err := decodeHex(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("invalid public key")
+	}
+	sig, err := decodeHex(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", fmt.Errorf("invalid signature")
+	}
+	for _, signed := range []string{
+		challenge,
+		"APTOS\nmessage: " + challenge + "\nnonce: " + nonce,
+	} {
+		if ed25519.Verify(pub, []byte(signed), sig) {
+			return aptosAddress(pub), nil
+		}
+	}
+	return "", fmt.Errorf("signature does not match the challenge")
+}
+
+// verifyLink is the deep link that starts wallet verification for chatID in private
+func (b *Bot) verifyLink(chatID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", b.api.Self.UserName, verifyStartPrefix, chatID)
+}
+
+// startVerification issues a challenge after the user followed a verify deep link
+func (b *Bot) startVerification(ctx context.Context, message *Message, arg string) {
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(arg, verifyStartPrefix), 10, 64)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "verify.invalid_link"))
+		return
+	}
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	nonce := hex.EncodeToString(raw)
+	if err := b.app.db.SaveWalletChallenge(ctx, chatID, message.From.ID, nonce); err != nil {
+		b.logf("Failed to save wallet challenge for %d: %v", message.From.ID, err)
+		b.app.reporter.Failure("db.saveWalletChallenge", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "verify.start_failed"))
+		return
+	}
+	b.reply(message, b.trFor(ctx, message, "verify.challenge",
+		int(walletChallengeTTL/time.Minute), walletChallengeText(chatID, message.From.ID, nonce)))
+}
+
+// cmdVerify links a wallet: in a group it points to the private flow, in
+// private it checks the signed challenge
+func (b *Bot) cmdVerify(ctx context.Context, message *Message) {
+	if message.Chat.Type != "private" {
+		b.reply(message, b.trFor(ctx, message, "verify.link", b.verifyLink(message.Chat.ID)))
+		return
+	}
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 {
+		b.reply(message, b.trFor(ctx, message, "verify.usage"))
+		return
+	}
+	chatID, nonce, ok, err := b.app.db.WalletChallenge(ctx, message.From.ID)
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "verify.lookup_failed"))
+		return
+	}
+	if !ok {
+		b.reply(message, b.trFor(ctx, message, "verify.none_pending"))
+		return
+	}
+	address, err := verifyWalletSignature(walletChallengeText(chatID, message.From.ID, nonce), nonce, args[0], args[1])
+	if err != nil {
+		b.reply(message, b.trFor(ctx, message, "verify.failed", err))
+		return
+	}
+	funder, allowed := b.checkSybil(ctx, chatID, *message.From, address)
+	if !allowed {
+		b.reply(message, b.trFor(ctx, message, "verify.sybil_rejected"))
+		return
+	}
+	if err := b.app.db.SaveWallet(ctx, chatID, message.From.ID, address, funder); err != nil {
+		b.logf("Failed to save wallet for %d: %v", message.From.ID, err)
+		b.app.reporter.Failure("db.saveWallet", err, b.errorContext(message.Message))
+		b.reply(message, b.trFor(ctx, message, "verify.save_failed"))
+		return
+	}
+	metrics.Add("wallets_verified", 1)
+	b.logf("User %d verified wallet %s for chat %d", message.From.ID, address, chatID)
+	b.reply(message, b.trFor(ctx, message, "verify.done", address))
+	// Clear a running token gate grace period right away
+	b.gateMember(ctx, chatID, *message.From)
+}
+
+// applyLinkGate enforces link_policy "verified": links from members without a
+// verified wallet are deleted without a strike and the sender is pointed to
+// /verify. Returns true when the message was removed.
+func (b *Bot) applyLinkGate(ctx context.Context, message *Message, text string) bool {
+	if text == "" || b.setting(ctx, message, settingLinkPolicy) != "verified" || !b.app.detector.HasLink(text) {
+		return false
+	}
+	address, err := b.app.db.Wallet(ctx, message.Chat.ID, message.From.ID)
+	if err != nil {
+		b.logf("Failed to look up wallet for %d: %v", message.From.ID, err)
+		b.app.reporter.Failure("db.wallet", err, b.errorContext(message.Message))
+		return false
+	}
+	if address != "" {
+		return false
+	}
+	b.deleteMessage(ctx, message, "link from unverified wallet")
+	sent, err := b.send(ctx, htmlMessage(message.Chat.ID, b.trFor(ctx, message, "verify.links_gated",
+		mention(*message.From), html.EscapeString(b.verifyLink(message.Chat.ID)))))
+	if err != nil {
+		return true
+	}
+	b.deleteNoticeLater(ctx, message.Chat.ID, sent.MessageID)
+	return true
+}
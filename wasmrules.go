@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"spambot/detector"
+)
+
+const (
+	// wasmRuleTimeout bounds one classify call of a WASM rule
+	wasmRuleTimeout = 250 * time.Millisecond
+	// wasmMemoryPages caps a rule's memory at 16 MiB of 64 KiB pages
+	wasmMemoryPages = 256
+	// wasmMaxOutput caps what a rule may write to stdout or stderr
+	wasmMaxOutput = 64 << 10
+)
+
+// wasmMetadata is classify's metadata argument: the chat's policy and the
+// time, since modules get no real clock
+type wasmMetadata struct {
+	LinksAreSpam   bool     `json:"links_are_spam"`
+	BlockedDomains []string `json:"blocked_domains"`
+	DMSolicitation string   `json:"dm_solicitation"`
+	KeywordOnly    bool     `json:"keyword_only"`
+	// Time is the Unix time the message is classified at
+	Time int64 `json:"time"`
+}
+
+// wasmVerdict is what classify returns
+type wasmVerdict struct {
+	Spam   bool   `json:"spam"`
+	Reason string `json:"reason"`
+}
+
+// wasmExports are the functions a rule module must export, with their
+// parameter and result counts; all are i32 but classify's i64 result
+var wasmExports = map[string][2]int{"alloc": {1, 1}, "classify": {4, 1}}
+
+// wasmEngine is the embedded runtime every WASM rule runs in. Modules are
+// compiled once and kept by content, so a reload that leaves a module alone
+// reuses its compiled code; an edited module's old code stays until restart.
+var wasmEngine struct {
+	once    sync.Once
+	runtime wazero.Runtime
+
+	mu      sync.Mutex
+	modules map[[sha256.Size]byte]wazero.CompiledModule
+}
+
+func wasmRuntime() wazero.Runtime {
+	wasmEngine.once.Do(func() {
+		ctx := context.Background()
+		config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithMemoryLimitPages(wasmMemoryPages)
+		wasmEngine.runtime = wazero.NewRuntimeWithConfig(ctx, config)
+		wasi_snapshot_preview1.MustInstantiate(ctx, wasmEngine.runtime)
+		wasmEngine.modules = make(map[[sha256.Size]byte]wazero.CompiledModule)
+	})
+	return wasmEngine.runtime
+}
+
+// compileWasm compiles the module at path, or returns it compiled already
+func compileWasm(path string) (wazero.CompiledModule, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	runtime := wasmRuntime()
+	sum := sha256.Sum256(code)
+	wasmEngine.mu.Lock()
+	defer wasmEngine.mu.Unlock()
+	if module, ok := wasmEngine.modules[sum]; ok {
+		return module, nil
+	}
+	module, err := runtime.CompileModule(context.Background(), code)
+	if err != nil {
+		return nil, err
+	}
+	wasmEngine.modules[sum] = module
+	return module, nil
+}
+
+// wasmCheck is a check implemented by a WASM module exporting
+//
+//	memory
+//	alloc(size i32) -> i32
+//	classify(text_ptr, text_len, metadata_ptr, metadata_len i32) -> i64
+//
+// The runtime copies the text (UTF-8) and the metadata (a JSON wasmMetadata)
+// into buffers from alloc and calls classify, which returns where in memory
+// it put its verdict, a JSON wasmVerdict: the pointer in the high 32 bits,
+// the length in the low ones, 0 for a clean message. A WASI reactor's
+// _initialize runs first; _start is not called.
+//
+// The module is compiled when the rules load and runs in the embedded
+// runtime with no files, network, environment or real clock, at most
+// wasmMemoryPages of memory, and is stopped after wasmRuleTimeout or when
+// the message's context is done. Each call runs a fresh instance, so one
+// message can't leave state behind for the next.
+func wasmCheck(name string, severity detector.Severity, score float64, path string) (detector.Check, error) {
+	module, err := compileWasm(path)
+	if err != nil {
+		return detector.Check{}, fmt.Errorf("rule %q: %v", name, err)
+	}
+	if err := checkWasmExports(module); err != nil {
+		return detector.Check{}, fmt.Errorf("rule %q: %v", name, err)
+	}
+	return detector.Check{Name: name, Severity: severity, Score: score, Match: func(ctx context.Context, text string, policy detector.Policy, env detector.Env) (string, bool) {
+		verdict, err := runWasmRule(ctx, module, text, wasmMetadata{
+			LinksAreSpam:   policy.LinksAreSpam,
+			BlockedDomains: policy.BlockedDomains,
+			DMSolicitation: policy.DMSolicitation,
+			KeywordOnly:    policy.KeywordOnly,
+			Time:           env.Now().Unix(),
+		})
+		if err != nil {
+			// A broken rule lets messages through rather than blocking the chat
+			if ctx.Err() == nil {
+				log.Printf("WASM rule %s failed: %v", name, err)
+				metrics.Add("wasm_rule_errors", 1)
+			}
+			return "", false
+		}
+		if verdict.Reason == "" {
+			verdict.Reason = name
+		}
+		return verdict.Reason, verdict.Spam
+	}}, nil
+}
+
+// checkWasmExports verifies that module implements the rule ABI
+func checkWasmExports(module wazero.CompiledModule) error {
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("module doesn't export its memory")
+	}
+	functions := module.ExportedFunctions()
+	for export, arity := range wasmExports {
+		def, ok := functions[export]
+		if !ok {
+			return fmt.Errorf("module doesn't export %s", export)
+		}
+		if len(def.ParamTypes()) != arity[0] || len(def.ResultTypes()) != arity[1] {
+			return fmt.Errorf("%s takes %d arguments and returns %d values, want %d and %d",
+				export, len(def.ParamTypes()), len(def.ResultTypes()), arity[0], arity[1])
+		}
+	}
+	return nil
+}
+
+func runWasmRule(ctx context.Context, module wazero.CompiledModule, text string, metadata wasmMetadata) (wasmVerdict, error) {
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return wasmVerdict{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, wasmRuleTimeout)
+	defer cancel()
+	stderr := &cappedBuffer{max: wasmMaxOutput}
+	// An empty name lets calls instantiate the module concurrently
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize").
+		WithStdout(&cappedBuffer{max: wasmMaxOutput}).WithStderr(stderr)
+	instance, err := wasmRuntime().InstantiateModule(ctx, module, config)
+	if err != nil {
+		return wasmVerdict{}, wasmError(err, stderr)
+	}
+	defer instance.Close(context.Background())
+
+	memory := instance.Memory()
+	var args []uint64
+	for _, data := range [][]byte{[]byte(text), meta} {
+		ptr, err := instance.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+		if err != nil {
+			return wasmVerdict{}, wasmError(err, stderr)
+		}
+		if !memory.Write(uint32(ptr[0]), data) {
+			return wasmVerdict{}, fmt.Errorf("alloc returned %d, outside memory", uint32(ptr[0]))
+		}
+		args = append(args, uint64(uint32(ptr[0])), uint64(len(data)))
+	}
+	result, err := instance.ExportedFunction("classify").Call(ctx, args...)
+	if err != nil {
+		return wasmVerdict{}, wasmError(err, stderr)
+	}
+	ptr, size := uint32(result[0]>>32), uint32(result[0])
+	if size == 0 {
+		return wasmVerdict{}, nil
+	}
+	if size > wasmMaxOutput {
+		return wasmVerdict{}, fmt.Errorf("verdict over %d bytes", wasmMaxOutput)
+	}
+	out, ok := memory.Read(ptr, size)
+	if !ok {
+		return wasmVerdict{}, fmt.Errorf("verdict at %d+%d is outside memory", ptr, size)
+	}
+	var verdict wasmVerdict
+	if err := json.Unmarshal(out, &verdict); err != nil {
+		return wasmVerdict{}, fmt.Errorf("bad verdict %q: %v", out, err)
+	}
+	return verdict, nil
+}
+
+// wasmError adds what the module wrote to stderr, such as a panic, to err
+func wasmError(err error, stderr *cappedBuffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%v: %s", err, msg)
+	}
+	return err
+}
+
+// cappedBuffer is a bytes.Buffer that fails writes past max bytes
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output over %d bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
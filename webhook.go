@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"spambot/telegram"
+)
+
+// maxWebhookBody caps the size of an incoming update
+const maxWebhookBody = 1 << 20
+
+// webhookCheckInterval is how often webhook mode asks Telegram about delivery errors
+const webhookCheckInterval = 10 * time.Minute
+
+// WebhookHandler processes one Telegram update per HTTP request, synchronously,
+// so it can run behind serverless platforms that freeze the process between calls.
+// Each bot is served at /<bot id>; with a single bot, / works too.
+type WebhookHandler struct {
+	bots   map[string]*Bot
+	secret string
+	// Serves /alerts when external alert ingestion is configured
+	alerts *AlertHandler
+	// Serves /dashboard when DASHBOARD_SECRET is set
+	dashboard *DashboardHandler
+	// Serves the moderation service when MODERATION_TOKEN is set
+	moderation *ModerationService
+	// Serves the read-only stats API
+	stats *StatsHandler
+}
+
+func newWebhookHandler(bots []*Bot, secret string, alerts *AlertHandler, dashboard *DashboardHandler, moderation *ModerationService) *WebhookHandler {
+	h := &WebhookHandler{bots: make(map[string]*Bot), secret: secret, alerts: alerts, dashboard: dashboard, moderation: moderation, stats: newStatsHandler(bots)}
+	for _, bot := range bots {
+		h.bots["/"+strconv.FormatInt(bot.api.Self.ID, 10)] = bot
+	}
+	if len(bots) == 1 {
+		h.bots["/"] = bots[0]
+	}
+	return h
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.alerts != nil && (r.URL.Path == alertPathPrefix || strings.HasPrefix(r.URL.Path, alertPathPrefix+"/")) {
+		h.alerts.ServeHTTP(w, r)
+		return
+	}
+	if h.dashboard != nil && (r.URL.Path == dashboardPathPrefix || strings.HasPrefix(r.URL.Path, dashboardPathPrefix+"/")) {
+		h.dashboard.ServeHTTP(w, r)
+		return
+	}
+	if h.moderation != nil && strings.HasPrefix(r.URL.Path, moderationPathPrefix) {
+		h.moderation.ServeHTTP(w, r)
+		return
+	}
+	if h.stats != nil && strings.HasPrefix(r.URL.Path, statsPathPrefix+"/") {
+		h.stats.ServeHTTP(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")), []byte(h.secret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	bot, ok := h.bots[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "failed to read update", http.StatusBadRequest)
+		return
+	}
+	update, err := parseUpdate(data)
+	if err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+	bot.handleWebhookUpdate(r.Context(), update)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleWebhookUpdate handles an update unless another invocation already did;
+// Telegram redelivers webhooks that time out, and serverless runs may overlap
+func (b *Bot) handleWebhookUpdate(ctx context.Context, update Update) {
+	claimed, err := b.app.db.ClaimUpdate(ctx, b.api.Self.ID, update.UpdateID)
+	if err != nil {
+		b.logf("Failed to claim update %d: %v", update.UpdateID, err)
+		b.app.reporter.Failure("db.claimUpdate", err, ErrorContext{Bot: b.api.Self.UserName, UpdateID: update.UpdateID})
+	} else if !claimed {
+		return
+	}
+	b.safeHandleUpdate(ctx, update)
+	// No background jobs in webhook mode; piggyback on incoming updates
+	b.sweepDeletions(ctx)
+	b.sweepRetries(ctx)
+	b.maybePostReminders(ctx)
+	b.maybeRecheckTokenGates(ctx)
+	b.maybePollOnchainEvents(ctx)
+	b.maybeSweepEvents(ctx)
+	b.maybeSendDigests(ctx)
+	b.maybeCheckWebhook(ctx)
+	b.app.maybePublishAudit(ctx)
+	b.app.maybeSyncPhishingFeeds(ctx)
+}
+
+// maybeCheckWebhook asks Telegram, at most once per webhookCheckInterval,
+// whether it recently failed to deliver an update to the webhook, and raises a
+// webhook_down alert if so. Telegram retries failed deliveries, so a flaky
+// endpoint still gets here now and then; one that is down for good can't.
+func (b *Bot) maybeCheckWebhook(ctx context.Context) {
+	now := time.Now().Unix()
+	last := b.lastWebhookCheck.Load()
+	if now-last < int64(webhookCheckInterval/time.Second) || !b.lastWebhookCheck.CompareAndSwap(last, now) {
+		return
+	}
+	info, err := telegram.CallWithContext(ctx, b.api.GetWebhookInfo)
+	if err != nil {
+		b.logf("Failed to get webhook info: %v", err)
+		return
+	}
+	if info.LastErrorDate == 0 || now-int64(info.LastErrorDate) > int64(webhookCheckInterval/time.Second) {
+		return
+	}
+	metrics.Add("webhook_errors", 1)
+	b.app.reporter.Event("webhook_down", fmt.Sprintf("Telegram failed to deliver updates to @%s's webhook: %s (%d pending)",
+		b.api.Self.UserName, info.LastErrorMessage, info.PendingUpdateCount), ErrorContext{Bot: b.api.Self.UserName})
+}
+
+// setWebhook registers url (plus the bot id path) with Telegram
+func (b *Bot) setWebhook(url, secret string) error {
+	params := tgbotapi.Params{
+		"url": strings.TrimSuffix(url, "/") + "/" + strconv.FormatInt(b.api.Self.ID, 10),
+	}
+	params.AddNonEmpty("secret_token", secret)
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+		return err
+	}
+	_, err := b.api.MakeRequest("setWebhook", params)
+	return err
+}
+
+// runLambda implements the AWS Lambda custom runtime loop (the provided.al2 "bootstrap"
+// contract), translating API Gateway / function URL events into requests for handler
+func runLambda(runtimeAPI string, handler http.Handler) error {
+	base := "http://" + runtimeAPI + "/2018-06-01/runtime/invocation/"
+	for {
+		resp, err := http.Get(base + "next")
+		if err != nil {
+			return fmt.Errorf("failed to fetch Lambda invocation: %v", err)
+		}
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		var event lambdaHTTPEvent
+		err = json.NewDecoder(resp.Body).Decode(&event)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("Failed to decode Lambda event %s: %v", requestID, err)
+			postLambda(base+requestID+"/error", map[string]string{"errorMessage": err.Error()})
+			continue
+		}
+
+		req, err := event.request()
+		if err != nil {
+			postLambda(base+requestID+"/error", map[string]string{"errorMessage": err.Error()})
+			continue
+		}
+		rec := &lambdaResponse{header: http.Header{}, status: http.StatusOK}
+		handler.ServeHTTP(rec, req)
+		postLambda(base+requestID+"/response", rec.payload(event.Version))
+	}
+}
+
+// lambdaHTTPEvent covers both API Gateway (v1, v2) and function URL payloads
+type lambdaHTTPEvent struct {
+	// Version is "2.0" for API Gateway HTTP APIs and function URLs
+	Version                         string              `json:"version"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	RawPath                         string              `json:"rawPath"`
+	RawQueryString                  string              `json:"rawQueryString"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	// Cookies are the v2 payload's request cookies, left out of Headers
+	Cookies         []string `json:"cookies"`
+	Body            string   `json:"body"`
+	IsBase64Encoded bool     `json:"isBase64Encoded"`
+	RequestContext  struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+func (e *lambdaHTTPEvent) request() (*http.Request, error) {
+	method := e.HTTPMethod
+	if method == "" {
+		method = e.RequestContext.HTTP.Method
+	}
+	path := e.RawPath
+	if path == "" {
+		path = e.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %v", err)
+		}
+		body = decoded
+	}
+	req, err := http.NewRequest(method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = e.query()
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, values := range e.MultiValueHeaders {
+		req.Header.Del(k)
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if len(e.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+	req.Host = req.Header.Get("Host")
+	return req, nil
+}
+
+// query is the event's query string: raw in v2 payloads, decoded parameters in v1
+func (e *lambdaHTTPEvent) query() string {
+	if e.RawQueryString != "" {
+		return e.RawQueryString
+	}
+	query := url.Values{}
+	for k, values := range e.MultiValueQueryStringParameters {
+		query[k] = values
+	}
+	for k, v := range e.QueryStringParameters {
+		if _, ok := query[k]; !ok {
+			query.Set(k, v)
+		}
+	}
+	return query.Encode()
+}
+
+// lambdaResponse is a minimal http.ResponseWriter buffering the handler's reply
+type lambdaResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *lambdaResponse) Header() http.Header         { return r.header }
+func (r *lambdaResponse) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *lambdaResponse) WriteHeader(status int)      { r.status = status }
+
+// payload is the reply in the event's format: v2 returns Set-Cookie as
+// cookies, v1 as multiValueHeaders. Bodies that aren't text are base64.
+func (r *lambdaResponse) payload(version string) map[string]interface{} {
+	if r.header.Get("Content-Type") == "" && r.body.Len() > 0 {
+		r.header.Set("Content-Type", http.DetectContentType(r.body.Bytes()))
+	}
+	headers := make(map[string]string)
+	for k, values := range r.header {
+		if k != "Set-Cookie" {
+			headers[k] = strings.Join(values, ", ")
+		}
+	}
+	payload := map[string]interface{}{
+		"statusCode":      r.status,
+		"headers":         headers,
+		"body":            r.body.String(),
+		"isBase64Encoded": false,
+	}
+	if !textContent(r.header.Get("Content-Type")) {
+		payload["body"] = base64.StdEncoding.EncodeToString(r.body.Bytes())
+		payload["isBase64Encoded"] = true
+	}
+	if cookies := r.header.Values("Set-Cookie"); len(cookies) > 0 {
+		if version == "2.0" {
+			payload["cookies"] = cookies
+		} else {
+			payload["multiValueHeaders"] = map[string][]string{"Set-Cookie": cookies}
+		}
+	}
+	return payload
+}
+
+// textContent reports whether a body of contentType can be returned as is
+func textContent(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "xml"):
+		return true
+	}
+	return mediaType == "application/javascript" || mediaType == "application/x-www-form-urlencoded"
+}
+
+func postLambda(url string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to report Lambda result: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
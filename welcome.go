@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// welcome greets a member who passed screening, using the chat's welcome_message template
+func (b *Bot) welcome(ctx context.Context, message *Message, member tgbotapi.User) {
+	template := b.app.settings.Get(ctx, message.Chat.ID, settingWelcomeMessage)
+	if template == "" {
+		return
+	}
+	text := welcomeText(template, member, message.Chat, b.app.settings.Get(ctx, message.Chat.ID, settingRules), b.rulesLink(ctx, message.Chat))
+	sent, err := b.send(ctx, htmlMessage(message.Chat.ID, text))
+	if err != nil {
+		b.logf("Failed to welcome %s in chat %d: %v", member.UserName, message.Chat.ID, err)
+		b.app.reporter.Failure("telegram.sendWelcome", err, b.errorContext(message.Message))
+		return
+	}
+	if delay, _ := strconv.Atoi(b.app.settings.Get(ctx, message.Chat.ID, settingWelcomeDeleteAfter)); delay > 0 {
+		b.deleteLater(ctx, message.Chat.ID, sent.MessageID, time.Duration(delay)*time.Second)
+	}
+}
+
+// thank answers a boost or gift service message with the chat's
+// boost_thanks_message template, if one is set
+func (b *Bot) thank(ctx context.Context, message *Message) {
+	template := b.app.settings.Get(ctx, message.Chat.ID, settingBoostThanks)
+	if template == "" || message.From == nil {
+		return
+	}
+	text := welcomeText(template, *message.From, message.Chat, b.app.settings.Get(ctx, message.Chat.ID, settingRules), b.rulesLink(ctx, message.Chat))
+	sent, err := b.send(ctx, htmlMessage(message.Chat.ID, text))
+	if err != nil {
+		b.logf("Failed to thank %s in chat %d: %v", message.From.UserName, message.Chat.ID, err)
+		return
+	}
+	if delay, _ := strconv.Atoi(b.app.settings.Get(ctx, message.Chat.ID, settingWelcomeDeleteAfter)); delay > 0 {
+		b.deleteLater(ctx, message.Chat.ID, sent.MessageID, time.Duration(delay)*time.Second)
+	}
+}
+
+// welcomeText fills the {name}, {username}, {chat}, {rules} and {rules_link}
+// placeholders, as HTML with {username} a mention for members who have no username
+func welcomeText(template string, member tgbotapi.User, chat *tgbotapi.Chat, rules, rulesLink string) string {
+	username := mention(member)
+	if member.UserName != "" {
+		username = "@" + member.UserName
+	}
+	return strings.NewReplacer(
+		"{name}", html.EscapeString(strings.TrimSpace(member.FirstName+" "+member.LastName)),
+		"{username}", username,
+		"{chat}", html.EscapeString(chat.Title),
+		"{rules}", html.EscapeString(rules),
+		"{rules_link}", html.EscapeString(rulesLink),
+		`\n`, "\n",
+	).Replace(html.EscapeString(template))
+}
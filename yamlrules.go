@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// A RULES_YAML file declares moderation rules for every chat, e.g.
+//
+//	rules:
+//	  - name: presale_shill
+//	    when:
+//	      contains: [presale, whitelist spot]
+//	      entity: [url, text_link]
+//	      sender: [new_member]
+//	    actions: [ban, notify]
+//	  - name: ticker_flood
+//	    severity: strike
+//	    when:
+//	      regex: '(\$[A-Z]{2,6}\b.*){4}'
+//
+// A rule matches when all of its conditions hold:
+//   - contains: the text has any of the phrases, ignoring case
+//   - regex: the text matches the Go regular expression
+//   - entity: the message has an entity of any of the types
+//   - sender: the sender has all of the traits in senderTraits
+//
+// actions are any of delete, warn (delete with a strike and the warn_message),
+// ban, mute (for mute_minutes, 60 by default) and notify (tell the admins).
+// Without actions a strike rule warns and a ban rule bans. Rules are compiled
+// when the configuration loads, so a bad one stops the bot from starting and
+// is rejected on reload.
+type yamlRuleFile struct {
+	Rules []yamlRuleSpec `yaml:"rules"`
+}
+
+type yamlRuleSpec struct {
+	Name     string `yaml:"name"`
+	Severity string `yaml:"severity"`
+	When     struct {
+		Contains []string `yaml:"contains"`
+		Regex    string   `yaml:"regex"`
+		Entity   []string `yaml:"entity"`
+		Sender   []string `yaml:"sender"`
+	} `yaml:"when"`
+	Actions     []string `yaml:"actions"`
+	MuteMinutes int      `yaml:"mute_minutes"`
+}
+
+// yamlRule is a compiled yamlRuleSpec
+type yamlRule struct {
+	name     string
+	contains []string
+	regex    *regexp.Regexp
+	entities []string
+	sender   []string
+	actions  []string
+	mute     time.Duration
+}
+
+var (
+	ruleActions  = []string{"delete", "warn", "ban", "mute", "notify"}
+	senderTraits = []string{
+		"new_member",    // joined within new_member_hours
+		"first_message", // a tracked member's first message
+		"no_username",
+		"has_strikes",
+		"forwarded", // the message is forwarded
+	}
+	entityTypes = []string{
+		"mention", "hashtag", "cashtag", "bot_command", "url", "email", "phone_number", "bold", "italic",
+		"underline", "strikethrough", "spoiler", "blockquote", "code", "pre", "text_link", "text_mention", "custom_emoji",
+	}
+)
+
+// loadYAMLRules reads and compiles the rules declared in path
+func loadYAMLRules(path string) ([]yamlRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file yamlRuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	rules := make([]yamlRule, 0, len(file.Rules))
+	seen := make(map[string]bool)
+	for _, spec := range file.Rules {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("rule without a name")
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("rule %q declared twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		rule, err := compileYAMLRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", spec.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func compileYAMLRule(spec yamlRuleSpec) (yamlRule, error) {
+	rule := yamlRule{name: spec.Name, entities: spec.When.Entity, sender: spec.When.Sender, actions: spec.Actions}
+	for _, phrase := range spec.When.Contains {
+		if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+			rule.contains = append(rule.contains, phrase)
+		}
+	}
+	if spec.When.Regex != "" {
+		re, err := regexp.Compile(spec.When.Regex)
+		if err != nil {
+			return yamlRule{}, err
+		}
+		rule.regex = re
+	}
+	for _, entity := range rule.entities {
+		if !containsString(entityTypes, entity) {
+			return yamlRule{}, fmt.Errorf("unknown entity type %q", entity)
+		}
+	}
+	for _, trait := range rule.sender {
+		if !containsString(senderTraits, trait) {
+			return yamlRule{}, fmt.Errorf("unknown sender trait %q (use %s)", trait, strings.Join(senderTraits, ", "))
+		}
+	}
+	if len(rule.contains) == 0 && rule.regex == nil && len(rule.entities) == 0 && len(rule.sender) == 0 {
+		return yamlRule{}, fmt.Errorf("no conditions")
+	}
+	switch spec.Severity {
+	case "", "strike":
+		if len(rule.actions) == 0 {
+			rule.actions = []string{"warn"}
+		}
+	case "ban":
+		if len(rule.actions) == 0 {
+			rule.actions = []string{"ban"}
+		}
+	default:
+		return yamlRule{}, fmt.Errorf("severity must be strike or ban, got %q", spec.Severity)
+	}
+	for _, action := range rule.actions {
+		if !containsString(ruleActions, action) {
+			return yamlRule{}, fmt.Errorf("unknown action %q (use %s)", action, strings.Join(ruleActions, ", "))
+		}
+	}
+	if spec.MuteMinutes < 0 {
+		return yamlRule{}, fmt.Errorf("mute_minutes must not be negative")
+	}
+	rule.mute = time.Duration(spec.MuteMinutes) * time.Minute
+	if rule.mute == 0 {
+		rule.mute = time.Hour
+	}
+	return rule, nil
+}
+
+// matches reports whether message, with text, breaks the rule, and why for the logs
+func (r yamlRule) matches(ctx context.Context, b *Bot, message *Message, text string) (string, bool) {
+	reason := "rule " + r.name
+	if len(r.contains) > 0 {
+		lowerText := strings.ToLower(text)
+		found := ""
+		for _, phrase := range r.contains {
+			if strings.Contains(lowerText, phrase) {
+				found = phrase
+				break
+			}
+		}
+		if found == "" {
+			return "", false
+		}
+		reason += ": " + found
+	}
+	if r.regex != nil {
+		loc := r.regex.FindStringIndex(text)
+		if loc == nil {
+			return "", false
+		}
+		reason += ": " + text[loc[0]:loc[1]]
+	}
+	if len(r.entities) > 0 && !hasEntityType(message, r.entities) {
+		return "", false
+	}
+	for _, trait := range r.sender {
+		if !b.senderHasTrait(ctx, message, trait) {
+			return "", false
+		}
+	}
+	return reason, true
+}
+
+func hasEntityType(message *Message, types []string) bool {
+	for _, entities := range [][]tgbotapi.MessageEntity{message.Entities, message.CaptionEntities} {
+		for _, entity := range entities {
+			if containsString(types, entity.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// senderHasTrait checks one of senderTraits; lookups that fail count as not
+func (b *Bot) senderHasTrait(ctx context.Context, message *Message, trait string) bool {
+	chatID, userID := message.Chat.ID, message.From.ID
+	switch trait {
+	case "no_username":
+		return message.From.UserName == ""
+	case "forwarded":
+		return message.ForwardDate != 0 || message.ext.ForwardOrigin != nil
+	case "has_strikes":
+		strikes, err := b.app.detector.SpamCount(ctx, chatID, userID)
+		return err == nil && strikes > 0
+	}
+	joined, messages, tracked, err := b.app.db.MemberActivity(ctx, chatID, userID)
+	if err != nil || !tracked {
+		return false
+	}
+	if trait == "first_message" {
+		return messages == 0
+	}
+	return time.Since(joined) < b.newMemberWindow(ctx, chatID)
+}
+
+// yamlRulesStage applies the RULES_YAML rules in order; the first that
+// matches acts, and the message stops there if it was removed
+func (b *Bot) yamlRulesStage(ctx context.Context, mc *messageContext, next func()) {
+	message := mc.message
+	for _, rule := range b.app.Config().YAMLRules {
+		reason, ok := rule.matches(ctx, b, message, mc.text)
+		if !ok {
+			continue
+		}
+		metrics.Add("yaml_rule."+rule.name, 1)
+		if b.applyYAMLRule(ctx, message, rule, reason) {
+			return
+		}
+		break
+	}
+	next()
+}
+
+// applyYAMLRule takes rule's actions on message; it reports whether the
+// message was removed
+func (b *Bot) applyYAMLRule(ctx context.Context, message *Message, rule yamlRule, reason string) bool {
+	removed := true
+	switch {
+	case containsString(rule.actions, "ban"):
+		b.punishMessage(ctx, message, reason, true)
+	case containsString(rule.actions, "warn"):
+		b.punish(ctx, message, reason)
+	case containsString(rule.actions, "delete"):
+		b.deleteMessage(ctx, message, reason)
+	default:
+		removed = false
+	}
+	if containsString(rule.actions, "mute") && !containsString(rule.actions, "ban") {
+		b.muteMember(ctx, message.Chat.ID, message.From.ID, rule.mute, reason)
+	}
+	if containsString(rule.actions, "notify") {
+		b.alertAdmins(ctx, message, b.tr(ctx, message.Chat.ID, "yaml_rule.notify", rule.name, message.From.UserName, message.From.ID, reason))
+	}
+	return removed
+}
+
+// muteMember stops userID from sending messages in chatID for d
+func (b *Bot) muteMember(ctx context.Context, chatID, userID int64, d time.Duration, reason string) {
+	restrict := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig: tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID},
+		UntilDate:        time.Now().Add(d).Unix(),
+		Permissions:      &tgbotapi.ChatPermissions{},
+	}
+	if _, err := b.request(ctx, restrict); err != nil {
+		b.logf("Failed to mute %d in chat %d: %v", userID, chatID, err)
+		b.app.reporter.Failure("telegram.restrictChatMember", err, ErrorContext{Bot: b.api.Self.UserName, ChatID: chatID, UserID: userID})
+		return
+	}
+	b.logf("Muted %d in chat %d for %s (reason: %s)", userID, chatID, d, reason)
+	b.audit(ctx, "mute", chatID, userID, 0, reason)
+}